@@ -1,16 +1,23 @@
 package addrmanager
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	peer2 "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/common"
 	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
 )
 
 type AddrManager struct {
@@ -23,19 +30,37 @@ type AddrManager struct {
 
 	cQuit chan struct{}
 
-	addrIndex map[string]*peer.Peer // address key to KnownAddress for all addrs.
+	addrIndex map[string]*KnownAddress                   // address key to KnownAddress for all addrs.
+	addrNew   [NewBucketCount]map[string]*KnownAddress   // addresses which are not connected yet
+	addrTried [TriedBucketCount]map[string]*KnownAddress // addresses which had a successful connection
+	srcCount  map[string]int                             // number of new addresses by source group
+	banned    map[string]*BannedPeer                     // peer id to ban of misbehaving peers
 }
 
 type serializedKnownAddress struct {
 	Addr      string
 	Src       string
 	PublicKey string
+
+	// quality of address, they are added in version 2
+	SrcGroup    string        `json:",omitempty"`
+	Tried       bool          `json:",omitempty"`
+	Attempts    int           `json:",omitempty"`
+	Successes   int           `json:",omitempty"`
+	Latency     int64         `json:",omitempty"`
+	Role        string        `json:",omitempty"`
+	ShardID     *byte         `json:",omitempty"`
+	Record      *wire.RawPeer `json:",omitempty"`
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastSeen    time.Time
 }
 
 type serializedAddrManager struct {
 	Version   int
 	Key       [32]byte
 	Addresses []*serializedKnownAddress
+	Banned    []*BannedPeer `json:",omitempty"`
 }
 
 func New(dataDir string) *AddrManager {
@@ -51,13 +76,15 @@ func New(dataDir string) *AddrManager {
 // savePeers saves all the known addresses to a file so they can be read back
 // in at next run.
 func (addrManager *AddrManager) savePeers() error {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
 
-	if len(addrManager.addrIndex) == 0 {
+	if len(addrManager.addrIndex) == 0 && len(addrManager.banned) == 0 {
 		return nil
 	}
 
 	sam := new(serializedAddrManager)
-	sam.Version = 1
+	sam.Version = Version
 	copy(sam.Key[:], addrManager.key[:])
 
 	sam.Addresses = make([]*serializedKnownAddress, len(addrManager.addrIndex))
@@ -66,13 +93,29 @@ func (addrManager *AddrManager) savePeers() error {
 	for k, v := range addrManager.addrIndex {
 		ska := new(serializedKnownAddress)
 		ska.Addr = k
-		ska.Src = v.PeerID.Pretty()
-		ska.PublicKey = v.PublicKey
+		ska.Src = v.Peer.PeerID.Pretty()
+		ska.PublicKey = v.Peer.PublicKey
+		ska.SrcGroup = v.SrcGroup
+		ska.Tried = v.tried
+		ska.Attempts = v.Attempts
+		ska.LastAttempt = v.LastAttempt
+		ska.Successes = v.Successes
+		ska.LastSuccess = v.LastSuccess
+		ska.LastSeen = v.LastSeen
+		ska.Latency = int64(v.Latency)
+		ska.Role = v.Role
+		ska.ShardID = v.ShardID
+		ska.Record = v.Record
 
 		sam.Addresses[i] = ska
 		i++
 	}
 
+	addrManager.removeExpiredBans(time.Now())
+	for _, bannedPeer := range addrManager.banned {
+		sam.Banned = append(sam.Banned, bannedPeer)
+	}
+
 	w, err := os.Create(addrManager.peersFile)
 	if err != nil {
 		Logger.log.Errorf("Error opening file %s: %+v", addrManager.peersFile, err)
@@ -90,8 +133,9 @@ func (addrManager *AddrManager) savePeers() error {
 // loadPeers loads the known address from the saved file.  If empty, missing, or
 // malformed file, just don't load anything and start fresh
 func (addrManager *AddrManager) loadPeers() {
-	//addrManager.mtx.Lock()
-	//defer addrManager.mtx.Unlock()
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
 	err := addrManager.deserializePeers(addrManager.peersFile)
 	if err != nil {
 		Logger.log.Errorf("Failed to parse file %s: %+v", addrManager.peersFile, err)
@@ -102,28 +146,45 @@ func (addrManager *AddrManager) loadPeers() {
 		}
 		addrManager.reset()
 	}
-	Logger.log.Infof("Loaded %d addresses from file '%s'", addrManager.numAddresses(), addrManager.peersFile)
+	Logger.log.Infof("Loaded %d addresses (%d tried) from file '%s'", addrManager.numAddresses(), addrManager.numTried(), addrManager.peersFile)
 }
 
 // NumAddresses returns the number of addresses known to the address manager.
 func (addrManager *AddrManager) numAddresses() int {
-	//return a.nTried + a.nNew
 	return len(addrManager.addrIndex)
 }
 
+// numTried returns the number of addresses in tried buckets
+func (addrManager *AddrManager) numTried() int {
+	n := 0
+	for _, bucket := range addrManager.addrTried {
+		n += len(bucket)
+	}
+	return n
+}
+
 // reset resets the address manager by reinitialising the random source
 // and allocating fresh empty bucket storage.
 func (addrManager *AddrManager) reset() {
-	//addrManager.mtx.Lock()
-	//defer addrManager.mtx.Unlock()
+	addrManager.addrIndex = make(map[string]*KnownAddress)
+	for i := range addrManager.addrNew {
+		addrManager.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	for i := range addrManager.addrTried {
+		addrManager.addrTried[i] = make(map[string]*KnownAddress)
+	}
+	addrManager.srcCount = make(map[string]int)
+	addrManager.banned = make(map[string]*BannedPeer)
 
-	addrManager.addrIndex = make(map[string]*peer.Peer)
+	// key of buckets is secret so that peers can not choose addresses which fall into the same bucket
+	if _, err := io.ReadFull(crand.Reader, addrManager.key[:]); err != nil {
+		Logger.log.Errorf("Failed to generate key of address buckets: %+v", err)
+	}
 }
 
+// deserializePeers loads addresses of a file, addresses of version 1 had a successful
+// connection so they are upgraded into tried buckets. Caller must hold the lock
 func (addrManager *AddrManager) deserializePeers(filePath string) error {
-	//addrManager.mtx.Lock()
-	//defer addrManager.mtx.Unlock()
-
 	_, err := os.Stat(filePath)
 	if os.IsNotExist(err) {
 		return nil
@@ -141,23 +202,151 @@ func (addrManager *AddrManager) deserializePeers(filePath string) error {
 		return fmt.Errorf("error reading %s: %+v", filePath, err)
 	}
 
-	if sam.Version != Version {
+	if sam.Version < 1 || sam.Version > Version {
 		return fmt.Errorf("unknown version %+v in serialized addrmanager", sam.Version)
 	}
 	copy(addrManager.key[:], sam.Key[:])
 
+	now := time.Now()
 	for _, v := range sam.Addresses {
 		peer := new(peer.Peer)
 		peer.PeerID = peer2.ID(v.Src)
 		peer.RawAddress = v.Addr
 		peer.PublicKey = v.PublicKey
 
-		addrManager.addrIndex[peer.RawAddress] = peer
-
+		knownAddress := &KnownAddress{
+			Peer:        peer,
+			SrcGroup:    v.SrcGroup,
+			Attempts:    v.Attempts,
+			LastAttempt: v.LastAttempt,
+			Successes:   v.Successes,
+			LastSuccess: v.LastSuccess,
+			LastSeen:    v.LastSeen,
+			Latency:     time.Duration(v.Latency),
+			Role:        v.Role,
+			ShardID:     v.ShardID,
+			Record:      v.Record,
+		}
+		tried := v.Tried
+		if sam.Version == 1 {
+			knownAddress.SrcGroup = GroupKey(common.EmptyString)
+			knownAddress.Successes = 1
+			knownAddress.LastSeen = now
+			tried = true
+		}
+		if tried {
+			addrManager.addTried(knownAddress, now)
+		} else {
+			addrManager.addNew(knownAddress, now)
+		}
+	}
+	for _, bannedPeer := range sam.Banned {
+		addrManager.banned[bannedPeer.PeerID] = bannedPeer
 	}
+	addrManager.removeExpiredBans(now)
 	return nil
 }
 
+// bucketHash returns a number which is derived from secret key and data
+func (addrManager *AddrManager) bucketHash(data ...string) uint64 {
+	hash := sha256.New()
+	hash.Write(addrManager.key[:])
+	for _, item := range data {
+		hash.Write([]byte(item))
+		hash.Write([]byte{0})
+	}
+	return binary.LittleEndian.Uint64(hash.Sum(nil)[:8])
+}
+
+/*
+newBucketIndex - return new bucket of an address, addresses of a source group
+only fall into NewBucketsPerGroup buckets
+*/
+func (addrManager *AddrManager) newBucketIndex(rawAddress string, srcGroup string) int {
+	i := addrManager.bucketHash(GroupKey(rawAddress), srcGroup) % NewBucketsPerGroup
+	return int(addrManager.bucketHash(srcGroup, fmt.Sprint(i)) % NewBucketCount)
+}
+
+/*
+triedBucketIndex - return tried bucket of an address, addresses of a group
+only fall into TriedBucketsPerGroup buckets
+*/
+func (addrManager *AddrManager) triedBucketIndex(rawAddress string) int {
+	i := addrManager.bucketHash(rawAddress) % TriedBucketsPerGroup
+	return int(addrManager.bucketHash(GroupKey(rawAddress), fmt.Sprint(i)) % TriedBucketCount)
+}
+
+// worstAddress returns a bad address of a bucket or the one with lowest chance
+func worstAddress(bucket map[string]*KnownAddress, now time.Time) *KnownAddress {
+	var worst *KnownAddress
+	for _, knownAddress := range bucket {
+		if knownAddress.isBad(now) {
+			return knownAddress
+		}
+		if worst == nil || knownAddress.chance(now) < worst.chance(now) {
+			worst = knownAddress
+		}
+	}
+	return worst
+}
+
+// removeAddress removes an address from index and its bucket, caller must hold the lock
+func (addrManager *AddrManager) removeAddress(knownAddress *KnownAddress) {
+	rawAddress := knownAddress.Peer.RawAddress
+	if knownAddress.tried {
+		delete(addrManager.addrTried[knownAddress.bucket], rawAddress)
+	} else {
+		delete(addrManager.addrNew[knownAddress.bucket], rawAddress)
+		addrManager.srcCount[knownAddress.SrcGroup]--
+		if addrManager.srcCount[knownAddress.SrcGroup] <= 0 {
+			delete(addrManager.srcCount, knownAddress.SrcGroup)
+		}
+	}
+	delete(addrManager.addrIndex, rawAddress)
+}
+
+/*
+addNew - put an address into its new bucket, the worst address of a full bucket is evicted.
+It returns false when source group of address has MaxAddressesPerSource new addresses.
+Caller must hold the lock
+*/
+func (addrManager *AddrManager) addNew(knownAddress *KnownAddress, now time.Time) bool {
+	if addrManager.srcCount[knownAddress.SrcGroup] >= MaxAddressesPerSource {
+		return false
+	}
+	rawAddress := knownAddress.Peer.RawAddress
+	bucketIndex := addrManager.newBucketIndex(rawAddress, knownAddress.SrcGroup)
+	bucket := addrManager.addrNew[bucketIndex]
+	if len(bucket) >= NewBucketSize {
+		addrManager.removeAddress(worstAddress(bucket, now))
+	}
+	knownAddress.tried = false
+	knownAddress.bucket = bucketIndex
+	bucket[rawAddress] = knownAddress
+	addrManager.addrIndex[rawAddress] = knownAddress
+	addrManager.srcCount[knownAddress.SrcGroup]++
+	return true
+}
+
+/*
+addTried - put an address into its tried bucket, the worst address of a full bucket
+is moved back into new buckets. Caller must hold the lock
+*/
+func (addrManager *AddrManager) addTried(knownAddress *KnownAddress, now time.Time) {
+	rawAddress := knownAddress.Peer.RawAddress
+	bucketIndex := addrManager.triedBucketIndex(rawAddress)
+	bucket := addrManager.addrTried[bucketIndex]
+	if len(bucket) >= TriedBucketSize {
+		evicted := worstAddress(bucket, now)
+		addrManager.removeAddress(evicted)
+		addrManager.addNew(evicted, now)
+	}
+	knownAddress.tried = true
+	knownAddress.bucket = bucketIndex
+	bucket[rawAddress] = knownAddress
+	addrManager.addrIndex[rawAddress] = knownAddress
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (addrManager *AddrManager) Start() {
@@ -209,30 +398,179 @@ out:
 	Logger.log.Infof("Address handler done")
 }
 
+/*
+updateRecord - keep a signed record of an address when it is newer than the known one,
+a record of another public key than the known public key of address is ignored.
+Caller must hold the lock
+*/
+func updateRecord(knownAddress *KnownAddress, record *wire.RawPeer) {
+	if record == nil || !record.IsSigned() {
+		return
+	}
+	if knownAddress.Peer.PublicKey != common.EmptyString && knownAddress.Peer.PublicKey != record.PublicKey {
+		return
+	}
+	if knownAddress.Record != nil && knownAddress.Record.Timestamp >= record.Timestamp {
+		return
+	}
+	recordCopy := *record
+	knownAddress.Record = &recordCopy
+	knownAddress.Peer.PublicKey = record.PublicKey
+	knownAddress.Role = record.Role
+	knownAddress.ShardID = record.ShardID
+}
+
+/*
+AddAddress - add an address which is learnt from source into a new bucket,
+source is raw address of the peer which sent it, empty for this node.
+Record is the verified signed record of address, it may be nil.
+An address of a banned peer or a source group which has too many addresses is ignored
+*/
+func (addrManager *AddrManager) AddAddress(addr *peer.Peer, record *wire.RawPeer, source string) {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	now := time.Now()
+	if knownAddress, ok := addrManager.addrIndex[addr.RawAddress]; ok {
+		knownAddress.LastSeen = now
+		if knownAddress.Peer.PublicKey == common.EmptyString {
+			knownAddress.Peer.PublicKey = addr.PublicKey
+		}
+		updateRecord(knownAddress, record)
+		return
+	}
+	if addrManager.isBanned(addr.PeerID.Pretty(), addr.PublicKey, now) {
+		return
+	}
+	knownAddress := &KnownAddress{
+		Peer: &peer.Peer{
+			PeerID:     addr.PeerID,
+			RawAddress: addr.RawAddress,
+			PublicKey:  addr.PublicKey,
+		},
+		SrcGroup: GroupKey(source),
+		LastSeen: now,
+	}
+	updateRecord(knownAddress, record)
+	if !addrManager.addNew(knownAddress, now) {
+		Logger.log.Debugf("Ignore address %s, source group %s has too many addresses", addr.RawAddress, knownAddress.SrcGroup)
+	}
+}
+
+// Attempt records a connection attempt of an address, an unknown address is ignored
+func (addrManager *AddrManager) Attempt(rawAddress string) {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	knownAddress, ok := addrManager.addrIndex[rawAddress]
+	if !ok {
+		return
+	}
+	knownAddress.Attempts++
+	knownAddress.LastAttempt = time.Now()
+}
+
 // Good marks the given address as good.  To be called after a successful
-// connection and version exchange.  If the address is unknown to the address
-// manager it will be ignored.
-func (addrManager *AddrManager) Good(addr *peer.Peer) {
+// connection and version exchange.  The address is moved into a tried bucket
+// with handshake latency and the role and shard of its public key.
+func (addrManager *AddrManager) Good(addr *peer.Peer, latency time.Duration, role string, shardID *byte) {
 	addrManager.mtx.Lock()
 	defer addrManager.mtx.Unlock()
 
-	addrManager.addrIndex[addr.RawAddress] = addr
+	now := time.Now()
+	knownAddress, ok := addrManager.addrIndex[addr.RawAddress]
+	if !ok {
+		knownAddress = &KnownAddress{
+			Peer:     &peer.Peer{RawAddress: addr.RawAddress},
+			SrcGroup: GroupKey(common.EmptyString),
+		}
+	}
+	knownAddress.Peer.PeerID = addr.PeerID
+	knownAddress.Peer.PublicKey = addr.PublicKey
+	knownAddress.Attempts = 0
+	knownAddress.Successes++
+	knownAddress.LastSuccess = now
+	knownAddress.LastSeen = now
+	knownAddress.Role = role
+	knownAddress.ShardID = shardID
+	if latency > 0 {
+		if knownAddress.Latency > 0 {
+			knownAddress.Latency = (knownAddress.Latency*3 + latency) / 4
+		} else {
+			knownAddress.Latency = latency
+		}
+	}
+	if knownAddress.tried {
+		return
+	}
+	if ok {
+		addrManager.removeAddress(knownAddress)
+	}
+	addrManager.addTried(knownAddress, now)
 }
 
-// AddressCache returns the current address cache.  It must be treated as
-// read-only (but since it is a copy now, this is not as dangerous).
+/*
+goodAddresses returns addresses which are not bad, tried addresses and
+addresses with higher chance come first. Caller must hold the lock
+*/
+func (addrManager *AddrManager) goodAddresses(now time.Time) []*KnownAddress {
+	knownAddresses := make([]*KnownAddress, 0, len(addrManager.addrIndex))
+	for _, v := range addrManager.addrIndex {
+		if !v.isBad(now) {
+			knownAddresses = append(knownAddresses, v)
+		}
+	}
+	sort.SliceStable(knownAddresses, func(i, j int) bool {
+		if knownAddresses[i].tried != knownAddresses[j].tried {
+			return knownAddresses[i].tried
+		}
+		return knownAddresses[i].chance(now) > knownAddresses[j].chance(now)
+	})
+	return knownAddresses
+}
+
+/*
+AddressCache returns addresses which are not bad, tried addresses and
+addresses with higher chance come first so that good peers are preferred
+*/
 func (addrManager *AddrManager) AddressCache() []*peer.Peer {
 	addrManager.mtx.Lock()
 	defer addrManager.mtx.Unlock()
 
-	addrIndexLen := len(addrManager.addrIndex)
-	if addrIndexLen == 0 {
+	if len(addrManager.addrIndex) == 0 {
 		return nil
 	}
-	allAddr := make([]*peer.Peer, 0, addrIndexLen)
-	// Iteration order is undefined here, but we randomise it anyway.
-	for _, v := range addrManager.addrIndex {
-		allAddr = append(allAddr, v)
+	knownAddresses := addrManager.goodAddresses(time.Now())
+	allAddr := make([]*peer.Peer, 0, len(knownAddresses))
+	for _, v := range knownAddresses {
+		allAddr = append(allAddr, v.Peer)
 	}
 	return allAddr
 }
+
+/*
+KnownRecords returns at most max records of good addresses which are shared with peers,
+signed records are relayed as they are received so that peers can verify them,
+an address whose record is expired is shared without record
+*/
+func (addrManager *AddrManager) KnownRecords(max int) []wire.RawPeer {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	now := time.Now()
+	records := []wire.RawPeer{}
+	for _, knownAddress := range addrManager.goodAddresses(now) {
+		if len(records) >= max {
+			break
+		}
+		if knownAddress.Record != nil && knownAddress.Record.CheckRecordTime(now) == nil {
+			records = append(records, *knownAddress.Record)
+			continue
+		}
+		records = append(records, wire.RawPeer{
+			RawAddress: knownAddress.Peer.RawAddress,
+			PublicKey:  knownAddress.Peer.PublicKey,
+		})
+	}
+	return records
+}
@@ -0,0 +1,244 @@
+package addrmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+)
+
+func newTestAddrManager(t *testing.T) (*AddrManager, string) {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("AddrManager test", true))
+	dataDir, err := ioutil.TempDir("", "addrmanager")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return New(dataDir), dataDir
+}
+
+func testRawAddress(a, b, c, d int) string {
+	return fmt.Sprintf("/ip4/%d.%d.%d.%d/tcp/9333", a, b, c, d)
+}
+
+func newTestKnownAddress(rawAddress string, srcGroup string, now time.Time) *KnownAddress {
+	return &KnownAddress{
+		Peer:     &peer.Peer{RawAddress: rawAddress},
+		SrcGroup: srcGroup,
+		LastSeen: now,
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	tests := map[string]string{
+		testRawAddress(1, 2, 3, 4):     "ip4:1.2",
+		testRawAddress(1, 2, 200, 100): "ip4:1.2",
+		testRawAddress(1, 3, 3, 4):     "ip4:1.3",
+		testRawAddress(127, 0, 0, 1):   "local",
+		"/ip6/2001:db8::1/tcp/9333":    "ip6:20010db8",
+		"":                             "local",
+		"not an address":               "unknown",
+	}
+	for rawAddress, expected := range tests {
+		if group := GroupKey(rawAddress); group != expected {
+			t.Errorf("group of %s: expected %s, got %s", rawAddress, expected, group)
+		}
+	}
+}
+
+func TestBucketPlacementByGroup(t *testing.T) {
+	addrManager, dataDir := newTestAddrManager(t)
+	defer os.RemoveAll(dataDir)
+
+	// addresses from one source group only fall into a few new buckets
+	srcGroup := GroupKey(testRawAddress(10, 0, 0, 1))
+	newBuckets := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		newBuckets[addrManager.newBucketIndex(testRawAddress(i/250+1, i%250, 1, 1), srcGroup)] = true
+	}
+	if len(newBuckets) > NewBucketsPerGroup {
+		t.Errorf("expected at most %d new buckets of a source group, got %d", NewBucketsPerGroup, len(newBuckets))
+	}
+
+	// addresses of one /16 group only fall into a few tried buckets
+	triedBuckets := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		triedBuckets[addrManager.triedBucketIndex(testRawAddress(20, 30, i/250, i%250))] = true
+	}
+	if len(triedBuckets) > TriedBucketsPerGroup {
+		t.Errorf("expected at most %d tried buckets of a group, got %d", TriedBucketsPerGroup, len(triedBuckets))
+	}
+
+	// a source group can not fill address book
+	source := testRawAddress(10, 0, 0, 1)
+	for i := 0; i < MaxAddressesPerSource+10; i++ {
+		addrManager.AddAddress(&peer.Peer{RawAddress: testRawAddress(i/250+1, i%250, 2, 2)}, nil, source)
+	}
+	if addrManager.numAddresses() != MaxAddressesPerSource {
+		t.Errorf("expected %d addresses of a source, got %d", MaxAddressesPerSource, addrManager.numAddresses())
+	}
+	// another address of the same /16 as source is counted in the same source group
+	addrManager.AddAddress(&peer.Peer{RawAddress: testRawAddress(100, 0, 0, 1)}, nil, testRawAddress(10, 0, 9, 9))
+	if addrManager.numAddresses() != MaxAddressesPerSource {
+		t.Errorf("expected source of the same group to be limited")
+	}
+	addrManager.AddAddress(&peer.Peer{RawAddress: testRawAddress(100, 0, 0, 1)}, nil, testRawAddress(10, 1, 0, 1))
+	if addrManager.numAddresses() != MaxAddressesPerSource+1 {
+		t.Errorf("expected address of another source group to be added")
+	}
+}
+
+// addressesOfNewBucket returns n addresses from different source groups which fall into a new bucket
+func addressesOfNewBucket(addrManager *AddrManager, bucketIndex int, n int) (addresses []string, srcGroups []string) {
+	for i := 0; len(addresses) < n; i++ {
+		rawAddress := testRawAddress(i/62500+1, (i/250)%250, i%250, 1)
+		srcGroup := fmt.Sprintf("ip4:%d.%d", i/250+1, i%250)
+		if addrManager.newBucketIndex(rawAddress, srcGroup) == bucketIndex {
+			addresses = append(addresses, rawAddress)
+			srcGroups = append(srcGroups, srcGroup)
+		}
+	}
+	return addresses, srcGroups
+}
+
+func TestNewBucketEviction(t *testing.T) {
+	addrManager, dataDir := newTestAddrManager(t)
+	defer os.RemoveAll(dataDir)
+	now := time.Now()
+
+	addresses, srcGroups := addressesOfNewBucket(addrManager, 0, NewBucketSize+1)
+	// the first address failed all its attempts so it is the one which is evicted
+	bad := newTestKnownAddress(addresses[0], srcGroups[0], now)
+	bad.Attempts = numRetries
+	bad.LastAttempt = now.Add(-time.Hour)
+	addrManager.addNew(bad, now)
+	for i := 1; i < NewBucketSize; i++ {
+		addrManager.addNew(newTestKnownAddress(addresses[i], srcGroups[i], now), now)
+	}
+	if len(addrManager.addrNew[0]) != NewBucketSize {
+		t.Fatalf("expected full bucket, got %d", len(addrManager.addrNew[0]))
+	}
+
+	addrManager.addNew(newTestKnownAddress(addresses[NewBucketSize], srcGroups[NewBucketSize], now), now)
+	if len(addrManager.addrNew[0]) != NewBucketSize || addrManager.numAddresses() != NewBucketSize {
+		t.Errorf("expected bucket to stay full, got %d", len(addrManager.addrNew[0]))
+	}
+	if _, ok := addrManager.addrIndex[addresses[0]]; ok {
+		t.Errorf("expected bad address to be evicted")
+	}
+	if _, ok := addrManager.srcCount[srcGroups[0]]; ok {
+		t.Errorf("expected source count of evicted address to be removed")
+	}
+	if _, ok := addrManager.addrNew[0][addresses[NewBucketSize]]; !ok {
+		t.Errorf("expected new address in bucket")
+	}
+}
+
+func TestTriedBucketEviction(t *testing.T) {
+	addrManager, dataDir := newTestAddrManager(t)
+	defer os.RemoveAll(dataDir)
+	now := time.Now()
+
+	addresses := []string{}
+	for i := 0; len(addresses) < TriedBucketSize+1; i++ {
+		rawAddress := testRawAddress(i/62500+1, (i/250)%250, i%250, 1)
+		if addrManager.triedBucketIndex(rawAddress) == 0 {
+			addresses = append(addresses, rawAddress)
+		}
+	}
+	for i, rawAddress := range addresses[:TriedBucketSize] {
+		knownAddress := newTestKnownAddress(rawAddress, fmt.Sprintf("src%d", i), now)
+		knownAddress.Successes = 1
+		knownAddress.LastSuccess = now
+		// the first address has the lowest chance
+		if i == 0 {
+			knownAddress.Attempts = 5
+		}
+		addrManager.addTried(knownAddress, now)
+	}
+	addrManager.addTried(newTestKnownAddress(addresses[TriedBucketSize], "src", now), now)
+
+	if len(addrManager.addrTried[0]) != TriedBucketSize || addrManager.numTried() != TriedBucketSize {
+		t.Errorf("expected tried bucket to stay full, got %d", len(addrManager.addrTried[0]))
+	}
+	evicted, ok := addrManager.addrIndex[addresses[0]]
+	if !ok || evicted.tried {
+		t.Fatalf("expected evicted address to move back into new buckets")
+	}
+	if _, ok := addrManager.addrNew[evicted.bucket][addresses[0]]; !ok {
+		t.Errorf("expected evicted address in its new bucket")
+	}
+	if addrManager.numAddresses() != TriedBucketSize+1 {
+		t.Errorf("expected no address to be lost, got %d", addrManager.numAddresses())
+	}
+}
+
+func TestUpgradePeersFile(t *testing.T) {
+	addrManager, dataDir := newTestAddrManager(t)
+	defer os.RemoveAll(dataDir)
+
+	// version 1 only has addresses which had a successful connection
+	v1 := map[string]interface{}{
+		"Version": 1,
+		"Addresses": []map[string]string{
+			{"Addr": testRawAddress(1, 2, 3, 4), "Src": "peer1", "PublicKey": "key1"},
+			{"Addr": testRawAddress(5, 6, 7, 8), "Src": "peer2", "PublicKey": "key2"},
+		},
+	}
+	data, err := json.Marshal(v1)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "peer.json"), data, 0644); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	addrManager.loadPeers()
+	if addrManager.numAddresses() != 2 || addrManager.numTried() != 2 {
+		t.Fatalf("expected 2 tried addresses, got %d addresses %d tried", addrManager.numAddresses(), addrManager.numTried())
+	}
+	knownAddress := addrManager.addrIndex[testRawAddress(1, 2, 3, 4)]
+	if knownAddress.Successes != 1 || knownAddress.Peer.PublicKey != "key1" || knownAddress.SrcGroup != GroupKey(common.EmptyString) {
+		t.Errorf("unexpected upgraded address %+v", knownAddress)
+	}
+
+	// file is saved in current version and loaded back with quality of addresses
+	addrManager.AddAddress(&peer.Peer{RawAddress: testRawAddress(9, 9, 9, 9)}, nil, testRawAddress(1, 2, 3, 4))
+	addrManager.Attempt(testRawAddress(9, 9, 9, 9))
+	if err := addrManager.savePeers(); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	loaded := New(dataDir)
+	loaded.loadPeers()
+	if loaded.numAddresses() != 3 || loaded.numTried() != 2 {
+		t.Fatalf("expected 3 addresses and 2 tried, got %d %d", loaded.numAddresses(), loaded.numTried())
+	}
+	newAddress := loaded.addrIndex[testRawAddress(9, 9, 9, 9)]
+	if newAddress.tried || newAddress.Attempts != 1 || newAddress.SrcGroup != "ip4:1.2" {
+		t.Errorf("unexpected loaded address %+v", newAddress)
+	}
+	if loaded.key != addrManager.key {
+		t.Errorf("expected bucket key to be kept")
+	}
+}
+
+func TestLoadInvalidPeersFile(t *testing.T) {
+	addrManager, dataDir := newTestAddrManager(t)
+	defer os.RemoveAll(dataDir)
+	peersFile := filepath.Join(dataDir, "peer.json")
+	if err := ioutil.WriteFile(peersFile, []byte(`{"Version": 3}`), 0644); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	addrManager.loadPeers()
+	if addrManager.numAddresses() != 0 {
+		t.Errorf("expected empty address book")
+	}
+	if _, err := os.Stat(peersFile); !os.IsNotExist(err) {
+		t.Errorf("expected unknown version file to be removed")
+	}
+}
@@ -0,0 +1,104 @@
+package addrmanager
+
+import (
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+)
+
+// BannedPeer is a misbehaving peer which is not connected until its ban expires
+type BannedPeer struct {
+	PeerID     string
+	PublicKey  string
+	RawAddress string
+	Reason     string
+	Since      time.Time
+	Until      time.Time
+}
+
+// removeExpiredBans removes bans which expired before now, caller must hold the lock
+func (addrManager *AddrManager) removeExpiredBans(now time.Time) {
+	for peerID, bannedPeer := range addrManager.banned {
+		if now.After(bannedPeer.Until) {
+			delete(addrManager.banned, peerID)
+		}
+	}
+}
+
+/*
+Ban - ban a peer for duration, known addresses of the peer are forgotten
+so that it is not connected again at next run
+*/
+func (addrManager *AddrManager) Ban(peerID string, publicKey string, rawAddress string, duration time.Duration, reason string) {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	now := time.Now()
+	addrManager.banned[peerID] = &BannedPeer{
+		PeerID:     peerID,
+		PublicKey:  publicKey,
+		RawAddress: rawAddress,
+		Reason:     reason,
+		Since:      now,
+		Until:      now.Add(duration),
+	}
+	for _, knownAddress := range addrManager.addrIndex {
+		knownPeer := knownAddress.Peer
+		// peer id of an address loaded from file is kept in its encoded form
+		if knownPeer.PeerID.Pretty() == peerID || string(knownPeer.PeerID) == peerID || (publicKey != common.EmptyString && knownPeer.PublicKey == publicKey) {
+			addrManager.removeAddress(knownAddress)
+		}
+	}
+}
+
+// Unban removes ban of a peer, it returns false when peer is not banned
+func (addrManager *AddrManager) Unban(peerID string) bool {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	_, ok := addrManager.banned[peerID]
+	delete(addrManager.banned, peerID)
+	return ok
+}
+
+// ClearBans removes all bans
+func (addrManager *AddrManager) ClearBans() {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	addrManager.banned = make(map[string]*BannedPeer)
+}
+
+// IsBanned returns whether a peer id or a public key is banned
+func (addrManager *AddrManager) IsBanned(peerID string, publicKey string) bool {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	return addrManager.isBanned(peerID, publicKey, time.Now())
+}
+
+// isBanned returns whether a peer id or a public key is banned at now, caller must hold the lock
+func (addrManager *AddrManager) isBanned(peerID string, publicKey string, now time.Time) bool {
+	for _, bannedPeer := range addrManager.banned {
+		if now.After(bannedPeer.Until) {
+			continue
+		}
+		if bannedPeer.PeerID == peerID || (publicKey != common.EmptyString && bannedPeer.PublicKey == publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// BannedPeers returns a copy of bans which have not expired
+func (addrManager *AddrManager) BannedPeers() []BannedPeer {
+	addrManager.mtx.Lock()
+	defer addrManager.mtx.Unlock()
+
+	addrManager.removeExpiredBans(time.Now())
+	result := make([]BannedPeer, 0, len(addrManager.banned))
+	for _, bannedPeer := range addrManager.banned {
+		result = append(result, *bannedPeer)
+	}
+	return result
+}
@@ -3,9 +3,44 @@ package addrmanager
 import "time"
 
 const (
-	Version = 1
+	// Version of peer.json, version 1 is a flat list of addresses which had a successful connection
+	Version = 2
 
 	// DumpAddressInterval is the interval used to dump the address
 	// cache to disk for future use.
 	DumpAddressInterval = time.Second * 10
 )
+
+// buckets of address book
+const (
+	// NewBucketCount is the number of buckets of addresses which are not connected yet
+	NewBucketCount = 64
+	// NewBucketSize is the max number of addresses in a new bucket
+	NewBucketSize = 64
+	// NewBucketsPerGroup is the number of new buckets which addresses of a source group are spread over
+	NewBucketsPerGroup = 8
+	// TriedBucketCount is the number of buckets of addresses which had a successful connection
+	TriedBucketCount = 16
+	// TriedBucketSize is the max number of addresses in a tried bucket
+	TriedBucketSize = 64
+	// TriedBucketsPerGroup is the number of tried buckets which addresses of a group are spread over
+	TriedBucketsPerGroup = 4
+	// MaxAddressesPerSource is the max number of new addresses which are learnt from a source group,
+	// so that peers of a network can not fill address book to eclipse this node
+	MaxAddressesPerSource = 64
+)
+
+// quality of addresses
+const (
+	// an address is not bad when it is attempted in this duration
+	recentAttempt = time.Minute
+	// an address which is attempted in this duration has lower chance to be chosen again
+	retryDelay = 10 * time.Minute
+	// an address which is never connected is bad after this number of attempts
+	numRetries = 3
+	// an address which is not connected for minBadDuration is bad after this number of attempts
+	maxFailures    = 10
+	minBadDuration = 7 * 24 * time.Hour
+	// an address which is not seen for this duration is bad
+	numMissingDuration = 30 * 24 * time.Hour
+)
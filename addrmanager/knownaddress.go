@@ -0,0 +1,101 @@
+package addrmanager
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+// KnownAddress tracks quality of an address in address book
+type KnownAddress struct {
+	Peer        *peer.Peer
+	SrcGroup    string // group of the peer which this address is learnt from
+	Attempts    int    // connection attempts since last success
+	LastAttempt time.Time
+	Successes   int
+	LastSuccess time.Time
+	LastSeen    time.Time
+	Latency     time.Duration // handshake latency, smoothed over successful connections
+	Role        string        // last role of public key of address, empty when it is not in a committee
+	ShardID     *byte         // last shard of public key of address
+	Record      *wire.RawPeer // latest signed record of address, nil when it is not known
+	tried       bool
+	bucket      int
+}
+
+// isBad returns whether an address should not be connected or shared with peers
+func (knownAddress *KnownAddress) isBad(now time.Time) bool {
+	if now.Sub(knownAddress.LastAttempt) < recentAttempt {
+		return false
+	}
+	if now.Sub(knownAddress.LastSeen) > numMissingDuration {
+		return true
+	}
+	if knownAddress.LastSuccess.IsZero() && knownAddress.Attempts >= numRetries {
+		return true
+	}
+	if now.Sub(knownAddress.LastSuccess) > minBadDuration && knownAddress.Attempts >= maxFailures {
+		return true
+	}
+	return false
+}
+
+/*
+chance - return relative chance that an address is chosen for a connection,
+it is lower for addresses which are attempted recently, failed many times or have high latency
+*/
+func (knownAddress *KnownAddress) chance(now time.Time) float64 {
+	c := 1.0
+	if now.Sub(knownAddress.LastAttempt) < retryDelay {
+		c *= 0.01
+	}
+	c *= math.Pow(0.66, math.Min(float64(knownAddress.Attempts), 8))
+	if knownAddress.Latency > 0 {
+		c /= 1 + knownAddress.Latency.Seconds()
+	}
+	if knownAddress.tried {
+		c *= 2
+	}
+	return c
+}
+
+/*
+GroupKey - return network group of a raw address: /16 of an ipv4 address or /32 of an ipv6 address,
+empty raw address is this node itself
+*/
+func GroupKey(rawAddress string) string {
+	if rawAddress == common.EmptyString {
+		return "local"
+	}
+	addr, err := ma.NewMultiaddr(rawAddress)
+	if err != nil {
+		return "unknown"
+	}
+	if value, err := addr.ValueForProtocol(ma.P_IP4); err == nil {
+		ip := net.ParseIP(value).To4()
+		if ip == nil {
+			return "unknown"
+		}
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return "local"
+		}
+		return fmt.Sprintf("ip4:%d.%d", ip[0], ip[1])
+	}
+	if value, err := addr.ValueForProtocol(ma.P_IP6); err == nil {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "unknown"
+		}
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return "local"
+		}
+		return fmt.Sprintf("ip6:%x", []byte(ip[:4]))
+	}
+	return "unknown"
+}
@@ -117,11 +117,18 @@ func GetBestStateBeacon() *BestStateBeacon {
 	return bestStateBeacon
 }
 
+/*
+InitBestStateBeacon - create best state of beacon chain at genesis and make it the singleton object,
+a new object is created each time so that chains of several nodes in one process do not share it
+*/
 func InitBestStateBeacon(netparam *Params) *BestStateBeacon {
-	if bestStateBeacon == nil {
-		bestStateBeacon = GetBestStateBeacon()
-	}
+	bestStateBeacon = NewBestStateBeacon(netparam)
+	return bestStateBeacon
+}
 
+// NewBestStateBeacon creates best state of beacon chain at genesis
+func NewBestStateBeacon(netparam *Params) *BestStateBeacon {
+	bestStateBeacon := &BestStateBeacon{}
 	bestStateBeacon.BestBlockHash.SetBytes(make([]byte, 32))
 	bestStateBeacon.BestBlock = nil
 	bestStateBeacon.BestShardHash = make(map[byte]common.Hash)
@@ -10,9 +10,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ninjadotorg/constant/cashec"
 	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metrics"
 )
 
 /*
@@ -33,6 +35,7 @@ import (
 func (blockchain *BlockChain) VerifyPreSignBeaconBlock(block *BeaconBlock, isCommittee bool) error {
 	blockchain.chainLock.Lock()
 	defer blockchain.chainLock.Unlock()
+	defer metrics.ObserveSince(metrics.BlockVerifyDuration.WithLabelValues(metrics.BeaconChain, metrics.VerifyPreSign), time.Now())
 	//========Verify block only
 	Logger.log.Infof("Verify block for signing process %d, with hash %+v", block.Header.Height, *block.Hash())
 	if err := blockchain.VerifyPreProcessingBeaconBlock(block, isCommittee); err != nil {
@@ -76,6 +79,7 @@ func (blockchain *BlockChain) VerifyPreSignBeaconBlock(block *BeaconBlock, isCom
 func (blockchain *BlockChain) InsertBeaconBlock(block *BeaconBlock, isCommittee bool) error {
 	blockchain.chainLock.Lock()
 	defer blockchain.chainLock.Unlock()
+	defer metrics.ObserveSince(metrics.BlockInsertDuration.WithLabelValues(metrics.BeaconChain), time.Now())
 	Logger.log.Infof("Check block existence for insert process %d, with hash %+v", block.Header.Height, *block.Hash())
 	isExist, _ := blockchain.config.DataBase.HasBeaconBlock(block.Hash())
 	if isExist {
@@ -102,6 +106,9 @@ func (blockchain *BlockChain) InsertBeaconBlock(block *BeaconBlock, isCommittee
 	Logger.log.Infof("Update BestState with Beacon Block %+v \n", *block.Hash())
 	//========Update best state with new block
 	snapShotBeaconCommittee := blockchain.BestState.Beacon.BeaconCommittee
+	// committees before update are copied since swaps may change them in place
+	oldBeaconCommittee := append([]string{}, blockchain.BestState.Beacon.BeaconCommittee...)
+	oldShardCommittee := copyShardCommittee(blockchain.BestState.Beacon.ShardCommittee)
 	if err := blockchain.BestState.Beacon.Update(block); err != nil {
 		return err
 	}
@@ -147,7 +154,7 @@ func (blockchain *BlockChain) InsertBeaconBlock(block *BeaconBlock, isCommittee
 	}
 	fmt.Println("Beacon Process/Shard Committee in Epoch ", block.Header.Epoch, shardCommittee)
 	//=========Store cross shard state ==================================
-	lastCrossShardState := GetBestStateBeacon().LastCrossShardState
+	lastCrossShardState := blockchain.BestState.Beacon.LastCrossShardState
 	if block.Body.ShardState != nil {
 		for fromShard, shardBlocks := range block.Body.ShardState {
 			for _, shardBlock := range shardBlocks {
@@ -192,9 +199,40 @@ func (blockchain *BlockChain) InsertBeaconBlock(block *BeaconBlock, isCommittee
 	blockchain.config.ShardToBeaconPool.SetShardState(blockchain.BestState.Beacon.BestShardHeight)
 
 	Logger.log.Infof("Finish Insert new block %d, with hash %+v", block.Header.Height, *block.Hash())
+	if blockchain.config.OnBeaconBlockInserted != nil {
+		blockchain.config.OnBeaconBlockInserted(block)
+	}
+	if blockchain.config.OnCommitteeChanged != nil && isCommitteeChanged(oldBeaconCommittee, blockchain.BestState.Beacon.BeaconCommittee, oldShardCommittee, blockchain.BestState.Beacon.ShardCommittee) {
+		blockchain.config.OnCommitteeChanged(blockchain.BestState.Beacon.Epoch, append([]string{}, blockchain.BestState.Beacon.BeaconCommittee...), copyShardCommittee(blockchain.BestState.Beacon.ShardCommittee))
+	}
 	return nil
 }
 
+// copyShardCommittee returns a copy of shard committees which is not changed by updates of best state
+func copyShardCommittee(shardCommittee map[byte][]string) map[byte][]string {
+	res := make(map[byte][]string, len(shardCommittee))
+	for shardID, committee := range shardCommittee {
+		res[shardID] = append([]string{}, committee...)
+	}
+	return res
+}
+
+// isCommitteeChanged returns whether members or order of beacon committee or any shard committee changed
+func isCommitteeChanged(oldBeaconCommittee []string, newBeaconCommittee []string, oldShardCommittee map[byte][]string, newShardCommittee map[byte][]string) bool {
+	if !common.CompareStringArray(oldBeaconCommittee, newBeaconCommittee) {
+		return true
+	}
+	if len(oldShardCommittee) != len(newShardCommittee) {
+		return true
+	}
+	for shardID, committee := range newShardCommittee {
+		if !common.CompareStringArray(oldShardCommittee[shardID], committee) {
+			return true
+		}
+	}
+	return false
+}
+
 /* Verify Pre-prosessing data
 This function DOES NOT verify new block with best state
 DO NOT USE THIS with GENESIS BLOCK
@@ -211,6 +249,7 @@ FOR CURRENT COMMITTEES ONLY
 	- Is shardState existed in pool
 */
 func (blockchain *BlockChain) VerifyPreProcessingBeaconBlock(block *BeaconBlock, isCommittee bool) error {
+	defer metrics.ObserveSince(metrics.BlockVerifyDuration.WithLabelValues(metrics.BeaconChain, metrics.VerifyPreProcessing), time.Now())
 	//verify producer sig
 	blkHash := block.Header.Hash()
 	err := cashec.ValidateDataB58(block.Header.Producer, block.ProducerSig, blkHash.GetBytes())
@@ -101,6 +101,15 @@ type Config struct {
 	ChainParams *Params
 	RelayShards []byte
 	NodeMode    string
+	// Clock is the source of time of block timestamps and sync timers, system time is used when it is nil
+	Clock common.Clock
+
+	// OnBeaconBlockInserted and OnShardBlockInserted are called after a block is stored and best state is updated,
+	// OnCommitteeChanged is called when a beacon block changes beacon or shard committees.
+	// Node uses them to notify RPC subscribers, they are called with chain locked and can be nil.
+	OnBeaconBlockInserted func(block *BeaconBlock)
+	OnShardBlockInserted  func(block *ShardBlock)
+	OnCommitteeChanged    func(epoch uint64, beaconCommittee []string, shardCommittee map[byte][]string)
 	//Light mode flag
 	// Light bool
 	//Wallet for light mode
@@ -147,6 +156,9 @@ func (blockchain *BlockChain) Init(config *Config) error {
 	}
 
 	blockchain.config = *config
+	if blockchain.config.Clock == nil {
+		blockchain.config.Clock = common.NewRealClock()
+	}
 
 	// Initialize the chain state from the passed database.  When the db
 	// does not yet contain any chain state, both it and the chain state
@@ -571,6 +583,22 @@ func (blockchain *BlockChain) GetShardBlockByHash(hash *common.Hash) (*ShardBloc
 	return &block, nil
 }
 
+/*
+Fetch DatabaseInterface and get shard committees of an epoch, they are stored by beacon at first block of epoch
+*/
+func (blockchain *BlockChain) GetShardCommitteeByEpoch(epoch uint64) (map[byte][]string, error) {
+	committeeBytes, err := blockchain.config.DataBase.FetchCommitteeByEpoch(epoch)
+	if err != nil {
+		return nil, err
+	}
+	shardCommittee := make(map[byte][]string)
+	err = json.Unmarshal(committeeBytes, &shardCommittee)
+	if err != nil {
+		return nil, err
+	}
+	return shardCommittee, nil
+}
+
 /*
 Store best state of block(best block, num of tx, ...) into Database
 */
@@ -917,6 +945,10 @@ func (blockchain *BlockChain) CreateAndSaveCrossOutputCoinViewPointFromBlock(blo
 		return err
 	}
 
+	// txs waiting for these cross output coins can be accepted into pool now
+	if len(block.Body.CrossOutputCoin) > 0 && blockchain.config.TxPool != nil {
+		go blockchain.config.TxPool.ProcessOrphans()
+	}
 	return nil
 }
 func (blockchain *BlockChain) CreateAndSaveCrossTxTokenDataViewPointFromBlock(block *ShardBlock) error {
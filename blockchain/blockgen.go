@@ -7,6 +7,9 @@ type BlkTmplGenerator struct {
 	crossShardPool    map[byte]CrossShardPool
 	chain             *BlockChain
 	rewardAgent       RewardAgent
+
+	// limits number of txs of a metadata type in a block, set by mempool policy
+	maxTxsPerMetadataType map[int]int
 }
 
 func (blkTmplGenerator BlkTmplGenerator) Init(txPool TxPool, chain *BlockChain, rewardAgent RewardAgent, shardToBeaconPool ShardToBeaconPool, crossShardPool map[byte]CrossShardPool) (*BlkTmplGenerator, error) {
@@ -18,3 +21,23 @@ func (blkTmplGenerator BlkTmplGenerator) Init(txPool TxPool, chain *BlockChain,
 		rewardAgent:       rewardAgent,
 	}, nil
 }
+
+/*
+GetBlockTemplate - return txs which would be selected from mempool for the next shard block,
+txs are not validated with chain like when producing block
+*/
+func (blkTmplGenerator *BlkTmplGenerator) GetBlockTemplate() *BlockTemplate {
+	return SelectTransactions(blkTmplGenerator.txPool.MiningDescs(), blkTmplGenerator.TxSelectionPolicy())
+}
+
+// SetMaxTxsPerMetadataType sets limit of number of txs with a metadata type in a block
+func (blkTmplGenerator *BlkTmplGenerator) SetMaxTxsPerMetadataType(limits map[int]int) {
+	blkTmplGenerator.maxTxsPerMetadataType = limits
+}
+
+// TxSelectionPolicy returns the policy which is used to select txs for a new shard block
+func (blkTmplGenerator *BlkTmplGenerator) TxSelectionPolicy() TxSelectionPolicy {
+	policy := DefaultTxSelectionPolicy()
+	policy.MaxTxsPerMetadataType = blkTmplGenerator.maxTxsPerMetadataType
+	return policy
+}
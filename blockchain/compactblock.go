@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+)
+
+// short id of a transaction is 6 bytes, like BIP152
+const shortTxIDMask = uint64(0xffffffffffff)
+
+/*
+CompactShardBlock - a shard block which replaces transactions with short ids,
+receiver rebuilds the block from transactions in its mempool.
+Body.Transactions of Block only holds prefilled transactions which receiver is unlikely to have
+*/
+type CompactShardBlock struct {
+	Block            ShardBlock
+	PrefilledIndexes []int    // index in block of each prefilled transaction
+	ShortTxIDs       []uint64 // short ids of other transactions in block order
+	Nonce            uint64
+}
+
+/*
+ShortTxID - short id of a transaction in a compact block,
+it is keyed by block hash and nonce so that collisions differ from block to block
+*/
+func ShortTxID(blockHash common.Hash, nonce uint64, txHash common.Hash) uint64 {
+	data := make([]byte, 0, common.HashSize*2+8)
+	data = append(data, blockHash[:]...)
+	nonceBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nonceBytes, nonce)
+	data = append(data, nonceBytes...)
+	data = append(data, txHash[:]...)
+	hash := common.HashH(data)
+	return binary.LittleEndian.Uint64(hash[:8]) & shortTxIDMask
+}
+
+/*
+NewCompactShardBlock - make compact block of a shard block,
+salary transactions are made by producer so they are always prefilled
+*/
+func NewCompactShardBlock(block *ShardBlock, nonce uint64) *CompactShardBlock {
+	compact := &CompactShardBlock{
+		Block:            *block,
+		PrefilledIndexes: []int{},
+		ShortTxIDs:       []uint64{},
+		Nonce:            nonce,
+	}
+	compact.Block.Body.Transactions = []metadata.Transaction{}
+	blockHash := block.Header.Hash()
+	for index, tx := range block.Body.Transactions {
+		if tx.GetType() == common.TxSalaryType {
+			compact.PrefilledIndexes = append(compact.PrefilledIndexes, index)
+			compact.Block.Body.Transactions = append(compact.Block.Body.Transactions, tx)
+			continue
+		}
+		compact.ShortTxIDs = append(compact.ShortTxIDs, ShortTxID(blockHash, nonce, *tx.Hash()))
+	}
+	return compact
+}
+
+// Hash returns hash of the full block
+func (compact *CompactShardBlock) Hash() *common.Hash {
+	return compact.Block.Hash()
+}
+
+// TxCount returns number of transactions in the full block
+func (compact *CompactShardBlock) TxCount() int {
+	return len(compact.PrefilledIndexes) + len(compact.ShortTxIDs)
+}
+
+/*
+PartialShardBlock - a compact block which is being rebuilt,
+transactions which are not found in mempool are nil
+*/
+type PartialShardBlock struct {
+	compact *CompactShardBlock
+	txs     []metadata.Transaction
+	// short id of transaction at each index, prefilled index is not in map
+	shortIDs map[int]uint64
+}
+
+/*
+Rebuild - fill transactions of a compact block with transactions in mempool,
+a short id which matches more than one transaction is left missing
+*/
+func (compact *CompactShardBlock) Rebuild(poolTxs []metadata.Transaction) (*PartialShardBlock, error) {
+	if len(compact.PrefilledIndexes) != len(compact.Block.Body.Transactions) {
+		return nil, NewBlockChainError(UnExpectedError, errors.New("prefilled transactions do not match prefilled indexes"))
+	}
+	partial := &PartialShardBlock{
+		compact:  compact,
+		txs:      make([]metadata.Transaction, compact.TxCount()),
+		shortIDs: make(map[int]uint64),
+	}
+	for i, index := range compact.PrefilledIndexes {
+		if index < 0 || index >= len(partial.txs) || partial.txs[index] != nil {
+			return nil, NewBlockChainError(UnExpectedError, fmt.Errorf("invalid prefilled index %d", index))
+		}
+		partial.txs[index] = compact.Block.Body.Transactions[i]
+	}
+
+	blockHash := compact.Block.Header.Hash()
+	indexOfShortID := make(map[uint64]int)
+	next := 0
+	for index := range partial.txs {
+		if partial.txs[index] != nil {
+			continue
+		}
+		shortID := compact.ShortTxIDs[next]
+		next++
+		if _, ok := indexOfShortID[shortID]; ok {
+			return nil, NewBlockChainError(UnExpectedError, fmt.Errorf("duplicated short id %d", shortID))
+		}
+		indexOfShortID[shortID] = index
+		partial.shortIDs[index] = shortID
+	}
+
+	ambiguous := make(map[uint64]bool)
+	for _, tx := range poolTxs {
+		shortID := ShortTxID(blockHash, compact.Nonce, *tx.Hash())
+		index, ok := indexOfShortID[shortID]
+		if !ok || ambiguous[shortID] {
+			continue
+		}
+		if partial.txs[index] != nil {
+			ambiguous[shortID] = true
+			partial.txs[index] = nil
+			continue
+		}
+		partial.txs[index] = tx
+	}
+	return partial, nil
+}
+
+// MissingIndexes returns indexes of transactions which are not found in mempool
+func (partial *PartialShardBlock) MissingIndexes() []int {
+	missing := []int{}
+	for index, tx := range partial.txs {
+		if tx == nil {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+/*
+Fill - fill missing transactions which are sent by peer,
+transaction which does not match short id at its index is rejected
+*/
+func (partial *PartialShardBlock) Fill(indexes []int, txs []metadata.Transaction) error {
+	if len(indexes) != len(txs) {
+		return NewBlockChainError(UnExpectedError, errors.New("number of transactions does not match number of indexes"))
+	}
+	blockHash := partial.compact.Block.Header.Hash()
+	for i, index := range indexes {
+		shortID, ok := partial.shortIDs[index]
+		if !ok {
+			return NewBlockChainError(UnExpectedError, fmt.Errorf("index %d is not a short id", index))
+		}
+		if ShortTxID(blockHash, partial.compact.Nonce, *txs[i].Hash()) != shortID {
+			return NewBlockChainError(UnExpectedError, fmt.Errorf("transaction at index %d does not match short id", index))
+		}
+		partial.txs[index] = txs[i]
+	}
+	return nil
+}
+
+/*
+ShardBlock - return the full block when all transactions are filled,
+transaction root is verified because a short id may collide with another transaction
+*/
+func (partial *PartialShardBlock) ShardBlock() (*ShardBlock, error) {
+	if len(partial.MissingIndexes()) > 0 {
+		return nil, NewBlockChainError(UnExpectedError, errors.New("block still misses transactions"))
+	}
+	block := partial.compact.Block
+	block.Body.Transactions = make([]metadata.Transaction, len(partial.txs))
+	copy(block.Body.Transactions, partial.txs)
+
+	txMerkle := Merkle{}.BuildMerkleTreeStore(block.Body.Transactions)
+	txRoot := &common.Hash{}
+	if len(txMerkle) > 0 {
+		txRoot = txMerkle[len(txMerkle)-1]
+	}
+	if !bytes.Equal(block.Header.TxRoot.GetBytes(), txRoot.GetBytes()) {
+		return nil, NewBlockChainError(HashError, errors.New("transaction root of rebuilt block is invalid"))
+	}
+	return &block, nil
+}
+
+/*
+TxList - list of transactions which are parsed into particular tx struct by their type
+*/
+type TxList []metadata.Transaction
+
+func (txList *TxList) UnmarshalJSON(data []byte) error {
+	txsTemp := []map[string]interface{}{}
+	err := json.Unmarshal(data, &txsTemp)
+	if err != nil {
+		return NewBlockChainError(UnmashallJsonBlockError, err)
+	}
+	txs, err := parseTransactions(txsTemp)
+	if err != nil {
+		return err
+	}
+	*txList = txs
+	return nil
+}
@@ -18,6 +18,14 @@ const (
 	ThresholdRatioOfGOVCrisis = 9000
 )
 
+// Weight of proof verification cost for selecting tx into block
+const (
+	txBaseVerifyCost         = 1
+	snProofVerifyCost        = 1
+	rangeProofVerifyCost     = 4  // per output coin
+	oneOfManyProofVerifyCost = 20 // per input coin
+)
+
 // CONSTANT for network MAINNET
 const (
 	// ------------- Mainnet ---------------------------------------------
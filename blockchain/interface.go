@@ -74,6 +74,12 @@ type TxPool interface {
 	EmptyPool() bool
 
 	MaybeAcceptTransactionForBlockProducing(metadata.Transaction) (*metadata.TxDesc, error)
+
+	// ProcessOrphans moves orphan txs which inputs are known now into the pool
+	ProcessOrphans() []metadata.Transaction
+
+	// RegisterShardBlock informs fee estimator of pool about a new shard block
+	RegisterShardBlock(block *ShardBlock)
 	//CheckTransactionFee
 	// CheckTransactionFee(tx metadata.Transaction) (uint64, error)
 
@@ -132,9 +132,20 @@ func SetBestStateShard(shardID byte, beststateShard *BestStateShard) {
 	bestStateShardMap[shardID] = beststateShard
 }
 
+/*
+InitBestStateShard - create best state of a shard chain at genesis and make it the singleton object of shard,
+a new object is created each time so that chains of several nodes in one process do not share it
+*/
 func InitBestStateShard(shardID byte, netparam *Params) *BestStateShard {
-	bestStateShard := GetBestStateShard(shardID)
+	bestStateShard := NewBestStateShard(shardID, netparam)
+	SetBestStateShard(shardID, bestStateShard)
+	return bestStateShard
+}
 
+// NewBestStateShard creates best state of a shard chain at genesis
+func NewBestStateShard(shardID byte, netparam *Params) *BestStateShard {
+	bestStateShard := &BestStateShard{}
+	bestStateShard.ShardID = shardID
 	bestStateShard.BestBlockHash.SetBytes(make([]byte, 32))
 	bestStateShard.BestBeaconHash.SetBytes(make([]byte, 32))
 	bestStateShard.BestBlock = nil
@@ -76,8 +76,20 @@ func (shardBody *ShardBody) UnmarshalJSON(data []byte) error {
 		return NewBlockChainError(UnmashallJsonBlockError, err)
 	}
 
-	// process tx from tx interface of temp
-	for _, txTemp := range temp.Transactions {
+	shardBody.Transactions, err = parseTransactions(temp.Transactions)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+parseTransactions - parse json of transactions into particular tx struct by their type
+*/
+func parseTransactions(txsTemp []map[string]interface{}) ([]metadata.Transaction, error) {
+	var txs []metadata.Transaction
+	for _, txTemp := range txsTemp {
 		txTempJson, _ := json.MarshalIndent(txTemp, "", "\t")
 		Logger.log.Debugf("Tx json data: ", string(txTempJson))
 
@@ -106,22 +118,21 @@ func (shardBody *ShardBody) UnmarshalJSON(data []byte) error {
 			}
 		default:
 			{
-				return NewBlockChainError(UnmashallJsonBlockError, errors.New("can not parse a wrong tx"))
+				return nil, NewBlockChainError(UnmashallJsonBlockError, errors.New("can not parse a wrong tx"))
 			}
 		}
 
 		if parseErr != nil {
-			return NewBlockChainError(UnmashallJsonBlockError, parseErr)
+			return nil, NewBlockChainError(UnmashallJsonBlockError, parseErr)
 		}
 		/*meta, parseErr := metadata.ParseMetadata(txTemp["Metadata"])
 		if parseErr != nil {
 			return NewBlockChainError(UnmashallJsonBlockError, parseErr)
 		}
 		tx.SetMetadata(meta)*/
-		shardBody.Transactions = append(shardBody.Transactions, tx)
+		txs = append(txs, tx)
 	}
-
-	return nil
+	return txs, nil
 }
 func (shardBody *CrossOutputCoin) Hash() common.Hash {
 	record := []byte{}
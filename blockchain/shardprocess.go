@@ -9,11 +9,13 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ninjadotorg/constant/cashec"
 	"github.com/ninjadotorg/constant/common"
 	"github.com/ninjadotorg/constant/common/base58"
 	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/metrics"
 	"github.com/ninjadotorg/constant/transaction"
 )
 
@@ -25,6 +27,7 @@ import (
 func (blockchain *BlockChain) VerifyPreSignShardBlock(block *ShardBlock, shardID byte) error {
 	blockchain.chainLock.Lock()
 	defer blockchain.chainLock.Unlock()
+	defer metrics.ObserveSince(metrics.BlockVerifyDuration.WithLabelValues(metrics.ShardChain(shardID), metrics.VerifyPreSign), time.Now())
 	//========Verify block only
 	Logger.log.Infof("Verify block for signing process %d, with hash %+v", block.Header.Height, *block.Hash())
 	if err := blockchain.VerifyPreProcessingShardBlock(block, shardID, true); err != nil {
@@ -81,6 +84,7 @@ func (blockchain *BlockChain) InsertShardBlock(block *ShardBlock) error {
 	blockchain.chainLock.Lock()
 	defer blockchain.chainLock.Unlock()
 	shardID := block.Header.ShardID
+	defer metrics.ObserveSince(metrics.BlockInsertDuration.WithLabelValues(metrics.ShardChain(shardID)), time.Now())
 	Logger.log.Infof("SHARD %+v | Check block existence for insert height %+v at hash %+v", block.Header.ShardID, block.Header.Height, block.Hash())
 	isExist, _ := blockchain.config.DataBase.HasBlock(block.Hash())
 	if isExist {
@@ -195,7 +199,11 @@ func (blockchain *BlockChain) InsertShardBlock(block *ShardBlock) error {
 	for _, tx := range block.Body.Transactions {
 		blockchain.config.TxPool.RemoveTx(tx)
 	}
+	blockchain.config.TxPool.RegisterShardBlock(block)
 	Logger.log.Infof("SHARD %+v | Finish Insert new block %d, with hash %+v", block.Header.ShardID, block.Header.Height, *block.Hash())
+	if blockchain.config.OnShardBlockInserted != nil {
+		blockchain.config.OnShardBlockInserted(block)
+	}
 	return nil
 }
 
@@ -286,6 +294,7 @@ DO NOT USE THIS with GENESIS BLOCK
 - ALL Transaction in block: see in VerifyTransactionFromNewBlock
 */
 func (blockchain *BlockChain) VerifyPreProcessingShardBlock(block *ShardBlock, shardID byte, isPresig bool) error {
+	defer metrics.ObserveSince(metrics.BlockVerifyDuration.WithLabelValues(metrics.ShardChain(shardID), metrics.VerifyPreProcessing), time.Now())
 	//verify producer sig
 	blkHash := block.Header.Hash()
 	err := cashec.ValidateDataB58(block.Header.Producer, block.ProducerSig, blkHash.GetBytes())
@@ -154,7 +154,7 @@ func (blockgen *BlkTmplGenerator) NewBlockShard(payToAddress *privacy.PaymentAdd
 		Version:              BlockVersion,
 		PrevBlockHash:        *prevBlockHash,
 		Height:               prevBlock.Header.Height + 1,
-		Timestamp:            time.Now().Unix(),
+		Timestamp:            blockgen.chain.config.Clock.Now().Unix(),
 		TxRoot:               *merkleRoot,
 		ShardTxRoot:          shardTxMerkleData[len(shardTxMerkleData)-1],
 		CrossOutputCoinRoot:  *crossOutputCoinRoot,
@@ -346,34 +346,36 @@ func (blockgen *BlkTmplGenerator) getPendingTransaction(shardID byte) (txsToAdd
 	//TODO: UNCOMMENT To avoid produce too many empty block
 	// get tx and wait for more if not enough
 	if len(sourceTxns) < common.MinTxsInBlock {
-		<-time.Tick(common.MinBlockWaitTime * time.Second)
+		<-blockgen.chain.config.Clock.After(common.MinBlockWaitTime * time.Second)
 		sourceTxns = blockgen.txPool.MiningDescs()
 		if len(sourceTxns) == 0 {
-			<-time.Tick(common.MaxBlockWaitTime * time.Second)
+			<-blockgen.chain.config.Clock.After(common.MaxBlockWaitTime * time.Second)
 			sourceTxns = blockgen.txPool.MiningDescs()
 		}
 	}
 
-	//TODO: sort transaction base on fee and check limit block size
-	// StartingPriority, fee, size, time
+	// sort transaction base on fee per kb and check limit block size, proof verification cost
+	template := SelectTransactions(sourceTxns, blockgen.TxSelectionPolicy())
 	fmt.Println("TempTxPool", reflect.TypeOf(blockgen.chain.config.TempTxPool))
 	isEmpty := blockgen.chain.config.TempTxPool.EmptyPool()
 	if !isEmpty {
 		panic("TempTxPool Is not Empty")
 	}
-	for _, txDesc := range sourceTxns {
-		tx := txDesc.Tx
-		tempTxDesc, err := blockgen.chain.config.TempTxPool.MaybeAcceptTransactionForBlockProducing(tx)
-		tempTx := tempTxDesc.Tx
+	// a tx is skipped when a tx it depends on is rejected
+	rejectedGroups := make(map[int]bool)
+	for _, templateTx := range template.Txs {
+		if rejectedGroups[templateTx.Group] {
+			continue
+		}
+		tx := templateTx.Desc.Tx
+		_, err := blockgen.chain.config.TempTxPool.MaybeAcceptTransactionForBlockProducing(tx)
 		if err != nil {
-			txToRemove = append(txToRemove, metadata.Transaction(tempTx))
+			rejectedGroups[templateTx.Group] = true
+			txToRemove = append(txToRemove, tx)
 			continue
 		}
 		totalFee += tx.GetTxFee()
-		txsToAdd = append(txsToAdd, tempTx)
-		if len(txsToAdd) == common.MaxTxsInBlock {
-			break
-		}
+		txsToAdd = append(txsToAdd, tx)
 	}
 	blockgen.chain.config.TempTxPool.EmptyPool()
 	return txsToAdd, txToRemove, totalFee
@@ -159,65 +159,158 @@ func (blkTmpGen *BlkTmplGenerator) buildStabilityInstructions(
 		if err != nil {
 			return [][]string{}, err
 		}
-		contentStr := inst[1]
-		newInst := [][]string{}
-		switch metaType {
-		case metadata.LoanRequestMeta, metadata.LoanResponseMeta, metadata.LoanWithdrawMeta, metadata.LoanPaymentMeta, metadata.DividendSubmitMeta:
-			newInst, err = buildPassThroughInstruction(metaType, contentStr)
+		newInst, built, err := buildInstructionsFromAction(blkTmpGen.chain, shardID, metaType, inst, beaconBestState, accumulativeValues)
+		if !built {
+			// instructions of boards and votes are stored by beacon instead
+			switch metaType {
+			case component.VoteDCBBoardIns:
+				err = blkTmpGen.chain.AddVoteDCBBoard(inst[2])
+
+			case component.VoteGOVBoardIns:
+				err = blkTmpGen.chain.AddVoteDCBBoard(inst[2])
+
+			case component.SealedLv1Or2VoteProposalIns:
+				err = blkTmpGen.chain.AddVoteLv1or2Proposal(inst[2])
+			case component.SealedLv3VoteProposalIns:
+				err = blkTmpGen.chain.AddVoteLv3Proposal(inst[2])
+			case component.NormalVoteProposalFromSealerIns:
+				err = blkTmpGen.chain.AddNormalVoteProposalFromSealer(inst[2])
+			case component.NormalVoteProposalFromOwnerIns:
+				err = blkTmpGen.chain.AddNormalVoteProposalFromOwner(inst[2])
+			case component.PunishDecryptIns:
+				// todo @0xjackalope
+			default:
+				continue
+			}
+		}
+		if err != nil {
+			return [][]string{}, err
+		}
+		instructions = append(instructions, newInst...)
+	}
+	// update component in beststate
+	return instructions, nil
+}
 
-		case metadata.BuyFromGOVRequestMeta:
-			newInst, err = buildInstructionsForBuyBondsFromGOVReq(shardID, contentStr, beaconBestState, accumulativeValues)
+/*
+buildInstructionsFromAction builds instructions of beacon from an action of a shard block which does not change chain,
+built is false when action is not built this way, like votes and boards which are stored by beacon
+*/
+func buildInstructionsFromAction(
+	chain *BlockChain,
+	shardID byte,
+	metaType int,
+	inst []string,
+	beaconBestState *BestStateBeacon,
+	accumulativeValues *accumulativeValues,
+) (newInst [][]string, built bool, err error) {
+	contentStr := inst[1]
+	switch metaType {
+	case metadata.LoanRequestMeta, metadata.LoanResponseMeta, metadata.LoanWithdrawMeta, metadata.LoanPaymentMeta, metadata.DividendSubmitMeta:
+		newInst, err = buildPassThroughInstruction(metaType, contentStr)
 
-		case metadata.BuyGOVTokenRequestMeta:
-			newInst, err = buildInstructionsForBuyGOVTokensReq(shardID, contentStr, beaconBestState, accumulativeValues)
+	case metadata.BuyFromGOVRequestMeta:
+		newInst, err = buildInstructionsForBuyBondsFromGOVReq(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case metadata.CrowdsaleRequestMeta:
-			newInst, err = buildInstructionsForCrowdsaleRequest(shardID, contentStr, beaconBestState, accumulativeValues)
+	case metadata.BuyGOVTokenRequestMeta:
+		newInst, err = buildInstructionsForBuyGOVTokensReq(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case metadata.BuyBackRequestMeta:
-			newInst, err = buildInstructionsForBuyBackBondsReq(shardID, contentStr, beaconBestState, accumulativeValues, blkTmpGen.chain)
+	case metadata.CrowdsaleRequestMeta:
+		newInst, err = buildInstructionsForCrowdsaleRequest(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case metadata.IssuingRequestMeta:
-			newInst, err = buildInstructionsForIssuingReq(shardID, contentStr, beaconBestState, accumulativeValues)
+	case metadata.BuyBackRequestMeta:
+		newInst, err = buildInstructionsForBuyBackBondsReq(shardID, contentStr, beaconBestState, accumulativeValues, chain)
 
-		case metadata.ContractingRequestMeta:
-			newInst, err = buildInstructionsForContractingReq(shardID, contentStr, beaconBestState, accumulativeValues)
+	case metadata.IssuingRequestMeta:
+		newInst, err = buildInstructionsForIssuingReq(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case metadata.ShardBlockSalaryRequestMeta:
-			newInst, err = buildInstForShardBlockSalaryReq(shardID, contentStr, beaconBestState, accumulativeValues)
+	case metadata.ContractingRequestMeta:
+		newInst, err = buildInstructionsForContractingReq(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case component.NewDCBConstitutionIns:
-			newInst, err = buildUpdateConstitutionIns(inst[2], common.DCBBoard)
+	case metadata.ShardBlockSalaryRequestMeta:
+		newInst, err = buildInstForShardBlockSalaryReq(shardID, contentStr, beaconBestState, accumulativeValues)
 
-		case component.NewGOVConstitutionIns:
-			newInst, err = buildUpdateConstitutionIns(inst[2], common.GOVBoard)
+	case component.NewDCBConstitutionIns:
+		newInst, err = buildUpdateConstitutionIns(inst[2], common.DCBBoard)
 
-		case component.VoteDCBBoardIns:
-			err = blkTmpGen.chain.AddVoteDCBBoard(inst[2])
+	case component.NewGOVConstitutionIns:
+		newInst, err = buildUpdateConstitutionIns(inst[2], common.GOVBoard)
 
-		case component.VoteGOVBoardIns:
-			err = blkTmpGen.chain.AddVoteDCBBoard(inst[2])
+	default:
+		return nil, false, nil
+	}
+	return newInst, true, err
+}
 
-		case component.SealedLv1Or2VoteProposalIns:
-			err = blkTmpGen.chain.AddVoteLv1or2Proposal(inst[2])
-		case component.SealedLv3VoteProposalIns:
-			err = blkTmpGen.chain.AddVoteLv3Proposal(inst[2])
-		case component.NormalVoteProposalFromSealerIns:
-			err = blkTmpGen.chain.AddNormalVoteProposalFromSealer(inst[2])
-		case component.NormalVoteProposalFromOwnerIns:
-			err = blkTmpGen.chain.AddNormalVoteProposalFromOwner(inst[2])
-		case component.PunishDecryptIns:
-			// todo @0xjackalope
-		default:
+// StabilityChanges are changes of stability operations which beacon accumulates while it builds instructions of a block
+type StabilityChanges struct {
+	BondsSold            uint64                         `json:"BondsSold"`
+	GOVTokensSold        uint64                         `json:"GOVTokensSold"`
+	IncomeFromBonds      uint64                         `json:"IncomeFromBonds"`
+	IncomeFromGOVTokens  uint64                         `json:"IncomeFromGOVTokens"`
+	DCBTokensSoldByUSD   uint64                         `json:"DCBTokensSoldByUSD"`
+	DCBTokensSoldByETH   uint64                         `json:"DCBTokensSoldByETH"`
+	ConstantsBurnedByETH uint64                         `json:"ConstantsBurnedByETH"`
+	BuyBackCoins         uint64                         `json:"BuyBackCoins"`
+	TotalFee             uint64                         `json:"TotalFee"`
+	TotalSalary          uint64                         `json:"TotalSalary"`
+	TotalRefundAmt       uint64                         `json:"TotalRefundAmt"`
+	TotalOracleRewards   uint64                         `json:"TotalOracleRewards"`
+	SaleData             map[string]*component.SaleData `json:"SaleData"`
+}
+
+/*
+SimulateStabilityInstructions builds instructions which beacon would build from actions of a shard on top of current best state,
+votes and boards are not built since beacon stores them, neither chain nor best state is changed.
+Instructions are built on a copy of best state which is taken under chain lock, so that blocks which are inserted meanwhile do not race with simulation
+*/
+func (blockchain *BlockChain) SimulateStabilityInstructions(shardID byte, actions [][]string) ([][]string, *StabilityChanges, error) {
+	beaconBestState := BestStateBeacon{}
+	blockchain.chainLock.RLock()
+	tempMarshal, err := json.Marshal(blockchain.BestState.Beacon)
+	blockchain.chainLock.RUnlock()
+	if err != nil {
+		return nil, nil, NewBlockChainError(MashallJsonError, err)
+	}
+	err = json.Unmarshal(tempMarshal, &beaconBestState)
+	if err != nil {
+		return nil, nil, NewBlockChainError(UnmashallJsonBlockError, err)
+	}
+
+	values := &accumulativeValues{
+		saleDataMap: map[string]*component.SaleData{},
+	}
+	instructions := [][]string{}
+	for _, action := range actions {
+		if action[0] == StakeAction || action[0] == SwapAction || action[0] == RandomAction {
 			continue
 		}
+		metaType, err := strconv.Atoi(action[0])
 		if err != nil {
-			return [][]string{}, err
+			return nil, nil, err
+		}
+		newInst, _, err := buildInstructionsFromAction(blockchain, shardID, metaType, action, &beaconBestState, values)
+		if err != nil {
+			return nil, nil, err
 		}
 		instructions = append(instructions, newInst...)
 	}
-	// update component in beststate
-	return instructions, nil
+	changes := &StabilityChanges{
+		BondsSold:            values.bondsSold,
+		GOVTokensSold:        values.govTokensSold,
+		IncomeFromBonds:      values.incomeFromBonds,
+		IncomeFromGOVTokens:  values.incomeFromGOVTokens,
+		DCBTokensSoldByUSD:   values.dcbTokensSoldByUSD,
+		DCBTokensSoldByETH:   values.dcbTokensSoldByETH,
+		ConstantsBurnedByETH: values.constantsBurnedByETH,
+		BuyBackCoins:         values.buyBackCoins,
+		TotalFee:             values.totalFee,
+		TotalSalary:          values.totalSalary,
+		TotalRefundAmt:       values.totalRefundAmt,
+		TotalOracleRewards:   values.totalOracleRewards,
+		SaleData:             values.saleDataMap,
+	}
+	return instructions, changes, nil
 }
 
 func buildUpdateConstitutionIns(inst string, boardType common.BoardType) ([][]string, error) {
@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ninjadotorg/constant/blockchain/component"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// newStabilityTestChain creates a chain whose beacon sells govTokensToSell GOV tokens at height of its next block
+func newStabilityTestChain(govTokensToSell uint64) *BlockChain {
+	beacon := &BestStateBeacon{BestBlock: &BeaconBlock{Header: BeaconHeader{Height: 10}}}
+	beacon.StabilityInfo.GOVConstitution.GOVParams.SellingGOVTokens = &component.SellingGOVTokens{
+		GOVTokensToSell: govTokensToSell,
+		GOVTokenPrice:   100,
+		StartSellingAt:  5,
+		SellingWithin:   10,
+	}
+	return &BlockChain{BestState: &BestState{Beacon: beacon}}
+}
+
+func buildBuyGOVTokenActions(t *testing.T, bc *BlockChain, lockTime int64, amount uint64) [][]string {
+	meta := metadata.NewBuyGOVTokenRequest(privacy.PaymentAddress{}, common.Hash{}, amount, 100, metadata.BuyGOVTokenRequestMeta)
+	tx := &transaction.Tx{Type: common.TxNormalType, LockTime: lockTime, Metadata: meta}
+	actions, err := meta.BuildReqActions(tx, bc, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return actions
+}
+
+func TestSimulateStabilityInstructions(t *testing.T) {
+	bc := newStabilityTestChain(100)
+	actions := buildBuyGOVTokenActions(t, bc, 1, 60)
+	actions = append(actions, buildBuyGOVTokenActions(t, bc, 2, 60)...)
+	// actions of committee are not stability actions
+	actions = append(actions, []string{StakeAction, "key"})
+
+	instructions, changes, err := bc.SimulateStabilityInstructions(0, actions)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(instructions) != 2 {
+		t.Fatalf("expected 2 instructions, got %+v", instructions)
+	}
+	// second request is refunded since it is over tokens which are left to sell
+	if instructions[0][2] != "accepted" || instructions[1][2] != "refund" {
+		t.Errorf("expected accepted and refunded request, got %+v", instructions)
+	}
+	if changes.GOVTokensSold != 60 || changes.IncomeFromGOVTokens != 160 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	// simulation does not accumulate on best state, same actions give same result
+	_, changes, err = bc.SimulateStabilityInstructions(0, actions)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if changes.GOVTokensSold != 60 {
+		t.Errorf("expected simulation not to change best state, got %+v", changes)
+	}
+}
+
+func TestSimulateStabilityInstructionsOutsideSale(t *testing.T) {
+	bc := newStabilityTestChain(100)
+	bc.BestState.Beacon.BestBlock.Header.Height = 20
+	instructions, changes, err := bc.SimulateStabilityInstructions(0, buildBuyGOVTokenActions(t, bc, 1, 60))
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(instructions) != 1 || instructions[0][2] != "refund" || changes.GOVTokensSold != 0 {
+		t.Errorf("expected request after sale to be refunded, got %+v %+v", instructions, changes)
+	}
+	if _, _, err := bc.SimulateStabilityInstructions(0, [][]string{{"abc", ""}}); err == nil {
+		t.Errorf("expected action of unknown type to fail")
+	}
+}
+
+func TestSimulateStabilityInstructionsWhileInserting(t *testing.T) {
+	bc := newStabilityTestChain(100)
+	actions := buildBuyGOVTokenActions(t, bc, 1, 60)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := uint64(0); i < 100; i++ {
+			bc.chainLock.Lock()
+			bc.BestState.Beacon.BestBlock = &BeaconBlock{Header: BeaconHeader{Height: 10 + i%2}}
+			bc.chainLock.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, _, err := bc.SimulateStabilityInstructions(0, actions); err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+	}
+	<-done
+}
@@ -3,7 +3,6 @@ package blockchain
 import (
 	"errors"
 	"fmt"
-	"time"
 
 	libp2p "github.com/libp2p/go-libp2p-peer"
 	"github.com/ninjadotorg/constant/common"
@@ -49,7 +48,7 @@ func (blockchain *BlockChain) StartSyncBlk() {
 			select {
 			case <-blockchain.cQuitSync:
 				return
-			case <-time.Tick(defaultBroadcastStateTime):
+			case <-blockchain.config.Clock.After(defaultBroadcastStateTime):
 				go blockchain.config.Server.BoardcastNodeState()
 			}
 		}
@@ -58,7 +57,7 @@ func (blockchain *BlockChain) StartSyncBlk() {
 		select {
 		case <-blockchain.cQuitSync:
 			return
-		case <-time.Tick(defaultProcessPeerStateTime):
+		case <-blockchain.config.Clock.After(defaultProcessPeerStateTime):
 			blockchain.InsertBlockFromPool()
 			blockchain.syncStatus.Lock()
 			blockchain.syncStatus.PeersStateLock.Lock()
@@ -377,7 +376,7 @@ func (blockchain *BlockChain) SyncBlkBeacon(byHash bool, getFromPool bool, blksH
 			go blockchain.config.Server.PushMessageGetBlockBeaconByHash(blksNeedToGet, getFromPool, peerID)
 		}
 		for _, blkHash := range blksNeedToGet {
-			blockchain.syncStatus.CurrentlySyncBeaconBlkByHash.Add(blkHash.String(), time.Now().Unix(), defaultMaxBlockSyncTime)
+			blockchain.syncStatus.CurrentlySyncBeaconBlkByHash.Add(blkHash.String(), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 		}
 	} else {
 		//Sync by height
@@ -391,7 +390,7 @@ func (blockchain *BlockChain) SyncBlkBeacon(byHash bool, getFromPool bool, blksH
 		}
 		for fromHeight, toHeight := range blkBatchsNeedToGet {
 			for height := fromHeight; height <= toHeight; height++ {
-				blockchain.syncStatus.CurrentlySyncBeaconBlkByHeight.Add(fmt.Sprint(height), time.Now().Unix(), defaultMaxBlockSyncTime)
+				blockchain.syncStatus.CurrentlySyncBeaconBlkByHeight.Add(fmt.Sprint(height), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 			}
 		}
 	}
@@ -416,7 +415,7 @@ func (blockchain *BlockChain) SyncBlkShard(shardID byte, byHash bool, getFromPoo
 			go blockchain.config.Server.PushMessageGetBlockShardByHash(shardID, blksNeedToGet, getFromPool, peerID)
 		}
 		for _, blkHash := range blksNeedToGet {
-			blockchain.syncStatus.CurrentlySyncShardBlkByHash[shardID].Add(blkHash.String(), time.Now().Unix(), defaultMaxBlockSyncTime)
+			blockchain.syncStatus.CurrentlySyncShardBlkByHash[shardID].Add(blkHash.String(), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 		}
 	} else {
 		//Sync by height
@@ -433,7 +432,7 @@ func (blockchain *BlockChain) SyncBlkShard(shardID byte, byHash bool, getFromPoo
 		}
 		for fromHeight, toHeight := range blkBatchsNeedToGet {
 			for height := fromHeight; height <= toHeight; height++ {
-				blockchain.syncStatus.CurrentlySyncShardBlkByHeight[shardID].Add(fmt.Sprint(height), time.Now().Unix(), defaultMaxBlockSyncTime)
+				blockchain.syncStatus.CurrentlySyncShardBlkByHeight[shardID].Add(fmt.Sprint(height), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 			}
 		}
 	}
@@ -458,7 +457,7 @@ func (blockchain *BlockChain) SyncBlkShardToBeacon(shardID byte, byHash bool, ge
 			go blockchain.config.Server.PushMessageGetBlockShardToBeaconByHash(shardID, blksNeedToGet, getFromPool, peerID)
 		}
 		for _, blkHash := range blksNeedToGet {
-			blockchain.syncStatus.CurrentlySyncShardToBeaconBlkByHash[shardID].Add(blkHash.String(), time.Now().Unix(), defaultMaxBlockSyncTime)
+			blockchain.syncStatus.CurrentlySyncShardToBeaconBlkByHash[shardID].Add(blkHash.String(), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 		}
 	} else {
 		//Sync by height
@@ -475,7 +474,7 @@ func (blockchain *BlockChain) SyncBlkShardToBeacon(shardID byte, byHash bool, ge
 		}
 		for fromHeight, toHeight := range blkBatchsNeedToGet {
 			for height := fromHeight; height <= toHeight; height++ {
-				blockchain.syncStatus.CurrentlySyncShardToBeaconBlkByHeight[shardID].Add(fmt.Sprint(height), time.Now().Unix(), defaultMaxBlockSyncTime)
+				blockchain.syncStatus.CurrentlySyncShardToBeaconBlkByHeight[shardID].Add(fmt.Sprint(height), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 			}
 		}
 	}
@@ -494,7 +493,7 @@ func (blockchain *BlockChain) SyncBlkCrossShard(getFromPool bool, byHash bool, b
 			go blockchain.config.Server.PushMessageGetBlockCrossShardByHash(fromShard, toShard, blksNeedToGet, getFromPool, peerID)
 		}
 		for _, blkHash := range blksNeedToGet {
-			blockchain.syncStatus.CurrentlySyncCrossShardBlkByHash[fromShard].Add(blkHash.String(), time.Now().Unix(), defaultMaxBlockSyncTime)
+			blockchain.syncStatus.CurrentlySyncCrossShardBlkByHash[fromShard].Add(blkHash.String(), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 		}
 	} else {
 		//Sync by specific heights
@@ -508,7 +507,7 @@ func (blockchain *BlockChain) SyncBlkCrossShard(getFromPool bool, byHash bool, b
 			go blockchain.config.Server.PushMessageGetBlockCrossShardBySpecificHeight(fromShard, toShard, blksNeedToGet, getFromPool, peerID)
 		}
 		for _, blkHeight := range blksNeedToGet {
-			blockchain.syncStatus.CurrentlySyncCrossShardBlkByHeight[fromShard].Add(fmt.Sprint(blkHeight), time.Now().Unix(), defaultMaxBlockSyncTime)
+			blockchain.syncStatus.CurrentlySyncCrossShardBlkByHeight[fromShard].Add(fmt.Sprint(blkHeight), blockchain.config.Clock.Now().Unix(), defaultMaxBlockSyncTime)
 		}
 	}
 }
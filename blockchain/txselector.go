@@ -0,0 +1,285 @@
+package blockchain
+
+import (
+	"sort"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// TxSelectionPolicy houses the limits used when packing mempool transactions
+// into a new shard block.
+type TxSelectionPolicy struct {
+	// MaxBlockSize is the maximum sum of tx actual sizes in a block (kilobyte, like GetTxActualSize)
+	MaxBlockSize uint64
+
+	// MaxVerifyCost is the maximum sum of proof verification cost in a block,
+	// see estimateTxVerifyCost for the unit
+	MaxVerifyCost uint64
+
+	// MaxTxs is the maximum number of transactions in a block
+	MaxTxs int
+
+	// MaxTxsPerMetadataType is the maximum number of txs of a metadata type in a block,
+	// types which are not in map are unlimited
+	MaxTxsPerMetadataType map[int]int
+}
+
+// DefaultTxSelectionPolicy returns the policy which is used by block producer
+func DefaultTxSelectionPolicy() TxSelectionPolicy {
+	return TxSelectionPolicy{
+		MaxBlockSize:  common.MaxBlockSize,
+		MaxVerifyCost: common.MaxBlockVerifyCost,
+		MaxTxs:        common.MaxTxsInBlock,
+	}
+}
+
+// BlockTemplateTx is a transaction which is selected for a block template
+type BlockTemplateTx struct {
+	Desc       *metadata.TxDesc
+	Size       uint64
+	FeePerKb   uint64
+	VerifyCost uint64
+
+	// Group is index of dependency group of tx in template,
+	// txs in the same group must be included together and in order
+	Group int
+}
+
+// BlockTemplate is result of selecting transactions from mempool
+type BlockTemplate struct {
+	Policy          TxSelectionPolicy
+	Txs             []*BlockTemplateTx
+	TotalFee        uint64
+	TotalSize       uint64
+	TotalVerifyCost uint64
+
+	// NumSkipped is the number of txs which do not fit in the limits of policy
+	NumSkipped int
+}
+
+type txDependencyGroup struct {
+	txs        []*BlockTemplateTx
+	fee        uint64
+	size       uint64
+	verifyCost uint64
+}
+
+func (group *txDependencyGroup) feePerKb() uint64 {
+	if group.size == 0 {
+		return 0
+	}
+	return group.fee / group.size
+}
+
+// fitsMetadataLimits checks number of txs per metadata type after adding group
+func (group *txDependencyGroup) fitsMetadataLimits(numTxsByMetaType map[int]int, limits map[int]int) bool {
+	if len(limits) == 0 {
+		return true
+	}
+	groupCount := make(map[int]int)
+	for _, tx := range group.txs {
+		if tx.Desc.Tx.GetMetadata() == nil {
+			continue
+		}
+		metaType := tx.Desc.Tx.GetMetadataType()
+		groupCount[metaType]++
+		if limit, ok := limits[metaType]; ok && numTxsByMetaType[metaType]+groupCount[metaType] > limit {
+			return false
+		}
+	}
+	return true
+}
+
+func (group *txDependencyGroup) firstAdded() int64 {
+	first := group.txs[0].Desc.Added.UnixNano()
+	for _, tx := range group.txs[1:] {
+		if added := tx.Desc.Added.UnixNano(); added < first {
+			first = added
+		}
+	}
+	return first
+}
+
+/*
+SelectTransactions - build a block template from mining descriptors of mempool
+1. Group txs which depend on each other (loan request -> response -> withdraw, cmb contract -> deposit,...)
+2. Sort groups by fee per kb (highest first), older group first if equal
+3. Add a whole group if it fits block size, proof verification cost, number of txs
+and number of txs per metadata type
+*/
+func SelectTransactions(descs []*metadata.TxDesc, policy TxSelectionPolicy) *BlockTemplate {
+	template := &BlockTemplate{
+		Policy: policy,
+		Txs:    []*BlockTemplateTx{},
+	}
+	groups := groupDependentTxs(descs)
+	sort.SliceStable(groups, func(i, j int) bool {
+		feeI, feeJ := groups[i].feePerKb(), groups[j].feePerKb()
+		if feeI != feeJ {
+			return feeI > feeJ
+		}
+		return groups[i].firstAdded() < groups[j].firstAdded()
+	})
+	numTxsByMetaType := make(map[int]int)
+	for _, group := range groups {
+		if template.TotalSize+group.size > policy.MaxBlockSize ||
+			template.TotalVerifyCost+group.verifyCost > policy.MaxVerifyCost ||
+			len(template.Txs)+len(group.txs) > policy.MaxTxs ||
+			!group.fitsMetadataLimits(numTxsByMetaType, policy.MaxTxsPerMetadataType) {
+			template.NumSkipped += len(group.txs)
+			continue
+		}
+		for _, tx := range group.txs {
+			if tx.Desc.Tx.GetMetadata() != nil {
+				numTxsByMetaType[tx.Desc.Tx.GetMetadataType()]++
+			}
+		}
+		groupIndex := len(template.Txs)
+		for _, tx := range group.txs {
+			tx.Group = groupIndex
+			template.Txs = append(template.Txs, tx)
+		}
+		template.TotalFee += group.fee
+		template.TotalSize += group.size
+		template.TotalVerifyCost += group.verifyCost
+	}
+	return template
+}
+
+// groupDependentTxs unions txs which share a dependency key
+// and sorts txs of each group by their dependency rank
+func groupDependentTxs(descs []*metadata.TxDesc) []*txDependencyGroup {
+	parents := make([]int, len(descs))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parents[i] != i {
+			parents[i] = parents[parents[i]]
+			i = parents[i]
+		}
+		return i
+	}
+
+	ranks := make([]int, len(descs))
+	owners := make(map[string]int)
+	for i, desc := range descs {
+		keys, rank := txDependencyKeys(desc.Tx)
+		ranks[i] = rank
+		for _, key := range keys {
+			if owner, ok := owners[key]; ok {
+				parents[find(i)] = find(owner)
+			} else {
+				owners[key] = i
+			}
+		}
+	}
+
+	groupByRoot := make(map[int]*txDependencyGroup)
+	groups := []*txDependencyGroup{}
+	rankOf := make(map[*BlockTemplateTx]int)
+	for i, desc := range descs {
+		root := find(i)
+		group, ok := groupByRoot[root]
+		if !ok {
+			group = &txDependencyGroup{}
+			groupByRoot[root] = group
+			groups = append(groups, group)
+		}
+		tx := newBlockTemplateTx(desc)
+		rankOf[tx] = ranks[i]
+		group.txs = append(group.txs, tx)
+		group.fee += desc.Fee
+		group.size += tx.Size
+		group.verifyCost += tx.VerifyCost
+	}
+	for _, group := range groups {
+		txs := group.txs
+		sort.SliceStable(txs, func(i, j int) bool {
+			if rankOf[txs[i]] != rankOf[txs[j]] {
+				return rankOf[txs[i]] < rankOf[txs[j]]
+			}
+			return txs[i].Desc.Added.Before(txs[j].Desc.Added)
+		})
+	}
+	return groups
+}
+
+func newBlockTemplateTx(desc *metadata.TxDesc) *BlockTemplateTx {
+	tx := &BlockTemplateTx{
+		Desc:       desc,
+		Size:       desc.Tx.GetTxActualSize(),
+		VerifyCost: estimateTxVerifyCost(desc.Tx),
+	}
+	if tx.Size > 0 {
+		tx.FeePerKb = desc.Fee / tx.Size
+	}
+	return tx
+}
+
+/*
+txDependencyKeys - return keys which link a tx with other txs in mempool and rank of tx in its group.
+Every tx is keyed by its own hash so that a tx which refers to another tx hash joins its group.
+*/
+func txDependencyKeys(tx metadata.Transaction) ([]string, int) {
+	keys := []string{"tx:" + tx.Hash().String()}
+	meta := tx.GetMetadata()
+	if meta == nil {
+		return keys, 0
+	}
+	switch meta.GetType() {
+	case metadata.LoanRequestMeta:
+		return append(keys, "loan:"+string(meta.(*metadata.LoanRequest).LoanID)), 0
+	case metadata.LoanResponseMeta:
+		return append(keys, "loan:"+string(meta.(*metadata.LoanResponse).LoanID)), 1
+	case metadata.LoanWithdrawMeta:
+		return append(keys, "loan:"+string(meta.(*metadata.LoanWithdraw).LoanID)), 2
+	case metadata.LoanUnlockMeta:
+		return append(keys, "loan:"+string(meta.(*metadata.LoanUnlock).LoanID)), 3
+	case metadata.LoanPaymentMeta:
+		return append(keys, "loan:"+string(meta.(*metadata.LoanPayment).LoanID)), 4
+	case metadata.CMBInitRequestMeta:
+		return append(keys, "cmbinit:"+string(meta.(*metadata.CMBInitRequest).MainAccount.Bytes())), 0
+	case metadata.CMBInitResponseMeta:
+		return append(keys, "cmbinit:"+string(meta.(*metadata.CMBInitResponse).MainAccount.Bytes())), 1
+	case metadata.CMBDepositContractMeta:
+		return append(keys, "cmbcontract:"+tx.Hash().String()), 0
+	case metadata.CMBDepositSendMeta:
+		return append(keys, "cmbcontract:"+meta.(*metadata.CMBDepositSend).ContractID.String()), 1
+	case metadata.CMBWithdrawRequestMeta:
+		return append(keys, "cmbcontract:"+meta.(*metadata.CMBWithdrawRequest).ContractID.String()), 2
+	case metadata.CMBWithdrawResponseMeta:
+		return append(keys, "tx:"+meta.(*metadata.CMBWithdrawResponse).RequestTxID.String()), 3
+	}
+	return keys, 0
+}
+
+/*
+estimateTxVerifyCost - estimate cost of verifying proofs of a tx,
+one unit is roughly the cost of verifying a serial number proof
+*/
+func estimateTxVerifyCost(tx metadata.Transaction) uint64 {
+	cost := uint64(txBaseVerifyCost) + estimateProofVerifyCost(tx.GetProof())
+	if tx.GetType() == common.TxCustomTokenPrivacyType {
+		if tokenTx, ok := tx.(*transaction.TxCustomTokenPrivacy); ok {
+			cost += estimateProofVerifyCost(tokenTx.TxTokenPrivacyData.TxNormal.Proof)
+		}
+	}
+	return cost
+}
+
+func estimateProofVerifyCost(proof *zkp.PaymentProof) uint64 {
+	if proof == nil {
+		return 0
+	}
+	cost := uint64(len(proof.OneOfManyProof)) * oneOfManyProofVerifyCost
+	cost += uint64(len(proof.SerialNumberProof)+len(proof.SNNoPrivacyProof)) * snProofVerifyCost
+	if proof.AggregatedRangeProof != nil {
+		cost += uint64(len(proof.OutputCoins)) * rangeProofVerifyCost
+	}
+	return cost
+}
@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// newSelectorTxDesc creates a descriptor of a tx which is sizeKB kilobytes, lockTime makes its hash unique
+func newSelectorTxDesc(lockTime int64, fee uint64, sizeKB int, meta metadata.Metadata, added time.Time) *metadata.TxDesc {
+	tx := &transaction.Tx{
+		Type:     common.TxNormalType,
+		LockTime: lockTime,
+		Fee:      fee,
+		// leave half a kilobyte for basic data and metadata so that actual size rounds up to sizeKB
+		Info:     make([]byte, sizeKB*1024-512),
+		Metadata: meta,
+	}
+	return &metadata.TxDesc{Tx: tx, Added: added, Fee: fee}
+}
+
+func newSelectorPolicy() TxSelectionPolicy {
+	return TxSelectionPolicy{
+		MaxBlockSize:  100,
+		MaxVerifyCost: 100,
+		MaxTxs:        100,
+	}
+}
+
+func TestSelectTransactionsFeePerKb(t *testing.T) {
+	now := time.Now()
+	low := newSelectorTxDesc(1, 100, 2, nil, now)
+	high := newSelectorTxDesc(2, 300, 1, nil, now.Add(time.Second))
+	older := newSelectorTxDesc(3, 50, 1, nil, now.Add(-time.Second))
+
+	template := SelectTransactions([]*metadata.TxDesc{low, high, older}, newSelectorPolicy())
+	if len(template.Txs) != 3 {
+		t.Fatalf("expected 3 txs, got %+v", len(template.Txs))
+	}
+	if template.Txs[0].Desc != high {
+		t.Errorf("expected tx with highest fee per kb first")
+	}
+	// low and older both pay 50 per kb, the older one goes first
+	if template.Txs[1].Desc != older || template.Txs[2].Desc != low {
+		t.Errorf("expected older tx first when fee per kb is equal")
+	}
+	if template.Txs[0].Size != 1 || template.Txs[0].FeePerKb != 300 {
+		t.Errorf("expected size 1 kb and 300 fee per kb, got %+v %+v", template.Txs[0].Size, template.Txs[0].FeePerKb)
+	}
+	if template.TotalSize != 4 || template.TotalFee != 450 {
+		t.Errorf("unexpected totals size %+v fee %+v", template.TotalSize, template.TotalFee)
+	}
+}
+
+func TestSelectTransactionsDependencyGroup(t *testing.T) {
+	now := time.Now()
+	loanID := []byte("loan")
+	response := &metadata.LoanResponse{LoanID: loanID, Response: metadata.Accept, MetadataBase: *metadata.NewMetadataBase(metadata.LoanResponseMeta)}
+	withdraw := &metadata.LoanWithdraw{LoanID: loanID, MetadataBase: *metadata.NewMetadataBase(metadata.LoanWithdrawMeta)}
+
+	// withdraw is added first and pays well, but it is included after the response it depends on
+	withdrawDesc := newSelectorTxDesc(1, 1000, 1, withdraw, now)
+	responseDesc := newSelectorTxDesc(2, 0, 1, response, now.Add(time.Second))
+	other := newSelectorTxDesc(3, 400, 1, nil, now)
+
+	template := SelectTransactions([]*metadata.TxDesc{withdrawDesc, other, responseDesc}, newSelectorPolicy())
+	if len(template.Txs) != 3 {
+		t.Fatalf("expected 3 txs, got %+v", len(template.Txs))
+	}
+	// group pays 1000 for 2 kb, that is more than 400 per kb of other tx
+	if template.Txs[0].Desc != responseDesc || template.Txs[1].Desc != withdrawDesc || template.Txs[2].Desc != other {
+		t.Errorf("expected loan response, loan withdraw, then other tx")
+	}
+	if template.Txs[0].Group != template.Txs[1].Group || template.Txs[2].Group == template.Txs[0].Group {
+		t.Errorf("expected loan txs in the same group and other tx in its own group")
+	}
+
+	// group does not fit as a whole, so none of its txs are included
+	policy := newSelectorPolicy()
+	policy.MaxTxs = 2
+	template = SelectTransactions([]*metadata.TxDesc{withdrawDesc, other, responseDesc}, policy)
+	if len(template.Txs) != 0 || template.NumSkipped != 3 {
+		t.Errorf("expected group and other tx to be skipped, got %+v txs %+v skipped", len(template.Txs), template.NumSkipped)
+	}
+}
+
+func TestSelectTransactionsMaxBlockSize(t *testing.T) {
+	now := time.Now()
+	big := newSelectorTxDesc(1, 3000, 3, nil, now)
+	medium := newSelectorTxDesc(2, 1000, 2, nil, now)
+	small := newSelectorTxDesc(3, 100, 1, nil, now)
+
+	policy := newSelectorPolicy()
+	policy.MaxBlockSize = 4
+	template := SelectTransactions([]*metadata.TxDesc{small, medium, big}, policy)
+	if len(template.Txs) != 2 || template.Txs[0].Desc != big || template.Txs[1].Desc != small {
+		t.Fatalf("expected big and small txs to fill 4 kb block")
+	}
+	if template.TotalSize != 4 || template.NumSkipped != 1 {
+		t.Errorf("unexpected total size %+v skipped %+v", template.TotalSize, template.NumSkipped)
+	}
+
+	// default cap is in kilobytes, a few small txs must fit in it
+	template = SelectTransactions([]*metadata.TxDesc{small, medium, big}, DefaultTxSelectionPolicy())
+	if len(template.Txs) != 3 {
+		t.Errorf("expected all txs to fit default block size, got %+v", len(template.Txs))
+	}
+	if DefaultTxSelectionPolicy().MaxBlockSize != common.MaxBlockSize {
+		t.Errorf("expected default max block size %+v", common.MaxBlockSize)
+	}
+}
+
+func TestFitsMetadataLimits(t *testing.T) {
+	now := time.Now()
+	newWithdraw := func(lockTime int64, loanID string) *metadata.TxDesc {
+		withdraw := &metadata.LoanWithdraw{LoanID: []byte(loanID), MetadataBase: *metadata.NewMetadataBase(metadata.LoanWithdrawMeta)}
+		return newSelectorTxDesc(lockTime, 100, 1, withdraw, now)
+	}
+	group := &txDependencyGroup{txs: []*BlockTemplateTx{
+		newBlockTemplateTx(newWithdraw(1, "loan1")),
+		newBlockTemplateTx(newWithdraw(2, "loan2")),
+		newBlockTemplateTx(newSelectorTxDesc(3, 100, 1, nil, now)),
+	}}
+	tests := []struct {
+		numTxs map[int]int
+		limits map[int]int
+		fits   bool
+	}{
+		{map[int]int{}, nil, true},
+		{map[int]int{}, map[int]int{metadata.LoanWithdrawMeta: 2}, true},
+		{map[int]int{metadata.LoanWithdrawMeta: 1}, map[int]int{metadata.LoanWithdrawMeta: 2}, false},
+		{map[int]int{}, map[int]int{metadata.LoanWithdrawMeta: 1}, false},
+		{map[int]int{metadata.LoanWithdrawMeta: 5}, map[int]int{metadata.OracleFeedMeta: 1}, true},
+	}
+	for i, test := range tests {
+		if fits := group.fitsMetadataLimits(test.numTxs, test.limits); fits != test.fits {
+			t.Errorf("test %d: expected fits %v, got %v", i, test.fits, fits)
+		}
+	}
+}
+
+func TestSelectTransactionsMaxTxsPerMetadataType(t *testing.T) {
+	now := time.Now()
+	descs := []*metadata.TxDesc{}
+	for i := 0; i < 3; i++ {
+		withdraw := &metadata.LoanWithdraw{LoanID: []byte{byte(i)}, MetadataBase: *metadata.NewMetadataBase(metadata.LoanWithdrawMeta)}
+		descs = append(descs, newSelectorTxDesc(int64(i), uint64(300-i*100), 1, withdraw, now))
+	}
+	descs = append(descs, newSelectorTxDesc(4, 50, 1, nil, now))
+
+	policy := newSelectorPolicy()
+	policy.MaxTxsPerMetadataType = map[int]int{metadata.LoanWithdrawMeta: 2}
+	template := SelectTransactions(descs, policy)
+	if len(template.Txs) != 3 || template.NumSkipped != 1 {
+		t.Fatalf("expected 3 txs and 1 skipped, got %+v txs %+v skipped", len(template.Txs), template.NumSkipped)
+	}
+	// txs paying most are kept, the third loan withdraw is over the limit
+	if template.Txs[0].Desc != descs[0] || template.Txs[1].Desc != descs[1] || template.Txs[2].Desc != descs[3] {
+		t.Errorf("expected 2 loan withdraws paying most and tx without metadata")
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 // See loadConfig for details on the configuration load process.
 type config struct {
-	RPCPort int `long:"rpcport" short:"p" description:"Max number of RPC clients for standard connections"`
+	RPCPort  int    `long:"rpcport" short:"p" description:"Max number of RPC clients for standard connections"`
+	HTTPPort int    `long:"httpport" description:"Port of http status endpoint, it is disabled when it is 0"`
+	DataFile string `long:"datafile" description:"File which peers are persisted to, peers are only kept in memory when it is empty"`
 }
 
 // newConfigParser returns a new command line flags parser.
@@ -19,7 +21,9 @@ func newConfigParser(cfg *config, options flags.Options) *flags.Parser {
 
 func loadConfig() (*config, error) {
 	cfg := config{
-		RPCPort: RpcServerPort,
+		RPCPort:  RpcServerPort,
+		HTTPPort: HttpServerPort,
+		DataFile: DefaultDataFile,
 	}
 
 	preCfg := cfg
@@ -30,7 +34,8 @@ func loadConfig() (*config, error) {
 			fmt.Fprintln(os.Stderr, err)
 			return nil, err
 		}
+		return nil, err
 	}
 
-	return &cfg, nil
+	return &preCfg, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 const (
-	Version       = "1.0.0"
-	RpcServerPort = 9330
+	Version         = "1.0.0"
+	RpcServerPort   = 9330
+	HttpServerPort  = 9340
+	DefaultDataFile = "bootnode_peers.json"
 )
@@ -23,7 +23,8 @@ func main() {
 	cfg = tcfg
 
 	rpcConfig := server.RpcServerConfig{
-		Port: cfg.RPCPort,
+		Port:     cfg.RPCPort,
+		DataFile: cfg.DataFile,
 	}
 	server := &server.RpcServer{}
 	err = server.Init(&rpcConfig)
@@ -32,6 +33,12 @@ func main() {
 		return
 	}
 	log.Printf("Start server with config \n %+v", server.Config)
+	if cfg.HTTPPort != 0 {
+		go func() {
+			err := server.StartStatus(cfg.HTTPPort)
+			log.Println("Status server error", err)
+		}()
+	}
 	for {
 		server.Start()
 	}
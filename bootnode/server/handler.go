@@ -1,7 +1,7 @@
 package server
 
 import (
-	"fmt"
+	"log"
 
 	"github.com/ninjadotorg/constant/wire"
 )
@@ -14,19 +14,25 @@ type PingArgs struct {
 	RawAddress string
 	PublicKey  string
 	SignData   string
+	// Record is the signed record of node with its role and shard, it is nil for old nodes
+	Record *wire.RawPeer
+	// Query filters peers which are returned, all fresh peers are returned when it is empty
+	Query PeerQuery
 }
 
 func (s Handler) Ping(args *PingArgs, peers *[]wire.RawPeer) error {
-	fmt.Println("Ping", args)
+	log.Println("Ping", args.RawAddress, args.PublicKey)
 	// update peer information to server
-	s.server.AddOrUpdatePeer(args.RawAddress, args.PublicKey, args.SignData)
+	s.server.AddOrUpdatePeer(args.RawAddress, args.PublicKey, args.SignData, args.Record)
 	// return note list
-	s.server.peersMtx.Lock()
-	for _, p := range s.server.Peers {
-		*peers = append(*peers, wire.RawPeer{p.RawAddress, p.PublicKey})
-	}
-	s.server.peersMtx.Unlock()
-	fmt.Println("Response", *peers)
+	*peers = append(*peers, s.server.QueryPeers(&args.Query)...)
+	log.Println("Response", len(*peers), "peers")
 
 	return nil
 }
+
+// GetPeers returns peers which match a query without registering the caller
+func (s Handler) GetPeers(query *PeerQuery, peers *[]wire.RawPeer) error {
+	*peers = append(*peers, s.server.QueryPeers(query)...)
+	return nil
+}
@@ -1,19 +1,29 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/rpc"
+	"os"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/wire"
 )
 
 const (
 	heartbeatInterval = 5
 	heartbeatTimeout  = 60
+	// peers are saved to data file at this interval when they changed
+	saveInterval = 10
+	// MaxPeersPerResponse caps peers which are returned for a ping or a query
+	MaxPeersPerResponse = 1000
 )
 
 // timeZeroVal is simply the zero value for a time.Time and is used to avoid
@@ -30,24 +40,50 @@ type Peer struct {
 	PublicKey  string
 	FirstPing  time.Time
 	LastPing   time.Time
+	// role and shard which are signed by the peer in its ping, they are empty for old nodes
+	Role    string
+	ShardID *byte
+	// Record is the signed record of the peer which is returned to other nodes
+	Record *wire.RawPeer `json:",omitempty"`
+}
+
+// PeerQuery filters peers which are returned by a bootnode
+type PeerQuery struct {
+	Shards []byte   // peers of any of these shards, all shards when it is empty
+	Roles  []string // peers of any of these roles, all roles when it is empty
+	MaxAge int64    // peers which pinged in this number of seconds, heartbeat timeout when it is 0
+	Limit  int      // max number of peers, MaxPeersPerResponse when it is 0 or greater
 }
 
 // rpcServer provides a concurrent safe RPC server to a chain server.
 type RpcServer struct {
 	Peers    map[string]*Peer
 	peersMtx sync.Mutex
+	dirty    bool
 
 	Config RpcServerConfig
 }
 
 type RpcServerConfig struct {
 	Port int
+	// DataFile is where peers are persisted, peers are only kept in memory when it is empty
+	DataFile string
+	// Clock drives heartbeats and ping times of peers, system time is used when it is nil
+	Clock common.Clock
 }
 
 func (self *RpcServer) Init(config *RpcServerConfig) error {
 	self.Config = *config
+	if self.Config.Clock == nil {
+		self.Config.Clock = common.NewRealClock()
+	}
 	self.Peers = make(map[string]*Peer)
+	err := self.loadPeers()
+	if err != nil {
+		log.Println("Load peers error", err)
+	}
 	go self.PeerHeartBeat()
+	go self.saveHandler()
 	return nil
 }
 
@@ -63,18 +99,37 @@ func (self *RpcServer) Start() {
 	l.Close()
 }
 
-func (self *RpcServer) AddOrUpdatePeer(rawAddress string, publicKeyB58 string, signDataB58 string) {
+/*
+AddOrUpdatePeer - register a peer whose address is signed by its public key,
+role and shard are taken from its signed record when the record matches the address
+*/
+func (self *RpcServer) AddOrUpdatePeer(rawAddress string, publicKeyB58 string, signDataB58 string, record *wire.RawPeer) {
 	if signDataB58 != "" && publicKeyB58 != "" && rawAddress != "" {
 		err := cashec.ValidateDataB58(publicKeyB58, signDataB58, []byte(rawAddress))
 		if err == nil {
-			self.peersMtx.Lock()
-			self.Peers[publicKeyB58] = &Peer{
+			now := self.Config.Clock.Now().Local()
+			peer := &Peer{
 				ID:         self.CombineID(rawAddress, publicKeyB58),
 				RawAddress: rawAddress,
 				PublicKey:  publicKeyB58,
-				FirstPing:  time.Now().Local(),
-				LastPing:   time.Now().Local(),
+				FirstPing:  now,
+				LastPing:   now,
+			}
+			if record != nil {
+				if record.RawAddress == rawAddress && record.PublicKey == publicKeyB58 && record.VerifyRecord(now) == nil {
+					peer.Role = record.Role
+					peer.ShardID = record.ShardID
+					peer.Record = record
+				} else {
+					log.Println("AddOrUpdatePeer invalid record of", rawAddress)
+				}
 			}
+			self.peersMtx.Lock()
+			if oldPeer, ok := self.Peers[publicKeyB58]; ok && oldPeer.RawAddress == rawAddress {
+				peer.FirstPing = oldPeer.FirstPing
+			}
+			self.Peers[publicKeyB58] = peer
+			self.dirty = true
 			self.peersMtx.Unlock()
 		} else {
 			log.Println("AddOrUpdatePeer error", err)
@@ -83,25 +138,180 @@ func (self *RpcServer) AddOrUpdatePeer(rawAddress string, publicKeyB58 string, s
 }
 
 func (self *RpcServer) RemovePeerByPbk(publicKey string) {
+	self.peersMtx.Lock()
+	defer self.peersMtx.Unlock()
+	self.removePeerByPbk(publicKey)
+}
+
+// removePeerByPbk removes a peer, caller must hold the lock
+func (self *RpcServer) removePeerByPbk(publicKey string) {
 	delete(self.Peers, publicKey)
+	self.dirty = true
 }
 
 func (self *RpcServer) CombineID(rawAddress string, publicKey string) string {
 	return rawAddress + publicKey
 }
 
+// match returns whether a peer passes filters of query at now
+func (query *PeerQuery) match(peer *Peer, now time.Time) bool {
+	maxAge := query.MaxAge
+	if maxAge <= 0 || maxAge > heartbeatTimeout {
+		maxAge = heartbeatTimeout
+	}
+	if now.Sub(peer.LastPing).Seconds() > float64(maxAge) {
+		return false
+	}
+	if len(query.Roles) > 0 {
+		found := false
+		for _, role := range query.Roles {
+			if peer.Role == role {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(query.Shards) > 0 {
+		if peer.ShardID == nil {
+			return false
+		}
+		found := false
+		for _, shard := range query.Shards {
+			if *peer.ShardID == shard {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+QueryPeers - return peers which match query, most recently pinged peers first,
+signed records are returned so that nodes can verify role and shard of peers
+*/
+func (self *RpcServer) QueryPeers(query *PeerQuery) []wire.RawPeer {
+	if query == nil {
+		query = &PeerQuery{}
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > MaxPeersPerResponse {
+		limit = MaxPeersPerResponse
+	}
+	now := self.Config.Clock.Now().Local()
+
+	self.peersMtx.Lock()
+	matched := []*Peer{}
+	for _, peer := range self.Peers {
+		if query.match(peer, now) {
+			matched = append(matched, peer)
+		}
+	}
+	self.peersMtx.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].LastPing.After(matched[j].LastPing)
+	})
+	peers := []wire.RawPeer{}
+	for _, peer := range matched {
+		if len(peers) >= limit {
+			break
+		}
+		if peer.Record != nil {
+			peers = append(peers, *peer.Record)
+			continue
+		}
+		peers = append(peers, wire.RawPeer{RawAddress: peer.RawAddress, PublicKey: peer.PublicKey})
+	}
+	return peers
+}
+
 func (self *RpcServer) PeerHeartBeat() {
 	for {
-		now := time.Now().Local()
-		if len(self.Peers) > 0 {
-		loop:
-			for publicKey, peer := range self.Peers {
-				if now.Sub(peer.LastPing).Seconds() > heartbeatTimeout {
-					self.RemovePeerByPbk(publicKey)
-					goto loop
-				}
+		now := self.Config.Clock.Now().Local()
+		self.peersMtx.Lock()
+		for publicKey, peer := range self.Peers {
+			if now.Sub(peer.LastPing).Seconds() > heartbeatTimeout {
+				self.removePeerByPbk(publicKey)
 			}
 		}
-		time.Sleep(heartbeatInterval * time.Second)
+		self.peersMtx.Unlock()
+		self.Config.Clock.Sleep(heartbeatInterval * time.Second)
+	}
+}
+
+/*
+loadPeers - load peers of data file, last ping of each peer is reset to now
+so that peers have a heartbeat timeout to ping again before they are removed
+*/
+func (self *RpcServer) loadPeers() error {
+	if self.Config.DataFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(self.Config.DataFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	peers := []*Peer{}
+	err = json.Unmarshal(data, &peers)
+	if err != nil {
+		return err
+	}
+	now := self.Config.Clock.Now().Local()
+	self.peersMtx.Lock()
+	defer self.peersMtx.Unlock()
+	for _, peer := range peers {
+		peer.LastPing = now
+		self.Peers[peer.PublicKey] = peer
+	}
+	log.Printf("Loaded %d peers from %s\n", len(peers), self.Config.DataFile)
+	return nil
+}
+
+// savePeers writes peers to data file when they changed
+func (self *RpcServer) savePeers() error {
+	if self.Config.DataFile == "" {
+		return nil
+	}
+	self.peersMtx.Lock()
+	if !self.dirty {
+		self.peersMtx.Unlock()
+		return nil
+	}
+	peers := make([]*Peer, 0, len(self.Peers))
+	for _, peer := range self.Peers {
+		peers = append(peers, peer)
+	}
+	data, err := json.Marshal(peers)
+	self.dirty = false
+	self.peersMtx.Unlock()
+	if err != nil {
+		return err
+	}
+	// write a temporary file first so that a crash does not leave a truncated data file
+	tmpFile := self.Config.DataFile + ".tmp"
+	err = ioutil.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, self.Config.DataFile)
+}
+
+func (self *RpcServer) saveHandler() {
+	for {
+		self.Config.Clock.Sleep(saveInterval * time.Second)
+		err := self.savePeers()
+		if err != nil {
+			log.Println("Save peers error", err)
+		}
 	}
 }
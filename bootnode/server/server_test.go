@@ -0,0 +1,83 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+func newTestRpcServer(clock common.Clock, dataFile string) *RpcServer {
+	return &RpcServer{
+		Peers:  make(map[string]*Peer),
+		Config: RpcServerConfig{DataFile: dataFile, Clock: clock},
+	}
+}
+
+func TestPeersPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bootnode")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "peers.json")
+	clock := common.NewManualClock(time.Unix(1500000000, 0))
+
+	keySet := (&cashec.KeySet{}).GenerateKey([]byte("bootnode"))
+	rawAddress := "/ip4/1.2.3.4/tcp/9333"
+	signData, err := keySet.SignDataB58([]byte(rawAddress))
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	shardID := byte(3)
+	record := &wire.RawPeer{RawAddress: rawAddress, Role: "shard", ShardID: &shardID}
+	if err := record.Sign(keySet, clock.Now()); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	server := newTestRpcServer(clock, dataFile)
+	server.AddOrUpdatePeer(rawAddress, keySet.GetPublicKeyB58(), signData, record)
+	if len(server.Peers) != 1 {
+		t.Fatalf("expected a registered peer")
+	}
+	firstPing := server.Peers[keySet.GetPublicKeyB58()].FirstPing
+	if err := server.savePeers(); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if _, err := os.Stat(dataFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file to be renamed")
+	}
+
+	// bootnode restarts after heartbeat timeout of saved peers
+	clock.Advance(10 * heartbeatTimeout * time.Second)
+	restarted := newTestRpcServer(clock, dataFile)
+	if err := restarted.loadPeers(); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	peer, ok := restarted.Peers[keySet.GetPublicKeyB58()]
+	if !ok {
+		t.Fatalf("expected peer to be loaded")
+	}
+	if !peer.LastPing.Equal(clock.Now()) || !peer.FirstPing.Equal(firstPing) {
+		t.Errorf("expected last ping to be reset and first ping to be kept, got %+v", peer)
+	}
+	if peer.Role != "shard" || peer.ShardID == nil || *peer.ShardID != shardID || peer.Record == nil {
+		t.Errorf("expected role, shard and record to be kept, got %+v", peer)
+	}
+	peers := restarted.QueryPeers(&PeerQuery{Shards: []byte{shardID}})
+	if len(peers) != 1 || peers[0].SignDataB58 != record.SignDataB58 {
+		t.Errorf("expected loaded peer to be returned with its record, got %+v", peers)
+	}
+}
+
+func TestLoadPeersWithoutFile(t *testing.T) {
+	server := newTestRpcServer(common.NewManualClock(time.Unix(1500000000, 0)), filepath.Join(os.TempDir(), "bootnode-missing", "peers.json"))
+	if err := server.loadPeers(); err != nil || len(server.Peers) != 0 {
+		t.Errorf("expected no peer and no error, got %+v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// PeerStatus is a peer in status of bootnode
+type PeerStatus struct {
+	RawAddress string
+	PublicKey  string
+	Role       string
+	ShardID    *byte
+	FirstPing  time.Time
+	LastPing   time.Time
+}
+
+// Status is composition of network which is known by bootnode
+type Status struct {
+	TotalPeers int
+	Roles      map[string]int // number of peers by role, peers without role are counted as ""
+	Shards     map[byte]int   // number of peers by shard
+	Peers      []PeerStatus
+}
+
+// GetStatus returns status of all peers which are registered
+func (self *RpcServer) GetStatus() *Status {
+	status := &Status{
+		Roles:  make(map[string]int),
+		Shards: make(map[byte]int),
+		Peers:  []PeerStatus{},
+	}
+	self.peersMtx.Lock()
+	for _, peer := range self.Peers {
+		status.Roles[peer.Role]++
+		if peer.ShardID != nil {
+			status.Shards[*peer.ShardID]++
+		}
+		status.Peers = append(status.Peers, PeerStatus{
+			RawAddress: peer.RawAddress,
+			PublicKey:  peer.PublicKey,
+			Role:       peer.Role,
+			ShardID:    peer.ShardID,
+			FirstPing:  peer.FirstPing,
+			LastPing:   peer.LastPing,
+		})
+	}
+	self.peersMtx.Unlock()
+	status.TotalPeers = len(status.Peers)
+	sort.Slice(status.Peers, func(i, j int) bool {
+		return status.Peers[i].RawAddress < status.Peers[j].RawAddress
+	})
+	return status
+}
+
+func (self *RpcServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(self.GetStatus())
+	if err != nil {
+		log.Println("Status error", err)
+	}
+}
+
+// StartStatus serves status of network by http GET /status, it blocks until http server stops
+func (self *RpcServer) StartStatus(port int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", self.handleStatus)
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
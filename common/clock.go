@@ -0,0 +1,164 @@
+package common
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Clock is the source of time of node components, timestamps of blocks, timeouts of consensus,
+epochs and heartbeats are read from it, so that tests can drive them with a manual clock
+*/
+type Clock interface {
+	Now() time.Time
+	// After waits for duration to elapse and then sends current time on returned channel
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc waits for duration to elapse and then calls f in its own goroutine
+	AfterFunc(d time.Duration, f func()) Timer
+	Sleep(d time.Duration)
+}
+
+// Timer is a timer of a clock which is created by AfterFunc
+type Timer interface {
+	// Stop prevents timer from firing, it returns false if timer already fired or was stopped
+	Stop() bool
+}
+
+type realClock struct{}
+
+// NewRealClock returns the clock of system time
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+/*
+ManualClock - clock whose time only moves when it is advanced,
+timers of clock fire in order of their deadlines when time passes them
+*/
+type ManualClock struct {
+	now    time.Time
+	timers []*manualTimer
+	mtx    sync.Mutex
+}
+
+type manualTimer struct {
+	clock    *ManualClock
+	deadline time.Time
+	f        func()
+	c        chan time.Time
+}
+
+// NewManualClock returns a manual clock which starts at start
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (clock *ManualClock) Now() time.Time {
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	return clock.now
+}
+
+func (clock *ManualClock) After(d time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	clock.addTimer(d, nil, c)
+	return c
+}
+
+func (clock *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	return clock.addTimer(d, f, nil)
+}
+
+// Sleep blocks until clock is advanced by d
+func (clock *ManualClock) Sleep(d time.Duration) {
+	<-clock.After(d)
+}
+
+func (clock *ManualClock) addTimer(d time.Duration, f func(), c chan time.Time) *manualTimer {
+	clock.mtx.Lock()
+	timer := &manualTimer{
+		clock:    clock,
+		deadline: clock.now.Add(d),
+		f:        f,
+		c:        c,
+	}
+	if d > 0 {
+		clock.timers = append(clock.timers, timer)
+		clock.mtx.Unlock()
+		return timer
+	}
+	now := clock.now
+	clock.mtx.Unlock()
+	timer.fire(now)
+	return timer
+}
+
+// Advance moves clock forward by d and fires timers whose deadlines passed
+func (clock *ManualClock) Advance(d time.Duration) {
+	clock.mtx.Lock()
+	clock.now = clock.now.Add(d)
+	now := clock.now
+	due := []*manualTimer{}
+	timers := []*manualTimer{}
+	for _, timer := range clock.timers {
+		if timer.deadline.After(now) {
+			timers = append(timers, timer)
+			continue
+		}
+		due = append(due, timer)
+	}
+	clock.timers = timers
+	clock.mtx.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].deadline.Before(due[j].deadline)
+	})
+	for _, timer := range due {
+		timer.fire(now)
+	}
+}
+
+// Timers returns number of timers which wait for clock, tests use it to know that a component is waiting
+func (clock *ManualClock) Timers() int {
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	return len(clock.timers)
+}
+
+func (timer *manualTimer) fire(now time.Time) {
+	if timer.f != nil {
+		go timer.f()
+		return
+	}
+	timer.c <- now
+}
+
+func (timer *manualTimer) Stop() bool {
+	clock := timer.clock
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	for i, t := range clock.timers {
+		if t == timer {
+			clock.timers = append(clock.timers[:i], clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClockAdvance(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("expected clock to start at %v, got %v", start, clock.Now())
+	}
+	clock.Advance(time.Minute)
+	clock.Advance(time.Second)
+	if expected := start.Add(time.Minute + time.Second); !clock.Now().Equal(expected) {
+		t.Errorf("expected %v after advance, got %v", expected, clock.Now())
+	}
+}
+
+func TestManualClockAfter(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	c := clock.After(time.Minute)
+	if clock.Timers() != 1 {
+		t.Fatalf("expected 1 timer, got %d", clock.Timers())
+	}
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-c:
+		t.Fatalf("expected After not to fire before its deadline")
+	default:
+	}
+
+	clock.Advance(2 * time.Second)
+	select {
+	case now := <-c:
+		if !now.Equal(clock.Now()) {
+			t.Errorf("expected time of advance %v, got %v", clock.Now(), now)
+		}
+	default:
+		t.Fatalf("expected After to fire when its deadline passed")
+	}
+	if clock.Timers() != 0 {
+		t.Errorf("expected fired timer to be removed, got %d", clock.Timers())
+	}
+
+	// no duration fires right away
+	select {
+	case <-clock.After(0):
+	default:
+		t.Errorf("expected After of no duration to fire right away")
+	}
+}
+
+func TestManualClockTimers(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	fired := make(chan int, 3)
+	clock.AfterFunc(time.Second, func() { fired <- 1 })
+	stopped := clock.AfterFunc(2*time.Second, func() { fired <- 2 })
+	clock.AfterFunc(time.Hour, func() { fired <- 3 })
+	if clock.Timers() != 3 {
+		t.Fatalf("expected 3 timers, got %d", clock.Timers())
+	}
+
+	if !stopped.Stop() {
+		t.Errorf("expected waiting timer to be stopped")
+	}
+	if stopped.Stop() {
+		t.Errorf("expected stopped timer not to be stopped again")
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case f := <-fired:
+		if f != 1 {
+			t.Errorf("expected only the first timer to fire, got %d", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected timer to fire when its deadline passed")
+	}
+	select {
+	case f := <-fired:
+		t.Errorf("unexpected timer %d fired", f)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if clock.Timers() != 1 {
+		t.Errorf("expected 1 waiting timer, got %d", clock.Timers())
+	}
+
+	// Sleep returns once clock is advanced
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Second)
+		close(slept)
+	}()
+	for clock.Timers() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-slept:
+	case <-time.After(time.Second):
+		t.Fatalf("expected Sleep to return after advance")
+	}
+}
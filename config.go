@@ -12,12 +12,15 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/jessevdk/go-flags"
 	"github.com/ninjadotorg/constant/cashec"
 	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
 	"github.com/ninjadotorg/constant/wallet"
 )
 
@@ -35,7 +38,16 @@ const (
 	defaultMaxPeersOther      = 125
 	defaultMaxPeersNoShard    = 125
 	defaultMaxPeersBeacon     = 20
+	defaultBanThreshold       = 100
+	defaultBanDuration        = 24 * time.Hour
+	defaultMaxBlocksPerReq    = 100
+	defaultDandelionEpoch     = 10 * time.Minute
+	defaultDandelionFluff     = 0.1
+	defaultDandelionEmbargo   = 30 * time.Second
+	defaultMinCrossShardLinks = 0
 	defaultMaxRPCClients      = 10
+	defaultMaxRPCWebsockets   = 25
+	defaultMaxOrphanTxs       = 100
 	sampleConfigFilename      = "sample-config.conf"
 	defaultDisableRpcTLS      = true
 	defaultFastStartup        = true
@@ -74,7 +86,8 @@ type config struct {
 	MaxOutPeers          int      `long:"maxoutpeers" description:"Max number of outbound peers"`
 	MaxInPeers           int      `long:"maxinpeers" description:"Max number of inbound peers"`
 	DiscoverPeers        bool     `long:"discoverpeers" description:"Enable discover peers"`
-	DiscoverPeersAddress string   `long:"discoverpeersaddress" description:"Url to connect discover peers server"`
+	DiscoverPeersAddress string   `long:"discoverpeersaddress" description:"Comma separated urls of bootnodes, the next bootnode is used when a bootnode is down"`
+	DiscoverDHT          bool     `long:"dht" description:"Discover peers in libp2p kademlia DHT in addition to bootnodes and peer exchange"`
 	MaxPeersSameShard    int      `long:"maxpeersameshard" description:"Max peers in same shard for connection"`
 	MaxPeersOtherShard   int      `long:"maxpeerothershard" description:"Max peers in other shard for connection"`
 	MaxPeersOther        int      `long:"maxpeerother" description:"Max peers in other for connection"`
@@ -83,18 +96,44 @@ type config struct {
 
 	ExternalAddress string `long:"externaladdress" description:"External address"`
 
-	RPCDisableAuth bool     `long:"norpcauth" description:"Disable RPC authorization by username/password"`
-	RPCUser        string   `short:"u" long:"rpcuser" description:"Username for RPC connections"`
-	RPCPass        string   `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
-	RPCLimitUser   string   `long:"rpclimituser" description:"Username for limited RPC connections"`
-	RPCLimitPass   string   `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
-	RPCListeners   []string `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 9334, testnet: 9334)"`
-	RPCCert        string   `long:"rpccert" description:"File containing the certificate file"`
-	RPCKey         string   `long:"rpckey" description:"File containing the certificate key"`
-	RPCMaxClients  int      `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
-	RPCQuirks      bool     `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of coin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
-	DisableRPC     bool     `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
-	DisableTLS     bool     `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
+	BanThreshold int32         `long:"banthreshold" description:"Ban score which a misbehaving peer is disconnected and banned at"`
+	BanDuration  time.Duration `long:"banduration" description:"How long a misbehaving peer is banned, valid time units are {s, m, h}"`
+
+	RateLimits          []string `long:"ratelimit" description:"Limit messages of a type which are received from each peer by token bucket in format <command>:<rate per second>:<burst>, e.g. getblkshard:2:10"`
+	MaxBlocksPerRequest int      `long:"maxblocksperrequest" description:"Max number of blocks which are served for one get block request of a peer"`
+	rateLimits          map[string]peer.RateLimit
+
+	PinnedPeers         []string      `long:"pinpeer" description:"Peer which is always connected and never disconnected in format <shard>:<raw address>, it can be set multiple times"`
+	BeaconFullMesh      bool          `long:"beaconfullmesh" description:"Connect a beacon validator to every other member of beacon committee"`
+	MinCrossShardLinks  int           `long:"mincrossshardlinks" description:"Min number of connections to each other shard, no min is kept when it is 0"`
+	RotatePeersInterval time.Duration `long:"rotatepeers" description:"Interval at which a random peer is replaced by another one, peers are not rotated when it is 0"`
+	pinnedPeers         map[byte][]string
+
+	DisableDandelion bool          `long:"nodandelion" description:"Disable dandelion, transactions of this node are announced to all peers right away"`
+	DandelionEpoch   time.Duration `long:"dandelionepoch" description:"How long stem relay peers and phase of node are kept before they are chosen again, valid time units are {s, m, h}"`
+	DandelionFluff   float64       `long:"dandelionfluff" description:"Probability that node fluffs stem transactions in an epoch, between 0 and 1"`
+	DandelionEmbargo time.Duration `long:"dandelionembargo" description:"Base embargo of a stem transaction, it is fluffed by this node when it is not seen in fluff phase before embargo expires"`
+
+	RPCDisableAuth   bool     `long:"norpcauth" description:"Disable RPC authorization by username/password"`
+	RPCUser          string   `short:"u" long:"rpcuser" description:"Username for RPC connections"`
+	RPCPass          string   `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
+	RPCLimitUser     string   `long:"rpclimituser" description:"Username for limited RPC connections"`
+	RPCLimitPass     string   `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
+	RPCAPIKeys       []string `long:"rpcapikey" description:"Add an API key of a RPC role in format role:sha256 hex of key, roles are readonly, wallet, stability and admin"`
+	RPCRoleMethods   []string `long:"rpcrolemethods" description:"Replace allowed commands of a RPC role in format role:command1,command2 or role:* for all commands"`
+	RPCRateLimits    []string `long:"rpcratelimit" description:"Limit requests of each client of a RPC role in format role:requests per second:burst"`
+	RPCAuditLog      string   `long:"rpcauditlog" description:"File which calls of state changing RPC commands are appended to, they are written to log when it is empty"`
+	RPCListeners     []string `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 9334, testnet: 9334)"`
+	RPCCert          string   `long:"rpccert" description:"File containing the certificate file"`
+	RPCKey           string   `long:"rpckey" description:"File containing the certificate key"`
+	RPCMaxClients    int      `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
+	RPCMaxWebsockets int      `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections, websocket endpoint /ws is disabled when it is 0"`
+	RPCWSOrigins     []string `long:"rpcwsorigin" description:"Add an origin which browsers may open RPC websockets from (eg. https://explorer.example.com), only origin of RPC listener is allowed by default"`
+	RPCRest          bool     `long:"rpcrest" description:"Serve REST gateway of read-only chain data under /rest/ of RPC listeners"`
+	MetricsListen    string   `long:"metricslisten" description:"Interface/port to serve Prometheus metrics on at /metrics, metrics are disabled when it is empty (eg. 127.0.0.1:9335)"`
+	RPCQuirks        bool     `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of coin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
+	DisableRPC       bool     `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	DisableTLS       bool     `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 
 	Proxy     string `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser string `long:"proxyuser" description:"Username for proxy server"`
@@ -107,6 +146,8 @@ type config struct {
 	SpendingKey string `long:"spendingkey" description:"User spending key used for operation in consensus"`
 	NodeMode    string `long:"nodemode" description:"Role of this node (beacon/shard/wallet/relay | default role is 'relay' (relayshards must be set to run), 'auto' mode will switch between 'beacon' and 'shard')"`
 	RelayShards string `long:"relayshards" description:"set relay shards of this node when in 'relay' mode if noderole is auto then it only sync shard data when user is a shard producer/validator"`
+
+	CompactPropose bool `long:"compactpropose" description:"Propose shard blocks as compact blocks which are rebuilt from mempool of validators, every validator of shard must support compact blocks"`
 	// For Wallet
 	Wallet           bool   `long:"enablewallet" description:"Enable wallet"`
 	WalletName       string `long:"wallet" description:"Wallet Database Name file, default is 'wallet'"`
@@ -114,6 +155,9 @@ type config struct {
 	WalletAutoInit   bool   `long:"walletautoinit" description:"Init wallet automatically if not exist"`
 
 	FastStartup bool `long:"faststartup" description:"Load existed shard/chain dependencies instead of rebuild from block data"`
+
+	MaxOrphanTxs int      `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	TxPolicies   []string `long:"txpolicy" description:"Add a mempool rule for a metadata type -- format: <metadata type>:minfee=<n>,maxperblock=<n>,maxpersender=<n>,allow=<pubkey>|<pubkey>,deny=<pubkey>|<pubkey>"`
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -242,22 +286,22 @@ func removeDuplicateAddresses(addrs []string) []string {
 	return result
 }
 
-/*
-// loadConfig initializes and parses the config using a config file and command
-// line options.
-//
-// The configuration proceeds as follows:
-// 	1) Start with a default config with sane settings
-// 	2) Pre-parse the command line to check for an alternative config file
-// 	3) Load configuration file overwriting defaults with any specified options
-// 	4) Parse CLI options and overwrite/add any specified options
-//
-// The above results in Constant functioning properly without any config settings
-// while still allowing the user to override settings with config files and
-// command line options.  Command line options always take precedence.
-*/
-func loadConfig() (*config, []string, error) {
-	cfg := config{
+// parsePinnedPeer parses a pinned peer in format <shard>:<raw address>.
+func parsePinnedPeer(value string) (byte, string, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == common.EmptyString {
+		return 0, common.EmptyString, fmt.Errorf("pinned peer %s is not in format <shard>:<raw address>", value)
+	}
+	shardID, err := strconv.Atoi(parts[0])
+	if err != nil || shardID < 0 || shardID >= common.MAX_SHARD_NUMBER {
+		return 0, common.EmptyString, fmt.Errorf("shard of pinned peer %s is invalid", value)
+	}
+	return byte(shardID), parts[1], nil
+}
+
+// defaultConfig returns config with default settings which options of config file and command line override.
+func defaultConfig() config {
+	return config{
 		ConfigFile:         defaultConfigFile,
 		LogLevel:           defaultLogLevel,
 		MaxOutPeers:        defaultMaxPeers,
@@ -268,7 +312,11 @@ func loadConfig() (*config, []string, error) {
 		MaxPeersOther:      defaultMaxPeersOther,
 		MaxPeersNoShard:    defaultMaxPeersNoShard,
 		MaxPeersBeacon:     defaultMaxPeersBeacon,
+		BanThreshold:       defaultBanThreshold,
+		BanDuration:        defaultBanDuration,
 		RPCMaxClients:      defaultMaxRPCClients,
+		RPCMaxWebsockets:   defaultMaxRPCWebsockets,
+		MaxOrphanTxs:       defaultMaxOrphanTxs,
 		DataDir:            defaultDataDir,
 		DatabaseDir:        defaultDatabaseDirname,
 		LogDir:             defaultLogDir,
@@ -285,7 +333,30 @@ func loadConfig() (*config, []string, error) {
 		NodeMode:             defaultNodeMode,
 		SpendingKey:          common.EmptyString,
 		FastStartup:          defaultFastStartup,
+		MaxBlocksPerRequest:  defaultMaxBlocksPerReq,
+		DandelionEpoch:       defaultDandelionEpoch,
+		DandelionFluff:       defaultDandelionFluff,
+		DandelionEmbargo:     defaultDandelionEmbargo,
+		MinCrossShardLinks:   defaultMinCrossShardLinks,
 	}
+}
+
+/*
+// loadConfig initializes and parses the config using a config file and command
+// line options.
+//
+// The configuration proceeds as follows:
+// 	1) Start with a default config with sane settings
+// 	2) Pre-parse the command line to check for an alternative config file
+// 	3) Load configuration file overwriting defaults with any specified options
+// 	4) Parse CLI options and overwrite/add any specified options
+//
+// The above results in Constant functioning properly without any config settings
+// while still allowing the user to override settings with config files and
+// command line options.  Command line options always take precedence.
+*/
+func loadConfig() (*config, []string, error) {
+	cfg := defaultConfig()
 
 	// Service options which are only added on Windows.
 	serviceOpts := serviceOptions{}
@@ -428,6 +499,51 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Rate limits in config replace default limits of the same message type.
+	cfg.rateLimits = make(map[string]peer.RateLimit)
+	for command, limit := range peer.DEFAULT_RATE_LIMITS {
+		cfg.rateLimits[command] = limit
+	}
+	for _, value := range cfg.RateLimits {
+		command, limit, err := peer.ParseRateLimit(value)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err.Error())
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.rateLimits[command] = limit
+	}
+
+	// Pinned peers are grouped by shard and topology limits can not be negative.
+	cfg.pinnedPeers = make(map[byte][]string)
+	for _, value := range cfg.PinnedPeers {
+		shardID, rawAddress, err := parsePinnedPeer(value)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err.Error())
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.pinnedPeers[shardID] = append(cfg.pinnedPeers[shardID], rawAddress)
+	}
+	if cfg.MinCrossShardLinks < 0 || cfg.RotatePeersInterval < 0 {
+		str := "%s: the --mincrossshardlinks and --rotatepeers options may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Dandelion epoch and embargo must be positive and fluff probability is in [0, 1].
+	if cfg.DandelionEpoch <= 0 || cfg.DandelionEmbargo <= 0 || cfg.DandelionFluff < 0 || cfg.DandelionFluff > 1 {
+		str := "%s: the --dandelionepoch and --dandelionembargo options must be positive and --dandelionfluff must be between 0 and 1"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// --proxy or --connect without --listen disables listening.
 	if (cfg.Proxy != common.EmptyString || len(cfg.ConnectPeers) > 0) &&
 		len(cfg.Listener) == 0 {
@@ -459,10 +575,10 @@ func loadConfig() (*config, []string, error) {
 			return nil, nil, err
 		}
 
-		// The RPC server is disabled if no username or password is provided.
+		// The RPC server is disabled if no username or password or API key is provided.
 		if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-			(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
-			Logger.log.Info("The RPC server is disabled if no username or password is provided.")
+			(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") && len(cfg.RPCAPIKeys) == 0 {
+			Logger.log.Info("The RPC server is disabled if no username or password or API key is provided.")
 			cfg.DisableRPC = true
 		}
 	}
@@ -548,7 +664,7 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
-	if cfg.DiscoverPeers {
+	if cfg.DiscoverPeers && !cfg.DiscoverDHT {
 		if cfg.DiscoverPeersAddress == "" {
 			err := errors.New("discover peers server is empty")
 			return nil, nil, err
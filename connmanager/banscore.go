@@ -0,0 +1,81 @@
+package connmanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+)
+
+const (
+	// DefaultBanThreshold is the ban score which a peer is disconnected and banned at
+	DefaultBanThreshold = 100
+	// DefaultBanDuration is how long a misbehaving peer is banned
+	DefaultBanDuration = 24 * time.Hour
+)
+
+// BanList stores banned peers, it is implemented by address manager which persists them
+type BanList interface {
+	Ban(peerID string, publicKey string, rawAddress string, duration time.Duration, reason string)
+	Unban(peerID string) bool
+	IsBanned(peerID string, publicKey string) bool
+}
+
+func (connManager *ConnManager) banThreshold() int32 {
+	if connManager.Config.BanThreshold <= 0 {
+		return DefaultBanThreshold
+	}
+	return connManager.Config.BanThreshold
+}
+
+func (connManager *ConnManager) banDuration() time.Duration {
+	if connManager.Config.BanDuration <= 0 {
+		return DefaultBanDuration
+	}
+	return connManager.Config.BanDuration
+}
+
+/*
+handleMisbehaved - disconnect and ban a peer when its ban score reaches ban threshold
+*/
+func (connManager *ConnManager) handleMisbehaved(peerConn *peer.PeerConn, offense int, reason string) {
+	score := peerConn.GetBanScore()
+	if score < connManager.banThreshold() {
+		return
+	}
+	reason = fmt.Sprintf("ban score %d reached threshold, last offense %s: %s", score, peer.OffenseName(offense), reason)
+	connManager.BanPeer(peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.PublicKey, peerConn.RemoteRawAddress, connManager.banDuration(), reason)
+}
+
+/*
+BanPeer - ban a peer for duration and disconnect all of its connections,
+connections of the same public key are also closed
+*/
+func (connManager *ConnManager) BanPeer(peerID string, publicKey string, rawAddress string, duration time.Duration, reason string) {
+	Logger.log.Warnf("Ban peer %s for %s: %s", peerID, duration.String(), reason)
+	if connManager.Config.BanList != nil {
+		connManager.Config.BanList.Ban(peerID, publicKey, rawAddress, duration, reason)
+	}
+	for _, peerConn := range connManager.GetPeerConnOfAll() {
+		if peerConn.RemotePeerID.Pretty() == peerID || (publicKey != common.EmptyString && peerConn.RemotePeer.PublicKey == publicKey) {
+			peerConn.ForceClose()
+		}
+	}
+}
+
+// UnbanPeer removes ban of a peer, it returns false when peer is not banned
+func (connManager *ConnManager) UnbanPeer(peerID string) bool {
+	if connManager.Config.BanList == nil {
+		return false
+	}
+	return connManager.Config.BanList.Unban(peerID)
+}
+
+// IsBanned returns whether a peer id or public key is banned
+func (connManager *ConnManager) IsBanned(peerID string, publicKey string) bool {
+	if connManager.Config.BanList == nil {
+		return false
+	}
+	return connManager.Config.BanList.IsBanned(peerID, publicKey)
+}
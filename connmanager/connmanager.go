@@ -1,20 +1,17 @@
 package connmanager
 
 import (
+	"context"
 	"fmt"
 	"math"
-	"net"
-	"net/rpc"
-	"os"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	discovery "github.com/libp2p/go-libp2p-discovery"
 	libpeer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	ma "github.com/multiformats/go-multiaddr"
-	"github.com/ninjadotorg/constant/bootnode/server"
 	"github.com/ninjadotorg/constant/common"
 	"github.com/ninjadotorg/constant/peer"
 	"github.com/ninjadotorg/constant/wire"
@@ -93,6 +90,11 @@ type ConnManager struct {
 	ListeningPeer *peer.Peer
 
 	randShards []byte
+	// bootnodeIndex is the bootnode which responded last time, it is used atomically
+	bootnodeIndex int32
+	// routingDiscovery finds peers in kademlia dht, it is nil when dht is disabled
+	routingDiscovery *discovery.RoutingDiscovery
+	cancelDHT        context.CancelFunc
 }
 
 type Config struct {
@@ -126,6 +128,25 @@ type Config struct {
 	DiscoverPeers        bool
 	DiscoverPeersAddress string
 	ConsensusState       *ConsensusState
+
+	// BanThreshold is the ban score which a misbehaving peer is banned at
+	BanThreshold int32
+	// BanDuration is how long a peer is banned
+	BanDuration time.Duration
+	// BanList stores banned peers, peers are never banned when it is nil
+	BanList BanList
+	// AddrBook records connection attempts of known addresses and provides records of peer exchange, it may be nil
+	AddrBook AddrBook
+	// DiscoverDHT enables discovery of peers in kademlia dht
+	DiscoverDHT bool
+	// Topology decides which peers node keeps connections to, default policy is used when it is nil
+	Topology TopologyPolicy
+}
+
+// AddrBook keeps quality of known addresses, it is implemented by address manager
+type AddrBook interface {
+	Attempt(rawAddress string)
+	KnownRecords(max int) []wire.RawPeer
 }
 
 type DiscoverPeerInfo struct {
@@ -210,6 +231,9 @@ func (connManager *ConnManager) Stop() {
 	if connManager.cDiscoveredPeers != nil {
 		close(connManager.cDiscoveredPeers)
 	}
+	if connManager.cancelDHT != nil {
+		connManager.cancelDHT()
+	}
 
 	close(connManager.cQuit)
 	Logger.log.Warn("Connection manager stopped")
@@ -222,10 +246,18 @@ func (connManager ConnManager) New(cfg *Config) *ConnManager {
 	connManager.ListeningPeer = nil
 	connManager.Config.ConsensusState = &ConsensusState{}
 	connManager.cDiscoveredPeers = make(chan struct{})
+	if connManager.Config.Topology == nil {
+		connManager.Config.Topology = NewTopologyPolicy(TopologyConfig{})
+	}
 	return &connManager
 }
 
 func (connManager *ConnManager) GetPeerId(addr string) string {
+	return peerIDOfRawAddress(addr)
+}
+
+// peerIDOfRawAddress returns pretty peer id of a raw address, it is empty when address has no valid peer id
+func peerIDOfRawAddress(addr string) string {
 	ipfsAddr, err := ma.NewMultiaddr(addr)
 	if err != nil {
 		Logger.log.Error(err)
@@ -280,6 +312,15 @@ func (connManager *ConnManager) Connect(addr string, pubKey string, cConn chan *
 	listen.HandleConnected = connManager.handleConnected
 	listen.HandleDisconnected = connManager.handleDisconnected
 	listen.HandleFailed = connManager.handleFailed
+	listen.HandleMisbehaved = connManager.handleMisbehaved
+
+	if connManager.IsBanned(peerId.Pretty(), pubKey) {
+		Logger.log.Infof("Skip connecting to banned peer %s", peerId.Pretty())
+		return
+	}
+	if connManager.Config.AddrBook != nil {
+		connManager.Config.AddrBook.Attempt(addr)
+	}
 
 	peer := peer.Peer{
 		TargetAddress:      targetAddr,
@@ -320,10 +361,17 @@ func (connManager *ConnManager) Start(discoverPeerAddress string) {
 		listner.HandleConnected = connManager.handleConnected
 		listner.HandleDisconnected = connManager.handleDisconnected
 		listner.HandleFailed = connManager.handleFailed
+		listner.HandleMisbehaved = connManager.handleMisbehaved
 		go connManager.listenHandler(listner)
 		connManager.ListeningPeer = listner
 
-		if connManager.Config.DiscoverPeers && connManager.Config.DiscoverPeersAddress != common.EmptyString {
+		if connManager.Config.DiscoverPeers {
+			if connManager.Config.DiscoverDHT {
+				err := connManager.startDHT()
+				if err != nil {
+					Logger.log.Error(err)
+				}
+			}
 			Logger.log.Infof("DiscoverPeers: true\n----------------------------------------------------------------\n|               Discover peer url: %s               |\n----------------------------------------------------------------", connManager.Config.DiscoverPeersAddress)
 			go connManager.DiscoverPeers(discoverPeerAddress)
 		}
@@ -364,6 +412,7 @@ func (connManager *ConnManager) DiscoverPeers(discoverPeerAddress string) {
 	connManager.discoverPeerAddress = discoverPeerAddress
 	for {
 		connManager.processDiscoverPeers()
+		connManager.discoverDHTPeers()
 		select {
 		case <-connManager.cDiscoveredPeers:
 			Logger.log.Info("Stop Discover Peers")
@@ -375,97 +424,47 @@ func (connManager *ConnManager) DiscoverPeers(discoverPeerAddress string) {
 }
 
 func (connManager *ConnManager) processDiscoverPeers() {
-	discoverPeerAddress := connManager.discoverPeerAddress
-	if discoverPeerAddress == "" {
-		return
-	}
-	client, err := rpc.Dial("tcp", discoverPeerAddress)
-	if err != nil {
-		Logger.log.Error("[Exchange Peers] re-connect:")
-		Logger.log.Error(err)
-		return
-	}
-	if client != nil {
-		listener := connManager.Config.ListenerPeer
-		var response []wire.RawPeer
-
-		externalAddress := connManager.Config.ExternalAddress
-		Logger.log.Info("Start Process Discover Peers ExternalAddress", externalAddress)
-
-		// remove later
-		rawAddress := listener.RawAddress
-		rawPort := listener.Port
-		if externalAddress == common.EmptyString {
-			externalAddress = os.Getenv("EXTERNAL_ADDRESS")
-		}
-		if externalAddress != common.EmptyString {
-			host, port, err := net.SplitHostPort(externalAddress)
-			if err == nil && host != common.EmptyString {
-				rawAddress = strings.Replace(rawAddress, "127.0.0.1", host, 1)
-				rawAddress = strings.Replace(rawAddress, "0.0.0.0", host, 1)
-				rawAddress = strings.Replace(rawAddress, "localhost", host, 1)
-				rawAddress = strings.Replace(rawAddress, fmt.Sprintf("/%s/", rawPort), fmt.Sprintf("/%s/", port), 1)
+	listener := connManager.Config.ListenerPeer
+	Logger.log.Info("Dump PeerConns", len(listener.PeerConns))
+	for pubK, info := range connManager.discoveredPeers {
+		var result []string
+		for _, peerConn := range listener.PeerConns {
+			if peerConn.RemotePeer.PublicKey == pubK {
+				result = append(result, peerConn.RemotePeer.PeerID.Pretty())
 			}
-		} else {
-			rawAddress = ""
 		}
+		Logger.log.Infof("Public PubKey %s, %s, %s", pubK, info.PeerID.Pretty(), result)
+	}
 
-		pbkB58 := ""
-		signDataB58 := ""
-		if listener.Config.UserKeySet != nil {
-			pbkB58 = listener.Config.UserKeySet.GetPublicKeyB58()
-			Logger.log.Info("Start Process Discover Peers", pbkB58)
-			// sign data
-			signDataB58, err = listener.Config.UserKeySet.SignDataB58([]byte(rawAddress))
-			if err != nil {
-				Logger.log.Error(err)
-			}
-		}
+	for _, peerConn := range listener.PeerConns {
+		Logger.log.Info("PeerConn state %s %s %s", peerConn.ConnState(), peerConn.GetIsOutbound(), peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.RawAddress)
+	}
 
-		args := &server.PingArgs{
-			RawAddress: rawAddress,
-			PublicKey:  pbkB58,
-			SignData:   signDataB58,
-		}
-		Logger.log.Infof("[Exchange Peers] Ping %+v", args)
-
-		Logger.log.Info("Dump PeerConns", len(listener.PeerConns))
-		for pubK, info := range connManager.discoveredPeers {
-			var result []string
-			for _, peerConn := range listener.PeerConns {
-				if peerConn.RemotePeer.PublicKey == pubK {
-					result = append(result, peerConn.RemotePeer.PeerID.Pretty())
-				}
+	// make models, records of peer exchange are overridden by records of bootnodes
+	mPeers := make(map[string]*wire.RawPeer)
+	if connManager.Config.AddrBook != nil {
+		for _, rawPeer := range connManager.Config.AddrBook.KnownRecords(wire.MaxAddrPerMsg) {
+			// public key of an unsigned record is not trusted
+			if !rawPeer.IsSigned() {
+				continue
 			}
-			Logger.log.Infof("Public PubKey %s, %s, %s", pubK, info.PeerID.Pretty(), result)
-		}
-
-		for _, peerConn := range listener.PeerConns {
-			Logger.log.Info("PeerConn state %s %s %s", peerConn.ConnState(), peerConn.GetIsOutbound(), peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.RawAddress)
-		}
-
-		err := client.Call("Handler.Ping", args, &response)
-		if err != nil {
-			Logger.log.Error("[Exchange Peers] Ping:")
-			Logger.log.Error(err)
-			client = nil
-			return
-		}
-		// make models
-		mPeers := make(map[string]*wire.RawPeer)
-		for _, rawPeer := range response {
 			p := rawPeer
 			mPeers[rawPeer.PublicKey] = &p
 		}
-		// connect to beacon peers
-		connManager.handleRandPeersOfBeacon(connManager.Config.MaxPeersBeacon, mPeers)
-		// connect to same shard peers
-		connManager.handleRandPeersOfShard(connManager.Config.ConsensusState.CurrentShard, connManager.Config.MaxPeersSameShard, mPeers)
-		// connect to other shard peers
-		connManager.handleRandPeersOfOtherShard(connManager.Config.ConsensusState.CurrentShard, connManager.Config.MaxPeersOtherShard, connManager.Config.MaxPeersOther, mPeers)
-		// connect to no shard peers
-		connManager.handleRandPeersOfNoShard(connManager.Config.MaxPeersNoShard, mPeers)
 	}
+	response, err := connManager.pingBootnodes()
+	if err != nil {
+		Logger.log.Error("[Exchange Peers] Ping:")
+		Logger.log.Error(err)
+	}
+	for _, rawPeer := range response {
+		p := rawPeer
+		mPeers[rawPeer.PublicKey] = &p
+	}
+	// ask a peer for addresses so that records of peer exchange are refreshed
+	connManager.requestAddresses()
+
+	connManager.Config.Topology.Apply(connManager, mPeers)
 }
 
 func (connManager *ConnManager) getPeerIdsFromPbk(pbk string) []libpeer.ID {
@@ -534,10 +533,13 @@ func (connManager *ConnManager) checkBeaconOfPbk(pbk string) bool {
 	return false
 }
 
+// closePeerConnOfShard closes connections of a shard except those which topology protects
 func (connManager *ConnManager) closePeerConnOfShard(shard byte) {
 	cPeers := connManager.getPeerConnOfShard(&shard)
 	for _, p := range cPeers {
-		p.ForceClose()
+		if !connManager.protected(p) {
+			p.ForceClose()
+		}
 	}
 }
 
@@ -553,7 +555,9 @@ func (connManager *ConnManager) handleRandPeersOfShard(shard *byte, maxPeers int
 			cPeers := connManager.getPeerConnOfShard(shard)
 			lPeers := len(cPeers)
 			for idx := maxPeers; idx < lPeers; idx++ {
-				cPeers[idx].ForceClose()
+				if !connManager.protected(cPeers[idx]) {
+					cPeers[idx].ForceClose()
+				}
 			}
 		}
 		return maxPeers
@@ -579,13 +583,18 @@ func (connManager *ConnManager) handleRandPeersOfShard(shard *byte, maxPeers int
 	return countPeerShard
 }
 
-func (connManager *ConnManager) handleRandPeersOfOtherShard(cShard *byte, maxShardPeers int, maxPeers int, mPeers map[string]*wire.RawPeer) int {
+/*
+handleRandPeersOfOtherShard - connect to peers of other shards up to maxPeers in total and maxShardPeers per shard,
+every shard keeps at least minShardPeers connections even when maxPeers is reached
+*/
+func (connManager *ConnManager) handleRandPeersOfOtherShard(cShard *byte, maxShardPeers int, maxPeers int, minShardPeers int, mPeers map[string]*wire.RawPeer) int {
 	//Logger.log.Info("handleRandPeersOfOtherShard", maxShardPeers, maxPeers)
 	countPeers := 0
 	for _, shard := range connManager.randShards {
 		if cShard == nil || (cShard != nil && *cShard != shard) {
 			if countPeers < maxPeers {
 				mP := int(math.Min(float64(maxShardPeers), float64(maxPeers-countPeers)))
+				mP = int(math.Max(float64(mP), float64(minShardPeers)))
 				cPeer := connManager.handleRandPeersOfShard(&shard, mP, mPeers)
 				countPeers += cPeer
 				if countPeers >= maxPeers {
@@ -593,7 +602,11 @@ func (connManager *ConnManager) handleRandPeersOfOtherShard(cShard *byte, maxSha
 				}
 			}
 			if countPeers >= maxPeers {
-				connManager.closePeerConnOfShard(shard)
+				if minShardPeers > 0 {
+					countPeers += connManager.handleRandPeersOfShard(&shard, minShardPeers, mPeers)
+				} else {
+					connManager.closePeerConnOfShard(shard)
+				}
 			}
 		}
 	}
@@ -667,6 +680,14 @@ func (connManager *ConnManager) CheckForAcceptConn(peerConn *peer.PeerConn) bool
 	if peerConn == nil {
 		return false
 	}
+	if connManager.IsBanned(peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.PublicKey) {
+		Logger.log.Infof("Reject connection of banned peer %s", peerConn.RemotePeerID.Pretty())
+		return false
+	}
+	// pinned peers and peers which topology needs are accepted over limits
+	if connManager.protected(peerConn) {
+		return true
+	}
 	// check max shard conn
 	sh := connManager.getShardOfPbk(peerConn.RemotePeer.PublicKey)
 	currentShard := connManager.Config.ConsensusState.CurrentShard
@@ -700,6 +721,19 @@ func (connManager *ConnManager) getShardOfPbk(pbk string) *byte {
 	return nil
 }
 
+// GetRoleShardOfPbk returns role and shard of a public key in committees, role is empty when it is not in a committee
+func (connManager *ConnManager) GetRoleShardOfPbk(pbk string) (string, *byte) {
+	if connManager.checkBeaconOfPbk(pbk) {
+		return common.BEACON_ROLE, nil
+	}
+	connManager.Config.ConsensusState.Lock()
+	defer connManager.Config.ConsensusState.Unlock()
+	if shard := connManager.getShardOfPbk(pbk); shard != nil {
+		return common.SHARD_ROLE, shard
+	}
+	return common.EmptyString, nil
+}
+
 func (connManager *ConnManager) GetCurrentRoleShard() (string, *byte) {
 	return connManager.Config.ConsensusState.Role, connManager.Config.ConsensusState.CurrentShard
 }
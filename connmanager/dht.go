@@ -0,0 +1,80 @@
+package connmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	discovery "github.com/libp2p/go-libp2p-discovery"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/ninjadotorg/constant/common"
+)
+
+const (
+	// DHTRendezvous is the namespace which nodes advertise themselves at in dht
+	DHTRendezvous = "constant/peers"
+	// DefaultDHTPeers is the number of connections which node keeps by connecting to peers found in dht
+	DefaultDHTPeers = 8
+	// peers are looked up in dht for at most this duration in a discovery round
+	dhtFindPeersTimeout = 30 * time.Second
+)
+
+/*
+startDHT - join kademlia dht through connected peers and advertise this node at DHTRendezvous,
+peers which are found in dht are connected without a bootnode
+*/
+func (connManager *ConnManager) startDHT() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	kadDHT, err := dht.New(ctx, connManager.Config.ListenerPeer.Host)
+	if err != nil {
+		cancel()
+		return err
+	}
+	err = kadDHT.Bootstrap(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	connManager.cancelDHT = cancel
+	connManager.routingDiscovery = discovery.NewRoutingDiscovery(kadDHT)
+	discovery.Advertise(ctx, connManager.routingDiscovery, DHTRendezvous)
+	Logger.log.Info("DHT discovery started")
+	return nil
+}
+
+/*
+discoverDHTPeers - connect to peers which advertise themselves in dht
+when node has fewer than DefaultDHTPeers connections
+*/
+func (connManager *ConnManager) discoverDHTPeers() {
+	if connManager.routingDiscovery == nil {
+		return
+	}
+	listener := connManager.Config.ListenerPeer
+	count := len(connManager.GetPeerConnOfAll())
+	if count >= DefaultDHTPeers {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dhtFindPeersTimeout)
+	defer cancel()
+	peerChan, err := connManager.routingDiscovery.FindPeers(ctx, DHTRendezvous)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	for peerInfo := range peerChan {
+		if count >= DefaultDHTPeers {
+			break
+		}
+		if peerInfo.ID == listener.PeerID || len(peerInfo.Addrs) == 0 {
+			continue
+		}
+		if listener.GetPeerConnByPeerID(peerInfo.ID.Pretty()) != nil {
+			continue
+		}
+		rawAddress := fmt.Sprintf("%s/ipfs/%s", peerInfo.Addrs[0].String(), peerInfo.ID.Pretty())
+		Logger.log.Infof("Connect to peer %s which is found in DHT", rawAddress)
+		go connManager.Connect(rawAddress, common.EmptyString, nil)
+		count++
+	}
+}
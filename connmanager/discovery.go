@@ -0,0 +1,157 @@
+package connmanager
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ninjadotorg/constant/bootnode/server"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	// a bootnode which does not accept connection in this timeout is skipped for the next one
+	bootnodeDialTimeout = 10 * time.Second
+)
+
+// bootnodes returns addresses of bootnodes, discover peers address is a comma separated list
+func (connManager *ConnManager) bootnodes() []string {
+	bootnodes := []string{}
+	for _, address := range strings.Split(connManager.discoverPeerAddress, ",") {
+		address = strings.TrimSpace(address)
+		if address != common.EmptyString {
+			bootnodes = append(bootnodes, address)
+		}
+	}
+	return bootnodes
+}
+
+/*
+ExternalRawAddress - return raw address which other nodes connect to this node at,
+host and port of listener are replaced by external address, it is empty when external address is not known
+*/
+func (connManager *ConnManager) ExternalRawAddress() string {
+	listener := connManager.Config.ListenerPeer
+	externalAddress := connManager.Config.ExternalAddress
+	if externalAddress == common.EmptyString {
+		externalAddress = os.Getenv("EXTERNAL_ADDRESS")
+	}
+	if externalAddress == common.EmptyString {
+		return common.EmptyString
+	}
+	rawAddress := listener.RawAddress
+	host, port, err := net.SplitHostPort(externalAddress)
+	if err == nil && host != common.EmptyString {
+		rawAddress = strings.Replace(rawAddress, "127.0.0.1", host, 1)
+		rawAddress = strings.Replace(rawAddress, "0.0.0.0", host, 1)
+		rawAddress = strings.Replace(rawAddress, "localhost", host, 1)
+		rawAddress = strings.Replace(rawAddress, fmt.Sprintf("/%s/", listener.Port), fmt.Sprintf("/%s/", port), 1)
+	}
+	return rawAddress
+}
+
+// makePingArgs returns external address of node which is signed by its key set
+func (connManager *ConnManager) makePingArgs() *server.PingArgs {
+	listener := connManager.Config.ListenerPeer
+	rawAddress := connManager.ExternalRawAddress()
+	Logger.log.Info("Start Process Discover Peers ExternalAddress", rawAddress)
+
+	pbkB58 := ""
+	signDataB58 := ""
+	var record *wire.RawPeer
+	if listener.Config.UserKeySet != nil {
+		pbkB58 = listener.Config.UserKeySet.GetPublicKeyB58()
+		Logger.log.Info("Start Process Discover Peers", pbkB58)
+		// sign data
+		var err error
+		signDataB58, err = listener.Config.UserKeySet.SignDataB58([]byte(rawAddress))
+		if err != nil {
+			Logger.log.Error(err)
+		}
+		// signed record lets bootnode know role and shard of node
+		role, shardID := connManager.GetCurrentRoleShard()
+		record = &wire.RawPeer{
+			RawAddress: rawAddress,
+			Role:       role,
+		}
+		if shardID != nil {
+			shard := *shardID
+			record.ShardID = &shard
+		}
+		err = record.Sign(listener.Config.UserKeySet, time.Now())
+		if err != nil {
+			Logger.log.Error(err)
+			record = nil
+		}
+	}
+	return &server.PingArgs{
+		RawAddress: rawAddress,
+		PublicKey:  pbkB58,
+		SignData:   signDataB58,
+		Record:     record,
+	}
+}
+
+// pingBootnode registers node at a bootnode and returns peers which it knows
+func pingBootnode(address string, args *server.PingArgs) ([]wire.RawPeer, error) {
+	conn, err := net.DialTimeout("tcp", address, bootnodeDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	client := rpc.NewClient(conn)
+	defer client.Close()
+	var response []wire.RawPeer
+	err = client.Call("Handler.Ping", args, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+/*
+pingBootnodes - ping bootnodes in order starting from the one which responded last time,
+the next bootnode is asked when a bootnode is down so that node can join while any of them is up
+*/
+func (connManager *ConnManager) pingBootnodes() ([]wire.RawPeer, error) {
+	bootnodes := connManager.bootnodes()
+	if len(bootnodes) == 0 {
+		return nil, nil
+	}
+	args := connManager.makePingArgs()
+	Logger.log.Infof("[Exchange Peers] Ping %+v", args)
+	start := int(atomic.LoadInt32(&connManager.bootnodeIndex))
+	var lastErr error
+	for i := 0; i < len(bootnodes); i++ {
+		index := (start + i) % len(bootnodes)
+		response, err := pingBootnode(bootnodes[index], args)
+		if err != nil {
+			Logger.log.Warnf("[Exchange Peers] Bootnode %s failed: %+v", bootnodes[index], err)
+			lastErr = err
+			continue
+		}
+		atomic.StoreInt32(&connManager.bootnodeIndex, int32(index))
+		return response, nil
+	}
+	return nil, lastErr
+}
+
+// requestAddresses asks a random connected peer for its known addresses
+func (connManager *ConnManager) requestAddresses() {
+	peerConns := connManager.GetPeerConnOfAll()
+	if len(peerConns) == 0 {
+		return
+	}
+	msg, err := wire.MakeEmptyMessage(wire.CmdGetAddr)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	peerConn := peerConns[common.RandInt()%len(peerConns)]
+	go peerConn.QueueMessageWithEncoding(msg, nil, peer.MESSAGE_TO_PEER, nil)
+}
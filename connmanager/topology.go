@@ -0,0 +1,322 @@
+package connmanager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+/*
+TopologyPolicy decides which peers node keeps connections to,
+it is applied each time peers are discovered and when consensus state of node changes
+*/
+type TopologyPolicy interface {
+	// Apply connects to and disconnects from peers to meet targets of policy, mPeers are known peers by public key
+	Apply(connManager *ConnManager, mPeers map[string]*wire.RawPeer)
+	// Protected returns whether a connection must be kept even when limits of peers are reached
+	Protected(connManager *ConnManager, peerConn *peer.PeerConn) bool
+	// Status reports connections of node against targets of policy
+	Status(connManager *ConnManager) *TopologyStatus
+}
+
+// TopologyConfig is config of the default topology policy, zero value keeps random peers by maxpeer* limits only
+type TopologyConfig struct {
+	// PinnedPeers are raw addresses by shard which node always connects to and never disconnects
+	PinnedPeers map[byte][]string
+	// BeaconFullMesh makes a beacon validator connect to every other member of beacon committee
+	BeaconFullMesh bool
+	// MinCrossShardLinks is the min number of connections to each other shard, no min is kept when it is 0
+	MinCrossShardLinks int
+	// RotationInterval is the interval at which a random peer is replaced, peers are not rotated when it is 0
+	RotationInterval time.Duration
+}
+
+// TopologyTarget is the number of connections of a group of peers against its target
+type TopologyTarget struct {
+	Target    int
+	Connected int
+}
+
+// PinnedPeerStatus is connection state of a pinned peer
+type PinnedPeerStatus struct {
+	ShardID    byte
+	RawAddress string
+	Connected  bool
+}
+
+// TopologyStatus reports connections of node against targets of topology policy
+type TopologyStatus struct {
+	Role         string
+	ShardID      *byte
+	Beacon       TopologyTarget
+	SameShard    TopologyTarget
+	OtherShards  map[byte]TopologyTarget // target of other shards is min cross shard links
+	NoShard      TopologyTarget          // target of peers without shard is a max
+	PinnedPeers  []PinnedPeerStatus
+	LastRotation time.Time
+	MeetsTargets bool // all pinned peers are connected and no min target is missed
+}
+
+type defaultTopology struct {
+	config       TopologyConfig
+	pinnedIDs    map[string]byte // peer id of pinned peer -> shard
+	lastRotation time.Time
+	mtx          sync.Mutex
+}
+
+// NewTopologyPolicy returns the default topology policy
+func NewTopologyPolicy(config TopologyConfig) TopologyPolicy {
+	topology := &defaultTopology{
+		config:       config,
+		pinnedIDs:    make(map[string]byte),
+		lastRotation: time.Now(),
+	}
+	for shard, rawAddresses := range config.PinnedPeers {
+		for _, rawAddress := range rawAddresses {
+			peerID := peerIDOfRawAddress(rawAddress)
+			if peerID == common.EmptyString {
+				Logger.log.Errorf("Pinned peer %s has no peer id", rawAddress)
+				continue
+			}
+			topology.pinnedIDs[peerID] = shard
+		}
+	}
+	return topology
+}
+
+func (topology *defaultTopology) beaconFullMesh(connManager *ConnManager) bool {
+	return topology.config.BeaconFullMesh && connManager.Config.ConsensusState.Role == common.BEACON_ROLE
+}
+
+func (topology *defaultTopology) Apply(connManager *ConnManager, mPeers map[string]*wire.RawPeer) {
+	// pinned peers are connected even if bootnodes do not know them
+	topology.connectPinnedPeers(connManager)
+	if len(mPeers) == 0 {
+		return
+	}
+	currentShard := connManager.currentShard()
+	// connect to beacon peers
+	maxPeersBeacon := connManager.Config.MaxPeersBeacon
+	if topology.beaconFullMesh(connManager) {
+		maxPeersBeacon = len(connManager.Config.ConsensusState.GetBeaconCommittee())
+	}
+	connManager.handleRandPeersOfBeacon(maxPeersBeacon, mPeers)
+	// connect to same shard peers
+	connManager.handleRandPeersOfShard(currentShard, connManager.Config.MaxPeersSameShard, mPeers)
+	// connect to other shard peers, every other shard keeps its min links
+	connManager.handleRandPeersOfOtherShard(currentShard, connManager.Config.MaxPeersOtherShard, connManager.Config.MaxPeersOther, topology.config.MinCrossShardLinks, mPeers)
+	// connect to no shard peers
+	connManager.handleRandPeersOfNoShard(connManager.Config.MaxPeersNoShard, mPeers)
+	topology.rotate(connManager)
+}
+
+// connectPinnedPeers connects to pinned peers which are not connected
+func (topology *defaultTopology) connectPinnedPeers(connManager *ConnManager) {
+	connected := connManager.connectedPeerIDs()
+	for _, rawAddresses := range topology.config.PinnedPeers {
+		for _, rawAddress := range rawAddresses {
+			if !connected[peerIDOfRawAddress(rawAddress)] {
+				go connManager.Connect(rawAddress, common.EmptyString, nil)
+			}
+		}
+	}
+}
+
+/*
+rotate - disconnect a random outbound peer which is not needed by targets of policy when rotation interval passed,
+next discovery connects to another random peer instead, so that node does not keep the same view of network
+*/
+func (topology *defaultTopology) rotate(connManager *ConnManager) {
+	if topology.config.RotationInterval <= 0 {
+		return
+	}
+	topology.mtx.Lock()
+	defer topology.mtx.Unlock()
+	if time.Since(topology.lastRotation) < topology.config.RotationInterval {
+		return
+	}
+	topology.lastRotation = time.Now()
+	connManager.randShards = connManager.makeRandShards(common.MAX_SHARD_NUMBER)
+
+	currentShard := connManager.currentShard()
+	candidates := []*peer.PeerConn{}
+	for _, peerConn := range connManager.GetPeerConnOfAll() {
+		if !peerConn.GetIsOutbound() || topology.Protected(connManager, peerConn) {
+			continue
+		}
+		pbk := peerConn.RemotePeer.PublicKey
+		if connManager.checkBeaconOfPbk(pbk) {
+			continue
+		}
+		shard := connManager.getShardOfPbk(pbk)
+		if shard != nil && currentShard != nil && *shard == *currentShard {
+			continue
+		}
+		if shard != nil && connManager.countLinksOfShard(*shard) <= topology.config.MinCrossShardLinks {
+			continue
+		}
+		candidates = append(candidates, peerConn)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	peerConn := candidates[common.RandInt()%len(candidates)]
+	Logger.log.Infof("Rotate peer %s", peerConn.RemotePeerID.Pretty())
+	peerConn.ForceClose()
+}
+
+func (topology *defaultTopology) Protected(connManager *ConnManager, peerConn *peer.PeerConn) bool {
+	if _, ok := topology.pinnedIDs[peerConn.RemotePeerID.Pretty()]; ok {
+		return true
+	}
+	return topology.beaconFullMesh(connManager) && connManager.checkBeaconOfPbk(peerConn.RemotePeer.PublicKey)
+}
+
+func (topology *defaultTopology) Status(connManager *ConnManager) *TopologyStatus {
+	role, shardID := connManager.Config.ConsensusState.Role, connManager.currentShard()
+	status := &TopologyStatus{
+		Role:         role,
+		ShardID:      shardID,
+		OtherShards:  make(map[byte]TopologyTarget),
+		PinnedPeers:  []PinnedPeerStatus{},
+		MeetsTargets: true,
+	}
+	userPbk := connManager.Config.ConsensusState.UserPbk
+	connectedPbks := make(map[string]bool)
+	for _, peerConn := range connManager.GetPeerConnOfAll() {
+		connectedPbks[peerConn.RemotePeer.PublicKey] = true
+	}
+
+	// beacon committee, target is a max unless node is a beacon validator in full mesh
+	beaconCommittee := connManager.Config.ConsensusState.GetBeaconCommittee()
+	others := 0
+	for _, pbk := range beaconCommittee {
+		if pbk == userPbk {
+			continue
+		}
+		others++
+		if connectedPbks[pbk] {
+			status.Beacon.Connected++
+		}
+	}
+	status.Beacon.Target = others
+	if !topology.beaconFullMesh(connManager) && connManager.Config.MaxPeersBeacon < others {
+		status.Beacon.Target = connManager.Config.MaxPeersBeacon
+	}
+	if topology.beaconFullMesh(connManager) && status.Beacon.Connected < status.Beacon.Target {
+		status.MeetsTargets = false
+	}
+
+	// same shard and other shards
+	for _, shard := range connManager.committeeShards() {
+		if shardID != nil && *shardID == shard {
+			s := shard
+			status.SameShard.Connected = connManager.countPeerConnOfShard(&s)
+			status.SameShard.Target = connManager.Config.MaxPeersSameShard
+			continue
+		}
+		target := TopologyTarget{
+			Target:    topology.config.MinCrossShardLinks,
+			Connected: connManager.countLinksOfShard(shard),
+		}
+		if target.Connected < target.Target {
+			status.MeetsTargets = false
+		}
+		status.OtherShards[shard] = target
+	}
+
+	// peers which are not in any committee
+	committee := connManager.Config.ConsensusState.GetCommittee()
+	for pbk := range connectedPbks {
+		if _, ok := committee[pbk]; ok {
+			continue
+		}
+		if common.IndexOfStr(pbk, beaconCommittee) >= 0 {
+			continue
+		}
+		status.NoShard.Connected++
+	}
+	status.NoShard.Target = connManager.Config.MaxPeersNoShard
+
+	// pinned peers
+	connected := connManager.connectedPeerIDs()
+	for shard, rawAddresses := range topology.config.PinnedPeers {
+		for _, rawAddress := range rawAddresses {
+			pinned := PinnedPeerStatus{
+				ShardID:    shard,
+				RawAddress: rawAddress,
+				Connected:  connected[peerIDOfRawAddress(rawAddress)],
+			}
+			if !pinned.Connected {
+				status.MeetsTargets = false
+			}
+			status.PinnedPeers = append(status.PinnedPeers, pinned)
+		}
+	}
+
+	topology.mtx.Lock()
+	status.LastRotation = topology.lastRotation
+	topology.mtx.Unlock()
+	return status
+}
+
+// TopologyStatus reports connections of node against targets of its topology policy
+func (connManager *ConnManager) TopologyStatus() *TopologyStatus {
+	return connManager.Config.Topology.Status(connManager)
+}
+
+// currentShard returns shard of node, it is the shard of its public key when consensus state has no current shard
+func (connManager *ConnManager) currentShard() *byte {
+	if connManager.Config.ConsensusState.CurrentShard != nil {
+		return connManager.Config.ConsensusState.CurrentShard
+	}
+	return connManager.getShardOfPbk(connManager.Config.ConsensusState.UserPbk)
+}
+
+// committeeShards returns shards which have a committee
+func (connManager *ConnManager) committeeShards() []byte {
+	connManager.Config.ConsensusState.Lock()
+	defer connManager.Config.ConsensusState.Unlock()
+	shards := make([]byte, 0, len(connManager.Config.ConsensusState.ShardCommittee))
+	for shard := range connManager.Config.ConsensusState.ShardCommittee {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// connectedPeerIDs returns peer ids of all connections
+func (connManager *ConnManager) connectedPeerIDs() map[string]bool {
+	connected := make(map[string]bool)
+	for _, peerConn := range connManager.GetPeerConnOfAll() {
+		connected[peerConn.RemotePeerID.Pretty()] = true
+	}
+	return connected
+}
+
+// countLinksOfShard counts connections to a shard, they are committee members of shard and pinned peers of shard
+func (connManager *ConnManager) countLinksOfShard(shard byte) int {
+	pinnedIDs := make(map[string]bool)
+	if topology, ok := connManager.Config.Topology.(*defaultTopology); ok {
+		for peerID, pinnedShard := range topology.pinnedIDs {
+			if pinnedShard == shard {
+				pinnedIDs[peerID] = true
+			}
+		}
+	}
+	c := 0
+	for _, peerConn := range connManager.GetPeerConnOfAll() {
+		sh := connManager.getShardOfPbk(peerConn.RemotePeer.PublicKey)
+		if (sh != nil && *sh == shard) || pinnedIDs[peerConn.RemotePeerID.Pretty()] {
+			c++
+		}
+	}
+	return c
+}
+
+// protected returns whether topology policy keeps a connection
+func (connManager *ConnManager) protected(peerConn *peer.PeerConn) bool {
+	return connManager.Config.Topology != nil && connManager.Config.Topology.Protected(connManager, peerConn)
+}
@@ -0,0 +1,117 @@
+package connmanager
+
+import (
+	"io/ioutil"
+	"testing"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	testPeerIDShard1 = "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC"
+	testPeerIDShard2 = "QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+	testPeerIDPinned = "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM"
+)
+
+// newTopologyTestConnManager creates a node of shard 0 in a network of 3 shards, it has no connection
+func newTopologyTestConnManager(config TopologyConfig) *ConnManager {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("ConnManager test", true))
+	consensusState := &ConsensusState{
+		UserPbk: "pbk0",
+		ShardCommittee: map[byte][]string{
+			0: {"pbk0"},
+			1: {"pbk1"},
+			2: {"pbk2"},
+		},
+	}
+	consensusState.rebuild()
+	connManager := &ConnManager{
+		Config: Config{
+			ListenerPeer:   &peer.Peer{PeerConns: make(map[string]*peer.PeerConn)},
+			ConsensusState: consensusState,
+		},
+		randShards: []byte{1, 2},
+	}
+	connManager.Config.Topology = NewTopologyPolicy(config)
+	return connManager
+}
+
+// addTestPeerConn adds a connection to a peer with public key
+func addTestPeerConn(connManager *ConnManager, peerIDB58 string, pbk string) *peer.PeerConn {
+	peerID, _ := libp2p.IDB58Decode(peerIDB58)
+	remotePeer := &peer.Peer{PeerID: peerID, PublicKey: pbk}
+	peerConn := &peer.PeerConn{RemotePeer: remotePeer, RemotePeerID: peerID}
+	connManager.Config.ListenerPeer.PeerConns[peerIDB58] = peerConn
+	return peerConn
+}
+
+func TestTopologyCrossShardLinksOff(t *testing.T) {
+	connManager := newTopologyTestConnManager(TopologyConfig{})
+	status := connManager.TopologyStatus()
+	if !status.MeetsTargets {
+		t.Errorf("expected targets to be met without cross shard links when min is 0")
+	}
+	if len(status.OtherShards) != 2 || status.OtherShards[1].Target != 0 || status.OtherShards[2].Target != 0 {
+		t.Errorf("unexpected other shards %+v", status.OtherShards)
+	}
+	if status.ShardID == nil || *status.ShardID != 0 {
+		t.Errorf("expected node in shard 0")
+	}
+}
+
+func TestTopologyMinCrossShardLinks(t *testing.T) {
+	connManager := newTopologyTestConnManager(TopologyConfig{MinCrossShardLinks: 1})
+	addTestPeerConn(connManager, testPeerIDShard1, "pbk1")
+
+	status := connManager.TopologyStatus()
+	if status.MeetsTargets {
+		t.Errorf("expected missing link of shard 2 to fail targets")
+	}
+	if status.OtherShards[1].Connected != 1 || status.OtherShards[2].Connected != 0 || status.OtherShards[2].Target != 1 {
+		t.Errorf("unexpected other shards %+v", status.OtherShards)
+	}
+
+	addTestPeerConn(connManager, testPeerIDShard2, "pbk2")
+	if status := connManager.TopologyStatus(); !status.MeetsTargets {
+		t.Errorf("expected targets to be met with a link to each other shard, got %+v", status.OtherShards)
+	}
+
+	// every other shard keeps its min link when max peers of other shards is reached
+	mPeers := map[string]*wire.RawPeer{
+		"pbk1": {PublicKey: "pbk1"},
+		"pbk2": {PublicKey: "pbk2"},
+	}
+	shard := byte(0)
+	if count := connManager.handleRandPeersOfOtherShard(&shard, 1, 1, 1, mPeers); count != 2 {
+		t.Errorf("expected links to both other shards, got %d", count)
+	}
+}
+
+func TestTopologyPinnedPeers(t *testing.T) {
+	pinnedAddress := "/ip4/1.2.3.4/tcp/9333/ipfs/" + testPeerIDPinned
+	connManager := newTopologyTestConnManager(TopologyConfig{
+		MinCrossShardLinks: 1,
+		PinnedPeers:        map[byte][]string{2: {pinnedAddress}},
+	})
+	addTestPeerConn(connManager, testPeerIDShard1, "pbk1")
+	status := connManager.TopologyStatus()
+	if status.MeetsTargets || len(status.PinnedPeers) != 1 || status.PinnedPeers[0].Connected {
+		t.Errorf("expected disconnected pinned peer to fail targets, got %+v", status.PinnedPeers)
+	}
+
+	// pinned peer is protected and counts as a link of its shard even if it is not in committee
+	pinned := addTestPeerConn(connManager, testPeerIDPinned, "")
+	if !connManager.protected(pinned) {
+		t.Errorf("expected pinned peer to be protected")
+	}
+	if connManager.protected(connManager.Config.ListenerPeer.PeerConns[testPeerIDShard1]) {
+		t.Errorf("expected committee peer not to be protected")
+	}
+	status = connManager.TopologyStatus()
+	if !status.MeetsTargets || status.OtherShards[2].Connected != 1 || !status.PinnedPeers[0].Connected {
+		t.Errorf("expected pinned peer to meet link of shard 2, got %+v", status)
+	}
+}
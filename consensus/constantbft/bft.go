@@ -13,6 +13,7 @@ import (
 	"github.com/ninjadotorg/constant/cashec"
 
 	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/metrics"
 	"github.com/ninjadotorg/constant/wire"
 )
 
@@ -26,11 +27,16 @@ type BFTProtocol struct {
 	BlockChain        *blockchain.BlockChain
 	Server            serverInterface
 	UserKeySet        *cashec.KeySet
+	CompactPropose    bool
+	Clock             common.Clock
 
 	cQuit    chan struct{}
 	cTimeout chan struct{}
 
 	phase string
+	// phase which is being measured and when it started
+	measuredPhase string
+	phaseStart    time.Time
 
 	pendingBlock interface{}
 
@@ -59,8 +65,10 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer protocol.endPhase()
 	for {
 		fmt.Println("New Phase")
+		protocol.startPhase()
 		protocol.cTimeout = make(chan struct{})
 		select {
 		case <-protocol.cQuit:
@@ -69,18 +77,19 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 			switch protocol.phase {
 			case PBFT_PROPOSE:
 				//    single-node start    //
-				time.Sleep(2 * time.Second)
+				protocol.Clock.Sleep(2 * time.Second)
 				_, err := protocol.CreateBlockMsg()
 				if err != nil {
 					return nil, err
 				}
 				return protocol.pendingBlock, nil
 				//    single-node end    //
-				timeout := time.AfterFunc(ListenTimeout*time.Second, func() {
+				timeout := protocol.Clock.AfterFunc(ListenTimeout*time.Second, func() {
 					fmt.Println("Propose phase timeout")
+					metrics.BFTTimeouts.WithLabelValues(protocol.RoundData.Layer, PBFT_PROPOSE).Inc()
 					protocol.closeTimeoutCh()
 				})
-				timeout2 := time.AfterFunc((ListenTimeout/2)*time.Second, func() {
+				timeout2 := protocol.Clock.AfterFunc((ListenTimeout/2)*time.Second, func() {
 					fmt.Println("Request ready msg")
 					if protocol.RoundData.Layer == common.BEACON_ROLE {
 						msgReq, _ := MakeMsgBFTReq(protocol.BlockChain.BestState.Beacon.Hash(), protocol.RoundData.ProposerOffset, protocol.UserKeySet)
@@ -152,8 +161,9 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 					protocol.Server.PushMessageToShard(msgReady, protocol.RoundData.ShardID)
 				}
 				fmt.Println("Listen phase")
-				timeout := time.AfterFunc(ListenTimeout*time.Second, func() {
+				timeout := protocol.Clock.AfterFunc(ListenTimeout*time.Second, func() {
 					fmt.Println("Listen phase timeout")
+					metrics.BFTTimeouts.WithLabelValues(protocol.RoundData.Layer, PBFT_LISTEN).Inc()
 					protocol.closeTimeoutCh()
 				})
 			listenphase:
@@ -175,7 +185,15 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 								protocol.multiSigScheme.dataToSig = pendingBlk.Header.Hash()
 							} else {
 								pendingBlk := blockchain.ShardBlock{}
-								pendingBlk.UnmarshalJSON(msgPropose.(*wire.MessageBFTPropose).Block)
+								if msgPropose.(*wire.MessageBFTPropose).CompactBlock {
+									// compact block is rebuilt from mempool before proposal is passed to consensus
+									if msgPropose.(*wire.MessageBFTPropose).ShardBlock == nil {
+										continue
+									}
+									pendingBlk = *msgPropose.(*wire.MessageBFTPropose).ShardBlock
+								} else {
+									pendingBlk.UnmarshalJSON(msgPropose.(*wire.MessageBFTPropose).Block)
+								}
 								err = protocol.BlockChain.VerifyPreSignShardBlock(&pendingBlk, protocol.RoundData.ShardID)
 								if err != nil {
 									Logger.log.Error(err)
@@ -214,11 +232,12 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 				}
 			case PBFT_PREPARE:
 				fmt.Println("Prepare phase")
-				timeout := time.AfterFunc(PrepareTimeout*time.Second, func() {
+				timeout := protocol.Clock.AfterFunc(PrepareTimeout*time.Second, func() {
 					fmt.Println("Prepare phase timeout")
+					metrics.BFTTimeouts.WithLabelValues(protocol.RoundData.Layer, PBFT_PREPARE).Inc()
 					protocol.closeTimeoutCh()
 				})
-				time.AfterFunc(DelayTime*time.Millisecond, func() {
+				protocol.Clock.AfterFunc(DelayTime*time.Millisecond, func() {
 					fmt.Println("Sending out prepare msg")
 					msg, err := MakeMsgBFTPrepare(protocol.multiSigScheme.personal.Ri, protocol.UserKeySet, protocol.multiSigScheme.dataToSig)
 					if err != nil {
@@ -266,12 +285,13 @@ func (protocol *BFTProtocol) Start() (interface{}, error) {
 				}
 			case PBFT_COMMIT:
 				fmt.Println("Commit phase")
-				cmTimeout := time.AfterFunc(CommitTimeout*time.Second, func() {
+				cmTimeout := protocol.Clock.AfterFunc(CommitTimeout*time.Second, func() {
 					fmt.Println("Commit phase timeout")
+					metrics.BFTTimeouts.WithLabelValues(protocol.RoundData.Layer, PBFT_COMMIT).Inc()
 					protocol.closeTimeoutCh()
 				})
 
-				time.AfterFunc(DelayTime*time.Millisecond, func() {
+				protocol.Clock.AfterFunc(DelayTime*time.Millisecond, func() {
 					msg, err := MakeMsgBFTCommit(protocol.multiSigScheme.combine.CommitSig, protocol.multiSigScheme.combine.R, protocol.multiSigScheme.combine.ValidatorsIdxR, protocol.UserKeySet)
 					if err != nil {
 						Logger.log.Error(err)
@@ -396,8 +416,13 @@ func (protocol *BFTProtocol) CreateBlockMsg() (wire.Message, error) {
 		if err != nil {
 			return nil, err
 		}
-		jsonBlock, _ := json.Marshal(newBlock)
-		msg, err = MakeMsgBFTPropose(jsonBlock, protocol.RoundData.Layer, protocol.RoundData.ShardID, protocol.UserKeySet)
+		if protocol.CompactPropose {
+			jsonBlock, _ := json.Marshal(protocol.Server.CompactShardBlock(newBlock))
+			msg, err = MakeMsgBFTProposeCompact(jsonBlock, protocol.RoundData.ShardID, protocol.UserKeySet)
+		} else {
+			jsonBlock, _ := json.Marshal(newBlock)
+			msg, err = MakeMsgBFTPropose(jsonBlock, protocol.RoundData.Layer, protocol.RoundData.ShardID, protocol.UserKeySet)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -415,6 +440,22 @@ func (protocol *BFTProtocol) forwardMsg(msg wire.Message) {
 	}
 }
 
+// startPhase ends measuring of previous phase and starts measuring of current phase
+func (protocol *BFTProtocol) startPhase() {
+	protocol.endPhase()
+	protocol.measuredPhase = protocol.phase
+	protocol.phaseStart = protocol.Clock.Now()
+}
+
+// endPhase observes duration of measured phase, if any
+func (protocol *BFTProtocol) endPhase() {
+	if protocol.measuredPhase == "" {
+		return
+	}
+	metrics.BFTPhaseDuration.WithLabelValues(protocol.RoundData.Layer, protocol.measuredPhase).Observe(protocol.Clock.Now().Sub(protocol.phaseStart).Seconds())
+	protocol.measuredPhase = ""
+}
+
 func (protocol *BFTProtocol) closeTimeoutCh() {
 	select {
 	case <-protocol.cTimeout:
@@ -32,12 +32,20 @@ type EngineConfig struct {
 	Server            serverInterface
 	ShardToBeaconPool blockchain.ShardToBeaconPool
 	CrossShardPool    map[byte]blockchain.CrossShardPool
+	// shard blocks are proposed as compact blocks which validators rebuild from mempool
+	CompactPropose bool
+	// Clock drives timeouts of consensus phases, system time is used when it is nil
+	Clock common.Clock
 }
 
 //Init apply configuration to consensus engine
 func (engine Engine) Init(cfg *EngineConfig) (*Engine, error) {
+	config := *cfg
+	if config.Clock == nil {
+		config.Clock = common.NewRealClock()
+	}
 	return &Engine{
-		config: *cfg,
+		config: config,
 	}, nil
 }
 
@@ -52,7 +60,7 @@ func (engine *Engine) Start() error {
 	engine.started = true
 	Logger.log.Info("Start consensus with key", engine.config.UserKeySet.GetPublicKeyB58())
 	fmt.Println(engine.config.BlockChain.BestState.Beacon.BeaconCommittee)
-	time.AfterFunc(DelayTime*time.Millisecond, func() {
+	engine.config.Clock.AfterFunc(DelayTime*time.Millisecond, func() {
 		currentPBFTBlkHeight := uint64(0)
 		currentPBFTRound := 1
 		prevRoundNodeRole := ""
@@ -96,6 +104,8 @@ func (engine *Engine) Start() error {
 							Server:            engine.config.Server,
 							ShardToBeaconPool: engine.config.ShardToBeaconPool,
 							CrossShardPool:    engine.config.CrossShardPool,
+							CompactPropose:    engine.config.CompactPropose,
+							Clock:             engine.config.Clock,
 						}
 
 						if (engine.config.NodeMode == common.NODEMODE_BEACON || engine.config.NodeMode == common.NODEMODE_AUTO) && userRole != common.SHARD_ROLE {
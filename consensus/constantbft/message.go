@@ -69,6 +69,27 @@ func MakeMsgBFTPropose(block json.RawMessage, layer string, shardID byte, userKe
 	return msg, nil
 }
 
+/*
+MakeMsgBFTProposeCompact - make proposal of a shard block which carries json of blockchain.CompactShardBlock
+*/
+func MakeMsgBFTProposeCompact(compactBlock json.RawMessage, shardID byte, userKeySet *cashec.KeySet) (wire.Message, error) {
+	msg, err := wire.MakeEmptyMessage(wire.CmdBFTPropose)
+	if err != nil {
+		Logger.log.Error(err)
+		return msg, err
+	}
+	msg.(*wire.MessageBFTPropose).Block = compactBlock
+	msg.(*wire.MessageBFTPropose).CompactBlock = true
+	msg.(*wire.MessageBFTPropose).Layer = common.SHARD_ROLE
+	msg.(*wire.MessageBFTPropose).ShardID = shardID
+	msg.(*wire.MessageBFTPropose).Pubkey = userKeySet.GetPublicKeyB58()
+	err = msg.(*wire.MessageBFTPropose).SignMsg(userKeySet)
+	if err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
 func MakeMsgBFTPrepare(Ri []byte, userKeySet *cashec.KeySet, blkHash common.Hash) (wire.Message, error) {
 	msg, err := wire.MakeEmptyMessage(wire.CmdBFTPrepare)
 	if err != nil {
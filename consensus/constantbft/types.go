@@ -2,6 +2,7 @@ package constantbft
 
 import (
 	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
 	"github.com/ninjadotorg/constant/wire"
 )
 
@@ -25,4 +26,5 @@ type serverInterface interface {
 	PushMessageToBeacon(wire.Message) error
 	PushMessageToPbk(wire.Message, string) error
 	UpdateConsensusState(role string, userPbk string, currentShard *byte, beaconCommittee []string, shardCommittee map[byte][]string)
+	CompactShardBlock(block *blockchain.ShardBlock) *blockchain.CompactShardBlock
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	// number of outbound peers which stem transactions are relayed to in an epoch
+	dandelionStemRelays = 2
+	// stem transactions whose embargo expired are fluffed at this interval
+	dandelionTickerInterval = time.Second
+)
+
+/*
+dandelionRouter keeps state of dandelion++ in an epoch: the phase of node,
+relay peers which stem transactions are sent to and the relay of each source peer,
+all of them are chosen again when epoch ends or a relay peer is disconnected
+*/
+type dandelionRouter struct {
+	clock   common.Clock
+	epoch   time.Duration
+	fluff   float64
+	embargo time.Duration
+
+	epochEnd  time.Time
+	fluffMode bool
+	relays    []string
+	routes    map[string]string // peer id of source -> peer id of relay, own txs use source ""
+	random    *rand.Rand
+	mtx       sync.Mutex
+}
+
+func newDandelionRouter(clock common.Clock, epoch time.Duration, fluff float64, embargo time.Duration) *dandelionRouter {
+	return &dandelionRouter{
+		clock:   clock,
+		epoch:   epoch,
+		fluff:   fluff,
+		embargo: embargo,
+		routes:  make(map[string]string),
+		random:  rand.New(rand.NewSource(clock.Now().UnixNano())),
+	}
+}
+
+// newEpoch chooses phase and relay peers of node, this function MUST be called with the router lock held
+func (router *dandelionRouter) newEpoch(now time.Time, candidates []string) {
+	router.epochEnd = now.Add(router.epoch)
+	router.fluffMode = router.random.Float64() < router.fluff
+	router.routes = make(map[string]string)
+	router.relays = []string{}
+	for _, i := range router.random.Perm(len(candidates)) {
+		if len(router.relays) == dandelionStemRelays {
+			break
+		}
+		router.relays = append(router.relays, candidates[i])
+	}
+	Logger.log.Debugf("Dandelion epoch starts, fluff mode %v, stem relays %v", router.fluffMode, router.relays)
+}
+
+/*
+route - return relay peer of a stem tx from source peer, source is empty for txs of this node,
+fluff is true when tx should be fluffed by this node instead
+*/
+func (router *dandelionRouter) route(source string, candidates []string) (relay string, fluff bool) {
+	router.mtx.Lock()
+	defer router.mtx.Unlock()
+
+	connected := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		connected[candidate] = true
+	}
+	now := router.clock.Now()
+	renew := now.After(router.epochEnd)
+	for _, relay := range router.relays {
+		if !connected[relay] {
+			renew = true
+		}
+	}
+	if renew {
+		router.newEpoch(now, candidates)
+	}
+	if len(router.relays) == 0 {
+		return "", true
+	}
+	// own txs are always stemmed, so that fluff mode does not reveal their origin
+	if router.fluffMode && source != "" {
+		return "", true
+	}
+	relay, ok := router.routes[source]
+	if !ok {
+		relay = router.relays[0]
+		if source != "" {
+			relay = router.relays[router.random.Intn(len(router.relays))]
+		}
+		router.routes[source] = relay
+	}
+	return relay, false
+}
+
+// embargoDuration returns embargo of a stem tx, it is base embargo plus an exponential random delay
+func (router *dandelionRouter) embargoDuration() time.Duration {
+	router.mtx.Lock()
+	defer router.mtx.Unlock()
+	return router.embargo + time.Duration(router.random.ExpFloat64()*float64(router.embargo))
+}
+
+// localCapabilities returns capabilities which this node announces in version message
+func (serverObj *Server) localCapabilities() uint64 {
+	if serverObj.cfg.DisableDandelion {
+		return wire.CurrentCapabilities &^ wire.CapabilityDandelion
+	}
+	return wire.CurrentCapabilities
+}
+
+// dandelionRelays returns outbound peers which read stem transactions by peer id
+func (serverObj *Server) dandelionRelays() map[string]*peer.PeerConn {
+	relays := make(map[string]*peer.PeerConn)
+	for _, peerConn := range serverObj.connManager.GetPeerConnOfAll() {
+		if peerConn.GetIsOutbound() && peerConn.HasCapability(wire.CapabilityDandelion) {
+			relays[peerConn.RemotePeerID.Pretty()] = peerConn
+		}
+	}
+	return relays
+}
+
+/*
+routeStemTx - return the connection which a stem tx from source is relayed to,
+nil when node is in fluff phase or it has no relay peer
+*/
+func (serverObj *Server) routeStemTx(source string) *peer.PeerConn {
+	relays := serverObj.dandelionRelays()
+	candidates := make([]string, 0, len(relays))
+	for peerID := range relays {
+		candidates = append(candidates, peerID)
+	}
+	relay, fluff := serverObj.dandelion.route(source, candidates)
+	if fluff {
+		return nil
+	}
+	return relays[relay]
+}
+
+// sendStemTx sends a tx in stem phase to relay peer
+func (serverObj *Server) sendStemTx(relay *peer.PeerConn, tx metadata.Transaction) {
+	msg, err := wire.MakeEmptyMessage(wire.CmdStemTx)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	msg.(*wire.MessageStemTx).Transaction = tx
+	msg.SetSenderID(serverObj.connManager.Config.ListenerPeer.PeerID)
+	relay.QueueMessageWithEncoding(msg, nil, peer.MESSAGE_TO_PEER, nil)
+}
+
+/*
+stemLocalTx - send a tx which is created by this node in stem phase,
+it returns false when tx can not be stemmed and it should be fluffed
+*/
+func (serverObj *Server) stemLocalTx(tx metadata.Transaction) bool {
+	relay := serverObj.routeStemTx("")
+	if relay == nil {
+		return false
+	}
+	err := serverObj.memPool.StemTransaction(tx.Hash(), serverObj.dandelion.embargoDuration())
+	if err != nil {
+		Logger.log.Debug(err)
+		return false
+	}
+	Logger.log.Debugf("Stem tx %s to peer %s", tx.Hash().String(), relay.RemotePeerID.Pretty())
+	serverObj.sendStemTx(relay, tx)
+	return true
+}
+
+/*
+RelayMessageTx - announce a tx which is in mempool to all peers, this is fluff phase of dandelion
+*/
+func (serverObj *Server) RelayMessageTx(msg *wire.MessageTx) error {
+	invVect, ok := inventoryOfMessage(msg)
+	if !ok {
+		return nil
+	}
+	serverObj.relayInventory(invVect, msg)
+	return nil
+}
+
+// fluffTx adds a tx into mempool and announces it to all peers
+func (serverObj *Server) fluffTx(tx metadata.Transaction) error {
+	_, _, err := serverObj.memPool.MaybeAcceptTransaction(tx)
+	if err != nil {
+		return err
+	}
+	msg, err := wire.MakeEmptyMessage(wire.CmdTx)
+	if err != nil {
+		return err
+	}
+	msg.(*wire.MessageTx).Transaction = tx
+	return serverObj.RelayMessageTx(msg.(*wire.MessageTx))
+}
+
+/*
+OnStemTx is invoked when a peer relays a tx in stem phase,
+tx is kept in stem pool and relayed to the relay peer of source peer, or it is fluffed in fluff phase
+*/
+func (serverObj *Server) OnStemTx(peerConn *peer.PeerConn, msg *wire.MessageStemTx) {
+	Logger.log.Debug("Receive a new stem transaction START")
+	if msg.Transaction == nil {
+		return
+	}
+	txHash := msg.Transaction.Hash()
+	if serverObj.memPool.HaveTransaction(txHash) || serverObj.memPool.HaveStemTransaction(txHash) {
+		return
+	}
+	var relay *peer.PeerConn
+	if !serverObj.cfg.DisableDandelion {
+		relay = serverObj.routeStemTx(peerConn.RemotePeerID.Pretty())
+	}
+	if relay == nil {
+		err := serverObj.fluffTx(msg.Transaction)
+		if err != nil {
+			Logger.log.Error(err)
+		}
+		return
+	}
+	_, err := serverObj.memPool.MaybeAcceptStemTransaction(msg.Transaction, serverObj.dandelion.embargoDuration())
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	serverObj.sendStemTx(relay, msg.Transaction)
+	Logger.log.Debug("Receive a new stem transaction END")
+}
+
+/*
+dandelionHandler - fluff stem transactions whose embargo expired,
+a stem tx which is lost by a malicious or disconnected relay is still announced this way
+*/
+func (serverObj *Server) dandelionHandler() {
+	for {
+		select {
+		case now := <-serverObj.clock.After(dandelionTickerInterval):
+			for _, tx := range serverObj.memPool.ExpiredStemTransactions(now) {
+				Logger.log.Debugf("Embargo of stem tx %s expired, fluff it", tx.Hash().String())
+				err := serverObj.fluffTx(tx)
+				if err != nil {
+					Logger.log.Debug(err)
+					serverObj.memPool.ReleaseStemTransaction(tx.Hash())
+				}
+			}
+		case <-serverObj.cQuit:
+			return
+		}
+	}
+}
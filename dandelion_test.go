@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+)
+
+func newTestDandelionRouter(clock common.Clock, fluff float64) *dandelionRouter {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("Server test", true))
+	return newDandelionRouter(clock, 10*time.Minute, fluff, 30*time.Second)
+}
+
+func TestDandelionRouteStem(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	router := newTestDandelionRouter(clock, 0)
+	candidates := []string{"a", "b", "c", "d"}
+
+	// own txs always go to the first relay
+	relay, fluff := router.route("", candidates)
+	if fluff || relay != router.relays[0] {
+		t.Fatalf("expected own tx to be stemmed to first relay, got %s %v", relay, fluff)
+	}
+	if len(router.relays) != dandelionStemRelays {
+		t.Errorf("expected %d relays, got %+v", dandelionStemRelays, router.relays)
+	}
+
+	// a source keeps its relay for the whole epoch
+	first, _ := router.route("source", candidates)
+	for i := 0; i < 10; i++ {
+		if relay, fluff := router.route("source", candidates); fluff || relay != first {
+			t.Fatalf("expected stable route of source, got %s then %s", first, relay)
+		}
+	}
+	isRelay := false
+	for _, relay := range router.relays {
+		isRelay = isRelay || relay == first
+	}
+	if !isRelay {
+		t.Errorf("expected route to one of relays %+v, got %s", router.relays, first)
+	}
+
+	// no relay peer means tx is fluffed
+	if _, fluff := newTestDandelionRouter(clock, 0).route("", nil); !fluff {
+		t.Errorf("expected tx to be fluffed without relay peers")
+	}
+}
+
+func TestDandelionFluffMode(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	router := newTestDandelionRouter(clock, 1)
+	candidates := []string{"a", "b"}
+	if _, fluff := router.route("source", candidates); !fluff {
+		t.Errorf("expected txs of peers to be fluffed in fluff mode")
+	}
+	// own txs are stemmed in fluff mode too
+	if _, fluff := router.route("", candidates); fluff {
+		t.Errorf("expected own tx to be stemmed in fluff mode")
+	}
+}
+
+func TestDandelionEpochRotation(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	router := newTestDandelionRouter(clock, 0)
+	candidates := []string{"a", "b", "c", "d"}
+	router.route("source", candidates)
+	epochEnd := router.epochEnd
+	if !epochEnd.Equal(clock.Now().Add(router.epoch)) {
+		t.Fatalf("unexpected end of epoch %v", epochEnd)
+	}
+
+	// epoch is kept before it ends
+	clock.Advance(5 * time.Minute)
+	router.route("source", candidates)
+	if !router.epochEnd.Equal(epochEnd) {
+		t.Errorf("expected epoch to be kept")
+	}
+
+	// a new epoch starts after the end
+	clock.Advance(6 * time.Minute)
+	router.route("source", candidates)
+	if !router.epochEnd.Equal(clock.Now().Add(router.epoch)) {
+		t.Errorf("expected a new epoch after the end")
+	}
+
+	// a new epoch starts when a relay is disconnected
+	epochEnd = router.epochEnd
+	clock.Advance(time.Minute)
+	remaining := []string{}
+	for _, candidate := range candidates {
+		if candidate != router.relays[0] {
+			remaining = append(remaining, candidate)
+		}
+	}
+	relay, _ := router.route("source", remaining)
+	if router.epochEnd.Equal(epochEnd) {
+		t.Errorf("expected a new epoch when a relay is disconnected")
+	}
+	for _, candidate := range remaining {
+		if candidate == relay {
+			return
+		}
+	}
+	t.Errorf("expected route to a connected peer, got %s", relay)
+}
+
+func TestDandelionEmbargoDuration(t *testing.T) {
+	router := newTestDandelionRouter(common.NewManualClock(time.Unix(1000, 0)), 0)
+	for i := 0; i < 100; i++ {
+		if embargo := router.embargoDuration(); embargo < router.embargo {
+			t.Fatalf("expected embargo not under base embargo, got %v", embargo)
+		}
+	}
+}
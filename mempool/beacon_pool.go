@@ -5,6 +5,7 @@ import (
 	"github.com/ninjadotorg/constant/blockchain"
 	"github.com/ninjadotorg/constant/common"
 	"sort"
+	"sync"
 )
 
 const (
@@ -15,6 +16,7 @@ const (
 type BeaconPool struct {
 	pool              []*blockchain.BeaconBlock // block
 	latestValidHeight uint64
+	poolMu            sync.RWMutex
 }
 
 var beaconPool *BeaconPool = nil
@@ -35,6 +37,8 @@ func GetBeaconPool() *BeaconPool {
 }
 
 func (self *BeaconPool) SetBeaconState(lastestBeaconHeight uint64) {
+	self.poolMu.Lock()
+	defer self.poolMu.Unlock()
 	self.latestValidHeight = lastestBeaconHeight
 
 	//Remove pool base on new shardstate
@@ -48,6 +52,8 @@ func (self *BeaconPool) GetBeaconState() uint64 {
 
 func (self *BeaconPool) AddBeaconBlock(blk *blockchain.BeaconBlock) error {
 	//TODO: validate aggregated signature
+	self.poolMu.Lock()
+	defer self.poolMu.Unlock()
 
 	blkHeight := blk.Header.Height
 
@@ -170,3 +176,10 @@ func (self *BeaconPool) GetAllBlockHeight() []uint64 {
 	}
 	return finalBlocks
 }
+
+// CountBlocks returns number of blocks in pool, it is safe to call while blocks are added
+func (self *BeaconPool) CountBlocks() int {
+	self.poolMu.RLock()
+	defer self.poolMu.RUnlock()
+	return len(self.pool)
+}
@@ -1,5 +1,7 @@
 package mempool
 
+import "time"
+
 const (
 	// UnminedHeight is the height used for the "block" height field of the
 	// contextual transaction information provided in a transaction store
@@ -7,3 +9,16 @@ const (
 	UnminedHeight = 0x7fffffff
 	MaxVersion    = 1
 )
+
+// orphan pool
+const (
+	DefaultMaxOrphanTxs      = 100
+	DefaultMaxOrphanTxSize   = 100              // kb
+	DefaultOrphanTTL         = 15 * time.Minute // time an orphan tx waits for cross output coins
+	orphanExpireScanInterval = 5 * time.Minute
+)
+
+// stem pool
+const (
+	DefaultMaxStemTxs = 1000
+)
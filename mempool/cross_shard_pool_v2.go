@@ -39,6 +39,9 @@ type CrossShardPool_v2 struct {
 	crossShardState map[byte]uint64
 	poolMu          *sync.RWMutex
 	db              database.DatabaseInterface
+	// bestState returns best state of shard of pool in chain of node, it is nil when shard is not active.
+	// Singleton best state of shard is used when bestState is nil
+	bestState func() *blockchain.BestStateShard
 }
 
 var crossShardPoolMap = make(map[byte]*CrossShardPool_v2)
@@ -54,16 +57,28 @@ func InitCrossShardPool(pool map[byte]blockchain.CrossShardPool, db database.Dat
 func GetCrossShardPool(shardID byte) *CrossShardPool_v2 {
 	p, ok := crossShardPoolMap[shardID]
 	if ok == false {
-		p = new(CrossShardPool_v2)
-		p.shardID = shardID
-		p.validPool = make(map[byte][]*blockchain.CrossShardBlock)
-		p.pendingPool = make(map[byte][]*blockchain.CrossShardBlock)
-		p.poolMu = new(sync.RWMutex)
+		p = NewCrossShardPool(shardID, nil, nil)
 		crossShardPoolMap[shardID] = p
 	}
 	return p
 }
 
+/*
+NewCrossShardPool creates a pool of cross shard blocks which is not shared with other nodes of the process,
+bestState looks up best state of shard in chain of node each time pool is updated, so that a shard which becomes active
+later is followed. The singleton best state is used when bestState is nil
+*/
+func NewCrossShardPool(shardID byte, db database.DatabaseInterface, bestState func() *blockchain.BestStateShard) *CrossShardPool_v2 {
+	p := new(CrossShardPool_v2)
+	p.shardID = shardID
+	p.validPool = make(map[byte][]*blockchain.CrossShardBlock)
+	p.pendingPool = make(map[byte][]*blockchain.CrossShardBlock)
+	p.poolMu = new(sync.RWMutex)
+	p.db = db
+	p.bestState = bestState
+	return p
+}
+
 // Validate pending pool again, to move pending block to valid block
 // When receive new cross shard block or new beacon state arrive
 func (pool *CrossShardPool_v2) UpdatePool() error {
@@ -83,7 +98,14 @@ func (pool *CrossShardPool_v2) getNextCrossShardHeight(fromShard, toShard byte,
 
 }
 func (pool *CrossShardPool_v2) updatePool() (map[byte]uint64, error) {
-	pool.crossShardState = blockchain.GetBestStateShard(pool.shardID).BestCrossShard
+	if pool.bestState != nil {
+		pool.crossShardState = make(map[byte]uint64)
+		if shardBestState := pool.bestState(); shardBestState != nil {
+			pool.crossShardState = shardBestState.BestCrossShard
+		}
+	} else {
+		pool.crossShardState = blockchain.GetBestStateShard(pool.shardID).BestCrossShard
+	}
 	pool.removeBlockByHeight(pool.crossShardState)
 	expectedHeight := make(map[byte]uint64)
 	for blkShardID, blks := range pool.pendingPool {
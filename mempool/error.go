@@ -17,6 +17,10 @@ const (
 	CanNotCheckDoubleSpend
 	DatabaseError
 	ShardToBeaconBoolError
+	RejectOrphanTx
+	OrphanTx
+	RejectMetadataPolicy
+	RejectStemTx
 )
 
 var ErrCodeMessage = map[int]struct {
@@ -33,6 +37,10 @@ var ErrCodeMessage = map[int]struct {
 	DatabaseError:          {-1007, "Database Error"},
 	ShardToBeaconBoolError: {-1007, "ShardToBeaconBool Error"},
 	RejectDuplicateStakeTx: {-1008, "Reject Duplicate Stake Error"},
+	RejectOrphanTx:         {-1009, "Reject orphan tx"},
+	OrphanTx:               {-1010, "Tx is stored in orphan pool"},
+	RejectMetadataPolicy:   {-1011, "Reject tx by metadata policy"},
+	RejectStemTx:           {-1012, "Reject stem tx"},
 }
 
 type MempoolTxError struct {
@@ -148,6 +148,10 @@ type FeeEstimator struct {
 	// Transactions that have been removed from the bins. This allows us to
 	// revert in case of an orphaned block.
 	dropped []*registeredBlock
+
+	// Estimators of fees which are paid in privacy custom tokens, keyed by token ID.
+	// They are only accessed with the lock of this estimator held.
+	tokens map[common.Hash]*FeeEstimator
 }
 
 // NewFeeEstimator creates a feeEstimator for which at most maxRollback blocks
@@ -162,10 +166,13 @@ func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
 		maxReplacements:     estimateFeeMaxReplacements,
 		observed:            make(map[common.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		tokens:              make(map[common.Hash]*FeeEstimator),
 	}
 }
 
 // ObserveTransaction is called when a new transaction is observed in the mempool.
+// Fee in constant is observed for normal txs and privacy custom token txs,
+// fee in token is observed by the estimator of token for privacy custom token txs.
 func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
@@ -176,27 +183,80 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 		return
 	}
 
-	hash := *t.Desc.Tx.Hash()
+	size := t.Desc.Tx.GetTxActualSize()
+	ef.observe(*t.Desc.Tx.Hash(), t.Desc.Fee, size, t.Desc.Height)
+
+	if t.Desc.Tx.GetType() == common.TxCustomTokenPrivacyType {
+		tokenTx, ok := t.Desc.Tx.(*transaction.TxCustomTokenPrivacy)
+		if !ok || tokenTx.TxTokenPrivacyData.TxNormal.Fee == 0 {
+			return
+		}
+		tokenEstimator := ef.tokenEstimator(tokenTx.TxTokenPrivacyData.PropertyID)
+		tokenEstimator.observe(*t.Desc.Tx.Hash(), tokenTx.TxTokenPrivacyData.TxNormal.Fee, size, t.Desc.Height)
+	}
+}
+
+// observe records fee rate of a tx, it must be called with the lock held.
+func (ef *FeeEstimator) observe(hash common.Hash, fee uint64, size uint64, height uint64) {
 	if _, ok := ef.observed[hash]; !ok {
-		size := t.Desc.Tx.GetTxActualSize()
 		ef.observed[hash] = &observedTransaction{
 			hash:     hash,
-			feeRate:  NewCoinPerKilobyte(uint64(t.Desc.Fee), size),
-			observed: t.Desc.Height,
+			feeRate:  NewCoinPerKilobyte(fee, size),
+			observed: height,
 			mined:    UnminedHeight,
 		}
 	}
 }
 
+// tokenEstimator returns estimator of fees in a token, it is created when not existed.
+// It must be called with the lock held.
+func (ef *FeeEstimator) tokenEstimator(tokenID common.Hash) *FeeEstimator {
+	if ef.tokens == nil {
+		ef.tokens = make(map[common.Hash]*FeeEstimator)
+	}
+	tokenEstimator, ok := ef.tokens[tokenID]
+	if !ok {
+		tokenEstimator = NewFeeEstimator(ef.maxRollback, ef.minRegisteredBlocks)
+		tokenEstimator.lastKnownHeight = ef.lastKnownHeight
+		ef.tokens[tokenID] = tokenEstimator
+	}
+	return tokenEstimator
+}
+
 // RegisterBlock informs the fee estimator of a new block to take into account.
 func (ef *FeeEstimator) RegisterBlock(block *blockchain.ShardBlock) error {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
+	// Randomly order txs in block.
+	transactions := make(map[common.Hash]struct{})
+	for _, t := range block.Body.Transactions {
+		switch t.GetType() {
+		case common.TxNormalType, common.TxSalaryType, common.TxCustomTokenPrivacyType:
+			{
+				transactions[*t.Hash()] = struct{}{}
+			}
+		}
+	}
+
+	err := ef.registerBlock(block.Header.Height, *block.Hash(), transactions)
+	if err != nil {
+		return err
+	}
+	for tokenID, tokenEstimator := range ef.tokens {
+		err := tokenEstimator.registerBlock(block.Header.Height, *block.Hash(), transactions)
+		if err != nil {
+			Logger.log.Errorf("Estimate fee: can not register block for token %+v: %+v", tokenID.String(), err)
+		}
+	}
+	return nil
+}
+
+// registerBlock puts mined txs into bins, it must be called with the lock held.
+func (ef *FeeEstimator) registerBlock(height uint64, blockHash common.Hash, transactions map[common.Hash]struct{}) error {
 	// The previous sorted list is invalid, so delete it.
 	ef.cached = nil
 
-	height := block.Header.Height
 	if height != ef.lastKnownHeight+1 && ef.lastKnownHeight != UnminedHeight {
 		return fmt.Errorf("intermediate block not recorded; current height is %d; new height is %d",
 			ef.lastKnownHeight, height)
@@ -206,31 +266,18 @@ func (ef *FeeEstimator) RegisterBlock(block *blockchain.ShardBlock) error {
 	ef.lastKnownHeight = height
 	ef.numBlocksRegistered++
 
-	// Randomly order txs in block.
-	transactions := make(map[*transaction.Tx]struct{})
-	for _, t := range block.Body.Transactions {
-		switch t.GetType() {
-		case common.TxNormalType, common.TxSalaryType:
-			{
-				transactions[t.(*transaction.Tx)] = struct{}{}
-			}
-		}
-	}
-
 	// Count the number of replacements we make per bin so that we don't
 	// replace too many.
 	var replacementCounts [estimateFeeDepth]int
 
 	// Keep track of which txs were dropped in case of an orphan block.
 	dropped := &registeredBlock{
-		hash:         *block.Hash(),
+		hash:         blockHash,
 		transactions: make([]*observedTransaction, 0, 100),
 	}
 
 	// Go through the txs in the block.
-	for t := range transactions {
-		hash := *t.Hash()
-
+	for hash := range transactions {
 		// Have we observed this tx in the mempool?
 		o, ok := ef.observed[hash]
 		if !ok {
@@ -321,6 +368,20 @@ func (ef *FeeEstimator) Rollback(hash *common.Hash) error {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
+	err := ef.rollbackTo(hash)
+	if err != nil {
+		return err
+	}
+	// estimators of tokens which are created after the block was registered
+	// do not know it, they are left as they are.
+	for _, tokenEstimator := range ef.tokens {
+		tokenEstimator.rollbackTo(hash)
+	}
+	return nil
+}
+
+// rollbackTo unregisters blocks until the block with hash, it must be called with the lock held.
+func (ef *FeeEstimator) rollbackTo(hash *common.Hash) error {
 	// Find this block in the stack of recent registered blocks.
 	var n int
 	for n = 1; n <= len(ef.dropped); n++ {
@@ -524,6 +585,28 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint64) (CoinPerKilobyte, error) {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
+	return ef.estimateFee(numBlocks)
+}
+
+// EstimateTokenFee estimates the fee per kb in a privacy custom token to have a tx
+// confirmed a given number of blocks from now. Fee in constant is estimated
+// when tokenID is nil or constant ID.
+func (ef *FeeEstimator) EstimateTokenFee(tokenID *common.Hash, numBlocks uint64) (CoinPerKilobyte, error) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if tokenID == nil || tokenID.IsEqual(&common.ConstantID) {
+		return ef.estimateFee(numBlocks)
+	}
+	tokenEstimator, ok := ef.tokens[*tokenID]
+	if !ok {
+		return 0, fmt.Errorf("no fee has been observed for token %+v", tokenID.String())
+	}
+	return tokenEstimator.estimateFee(numBlocks)
+}
+
+// estimateFee must be called with the lock held.
+func (ef *FeeEstimator) estimateFee(numBlocks uint64) (CoinPerKilobyte, error) {
 	// If the number of registered blocks is below the minimum, return
 	// an error.
 	if ef.numBlocksRegistered < ef.minRegisteredBlocks {
@@ -553,7 +636,7 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint64) (CoinPerKilobyte, error) {
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
 // start fee estimation over.
-const estimateFeeSaveVersion = 1
+const estimateFeeSaveVersion = 2
 
 func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
 	var lenTransactions uint32
@@ -601,7 +684,14 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 	w := bytes.NewBuffer(make([]byte, 0))
 
 	binary.Write(w, binary.BigEndian, uint32(estimateFeeSaveVersion))
+	ef.save(w)
 
+	// CommitAll the tx and return.
+	return FeeEstimatorState(w.Bytes())
+}
+
+// save writes state of estimator and estimators of tokens, it must be called with the lock held.
+func (ef *FeeEstimator) save(w io.Writer) {
 	// Insert basic parameters.
 	binary.Write(w, binary.BigEndian, &ef.maxRollback)
 	binary.Write(w, binary.BigEndian, &ef.binSize)
@@ -645,8 +735,19 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 		registered.serialize(w, observed)
 	}
 
-	// CommitAll the tx and return.
-	return FeeEstimatorState(w.Bytes())
+	// Estimators of tokens, sorted by token ID.
+	tokenIDs := make([]common.Hash, 0, len(ef.tokens))
+	for tokenID := range ef.tokens {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Slice(tokenIDs, func(i, j int) bool {
+		return bytes.Compare(tokenIDs[i][:], tokenIDs[j][:]) < 0
+	})
+	binary.Write(w, binary.BigEndian, uint32(len(tokenIDs)))
+	for _, tokenID := range tokenIDs {
+		binary.Write(w, binary.BigEndian, tokenID)
+		ef.tokens[tokenID].save(w)
+	}
 }
 
 // RestoreFeeEstimator takes a FeeEstimatorState that was previously
@@ -663,9 +764,13 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 	if version != estimateFeeSaveVersion {
 		return nil, fmt.Errorf("Incorrect version: expected %d found %d", estimateFeeSaveVersion, version)
 	}
+	return restoreFeeEstimator(r)
+}
 
+func restoreFeeEstimator(r io.Reader) (*FeeEstimator, error) {
 	ef := &FeeEstimator{
 		observed: make(map[common.Hash]*observedTransaction),
+		tokens:   make(map[common.Hash]*FeeEstimator),
 	}
 
 	// Read basic parameters.
@@ -719,5 +824,23 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		}
 	}
 
+	// Read estimators of tokens.
+	var numTokens uint32
+	err := binary.Read(r, binary.BigEndian, &numTokens)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numTokens; i++ {
+		var tokenID common.Hash
+		err := binary.Read(r, binary.BigEndian, &tokenID)
+		if err != nil {
+			return nil, err
+		}
+		ef.tokens[tokenID], err = restoreFeeEstimator(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return ef, nil
 }
@@ -0,0 +1,41 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ninjadotorg/constant/common"
+)
+
+func TestFeeEstimatorTokenSaveRestore(t *testing.T) {
+	ef := NewFeeEstimator(DefaultEstimateFeeMaxRollback, 1)
+	ef.lastKnownHeight = 10
+	tokenID := common.Hash{1, 2, 3}
+	txHash := common.Hash{9}
+	ef.observe(txHash, 100, 1, 10)
+	ef.tokenEstimator(tokenID).observe(txHash, 500, 1, 10)
+
+	blockHash := common.Hash{7}
+	transactions := map[common.Hash]struct{}{txHash: {}}
+	if err := ef.registerBlock(11, blockHash, transactions); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if err := ef.tokens[tokenID].registerBlock(11, blockHash, transactions); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	restored, err := RestoreFeeEstimator(ef.Save())
+	if err != nil {
+		t.Fatalf("can not restore fee estimator %+v", err)
+	}
+	fee, err := restored.EstimateTokenFee(&tokenID, 1)
+	if err != nil || fee != 500 {
+		t.Errorf("wrong token fee %d %+v", fee, err)
+	}
+	fee, err = restored.EstimateTokenFee(nil, 1)
+	if err != nil || fee != 100 {
+		t.Errorf("wrong constant fee %d %+v", fee, err)
+	}
+	if _, err := restored.EstimateTokenFee(&common.Hash{4, 5}, 1); err == nil {
+		t.Error("expect error for token without observed fee")
+	}
+}
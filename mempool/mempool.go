@@ -3,6 +3,7 @@ package mempool
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,6 +29,26 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator map[byte]*FeeEstimator
+
+	// Limits of orphan pool, default values are used when they are zero
+	MaxOrphanTxs    int
+	MaxOrphanTxSize uint64 // kb
+	OrphanTTL       time.Duration
+
+	// OnAcceptOrphanTx is called when an orphan tx is moved into pool,
+	// node uses it to relay the tx to other peers. It can be nil.
+	OnAcceptOrphanTx func(tx metadata.Transaction)
+
+	// OnTxAdded and OnTxRemoved are called when a tx enters or leaves pool, node uses them to notify
+	// RPC subscribers. They are called with pool locked so they must not call pool. They can be nil.
+	OnTxAdded   func(tx metadata.Transaction)
+	OnTxRemoved func(tx metadata.Transaction)
+
+	// Policy contains rules for txs with metadata, it can be nil
+	Policy *Policy
+
+	// Clock is the source of time of pool, system time is used when it is nil
+	Clock common.Clock
 }
 
 // TxDesc is transaction message in mempool
@@ -59,6 +80,13 @@ type TxPool struct {
 	//Token ID List in Mempool
 	tokenIDList []string
 	tokenIDMtx  sync.RWMutex
+
+	// txs which wait for cross output coins, protected by mtx
+	orphans              map[common.Hash]*orphanTx
+	nextOrphanExpireScan time.Time
+
+	// txs in stem phase of dandelion, they are kept out of pool until they are fluffed, protected by mtx
+	stemPool map[common.Hash]*stemTx
 }
 
 /*
@@ -72,6 +100,11 @@ func (tp *TxPool) Init(cfg *Config) {
 	tp.txCoinHashHPool = make(map[common.Hash][]common.Hash)
 	tp.coinHashHPool = make(map[common.Hash]bool)
 	tp.cMtx = sync.RWMutex{}
+
+	tp.orphans = make(map[common.Hash]*orphanTx)
+	tp.nextOrphanExpireScan = tp.now().Add(orphanExpireScanInterval)
+
+	tp.stemPool = make(map[common.Hash]*stemTx)
 }
 
 // ----------- transaction.MempoolRetriever's implementation -----------------
@@ -104,21 +137,24 @@ func (tp *TxPool) addTx(tx metadata.Transaction, height uint64, fee uint64) *TxD
 	txD := &TxDesc{
 		Desc: metadata.TxDesc{
 			Tx:     tx,
-			Added:  time.Now(),
+			Added:  tp.now(),
 			Height: height,
 			Fee:    fee,
 		},
 		StartingPriority: 1, //@todo we will apply calc function for it.
 	}
+	if size := tx.GetTxActualSize(); size > 0 {
+		txD.Desc.FeePerKB = fee / size
+	}
 	Logger.log.Info(tx.Hash().String())
 	tp.pool[*tx.Hash()] = txD
 	tp.poolSerialNumbers[*tx.Hash()] = txD.Desc.Tx.ListNullifiers()
-	atomic.StoreInt64(&tp.lastUpdated, time.Now().Unix())
+	atomic.StoreInt64(&tp.lastUpdated, tp.now().Unix())
 
-	// Record this tx for fee estimation if enabled. only apply for normal tx
-	if tx.GetType() == common.TxNormalType {
+	// Record this tx for fee estimation if enabled. only apply for normal tx and privacy custom token tx
+	if tx.GetType() == common.TxNormalType || tx.GetType() == common.TxCustomTokenPrivacyType {
 		if tp.config.FeeEstimator != nil {
-			shardID := common.GetShardIDFromLastByte(tx.(*transaction.Tx).PubKeyLastByteSender)
+			shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
 			if temp, ok := tp.config.FeeEstimator[shardID]; ok {
 				temp.ObserveTransaction(txD)
 			}
@@ -143,6 +179,9 @@ func (tp *TxPool) addTx(tx metadata.Transaction, height uint64, fee uint64) *TxD
 			tp.AddTokenIDToList(tokenID)
 		}
 	}
+	if tp.config.OnTxAdded != nil {
+		tp.config.OnTxAdded(tx)
+	}
 	return txD
 }
 
@@ -151,7 +190,7 @@ func (tp *TxPool) addTx(tx metadata.Transaction, height uint64, fee uint64) *TxD
 // See the comment for MaybeAcceptTransaction for more details.
 // This function MUST be called with the mempool lock held (for writes).
 1. Validate tx version
-2. Validate fee with tx size
+2. Validate fee with tx size and rule of metadata type (fee, sender, number of txs per sender)
 3. Validate type of tx
 4. Validate with other txs in mempool
 5. Validate sanity data of tx
@@ -162,6 +201,24 @@ func (tp *TxPool) addTx(tx metadata.Transaction, height uint64, fee uint64) *TxD
 10. Check Duplicate stake public key in pool ONLY with staking transaction
 */
 func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash, *TxDesc, error) {
+	err := tp.validateTransaction(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
+	bestHeight := tp.config.BlockChain.BestState.Shard[shardID].BestBlock.Header.Height
+	txD := tp.addTx(tx, bestHeight, tx.GetTxFee())
+	// a stem tx is fluffed when it is accepted into pool
+	delete(tp.stemPool, *tx.Hash())
+	return tx.Hash(), txD, nil
+}
+
+/*
+validateTransaction - check all rules of maybeAcceptTransaction without adding tx into pool,
+it is shared with stem pool which keeps valid txs out of pool until they are fluffed.
+This function MUST be called with the mempool lock held.
+*/
+func (tp *TxPool) validateTransaction(tx metadata.Transaction) error {
 	txHash := tx.Hash()
 
 	// Don't accept the transaction if it already exists in the pool.
@@ -169,7 +226,7 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 		str := fmt.Sprintf("already have transaction %+v", txHash.String())
 		err := MempoolTxError{}
 		err.Init(RejectDuplicateTx, errors.New(str))
-		return nil, nil, err
+		return err
 	}
 
 	// that make sure transaction is accepted when passed any rules
@@ -179,14 +236,13 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 	// get shardID of tx
 	shardID = common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
 
-	bestHeight := tp.config.BlockChain.BestState.Shard[shardID].BestBlock.Header.Height
 	// nextBlockHeight := bestHeight + 1
 	// check version
 	ok := tx.CheckTxVersion(MaxVersion)
 	if !ok {
 		err := MempoolTxError{}
 		err.Init(RejectVersion, fmt.Errorf("%+v's version is invalid", txHash.String()))
-		return nil, nil, err
+		return err
 	}
 
 	// check fee of tx
@@ -196,19 +252,32 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 	if !ok {
 		err := MempoolTxError{}
 		err.Init(RejectVersion, fmt.Errorf("transaction %+v has %d fees which is under the required amount of %d", tx.Hash().String(), txFee, minFeePerKbTx))
-		return nil, nil, err
+		return err
+	}
+	// check rule of metadata type
+	if tp.config.Policy != nil {
+		err = tp.config.Policy.CheckMetadataPolicy(tx, tp.countTxsBySender)
+		if err != nil {
+			return err
+		}
 	}
 	// end check with policy
 
 	ok = tx.ValidateType()
 	if !ok {
 		fmt.Printf("Type: %s\n", (tx.(*transaction.Tx).Type))
-		return nil, nil, errors.New("wrong tx type")
+		return errors.New("wrong tx type")
 	}
 	// check tx with all txs in current mempool
 	err = tx.ValidateTxWithCurrentMempool(tp)
 	if err != nil {
-		return nil, nil, err
+		return err
+	}
+	// serial numbers of stem txs are not in pool, so they are checked separately
+	if tp.isStemDoubleSpend(tx) {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("transaction %+v double spends a stem transaction", txHash.String()))
+		return err
 	}
 
 	// sanity data
@@ -217,7 +286,15 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 	if validated, errS := tx.ValidateSanityData(tp.config.BlockChain); !validated {
 		err := MempoolTxError{}
 		err.Init(RejectSansityTx, fmt.Errorf("transaction's sansity %v is error %v", txHash.String(), errS.Error()))
-		return nil, nil, err
+		return err
+	}
+
+	// proof of a tx which spends cross output coins that are not stored yet can not be verified,
+	// so tx is kept as an orphan instead of being rejected
+	if hasUnknownInputCommitments(tx, tp.config.DataBase, shardID) {
+		err := MempoolTxError{}
+		err.Init(OrphanTx, fmt.Errorf("transaction %+v is waiting for cross shard output coins", txHash.String()))
+		return err
 	}
 
 	// ValidateTransaction tx by it self
@@ -226,14 +303,14 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 	if !validated {
 		err := MempoolTxError{}
 		err.Init(RejectInvalidTx, errors.New("invalid tx"))
-		return nil, nil, err
+		return err
 	}
 
 	// validate tx with data of blockchain
 	err = tx.ValidateTxWithBlockChain(tp.config.BlockChain, shardID, tp.config.BlockChain.GetDatabase())
 	// err = tp.ValidateTxWithBlockChain(tx, shardID)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 	if tx.GetType() == common.TxCustomTokenType {
 		customTokenTx := tx.(*transaction.TxCustomToken)
@@ -243,7 +320,7 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 			found := common.IndexOfStr(tokenID, tp.tokenIDList)
 			tp.tokenIDMtx.Unlock()
 			if found > -1 {
-				return nil, nil, errors.New("Init Transaction of this Token is in pool already")
+				return errors.New("Init Transaction of this Token is in pool already")
 			}
 		}
 	}
@@ -253,7 +330,7 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 	if tx.IsSalaryTx() {
 		err := MempoolTxError{}
 		err.Init(RejectSalaryTx, fmt.Errorf("%+v is salary tx", txHash.String()))
-		return nil, nil, err
+		return err
 	}
 	// check duplicate stake public key ONLY with staking transaction
 	if tx.GetMetadata() != nil {
@@ -264,12 +341,62 @@ func (tp *TxPool) maybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 				str := fmt.Sprintf("This public key already stake and still in pool %+v", pubkey)
 				err := MempoolTxError{}
 				err.Init(RejectDuplicateStakeTx, errors.New(str))
-				return nil, nil, err
+				return err
 			}
 		}
 	}
-	txD := tp.addTx(tx, bestHeight, txFee)
-	return tx.Hash(), txD, nil
+	return nil
+}
+
+/*
+RegisterShardBlock - register a new shard block with fee estimator of its shard
+so that fee rates of txs in block are used for estimation
+*/
+func (tp *TxPool) RegisterShardBlock(block *blockchain.ShardBlock) {
+	if tp.config.FeeEstimator == nil {
+		return
+	}
+	feeEstimator, ok := tp.config.FeeEstimator[block.Header.ShardID]
+	if !ok {
+		return
+	}
+	err := feeEstimator.RegisterBlock(block)
+	if err != nil {
+		Logger.log.Errorf("Can not register block %+v with fee estimator: %+v", block.Hash().String(), err)
+	}
+}
+
+// countTxsBySender return number of txs with metadata type from sender in pool
+func (tp *TxPool) countTxsBySender(metaType int, sender string) int {
+	count := 0
+	for _, txDesc := range tp.pool {
+		tx := txDesc.Desc.Tx
+		if tx.GetMetadata() == nil || tx.GetMetadataType() != metaType {
+			continue
+		}
+		pubkey := base58.Base58Check{}.Encode(tx.GetSigPubKey(), byte(0x00))
+		if pubkey == sender {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+MetadataPolicyRules - return rules of metadata types which are applied by mempool
+*/
+func (tp *TxPool) MetadataPolicyRules() []*MetadataPolicyRule {
+	result := []*MetadataPolicyRule{}
+	if tp.config.Policy == nil {
+		return result
+	}
+	for _, rule := range tp.config.Policy.MetadataRules {
+		result = append(result, rule)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MetadataType < result[j].MetadataType
+	})
+	return result
 }
 
 // remove transaction for pool
@@ -277,7 +404,10 @@ func (tp *TxPool) removeTx(tx *metadata.Transaction) error {
 	Logger.log.Infof((*tx).Hash().String())
 	if _, exists := tp.pool[*(*tx).Hash()]; exists {
 		delete(tp.pool, *(*tx).Hash())
-		atomic.StoreInt64(&tp.lastUpdated, time.Now().Unix())
+		atomic.StoreInt64(&tp.lastUpdated, tp.now().Unix())
+		if tp.config.OnTxRemoved != nil {
+			tp.config.OnTxRemoved(*tx)
+		}
 		return nil
 	} else {
 		return errors.New("not exist tx in pool")
@@ -290,15 +420,19 @@ func (tp *TxPool) removeTx(tx *metadata.Transaction) error {
 // such as rejecting duplicate transactions, ensuring transactions follow all
 // rules, detecting orphan transactions, and insertion into the memory pool.
 //
-// If the transaction is an orphan (missing parent transactions), the
-// transaction is NOT added to the orphan pool, but each unknown referenced
-// parent is returned.  Use ProcessTransaction instead if new orphans should
-// be added to the orphan pool.
+// If the transaction is an orphan (it passes all rules which do not need its
+// input coins but spends cross output coins which are not stored yet), the
+// transaction is added to the orphan pool and an error with code OrphanTx is
+// returned. Any other error rejects the transaction. The orphan is moved into the pool by ProcessOrphans
+// when the cross shard block is processed.
 //
 // This function is safe for concurrent access.
 func (tp *TxPool) MaybeAcceptTransaction(tx metadata.Transaction) (*common.Hash, *TxDesc, error) {
 	tp.mtx.Lock()
 	hash, txDesc, err := tp.maybeAcceptTransaction(tx)
+	if isOrphanError(err) {
+		err = tp.maybeAddOrphan(tx)
+	}
 	tp.mtx.Unlock()
 	return hash, txDesc, err
 }
@@ -306,15 +440,19 @@ func (tp *TxPool) MaybeAcceptTransaction(tx metadata.Transaction) (*common.Hash,
 // This function is safe for concurrent access.
 func (tp *TxPool) MaybeAcceptTransactionForBlockProducing(tx metadata.Transaction) (*metadata.TxDesc, error) {
 	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
 	_, txDesc, err := tp.maybeAcceptTransaction(tx)
-	tempTxDesc := &txDesc.Desc
-	tp.mtx.Unlock()
-	return tempTxDesc, err
+	if err != nil {
+		return nil, err
+	}
+	return &txDesc.Desc, nil
 }
 
 // RemoveTx safe remove transaction for pool
 func (tp *TxPool) RemoveTx(tx metadata.Transaction) error {
 	tp.mtx.Lock()
+	// a stem tx which is included in a block is never fluffed
+	delete(tp.stemPool, *tx.Hash())
 	fmt.Println("...................................")
 	fmt.Println("txHash To Be Remove", tx.Hash())
 	fmt.Println("...................................")
@@ -359,7 +497,9 @@ func (tp *TxPool) MiningDescs() []*metadata.TxDesc {
 
 // Count return len of transaction pool
 func (tp *TxPool) Count() int {
+	tp.mtx.RLock()
 	count := len(tp.pool)
+	tp.mtx.RUnlock()
 	return count
 }
 
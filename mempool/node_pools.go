@@ -0,0 +1,34 @@
+package mempool
+
+import (
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/database"
+)
+
+/*
+InitNodePools - create shard and cross shard pools of a node and set state of its pools from best states of its chain,
+pools of a node are not shared with other nodes so that several nodes can run in one process,
+they also replace default pools of package which are read by rpc.
+Cross shard pools look up best state of their shard when they are updated, a shard which is not active yet has none
+*/
+func InitNodePools(bestState *blockchain.BestState, shardToBeacon *ShardToBeaconPool, shardPool map[byte]blockchain.ShardPool, crossShardPool map[byte]blockchain.CrossShardPool, db database.DatabaseInterface) {
+	shardToBeacon.SetShardState(bestState.Beacon.BestShardHeight)
+	shardToBeaconPool = shardToBeacon
+	for i := 0; i < 255; i++ {
+		shardID := byte(i)
+		shardHeight := uint64(0)
+		if shardBestState, ok := bestState.Shard[shardID]; ok {
+			shardHeight = shardBestState.ShardHeight
+		}
+		pool := NewShardPool(shardID)
+		pool.SetShardState(shardHeight)
+		shardPool[shardID] = pool
+		shardPoolMap[shardID] = pool
+
+		crossPool := NewCrossShardPool(shardID, db, func() *blockchain.BestStateShard {
+			return bestState.Shard[shardID]
+		})
+		crossShardPool[shardID] = crossPool
+		crossShardPoolMap[shardID] = crossPool
+	}
+}
@@ -0,0 +1,241 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/database"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// orphanTx is a transaction which spends output coins that are not known by this node yet,
+// usually cross output coins of a cross shard block which has not been processed
+type orphanTx struct {
+	tx         metadata.Transaction
+	shardID    byte
+	expiration time.Time
+}
+
+func (tp *TxPool) maxOrphanTxs() int {
+	if tp.config.MaxOrphanTxs > 0 {
+		return tp.config.MaxOrphanTxs
+	}
+	return DefaultMaxOrphanTxs
+}
+
+func (tp *TxPool) maxOrphanTxSize() uint64 {
+	if tp.config.MaxOrphanTxSize > 0 {
+		return tp.config.MaxOrphanTxSize
+	}
+	return DefaultMaxOrphanTxSize
+}
+
+// now returns time of clock of pool
+func (tp *TxPool) now() time.Time {
+	if tp.config.Clock != nil {
+		return tp.config.Clock.Now()
+	}
+	return time.Now()
+}
+
+func (tp *TxPool) orphanTTL() time.Duration {
+	if tp.config.OrphanTTL > 0 {
+		return tp.config.OrphanTTL
+	}
+	return DefaultOrphanTTL
+}
+
+/*
+maybeAddOrphan - add tx which is rejected by validateTransaction with code OrphanTx into orphan pool.
+It returns a MempoolTxError with code OrphanTx when tx is kept as an orphan.
+This function MUST be called with the mempool lock held (for writes).
+*/
+func (tp *TxPool) maybeAddOrphan(tx metadata.Transaction) error {
+	txHash := tx.Hash()
+	if _, exists := tp.orphans[*txHash]; exists {
+		err := MempoolTxError{}
+		err.Init(RejectDuplicateTx, fmt.Errorf("already have orphan transaction %+v", txHash.String()))
+		return err
+	}
+	shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
+	if tx.GetTxActualSize() > tp.maxOrphanTxSize() {
+		err := MempoolTxError{}
+		err.Init(RejectOrphanTx, fmt.Errorf("orphan transaction %+v is larger than max size %d", txHash.String(), tp.maxOrphanTxSize()))
+		return err
+	}
+
+	tp.limitNumOrphans()
+	tp.orphans[*txHash] = &orphanTx{
+		tx:         tx,
+		shardID:    shardID,
+		expiration: tp.now().Add(tp.orphanTTL()),
+	}
+	Logger.log.Infof("Stored orphan transaction %+v (total: %d)", txHash.String(), len(tp.orphans))
+
+	err := MempoolTxError{}
+	err.Init(OrphanTx, fmt.Errorf("transaction %+v is waiting for cross shard output coins", txHash.String()))
+	return err
+}
+
+/*
+limitNumOrphans - remove expired orphans and a random orphan when pool reaches the limit.
+This function MUST be called with the mempool lock held (for writes).
+*/
+func (tp *TxPool) limitNumOrphans() {
+	now := tp.now()
+	if now.After(tp.nextOrphanExpireScan) {
+		for txHash, otx := range tp.orphans {
+			if now.After(otx.expiration) {
+				delete(tp.orphans, txHash)
+			}
+		}
+		tp.nextOrphanExpireScan = now.Add(orphanExpireScanInterval)
+	}
+	for len(tp.orphans)+1 > tp.maxOrphanTxs() {
+		// map iteration order is random so this evicts a random orphan
+		for txHash := range tp.orphans {
+			delete(tp.orphans, txHash)
+			break
+		}
+	}
+}
+
+// isOrphanError returns whether tx is rejected only because some of its input coins are unknown
+func isOrphanError(err error) bool {
+	mempoolErr, ok := err.(MempoolTxError)
+	return ok && mempoolErr.code == ErrCodeMessage[OrphanTx].code
+}
+
+/*
+ProcessOrphans - try to move orphan transactions into pool,
+it is called after new cross output coins are stored into database.
+Return transactions which are accepted into pool.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) ProcessOrphans() []metadata.Transaction {
+	tp.mtx.Lock()
+	accepted := []metadata.Transaction{}
+	now := tp.now()
+	for txHash, otx := range tp.orphans {
+		if now.After(otx.expiration) {
+			delete(tp.orphans, txHash)
+			continue
+		}
+		if hasUnknownInputCommitments(otx.tx, tp.config.DataBase, otx.shardID) {
+			continue
+		}
+		delete(tp.orphans, txHash)
+		_, _, err := tp.maybeAcceptTransaction(otx.tx)
+		if err != nil {
+			Logger.log.Infof("Reject orphan transaction %+v: %+v", txHash.String(), err)
+			continue
+		}
+		Logger.log.Infof("Accept orphan transaction %+v", txHash.String())
+		accepted = append(accepted, otx.tx)
+	}
+	tp.mtx.Unlock()
+
+	if tp.config.OnAcceptOrphanTx != nil {
+		for _, tx := range accepted {
+			tp.config.OnAcceptOrphanTx(tx)
+		}
+	}
+	return accepted
+}
+
+// RemoveOrphan removes tx out of orphan pool
+func (tp *TxPool) RemoveOrphan(txHash *common.Hash) error {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+	if _, exists := tp.orphans[*txHash]; !exists {
+		return errors.New("not exist tx in orphan pool")
+	}
+	delete(tp.orphans, *txHash)
+	return nil
+}
+
+// IsOrphanInPool returns whether tx is waiting in orphan pool
+func (tp *TxPool) IsOrphanInPool(txHash *common.Hash) bool {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+	_, exists := tp.orphans[*txHash]
+	return exists
+}
+
+// OrphanCount return number of txs in orphan pool
+func (tp *TxPool) OrphanCount() int {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+	return len(tp.orphans)
+}
+
+/*
+List all orphan tx ids
+*/
+func (tp *TxPool) ListOrphanTxs() []string {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+	result := make([]string, 0, len(tp.orphans))
+	for txHash := range tp.orphans {
+		result = append(result, txHash.String())
+	}
+	return result
+}
+
+/*
+hasUnknownInputCommitments - check input coins of constant and privacy token proofs of tx
+- No privacy: commitment of input coin is not in database
+- Privacy: index of a commitment in one-out-of-many proof is out of commitments list in database
+*/
+func hasUnknownInputCommitments(tx metadata.Transaction, db database.DatabaseInterface, shardID byte) bool {
+	constantID := &common.Hash{}
+	constantID.SetBytes(common.ConstantID[:])
+	if hasUnknownCommitments(tx.GetProof(), tx.IsPrivacy(), constantID, db, shardID) {
+		return true
+	}
+	if tx.GetType() == common.TxCustomTokenPrivacyType {
+		tokenTx, ok := tx.(*transaction.TxCustomTokenPrivacy)
+		if !ok {
+			return false
+		}
+		tokenID := tokenTx.TxTokenPrivacyData.PropertyID
+		txNormal := tokenTx.TxTokenPrivacyData.TxNormal
+		return hasUnknownCommitments(txNormal.Proof, txNormal.IsPrivacy(), &tokenID, db, shardID)
+	}
+	return false
+}
+
+func hasUnknownCommitments(proof *zkp.PaymentProof, hasPrivacy bool, tokenID *common.Hash, db database.DatabaseInterface, shardID byte) bool {
+	if proof == nil || len(proof.InputCoins) == 0 {
+		return false
+	}
+	if !hasPrivacy {
+		for _, inputCoin := range proof.InputCoins {
+			if inputCoin.CoinDetails == nil || inputCoin.CoinDetails.CoinCommitment == nil {
+				continue
+			}
+			ok, err := db.HasCommitment(tokenID, inputCoin.CoinDetails.CoinCommitment.Compress(), shardID)
+			if err == nil && !ok {
+				return true
+			}
+		}
+		return false
+	}
+	cmLength, err := db.GetCommitmentLength(tokenID, shardID)
+	if err != nil || cmLength == nil {
+		// no commitment of this token in shard
+		cmLength = big.NewInt(0)
+	}
+	for _, cmIndex := range proof.CommitmentIndices {
+		if new(big.Int).SetUint64(cmIndex).Cmp(cmLength) >= 0 {
+			return true
+		}
+	}
+	return false
+}
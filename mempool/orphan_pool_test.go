@@ -0,0 +1,145 @@
+package mempool
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/database"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// orphanTestDB knows commitments of a shard up to a length, other methods are not used by orphan pool
+type orphanTestDB struct {
+	database.DatabaseInterface
+	commitmentLength int64
+}
+
+func (db *orphanTestDB) HasCommitment(tokenID *common.Hash, commitment []byte, shardID byte) (bool, error) {
+	return false, nil
+}
+
+func (db *orphanTestDB) GetCommitmentLength(tokenID *common.Hash, shardID byte) (*big.Int, error) {
+	return big.NewInt(db.commitmentLength), nil
+}
+
+// orphanTestTx passes all rules of pool which need chain data, except its own verification when it is invalid.
+// Its proof only has commitment indices of input coins so it is kept out of embedded tx which hashes and sizes it.
+type orphanTestTx struct {
+	*transaction.Tx
+	proof   *zkp.PaymentProof
+	invalid bool
+}
+
+func (tx *orphanTestTx) GetProof() *zkp.PaymentProof { return tx.proof }
+
+func (tx *orphanTestTx) IsPrivacy() bool { return true }
+
+func (tx *orphanTestTx) CheckTransactionFee(minFeePerKbTx uint64) bool { return true }
+
+func (tx *orphanTestTx) ValidateTxWithCurrentMempool(mr metadata.MempoolRetriever) error { return nil }
+
+func (tx *orphanTestTx) ValidateSanityData(bcr metadata.BlockchainRetriever) (bool, error) {
+	return true, nil
+}
+
+func (tx *orphanTestTx) ValidateTxByItself(hasPrivacy bool, db database.DatabaseInterface, bcr metadata.BlockchainRetriever, shardID byte) bool {
+	return !tx.invalid
+}
+
+func (tx *orphanTestTx) ValidateTxWithBlockChain(bcr metadata.BlockchainRetriever, shardID byte, db database.DatabaseInterface) error {
+	return nil
+}
+
+// newOrphanTestTx creates a privacy tx of shard 0 which spends the commitment at cmIndex
+func newOrphanTestTx(lockTime int64, cmIndex uint64, invalid bool) *orphanTestTx {
+	return &orphanTestTx{
+		Tx: &transaction.Tx{
+			Type:     common.TxNormalType,
+			LockTime: lockTime,
+		},
+		proof: &zkp.PaymentProof{
+			InputCoins:        []*privacy.InputCoin{{}},
+			CommitmentIndices: []uint64{cmIndex},
+		},
+		invalid: invalid,
+	}
+}
+
+func newOrphanTestPool(db *orphanTestDB) *TxPool {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("Mempool test", true))
+	chain := &blockchain.BlockChain{
+		BestState: &blockchain.BestState{
+			Beacon: &blockchain.BestStateBeacon{},
+			Shard:  map[byte]*blockchain.BestStateShard{0: {BestBlock: &blockchain.ShardBlock{}}},
+		},
+	}
+	tp := &TxPool{}
+	tp.Init(&Config{BlockChain: chain, DataBase: db})
+	return tp
+}
+
+func TestOrphanAcceptAndResolve(t *testing.T) {
+	db := &orphanTestDB{commitmentLength: 1}
+	tp := newOrphanTestPool(db)
+	var accepted []metadata.Transaction
+	tp.config.OnAcceptOrphanTx = func(tx metadata.Transaction) {
+		accepted = append(accepted, tx)
+	}
+
+	tx := newOrphanTestTx(1, 5, false)
+	_, _, err := tp.MaybeAcceptTransaction(tx)
+	if !isOrphanError(err) {
+		t.Fatalf("expected orphan error, got %+v", err)
+	}
+	if !tp.IsOrphanInPool(tx.Hash()) || tp.isTxInPool(tx.Hash()) {
+		t.Fatalf("expected tx in orphan pool only")
+	}
+	if _, _, err := tp.MaybeAcceptTransaction(tx); err == nil || isOrphanError(err) {
+		t.Errorf("expected duplicate orphan to be rejected, got %+v", err)
+	}
+
+	// cross output coins are not stored yet
+	if len(tp.ProcessOrphans()) != 0 || tp.OrphanCount() != 1 {
+		t.Errorf("expected tx to stay orphan")
+	}
+
+	db.commitmentLength = 10
+	if len(tp.ProcessOrphans()) != 1 {
+		t.Fatalf("expected orphan to be accepted")
+	}
+	if tp.OrphanCount() != 0 || !tp.isTxInPool(tx.Hash()) {
+		t.Errorf("expected tx to move from orphan pool into pool")
+	}
+	if len(accepted) != 1 || accepted[0] != tx {
+		t.Errorf("expected accepted orphan to be relayed")
+	}
+}
+
+func TestOrphanRejectInvalidTx(t *testing.T) {
+	db := &orphanTestDB{commitmentLength: 1}
+	tp := newOrphanTestPool(db)
+
+	// inputs are known so verification failure rejects tx
+	tx := newOrphanTestTx(1, 0, true)
+	_, _, err := tp.MaybeAcceptTransaction(tx)
+	if err == nil || isOrphanError(err) {
+		t.Errorf("expected invalid tx to be rejected, got %+v", err)
+	}
+
+	// a tx which breaks a rule that does not need its inputs is rejected even if inputs are unknown
+	tx = newOrphanTestTx(2, 5, false)
+	tx.Version = MaxVersion + 1
+	_, _, err = tp.MaybeAcceptTransaction(tx)
+	if err == nil || isOrphanError(err) {
+		t.Errorf("expected tx with invalid version to be rejected, got %+v", err)
+	}
+	if tp.OrphanCount() != 0 {
+		t.Errorf("expected no orphan, got %d", tp.OrphanCount())
+	}
+}
@@ -2,10 +2,14 @@ package mempool
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"errors"
 
 	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/metadata"
 	"github.com/ninjadotorg/constant/transaction"
 )
 
@@ -17,6 +21,9 @@ type Policy struct {
 	MaxTxVersion int8
 
 	BlockChain *blockchain.BlockChain
+
+	// MetadataRules are rules for txs with metadata, keyed by metadata type
+	MetadataRules map[int]*MetadataPolicyRule
 }
 
 /*
@@ -49,7 +56,6 @@ func (self *Policy) calcMinFeeTxAccepted(tx *transaction.Tx) uint64 {
 }
 
 /*
-
  */
 func (self *Policy) CheckTransactionFee(tx *transaction.Tx) error {
 	minFee := self.calcMinFeeTxAccepted(tx)
@@ -72,3 +78,178 @@ func (self *Policy) CheckCustomTokenTransactionFee(tx *transaction.TxCustomToken
 	}
 	return nil
 }
+
+// MetadataPolicyRule is the rule which is applied to txs having a specific type of metadata
+type MetadataPolicyRule struct {
+	MetadataType int
+
+	// MinFeePerKbTx overrides fee per kb of chain if it is higher
+	MinFeePerKbTx uint64
+
+	// MaxPerBlock is the maximum number of txs with this type in a block, zero is unlimited
+	MaxPerBlock int
+
+	// MaxPerSender is the maximum number of txs with this type from the same sender
+	// in mempool, zero is unlimited
+	MaxPerSender int
+
+	// Sender public keys (base58 check encoding), AllowList is ignored if empty
+	AllowList map[string]bool
+	DenyList  map[string]bool
+}
+
+// metadataTypeByName maps name of metadata type in config file to its value
+var metadataTypeByName = map[string]int{
+	"LoanRequestMeta":                     metadata.LoanRequestMeta,
+	"LoanResponseMeta":                    metadata.LoanResponseMeta,
+	"LoanWithdrawMeta":                    metadata.LoanWithdrawMeta,
+	"LoanUnlockMeta":                      metadata.LoanUnlockMeta,
+	"LoanPaymentMeta":                     metadata.LoanPaymentMeta,
+	"DividendSubmitMeta":                  metadata.DividendSubmitMeta,
+	"CrowdsaleRequestMeta":                metadata.CrowdsaleRequestMeta,
+	"CMBInitRequestMeta":                  metadata.CMBInitRequestMeta,
+	"CMBInitResponseMeta":                 metadata.CMBInitResponseMeta,
+	"CMBDepositContractMeta":              metadata.CMBDepositContractMeta,
+	"CMBDepositSendMeta":                  metadata.CMBDepositSendMeta,
+	"CMBWithdrawRequestMeta":              metadata.CMBWithdrawRequestMeta,
+	"CMBWithdrawResponseMeta":             metadata.CMBWithdrawResponseMeta,
+	"BuyFromGOVRequestMeta":               metadata.BuyFromGOVRequestMeta,
+	"BuyBackRequestMeta":                  metadata.BuyBackRequestMeta,
+	"IssuingRequestMeta":                  metadata.IssuingRequestMeta,
+	"ContractingRequestMeta":              metadata.ContractingRequestMeta,
+	"OracleFeedMeta":                      metadata.OracleFeedMeta,
+	"UpdatingOracleBoardMeta":             metadata.UpdatingOracleBoardMeta,
+	"MultiSigsRegistrationMeta":           metadata.MultiSigsRegistrationMeta,
+	"MultiSigsSpendingMeta":               metadata.MultiSigsSpendingMeta,
+	"WithSenderAddressMeta":               metadata.WithSenderAddressMeta,
+	"BuyGOVTokenRequestMeta":              metadata.BuyGOVTokenRequestMeta,
+	"SubmitDCBProposalMeta":               metadata.SubmitDCBProposalMeta,
+	"VoteDCBBoardMeta":                    metadata.VoteDCBBoardMeta,
+	"SubmitGOVProposalMeta":               metadata.SubmitGOVProposalMeta,
+	"VoteGOVBoardMeta":                    metadata.VoteGOVBoardMeta,
+	"SealedLv1DCBVoteProposalMeta":        metadata.SealedLv1DCBVoteProposalMeta,
+	"SealedLv2DCBVoteProposalMeta":        metadata.SealedLv2DCBVoteProposalMeta,
+	"SealedLv3DCBVoteProposalMeta":        metadata.SealedLv3DCBVoteProposalMeta,
+	"NormalDCBVoteProposalFromSealerMeta": metadata.NormalDCBVoteProposalFromSealerMeta,
+	"NormalDCBVoteProposalFromOwnerMeta":  metadata.NormalDCBVoteProposalFromOwnerMeta,
+	"SealedLv1GOVVoteProposalMeta":        metadata.SealedLv1GOVVoteProposalMeta,
+	"SealedLv2GOVVoteProposalMeta":        metadata.SealedLv2GOVVoteProposalMeta,
+	"SealedLv3GOVVoteProposalMeta":        metadata.SealedLv3GOVVoteProposalMeta,
+	"NormalGOVVoteProposalFromSealerMeta": metadata.NormalGOVVoteProposalFromSealerMeta,
+	"NormalGOVVoteProposalFromOwnerMeta":  metadata.NormalGOVVoteProposalFromOwnerMeta,
+	"ShardStakingMeta":                    metadata.ShardStakingMeta,
+	"BeaconStakingMeta":                   metadata.BeaconStakingMeta,
+}
+
+/*
+ParseMetadataPolicyRule - parse a rule from config file, format:
+<metadata type>:<key>=<value>,<key>=<value>,...
+- metadata type: name of metadata type (LoanRequestMeta, OracleFeedMeta,...) or its number
+- keys: minfee, maxperblock, maxpersender, allow, deny
+- allow and deny are lists of sender public keys which are separated by '|'
+Example: OracleFeedMeta:minfee=10,maxpersender=1,allow=<pubkey>|<pubkey>
+*/
+func ParseMetadataPolicyRule(ruleStr string) (*MetadataPolicyRule, error) {
+	parts := strings.SplitN(strings.TrimSpace(ruleStr), ":", 2)
+	metaType, ok := metadataTypeByName[parts[0]]
+	if !ok {
+		var err error
+		metaType, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("unknown metadata type %s", parts[0])
+		}
+	}
+	rule := &MetadataPolicyRule{
+		MetadataType: metaType,
+		AllowList:    make(map[string]bool),
+		DenyList:     make(map[string]bool),
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return rule, nil
+	}
+	for _, option := range strings.Split(parts[1], ",") {
+		keyValue := strings.SplitN(option, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("invalid option %s of metadata type %s", option, parts[0])
+		}
+		key, value := strings.ToLower(keyValue[0]), keyValue[1]
+		var err error
+		switch key {
+		case "minfee":
+			rule.MinFeePerKbTx, err = strconv.ParseUint(value, 10, 64)
+		case "maxperblock":
+			rule.MaxPerBlock, err = strconv.Atoi(value)
+		case "maxpersender":
+			rule.MaxPerSender, err = strconv.Atoi(value)
+		case "allow":
+			for _, pubkey := range strings.Split(value, "|") {
+				rule.AllowList[pubkey] = true
+			}
+		case "deny":
+			for _, pubkey := range strings.Split(value, "|") {
+				rule.DenyList[pubkey] = true
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s of metadata type %s", key, parts[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of option %s: %+v", key, err)
+		}
+	}
+	return rule, nil
+}
+
+// AddMetadataRule add a rule into policy, it replaces the old rule of the same metadata type
+func (self *Policy) AddMetadataRule(rule *MetadataPolicyRule) {
+	if self.MetadataRules == nil {
+		self.MetadataRules = make(map[int]*MetadataPolicyRule)
+	}
+	self.MetadataRules[rule.MetadataType] = rule
+}
+
+// MaxTxsPerBlockByMetadataType return limit of number txs in a block for each metadata type
+func (self *Policy) MaxTxsPerBlockByMetadataType() map[int]int {
+	result := make(map[int]int)
+	for metaType, rule := range self.MetadataRules {
+		if rule.MaxPerBlock > 0 {
+			result[metaType] = rule.MaxPerBlock
+		}
+	}
+	return result
+}
+
+/*
+CheckMetadataPolicy - check tx with rule of its metadata type
+1. Sender is not in deny list and is in allow list (if allow list is not empty)
+2. Fee is not lower than min fee of rule
+3. Number of txs with the same type from the same sender in pool does not exceed the limit
+*/
+func (self *Policy) CheckMetadataPolicy(tx metadata.Transaction, countSameSender func(metaType int, sender string) int) error {
+	if tx.GetMetadata() == nil {
+		return nil
+	}
+	rule, ok := self.MetadataRules[tx.GetMetadataType()]
+	if !ok {
+		return nil
+	}
+	sender := base58.Base58Check{}.Encode(tx.GetSigPubKey(), byte(0x00))
+	if rule.DenyList[sender] || (len(rule.AllowList) > 0 && !rule.AllowList[sender]) {
+		str := fmt.Sprintf("sender %+v is not allowed to send tx with metadata type %d", sender, rule.MetadataType)
+		err := MempoolTxError{}
+		err.Init(RejectMetadataPolicy, errors.New(str))
+		return err
+	}
+	if rule.MinFeePerKbTx > 0 && !tx.CheckTransactionFee(rule.MinFeePerKbTx) {
+		str := fmt.Sprintf("transaction %+v has %d fees which is under the required amount of %d per kb for metadata type %d", tx.Hash().String(), tx.GetTxFee(), rule.MinFeePerKbTx, rule.MetadataType)
+		err := MempoolTxError{}
+		err.Init(RejectInvalidFee, errors.New(str))
+		return err
+	}
+	if rule.MaxPerSender > 0 && countSameSender(rule.MetadataType, sender) >= rule.MaxPerSender {
+		str := fmt.Sprintf("sender %+v has %d txs with metadata type %d in pool already", sender, rule.MaxPerSender, rule.MetadataType)
+		err := MempoolTxError{}
+		err.Init(RejectMetadataPolicy, errors.New(str))
+		return err
+	}
+	return nil
+}
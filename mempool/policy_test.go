@@ -0,0 +1,125 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+func TestParseMetadataPolicyRule(t *testing.T) {
+	rule, err := ParseMetadataPolicyRule("LoanRequestMeta:minfee=100,maxperblock=10,maxpersender=2,allow=pk1|pk2,deny=pk3")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if rule.MetadataType != metadata.LoanRequestMeta || rule.MinFeePerKbTx != 100 || rule.MaxPerBlock != 10 || rule.MaxPerSender != 2 {
+		t.Errorf("wrong rule %+v", rule)
+	}
+	if !rule.AllowList["pk1"] || !rule.AllowList["pk2"] || !rule.DenyList["pk3"] || len(rule.DenyList) != 1 {
+		t.Errorf("wrong allow/deny list %+v %+v", rule.AllowList, rule.DenyList)
+	}
+
+	rule, err = ParseMetadataPolicyRule("1")
+	if err != nil || rule.MetadataType != 1 {
+		t.Errorf("can not parse numeric metadata type %+v %+v", rule, err)
+	}
+
+	for _, invalid := range []string{"UnknownMeta:minfee=1", "OracleFeedMeta:minfee", "OracleFeedMeta:minfee=abc", "OracleFeedMeta:foo=1"} {
+		if _, err := ParseMetadataPolicyRule(invalid); err == nil {
+			t.Errorf("expect error when parsing %s", invalid)
+		}
+	}
+}
+
+func TestMaxTxsPerBlockByMetadataType(t *testing.T) {
+	policy := &Policy{}
+	policy.AddMetadataRule(&MetadataPolicyRule{MetadataType: metadata.OracleFeedMeta, MaxPerBlock: 3})
+	policy.AddMetadataRule(&MetadataPolicyRule{MetadataType: metadata.LoanRequestMeta})
+	limits := policy.MaxTxsPerBlockByMetadataType()
+	if len(limits) != 1 || limits[metadata.OracleFeedMeta] != 3 {
+		t.Errorf("wrong limits %+v", limits)
+	}
+}
+
+// newPolicyTestTx creates a loan withdraw tx which is sent by sigPubKey
+func newPolicyTestTx(sigPubKey []byte, fee uint64) *transaction.Tx {
+	return &transaction.Tx{
+		Type:      common.TxNormalType,
+		Fee:       fee,
+		SigPubKey: sigPubKey,
+		Metadata:  &metadata.LoanWithdraw{LoanID: []byte("loan"), MetadataBase: *metadata.NewMetadataBase(metadata.LoanWithdrawMeta)},
+	}
+}
+
+func checkPolicyError(t *testing.T, err error, key int) {
+	if err == nil {
+		t.Errorf("expected error %d", ErrCodeMessage[key].code)
+		return
+	}
+	if mempoolErr, ok := err.(MempoolTxError); !ok || mempoolErr.code != ErrCodeMessage[key].code {
+		t.Errorf("expected error %d, got %+v", ErrCodeMessage[key].code, err)
+	}
+}
+
+func TestCheckMetadataPolicy(t *testing.T) {
+	allowed, denied, other := []byte{1, 2, 3}, []byte{4, 5, 6}, []byte{7, 8, 9}
+	allowedKey := base58.Base58Check{}.Encode(allowed, byte(0x00))
+	deniedKey := base58.Base58Check{}.Encode(denied, byte(0x00))
+	noTxs := func(metaType int, sender string) int { return 0 }
+
+	policy := &Policy{}
+	policy.AddMetadataRule(&MetadataPolicyRule{
+		MetadataType: metadata.LoanWithdrawMeta,
+		AllowList:    map[string]bool{allowedKey: true, deniedKey: true},
+		DenyList:     map[string]bool{deniedKey: true},
+	})
+	if err := policy.CheckMetadataPolicy(newPolicyTestTx(allowed, 0), noTxs); err != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+	// deny list wins over allow list
+	checkPolicyError(t, policy.CheckMetadataPolicy(newPolicyTestTx(denied, 0), noTxs), RejectMetadataPolicy)
+	checkPolicyError(t, policy.CheckMetadataPolicy(newPolicyTestTx(other, 0), noTxs), RejectMetadataPolicy)
+
+	// txs without metadata or with metadata without rule are not checked
+	if err := policy.CheckMetadataPolicy(&transaction.Tx{Type: common.TxNormalType, SigPubKey: other}, noTxs); err != nil {
+		t.Errorf("unexpected error of tx without metadata %+v", err)
+	}
+	otherMeta := newPolicyTestTx(other, 0)
+	otherMeta.Metadata = &metadata.LoanResponse{LoanID: []byte("loan"), Response: metadata.Accept, MetadataBase: *metadata.NewMetadataBase(metadata.LoanResponseMeta)}
+	if err := policy.CheckMetadataPolicy(otherMeta, noTxs); err != nil {
+		t.Errorf("unexpected error of tx without rule %+v", err)
+	}
+
+	policy.AddMetadataRule(&MetadataPolicyRule{MetadataType: metadata.LoanWithdrawMeta, MinFeePerKbTx: 100})
+	tx := newPolicyTestTx(other, 0)
+	tx.Fee = 100*tx.GetTxActualSize() - 1
+	checkPolicyError(t, policy.CheckMetadataPolicy(tx, noTxs), RejectInvalidFee)
+	tx.Fee++
+	if err := policy.CheckMetadataPolicy(tx, noTxs); err != nil {
+		t.Errorf("unexpected error of tx paying min fee %+v", err)
+	}
+}
+
+func TestCheckMetadataPolicyMaxPerSender(t *testing.T) {
+	sender := []byte{1, 2, 3}
+	senderKey := base58.Base58Check{}.Encode(sender, byte(0x00))
+	policy := &Policy{}
+	policy.AddMetadataRule(&MetadataPolicyRule{MetadataType: metadata.LoanWithdrawMeta, MaxPerSender: 2})
+
+	for count, valid := range []bool{true, true, false, false} {
+		err := policy.CheckMetadataPolicy(newPolicyTestTx(sender, 0), func(metaType int, key string) int {
+			if metaType != metadata.LoanWithdrawMeta || key != senderKey {
+				t.Errorf("unexpected count of metadata type %d of sender %s", metaType, key)
+			}
+			return count
+		})
+		if valid && err != nil {
+			t.Errorf("%d txs in pool: unexpected error %+v", count, err)
+		}
+		if !valid {
+			checkPolicyError(t, err, RejectMetadataPolicy)
+		}
+	}
+}
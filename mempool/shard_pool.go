@@ -36,16 +36,24 @@ func InitShardPool(pool map[byte]blockchain.ShardPool) {
 // get singleton instance of ShardToBeacon pool
 func GetShardPool(shardID byte) *ShardPool {
 	if shardPoolMap[shardID] == nil {
-		shardPool := new(ShardPool)
-		shardPool.shardID = shardID
-		shardPool.pool = []*blockchain.ShardBlock{}
-		shardPool.latestValidHeight = 1
-		shardPoolMap[shardID] = shardPool
+		shardPoolMap[shardID] = NewShardPool(shardID)
 	}
 	return shardPoolMap[shardID]
 }
 
+// NewShardPool creates a pool of shard blocks which is not shared with other nodes of the process
+func NewShardPool(shardID byte) *ShardPool {
+	shardPool := new(ShardPool)
+	shardPool.shardID = shardID
+	shardPool.pool = []*blockchain.ShardBlock{}
+	shardPool.latestValidHeight = 1
+	shardPool.poolMu = new(sync.Mutex)
+	return shardPool
+}
+
 func (self *ShardPool) SetShardState(lastestShardHeight uint64) {
+	self.poolMu.Lock()
+	defer self.poolMu.Unlock()
 	self.latestValidHeight = lastestShardHeight
 
 	//Remove pool base on new shardstate
@@ -186,3 +194,10 @@ func (self *ShardPool) GetAllBlockHeight() []uint64 {
 	}
 	return finalBlocks
 }
+
+// CountBlocks returns number of blocks in pool, it is safe to call while blocks are added
+func (self *ShardPool) CountBlocks() int {
+	self.poolMu.Lock()
+	defer self.poolMu.Unlock()
+	return len(self.pool)
+}
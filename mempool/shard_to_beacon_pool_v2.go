@@ -31,15 +31,21 @@ func InitShardToBeaconPool() {
 // get singleton instance of ShardToBeacon pool
 func GetShardToBeaconPool() *ShardToBeaconPool {
 	if shardToBeaconPool == nil {
-		shardToBeaconPool = new(ShardToBeaconPool)
-		shardToBeaconPool.pool = make(map[byte][]*blockchain.ShardToBeaconBlock)
-		shardToBeaconPool.poolMutex = new(sync.RWMutex)
-		shardToBeaconPool.latestValidHeight = make(map[byte]uint64)
-		shardToBeaconPool.latestValidHeightMutex = new(sync.RWMutex)
+		shardToBeaconPool = NewShardToBeaconPool()
 	}
 	return shardToBeaconPool
 }
 
+// NewShardToBeaconPool creates a ShardToBeacon pool which is not shared with other nodes of the process
+func NewShardToBeaconPool() *ShardToBeaconPool {
+	pool := new(ShardToBeaconPool)
+	pool.pool = make(map[byte][]*blockchain.ShardToBeaconBlock)
+	pool.poolMutex = new(sync.RWMutex)
+	pool.latestValidHeight = make(map[byte]uint64)
+	pool.latestValidHeightMutex = new(sync.RWMutex)
+	return pool
+}
+
 func (self *ShardToBeaconPool) SetShardState(latestShardState map[byte]uint64) {
 	self.latestValidHeightMutex.Lock()
 	defer self.latestValidHeightMutex.Unlock()
@@ -241,6 +247,8 @@ func (self *ShardToBeaconPool) GetLatestValidPendingBlockHeight() map[byte]uint6
 }
 
 func (self *ShardToBeaconPool) GetAllPendingBlockHeight() map[byte][]uint64 {
+	self.poolMutex.RLock()
+	defer self.poolMutex.RUnlock()
 	finalBlocks := make(map[byte][]uint64)
 	for shardID, blks := range self.pool {
 		for _, blk := range blks {
@@ -249,3 +257,14 @@ func (self *ShardToBeaconPool) GetAllPendingBlockHeight() map[byte][]uint64 {
 	}
 	return finalBlocks
 }
+
+// CountPendingBlocks returns number of blocks in pool of each shard, it is safe to call while blocks are added
+func (self *ShardToBeaconPool) CountPendingBlocks() map[byte]int {
+	self.poolMutex.RLock()
+	defer self.poolMutex.RUnlock()
+	counts := make(map[byte]int)
+	for shardID, blks := range self.pool {
+		counts[shardID] = len(blks)
+	}
+	return counts
+}
@@ -0,0 +1,210 @@
+package mempool
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+)
+
+// stemTx is a transaction in stem phase of dandelion,
+// it is fluffed by this node when its embargo expires before it is seen in fluff phase
+type stemTx struct {
+	tx      metadata.Transaction
+	added   time.Time
+	embargo time.Time
+}
+
+// isStemDoubleSpend returns whether tx spends a serial number which is spent by another stem tx,
+// this function MUST be called with the mempool lock held
+func (tp *TxPool) isStemDoubleSpend(tx metadata.Transaction) bool {
+	txHash := tx.Hash()
+	for stemHash, stem := range tp.stemPool {
+		if stemHash == *txHash {
+			continue
+		}
+		for _, stemSN := range stem.tx.ListNullifiers() {
+			for _, sn := range tx.ListNullifiers() {
+				if bytes.Equal(stemSN, sn) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+/*
+MaybeAcceptStemTransaction - validate a tx in stem phase with all rules of mempool
+and keep it in stem pool until embargo, it is not added into pool so it is neither mined
+nor served to peers before it is fluffed.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) MaybeAcceptStemTransaction(tx metadata.Transaction, embargo time.Duration) (*common.Hash, error) {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+
+	txHash := tx.Hash()
+	if _, exists := tp.stemPool[*txHash]; exists {
+		err := MempoolTxError{}
+		err.Init(RejectDuplicateTx, fmt.Errorf("already have stem transaction %+v", txHash.String()))
+		return nil, err
+	}
+	if len(tp.stemPool) >= DefaultMaxStemTxs {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("stem pool is full, transaction %+v is rejected", txHash.String()))
+		return nil, err
+	}
+	if !isStemmable(tx) {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("transaction %+v can not be stemmed", txHash.String()))
+		return nil, err
+	}
+	// validation rejects a tx which double spends a stem tx
+	err := tp.validateTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	now := tp.now()
+	tp.stemPool[*txHash] = &stemTx{
+		tx:      tx,
+		added:   now,
+		embargo: now.Add(embargo),
+	}
+	return txHash, nil
+}
+
+// isStemmable returns whether tx can be relayed in stem phase,
+// stem message only carries normal txs and staking txs are registered in candidate list of pool so they are always fluffed
+func isStemmable(tx metadata.Transaction) bool {
+	if tx.GetType() != common.TxNormalType {
+		return false
+	}
+	if tx.GetMetadata() != nil {
+		metaType := tx.GetMetadata().GetType()
+		if metaType == metadata.ShardStakingMeta || metaType == metadata.BeaconStakingMeta {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+StemTransaction - move a tx which is created by this node from pool into stem pool,
+so that it is not served to peers before it is fluffed by another node or its embargo expires.
+Its serial numbers are checked against stem pool and its coins stay reserved,
+so that neither a tx from peers nor a new tx of wallet double spends it in the meantime.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) StemTransaction(txHash *common.Hash, embargo time.Duration) error {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+
+	txDesc, exists := tp.pool[*txHash]
+	if !exists {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("transaction %+v is not in pool", txHash.String()))
+		return err
+	}
+	if !isStemmable(txDesc.Desc.Tx) {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("transaction %+v can not be stemmed", txHash.String()))
+		return err
+	}
+	if len(tp.stemPool) >= DefaultMaxStemTxs {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("stem pool is full, transaction %+v is not stemmed", txHash.String()))
+		return err
+	}
+	delete(tp.pool, *txHash)
+	delete(tp.poolSerialNumbers, *txHash)
+	now := tp.now()
+	tp.stemPool[*txHash] = &stemTx{
+		tx:      txDesc.Desc.Tx,
+		added:   now,
+		embargo: now.Add(embargo),
+	}
+	return nil
+}
+
+/*
+FluffStemTransaction - move a stem tx into pool, it returns error when tx is not in stem pool
+or it is not valid anymore.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) FluffStemTransaction(txHash *common.Hash) (*TxDesc, error) {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+
+	stem, exists := tp.stemPool[*txHash]
+	if !exists {
+		err := MempoolTxError{}
+		err.Init(RejectStemTx, fmt.Errorf("transaction %+v is not in stem pool", txHash.String()))
+		return nil, err
+	}
+	delete(tp.stemPool, *txHash)
+	_, txDesc, err := tp.maybeAcceptTransaction(stem.tx)
+	if err != nil {
+		tp.releaseStemTx(txHash)
+	}
+	return txDesc, err
+}
+
+// releaseStemTx releases coins which are reserved by a stem tx that is dropped, coins of a tx in pool are kept,
+// this function MUST be called with the mempool lock held
+func (tp *TxPool) releaseStemTx(txHash *common.Hash) {
+	if !tp.isTxInPool(txHash) {
+		tp.RemoveTxCoinHashH(*txHash)
+	}
+}
+
+/*
+ReleaseStemTransaction - release coins of an expired stem tx which can not be fluffed.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) ReleaseStemTransaction(txHash *common.Hash) {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+	tp.releaseStemTx(txHash)
+}
+
+/*
+ExpiredStemTransactions - return stem txs whose embargo expired,
+they are removed from stem pool and caller fluffs them.
+
+This function is safe for concurrent access.
+*/
+func (tp *TxPool) ExpiredStemTransactions(now time.Time) []metadata.Transaction {
+	tp.mtx.Lock()
+	defer tp.mtx.Unlock()
+
+	expired := []metadata.Transaction{}
+	for txHash, stem := range tp.stemPool {
+		if now.After(stem.embargo) {
+			expired = append(expired, stem.tx)
+			delete(tp.stemPool, txHash)
+		}
+	}
+	return expired
+}
+
+// HaveStemTransaction returns whether tx is in stem pool
+func (tp *TxPool) HaveStemTransaction(txHash *common.Hash) bool {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+	_, exists := tp.stemPool[*txHash]
+	return exists
+}
+
+// StemCount returns number of txs in stem pool
+func (tp *TxPool) StemCount() int {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+	return len(tp.stemPool)
+}
@@ -0,0 +1,127 @@
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+)
+
+// stemTestTx spends a serial number, so that double spends between stem pool and pool are detected
+type stemTestTx struct {
+	*orphanTestTx
+	serialNumber []byte
+}
+
+func (tx *stemTestTx) ListNullifiers() [][]byte { return [][]byte{tx.serialNumber} }
+
+func newStemTestTx(lockTime int64, serialNumber byte) *stemTestTx {
+	return &stemTestTx{
+		orphanTestTx: newOrphanTestTx(lockTime, 0, false),
+		serialNumber: []byte{serialNumber},
+	}
+}
+
+func newStemTestPool(clock common.Clock) *TxPool {
+	tp := newOrphanTestPool(&orphanTestDB{commitmentLength: 1})
+	tp.config.Clock = clock
+	return tp
+}
+
+func TestStemTransactionEmbargo(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	tp := newStemTestPool(clock)
+
+	tx := newStemTestTx(1, 1)
+	if _, err := tp.MaybeAcceptStemTransaction(tx, time.Minute); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if !tp.HaveStemTransaction(tx.Hash()) || tp.HaveTransaction(tx.Hash()) {
+		t.Fatalf("expected tx in stem pool only")
+	}
+	if _, err := tp.MaybeAcceptStemTransaction(tx, time.Minute); err == nil {
+		t.Errorf("expected duplicate stem tx to be rejected")
+	}
+
+	if expired := tp.ExpiredStemTransactions(clock.Now().Add(30 * time.Second)); len(expired) != 0 {
+		t.Errorf("expected no expired stem tx before embargo")
+	}
+	expired := tp.ExpiredStemTransactions(clock.Now().Add(2 * time.Minute))
+	if len(expired) != 1 || expired[0] != metadata.Transaction(tx) || tp.StemCount() != 0 {
+		t.Fatalf("expected stem tx to expire after embargo")
+	}
+
+	// expired tx is fluffed into pool
+	if _, _, err := tp.MaybeAcceptTransaction(expired[0]); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if !tp.HaveTransaction(tx.Hash()) {
+		t.Errorf("expected fluffed tx in pool")
+	}
+}
+
+func TestStemTransactionDoubleSpend(t *testing.T) {
+	tp := newStemTestPool(common.NewManualClock(time.Unix(1000, 0)))
+
+	// own tx is added into pool then moved into stem pool
+	own := newStemTestTx(1, 1)
+	coinHash := common.HashH([]byte("coin"))
+	tp.PrePoolTxCoinHashH(*own.Hash(), []common.Hash{coinHash})
+	if _, _, err := tp.MaybeAcceptTransaction(own); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if err := tp.StemTransaction(own.Hash(), time.Minute); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if tp.HaveTransaction(own.Hash()) || !tp.HaveStemTransaction(own.Hash()) {
+		t.Fatalf("expected own tx in stem pool only")
+	}
+	if tp.ValidateCoinHashH(coinHash) == nil {
+		t.Errorf("expected coins of stem tx to stay reserved")
+	}
+
+	// txs which spend the same serial number are rejected in both phases
+	doubleSpend := newStemTestTx(2, 1)
+	if _, _, err := tp.MaybeAcceptTransaction(doubleSpend); err == nil {
+		t.Errorf("expected tx which double spends stem tx to be rejected")
+	}
+	if _, err := tp.MaybeAcceptStemTransaction(doubleSpend, time.Minute); err == nil {
+		t.Errorf("expected stem tx which double spends stem tx to be rejected")
+	}
+	if _, err := tp.MaybeAcceptStemTransaction(newStemTestTx(3, 2), time.Minute); err != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+
+	// stem tx is fluffed with its own serial number
+	if _, err := tp.FluffStemTransaction(own.Hash()); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if !tp.HaveTransaction(own.Hash()) || tp.HaveStemTransaction(own.Hash()) {
+		t.Errorf("expected fluffed tx in pool")
+	}
+	if tp.ValidateCoinHashH(coinHash) == nil {
+		t.Errorf("expected coins of fluffed tx to stay reserved")
+	}
+}
+
+func TestReleaseStemTransaction(t *testing.T) {
+	tp := newStemTestPool(common.NewManualClock(time.Unix(1000, 0)))
+	own := newStemTestTx(1, 1)
+	coinHash := common.HashH([]byte("coin"))
+	tp.PrePoolTxCoinHashH(*own.Hash(), []common.Hash{coinHash})
+	if _, _, err := tp.MaybeAcceptTransaction(own); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if err := tp.StemTransaction(own.Hash(), time.Minute); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(tp.ExpiredStemTransactions(time.Unix(2000, 0))) != 1 {
+		t.Fatalf("expected stem tx to expire")
+	}
+	// expired tx which can not be fluffed releases its coins
+	tp.ReleaseStemTransaction(own.Hash())
+	if err := tp.ValidateCoinHashH(coinHash); err != nil {
+		t.Errorf("expected coins to be released, got %+v", err)
+	}
+}
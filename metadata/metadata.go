@@ -91,8 +91,8 @@ type TxDesc struct {
 	// Fee is the total fee the transaction associated with the entry pays.
 	Fee uint64
 
-	// FeePerKB is the fee the transaction pays in coin per 1000 bytes.
-	FeePerKB int32
+	// FeePerKB is the fee the transaction pays in coin per kilobyte of its actual size.
+	FeePerKB uint64
 }
 
 // Interface for mempool which is used in metadata
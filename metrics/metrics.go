@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace is prefix of names of metrics of node
+const Namespace = "constant"
+
+// BeaconChain is label of beacon chain, shard chains are labelled by their shard id
+const BeaconChain = "beacon"
+
+// Stages of block verification
+const (
+	VerifyPreSign       = "presign"
+	VerifyPreProcessing = "preprocessing"
+)
+
+// durationBuckets range from 1ms to about 16s
+var durationBuckets = prometheus.ExponentialBuckets(0.001, 2, 15)
+
+var (
+	// BlockInsertDuration is time to insert a block into its chain, including its verification and storing it
+	BlockInsertDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "block_insert_duration_seconds",
+		Help:      "Time to insert a block into a chain.",
+		Buckets:   durationBuckets,
+	}, []string{"chain"})
+
+	// BlockVerifyDuration is time to verify a block before it is signed or before it is inserted
+	BlockVerifyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "block_verify_duration_seconds",
+		Help:      "Time to verify a block of a chain by stage.",
+		Buckets:   durationBuckets,
+	}, []string{"chain", "stage"})
+
+	// BFTPhaseDuration is time which a BFT round spends in a phase
+	BFTPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "bft_phase_duration_seconds",
+		Help:      "Time spent in a BFT phase by layer.",
+		Buckets:   durationBuckets,
+	}, []string{"layer", "phase"})
+
+	// BFTTimeouts counts BFT phases which time out
+	BFTTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "bft_timeouts_total",
+		Help:      "Number of BFT phases which timed out by layer.",
+	}, []string{"layer", "phase"})
+
+	// ProofVerifyDuration is time to verify a zero knowledge payment proof of a transaction
+	ProofVerifyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "zkp_verify_duration_seconds",
+		Help:      "Time to verify a payment proof of a transaction, by whether it has privacy.",
+		Buckets:   durationBuckets,
+	}, []string{"privacy"})
+
+	// RPCRequestDuration is time to execute a RPC command
+	RPCRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Time to execute a RPC command by method.",
+		Buckets:   durationBuckets,
+	}, []string{"method"})
+
+	// RPCRequestErrors counts RPC commands which return an error
+	RPCRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rpc_request_errors_total",
+		Help:      "Number of RPC commands which returned an error by method.",
+	}, []string{"method"})
+)
+
+// Collectors returns collectors of events of node, they are registered into registry of metrics endpoint.
+// Collectors are package level, so nodes which run in one process share them
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		BlockInsertDuration,
+		BlockVerifyDuration,
+		BFTPhaseDuration,
+		BFTTimeouts,
+		ProofVerifyDuration,
+		RPCRequestDuration,
+		RPCRequestErrors,
+	}
+}
+
+// ShardChain returns label of chain of a shard
+func ShardChain(shardID byte) string {
+	return strconv.Itoa(int(shardID))
+}
+
+// ObserveSince observes seconds elapsed since start, it is meant to be deferred at the beginning of a measured function
+func ObserveSince(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
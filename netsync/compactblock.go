@@ -0,0 +1,294 @@
+package netsync
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	// blocks which are sent as compact blocks are kept to serve getblocktxn
+	maxCachedCompactBlocks = 32
+	// a compact block which still misses transactions after this timeout is dropped
+	compactBlockTimeout = time.Minute
+)
+
+type pendingCompactBlock struct {
+	// partial is nil when a proposal waits for its full block
+	partial *blockchain.PartialShardBlock
+	shardID byte
+	// peerID is the peer which delivered the compact block, it is asked for missing transactions
+	peerID libp2p.ID
+	// propose is the BFT proposal which carries the compact block, nil when block is relayed
+	propose *wire.MessageBFTPropose
+	expiry  time.Time
+}
+
+// compactBlockCache keeps full blocks which are sent compact and compact blocks which wait for transactions
+type compactBlockCache struct {
+	blocks     map[common.Hash]*blockchain.ShardBlock
+	blockOrder []common.Hash
+	pending    map[common.Hash]*pendingCompactBlock
+	mtx        sync.Mutex
+}
+
+func newCompactBlockCache() *compactBlockCache {
+	return &compactBlockCache{
+		blocks:  make(map[common.Hash]*blockchain.ShardBlock),
+		pending: make(map[common.Hash]*pendingCompactBlock),
+	}
+}
+
+func (cache *compactBlockCache) addBlock(block *blockchain.ShardBlock) {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	hash := *block.Hash()
+	if _, ok := cache.blocks[hash]; ok {
+		return
+	}
+	if len(cache.blockOrder) >= maxCachedCompactBlocks {
+		delete(cache.blocks, cache.blockOrder[0])
+		cache.blockOrder = cache.blockOrder[1:]
+	}
+	cache.blocks[hash] = block
+	cache.blockOrder = append(cache.blockOrder, hash)
+}
+
+func (cache *compactBlockCache) getBlock(hash common.Hash) *blockchain.ShardBlock {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	return cache.blocks[hash]
+}
+
+func (cache *compactBlockCache) addPending(hash common.Hash, pending *pendingCompactBlock) {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	now := time.Now()
+	for pendingHash, p := range cache.pending {
+		if now.After(p.expiry) {
+			delete(cache.pending, pendingHash)
+		}
+	}
+	pending.expiry = now.Add(compactBlockTimeout)
+	cache.pending[hash] = pending
+}
+
+func (cache *compactBlockCache) takePending(hash common.Hash) *pendingCompactBlock {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+	pending, ok := cache.pending[hash]
+	if !ok {
+		return nil
+	}
+	delete(cache.pending, hash)
+	return pending
+}
+
+/*
+CompactShardBlock - make compact block of a shard block which is about to be sent,
+the full block is kept to serve getblocktxn of receivers
+*/
+func (netSync *NetSync) CompactShardBlock(block *blockchain.ShardBlock) *blockchain.CompactShardBlock {
+	netSync.compactBlocks.addBlock(block)
+	return blockchain.NewCompactShardBlock(block, rand.Uint64())
+}
+
+func (netSync *NetSync) mempoolTxs() []metadata.Transaction {
+	txDescs := netSync.config.MemTxPool.MiningDescs()
+	txs := make([]metadata.Transaction, 0, len(txDescs))
+	for _, txDesc := range txDescs {
+		txs = append(txs, txDesc.Tx)
+	}
+	return txs
+}
+
+// deliveringPeer returns the peer which delivered a message, sender of message when it is unknown
+func deliveringPeer(peerID libp2p.ID, senderID string) (libp2p.ID, error) {
+	if peerID != "" {
+		return peerID, nil
+	}
+	return libp2p.IDB58Decode(senderID)
+}
+
+/*
+HandleMessageCompactBlockShard - rebuild a relayed compact block from mempool,
+peerID is the peer which delivered it
+*/
+func (netSync *NetSync) HandleMessageCompactBlockShard(msg *wire.MessageCompactBlockShard, peerID libp2p.ID) {
+	Logger.log.Info("Handling new message - " + wire.CmdCompactBlockShard)
+	peerID, err := deliveringPeer(peerID, msg.SenderID)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	blockHash := msg.Block.Hash()
+	if _, err := netSync.config.BlockChain.GetShardBlockByHash(blockHash); err == nil {
+		return
+	}
+	pending := &pendingCompactBlock{
+		shardID: msg.Block.Block.Header.ShardID,
+		peerID:  peerID,
+	}
+	pending.partial, err = msg.Block.Rebuild(netSync.mempoolTxs())
+	if err != nil {
+		Logger.log.Error(err)
+		netSync.requestFullShardBlock(*blockHash, pending)
+		return
+	}
+	netSync.completeCompactBlock(*blockHash, pending)
+}
+
+/*
+handleCompactPropose - rebuild block of a BFT proposal which carries a compact block,
+the proposal is passed to consensus with the rebuilt block, or with the full block
+which is requested from peerID when compact block can not be rebuilt
+*/
+func (netSync *NetSync) handleCompactPropose(msg *wire.MessageBFTPropose, peerID libp2p.ID) {
+	peerID, err := deliveringPeer(peerID, msg.SenderID)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	compact := blockchain.CompactShardBlock{}
+	err = json.Unmarshal(msg.Block, &compact)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	pending := &pendingCompactBlock{
+		shardID: compact.Block.Header.ShardID,
+		peerID:  peerID,
+		propose: msg,
+	}
+	pending.partial, err = compact.Rebuild(netSync.mempoolTxs())
+	if err != nil {
+		Logger.log.Error(err)
+		netSync.requestFullShardBlock(*compact.Hash(), pending)
+		return
+	}
+	netSync.completeCompactBlock(*compact.Hash(), pending)
+}
+
+// completeCompactBlock finishes a compact block or requests its missing transactions from the peer which delivered it
+func (netSync *NetSync) completeCompactBlock(blockHash common.Hash, pending *pendingCompactBlock) {
+	missing := pending.partial.MissingIndexes()
+	if len(missing) == 0 {
+		netSync.finishCompactBlock(blockHash, pending)
+		return
+	}
+	Logger.log.Infof("Compact block %s misses %d transactions", blockHash.String(), len(missing))
+	netSync.compactBlocks.addPending(blockHash, pending)
+	msg, err := wire.MakeEmptyMessage(wire.CmdGetBlockTxn)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	msg.(*wire.MessageGetBlockTxn).BlockHash = blockHash
+	msg.(*wire.MessageGetBlockTxn).Indexes = missing
+	err = netSync.config.Server.PushMessageToPeer(msg, pending.peerID)
+	if err != nil {
+		Logger.log.Error(err)
+	}
+}
+
+func (netSync *NetSync) finishCompactBlock(blockHash common.Hash, pending *pendingCompactBlock) {
+	block, err := pending.partial.ShardBlock()
+	if err != nil {
+		Logger.log.Error(err)
+		netSync.requestFullShardBlock(blockHash, pending)
+		return
+	}
+	// peers which receive the compact block from this node request missing transactions from it
+	netSync.compactBlocks.addBlock(block)
+	if pending.propose != nil {
+		pending.propose.ShardBlock = block
+		netSync.config.Consensus.OnBFTMsg(pending.propose)
+		return
+	}
+	netSync.HandleMessageBlockShard(&wire.MessageBlockShard{Block: *block})
+}
+
+/*
+requestFullShardBlock - request a block which can not be rebuilt from its compact block,
+a proposal is kept pending so that it is passed to consensus when the full block arrives
+*/
+func (netSync *NetSync) requestFullShardBlock(blockHash common.Hash, pending *pendingCompactBlock) {
+	if pending.propose != nil {
+		pending.partial = nil
+		netSync.compactBlocks.addPending(blockHash, pending)
+	}
+	msg, err := wire.MakeEmptyMessage(wire.CmdGetBlockShard)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	msg.(*wire.MessageGetBlockShard).ByHash = true
+	msg.(*wire.MessageGetBlockShard).BlksHash = []common.Hash{blockHash}
+	msg.(*wire.MessageGetBlockShard).ShardID = pending.shardID
+	err = netSync.config.Server.PushMessageToPeer(msg, pending.peerID)
+	if err != nil {
+		Logger.log.Error(err)
+	}
+}
+
+func (netSync *NetSync) HandleMessageGetBlockTxn(msg *wire.MessageGetBlockTxn) {
+	Logger.log.Info("Handling new message - " + wire.CmdGetBlockTxn)
+	peerID, err := libp2p.IDB58Decode(msg.SenderID)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	block := netSync.compactBlocks.getBlock(msg.BlockHash)
+	if block == nil {
+		block, err = netSync.config.BlockChain.GetShardBlockByHash(&msg.BlockHash)
+		if err != nil {
+			Logger.log.Error(err)
+			return
+		}
+	}
+	newMsg, err := wire.MakeEmptyMessage(wire.CmdBlockTxn)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	newMsg.(*wire.MessageBlockTxn).BlockHash = msg.BlockHash
+	for _, index := range msg.Indexes {
+		if index < 0 || index >= len(block.Body.Transactions) {
+			Logger.log.Errorf("Index %d of getblocktxn is out of block %s", index, msg.BlockHash.String())
+			return
+		}
+		newMsg.(*wire.MessageBlockTxn).Indexes = append(newMsg.(*wire.MessageBlockTxn).Indexes, index)
+		newMsg.(*wire.MessageBlockTxn).Transactions = append(newMsg.(*wire.MessageBlockTxn).Transactions, block.Body.Transactions[index])
+	}
+	err = netSync.config.Server.PushMessageToPeer(newMsg, peerID)
+	if err != nil {
+		Logger.log.Error(err)
+	}
+}
+
+func (netSync *NetSync) HandleMessageBlockTxn(msg *wire.MessageBlockTxn) {
+	Logger.log.Info("Handling new message - " + wire.CmdBlockTxn)
+	pending := netSync.compactBlocks.takePending(msg.BlockHash)
+	if pending == nil {
+		return
+	}
+	if pending.partial == nil {
+		// proposal waits for its full block
+		netSync.compactBlocks.addPending(msg.BlockHash, pending)
+		return
+	}
+	err := pending.partial.Fill(msg.Indexes, msg.Transactions)
+	if err != nil {
+		Logger.log.Error(err)
+		netSync.requestFullShardBlock(msg.BlockHash, pending)
+		return
+	}
+	netSync.finishCompactBlock(msg.BlockHash, pending)
+}
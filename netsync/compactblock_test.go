@@ -0,0 +1,177 @@
+package netsync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/transaction"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+type sentMessage struct {
+	msg    wire.Message
+	peerID libp2p.ID
+}
+
+// compactTestServer records messages which netsync sends to peers
+type compactTestServer struct {
+	sent []sentMessage
+}
+
+func (server *compactTestServer) PushMessageToPeer(msg wire.Message, peerID libp2p.ID) error {
+	server.sent = append(server.sent, sentMessage{msg: msg, peerID: peerID})
+	return nil
+}
+
+func (server *compactTestServer) PushMessageToAll(msg wire.Message) error { return nil }
+
+func (server *compactTestServer) RelayMessageTx(msg *wire.MessageTx) error { return nil }
+
+// compactTestConsensus records proposals which are passed to consensus
+type compactTestConsensus struct {
+	msgs []wire.Message
+}
+
+func (consensus *compactTestConsensus) OnBFTMsg(msg wire.Message) {
+	consensus.msgs = append(consensus.msgs, msg)
+}
+
+const deliveringPeerID = libp2p.ID("delivering peer")
+
+func newCompactTestNetSync() (*NetSync, *compactTestServer, *compactTestConsensus) {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("NetSync test", true))
+	blockchain.Logger.Init(common.NewBackend(ioutil.Discard).Logger("BlockChain test", true))
+	txPool := &mempool.TxPool{}
+	txPool.Init(&mempool.Config{})
+	server := &compactTestServer{}
+	consensus := &compactTestConsensus{}
+	netSync := NetSync{}.New(&NetSyncConfig{
+		MemTxPool: txPool,
+		Server:    server,
+		Consensus: consensus,
+	})
+	return netSync, server, consensus
+}
+
+func newCompactTestBlock(numTxs int) *blockchain.ShardBlock {
+	block := &blockchain.ShardBlock{}
+	block.Header.ProducerAddress = &privacy.PaymentAddress{}
+	block.Header.ShardID = 1
+	for i := 0; i < numTxs; i++ {
+		block.Body.Transactions = append(block.Body.Transactions, &transaction.Tx{Type: common.TxNormalType, LockTime: int64(i + 1)})
+	}
+	txMerkle := blockchain.Merkle{}.BuildMerkleTreeStore(block.Body.Transactions)
+	block.Header.TxRoot = *txMerkle[len(txMerkle)-1]
+	return block
+}
+
+// newCompactTestPropose makes a compact proposal which sender is not a valid peer id, so it must be delivered by a known peer
+func newCompactTestPropose(t *testing.T, compact *blockchain.CompactShardBlock) *wire.MessageBFTPropose {
+	compactBytes, err := json.Marshal(compact)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return &wire.MessageBFTPropose{
+		Layer:        common.SHARD_ROLE,
+		ShardID:      1,
+		Block:        compactBytes,
+		CompactBlock: true,
+		SenderID:     "sender",
+	}
+}
+
+func TestCompactProposeRebuild(t *testing.T) {
+	netSync, server, consensus := newCompactTestNetSync()
+	block := newCompactTestBlock(2)
+	propose := newCompactTestPropose(t, blockchain.NewCompactShardBlock(block, 1))
+
+	// mempool is empty so all transactions are requested from the peer which delivered the proposal
+	netSync.handleCompactPropose(propose, deliveringPeerID)
+	if len(server.sent) != 1 || server.sent[0].peerID != deliveringPeerID {
+		t.Fatalf("expected getblocktxn to delivering peer, got %+v", server.sent)
+	}
+	getBlockTxn, ok := server.sent[0].msg.(*wire.MessageGetBlockTxn)
+	if !ok || getBlockTxn.BlockHash != *block.Hash() || len(getBlockTxn.Indexes) != 2 {
+		t.Fatalf("wrong getblocktxn %+v", server.sent[0].msg)
+	}
+	if len(consensus.msgs) != 0 {
+		t.Fatalf("proposal is passed to consensus before block is rebuilt")
+	}
+
+	netSync.HandleMessageBlockTxn(&wire.MessageBlockTxn{
+		BlockHash:    *block.Hash(),
+		Indexes:      getBlockTxn.Indexes,
+		Transactions: block.Body.Transactions,
+	})
+	if len(consensus.msgs) != 1 || consensus.msgs[0] != propose {
+		t.Fatalf("expected rebuilt proposal to be passed to consensus")
+	}
+	if propose.ShardBlock == nil || *propose.ShardBlock.Hash() != *block.Hash() || len(propose.ShardBlock.Body.Transactions) != 2 {
+		t.Errorf("wrong rebuilt block %+v", propose.ShardBlock)
+	}
+	// rebuilt block is served to peers which request it from this node
+	if netSync.compactBlocks.getBlock(*block.Hash()) == nil {
+		t.Errorf("expected rebuilt block to be cached")
+	}
+}
+
+func TestCompactProposeFallback(t *testing.T) {
+	netSync, server, consensus := newCompactTestNetSync()
+	block := newCompactTestBlock(2)
+	compact := blockchain.NewCompactShardBlock(block, 1)
+	// a prefilled index without prefilled transaction can not be rebuilt
+	compact.PrefilledIndexes = []int{0}
+	propose := newCompactTestPropose(t, compact)
+
+	netSync.handleCompactPropose(propose, deliveringPeerID)
+	if len(server.sent) != 1 || server.sent[0].peerID != deliveringPeerID {
+		t.Fatalf("expected full block to be requested from delivering peer, got %+v", server.sent)
+	}
+	getBlock, ok := server.sent[0].msg.(*wire.MessageGetBlockShard)
+	if !ok || !getBlock.ByHash || len(getBlock.BlksHash) != 1 || getBlock.BlksHash[0] != *block.Hash() || getBlock.ShardID != 1 {
+		t.Fatalf("wrong getblockshard %+v", server.sent[0].msg)
+	}
+
+	netSync.HandleMessageBlockShard(&wire.MessageBlockShard{Block: *block})
+	if len(consensus.msgs) != 1 || consensus.msgs[0] != propose || propose.ShardBlock == nil || *propose.ShardBlock.Hash() != *block.Hash() {
+		t.Fatalf("expected proposal to be passed to consensus with full block")
+	}
+}
+
+func TestCompactProposeFallbackOnWrongTxs(t *testing.T) {
+	netSync, server, consensus := newCompactTestNetSync()
+	block := newCompactTestBlock(2)
+	propose := newCompactTestPropose(t, blockchain.NewCompactShardBlock(block, 1))
+
+	netSync.handleCompactPropose(propose, deliveringPeerID)
+	otherTxs := []metadata.Transaction{
+		&transaction.Tx{Type: common.TxNormalType, LockTime: 100},
+		&transaction.Tx{Type: common.TxNormalType, LockTime: 101},
+	}
+	netSync.HandleMessageBlockTxn(&wire.MessageBlockTxn{
+		BlockHash:    *block.Hash(),
+		Indexes:      []int{0, 1},
+		Transactions: otherTxs,
+	})
+	if len(server.sent) != 2 || server.sent[1].peerID != deliveringPeerID {
+		t.Fatalf("expected full block to be requested from delivering peer, got %+v", server.sent)
+	}
+	if _, ok := server.sent[1].msg.(*wire.MessageGetBlockShard); !ok {
+		t.Fatalf("wrong request %+v", server.sent[1].msg)
+	}
+	if len(consensus.msgs) != 0 {
+		t.Fatalf("proposal is passed to consensus with wrong transactions")
+	}
+
+	netSync.HandleMessageBlockShard(&wire.MessageBlockShard{Block: *block})
+	if len(consensus.msgs) != 1 || consensus.msgs[0] != propose {
+		t.Errorf("expected proposal to be passed to consensus with full block")
+	}
+}
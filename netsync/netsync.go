@@ -21,6 +21,8 @@ type NetSync struct {
 	cQuit    chan struct{}
 
 	config *NetSyncConfig
+	// compactBlocks keeps compact shard blocks which are being rebuilt from mempool
+	compactBlocks *compactBlockCache
 }
 
 type NetSyncConfig struct {
@@ -33,16 +35,21 @@ type NetSyncConfig struct {
 		// list functions callback which are assigned from Server struct
 		PushMessageToPeer(wire.Message, libp2p.ID) error
 		PushMessageToAll(wire.Message) error
+		// RelayMessageTx announces a tx of a peer to all peers, it is never stemmed
+		RelayMessageTx(*wire.MessageTx) error
 	}
 	Consensus interface {
 		OnBFTMsg(wire.Message)
 	}
+	// MaxBlocksPerRequest caps blocks which are served for a get block request, DefaultMaxBlocksPerRequest is used when it is not set
+	MaxBlocksPerRequest int
 }
 
 func (netSync NetSync) New(cfg *NetSyncConfig) *NetSync {
 	netSync.config = cfg
 	netSync.cQuit = make(chan struct{})
 	netSync.cMessage = make(chan interface{})
+	netSync.compactBlocks = newCompactBlockCache()
 	return &netSync
 }
 
@@ -141,6 +148,22 @@ out:
 						{
 							netSync.HandleMessagePeerState(msg)
 						}
+					case *wire.MessageCompactBlockShard:
+						{
+							netSync.HandleMessageCompactBlockShard(msg, "")
+						}
+					case peerMessage:
+						{
+							netSync.handlePeerMessage(msg)
+						}
+					case *wire.MessageGetBlockTxn:
+						{
+							netSync.HandleMessageGetBlockTxn(msg)
+						}
+					case *wire.MessageBlockTxn:
+						{
+							netSync.HandleMessageBlockTxn(msg)
+						}
 					default:
 						Logger.log.Infof("Invalid message type in block "+"handler: %T", msg)
 					}
@@ -191,7 +214,7 @@ func (netSync *NetSync) HandleMessageTx(msg *wire.MessageTx) {
 		Logger.log.Infof("there is priority of transaction in pool: %d", txDesc.StartingPriority)
 
 		// Broadcast to network
-		err := netSync.config.Server.PushMessageToAll(msg)
+		err := netSync.config.Server.RelayMessageTx(msg)
 		if err != nil {
 			Logger.log.Error(err)
 		}
@@ -236,12 +259,54 @@ func (netSync *NetSync) QueueMessage(peer *peer.Peer, msg wire.Message, done cha
 	netSync.cMessage <- msg
 }
 
+// peerMessage is a message with the peer which delivered it, the peer is not the sender of message when it is forwarded
+type peerMessage struct {
+	msg    wire.Message
+	peerID libp2p.ID
+}
+
+/*
+QueueMessageFromPeer - queue a message which needs the peer which delivered it,
+missing transactions of a compact block are requested from that peer
+*/
+func (netSync *NetSync) QueueMessageFromPeer(peerID libp2p.ID, msg wire.Message, done chan struct{}) {
+	// Don't accept more transactions if we're shutting down.
+	if atomic.LoadInt32(&netSync.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+	netSync.cMessage <- peerMessage{msg: msg, peerID: peerID}
+}
+
+func (netSync *NetSync) handlePeerMessage(msg peerMessage) {
+	switch message := msg.msg.(type) {
+	case *wire.MessageCompactBlockShard:
+		netSync.HandleMessageCompactBlockShard(message, msg.peerID)
+	case *wire.MessageBFTPropose:
+		if message.CompactBlock {
+			Logger.log.Info("Handling new message BFTMsg")
+			netSync.handleCompactPropose(message, msg.peerID)
+			return
+		}
+		netSync.HandleMessageBFTMsg(message)
+	default:
+		Logger.log.Infof("Invalid message type of peer message: %T", message)
+	}
+}
+
 func (netSync *NetSync) HandleMessageBlockBeacon(msg *wire.MessageBlockBeacon) {
 	Logger.log.Info("Handling new message BlockBeacon")
 	netSync.config.BlockChain.OnBlockBeaconReceived(&msg.Block)
 }
 func (netSync *NetSync) HandleMessageBlockShard(msg *wire.MessageBlockShard) {
 	Logger.log.Info("Handling new message BlockShard")
+	// a proposal which compact block could not be rebuilt waits for this full block
+	if pending := netSync.compactBlocks.takePending(*msg.Block.Hash()); pending != nil && pending.propose != nil {
+		netSync.compactBlocks.addBlock(&msg.Block)
+		pending.propose.ShardBlock = &msg.Block
+		netSync.config.Consensus.OnBFTMsg(pending.propose)
+		return
+	}
 	netSync.config.BlockChain.OnBlockShardReceived(&msg.Block)
 }
 func (netSync *NetSync) HandleMessageCrossShard(msg *wire.MessageCrossShard) {
@@ -256,8 +321,8 @@ func (netSync *NetSync) HandleMessageShardToBeacon(msg *wire.MessageShardToBeaco
 
 func (netSync *NetSync) HandleMessageBFTMsg(msg wire.Message) {
 	Logger.log.Info("Handling new message BFTMsg")
-	if err := msg.VerifyMsgSanity(); err != nil {
-		Logger.log.Error(err)
+	if msgPropose, ok := msg.(*wire.MessageBFTPropose); ok && msgPropose.CompactBlock {
+		netSync.handleCompactPropose(msgPropose, "")
 		return
 	}
 	netSync.config.Consensus.OnBFTMsg(msg)
@@ -287,11 +352,15 @@ func (netSync *NetSync) HandleMessageGetBlockShard(msg *wire.MessageGetBlockShar
 		return
 	}
 	if msg.ByHash {
-		for _, blkHash := range msg.BlksHash {
-			blk, err := netSync.config.BlockChain.GetShardBlockByHash(&blkHash)
-			if err != nil {
-				Logger.log.Error(err)
-				return
+		for _, blkHash := range netSync.capBlockHashes(msg.BlksHash) {
+			// a proposed block is not in chain yet, it is served from blocks which are sent compact
+			blk := netSync.compactBlocks.getBlock(blkHash)
+			if blk == nil {
+				blk, err = netSync.config.BlockChain.GetShardBlockByHash(&blkHash)
+				if err != nil {
+					Logger.log.Error(err)
+					return
+				}
 			}
 			newMsg, err := wire.MakeEmptyMessage(wire.CmdBlockShard)
 			if err != nil {
@@ -302,7 +371,7 @@ func (netSync *NetSync) HandleMessageGetBlockShard(msg *wire.MessageGetBlockShar
 			netSync.config.Server.PushMessageToPeer(newMsg, peerID)
 		}
 	} else {
-		for index := msg.From; index <= msg.To; index++ {
+		for index, to := msg.From, netSync.capBlockRange(msg.From, msg.To); index <= to; index++ {
 			if index == 1 {
 				continue
 			}
@@ -335,7 +404,7 @@ func (netSync *NetSync) HandleMessageGetBlockBeacon(msg *wire.MessageGetBlockBea
 		return
 	}
 	if msg.ByHash {
-		for _, blkHash := range msg.BlksHash {
+		for _, blkHash := range netSync.capBlockHashes(msg.BlksHash) {
 			blk, err := netSync.config.BlockChain.GetBeaconBlockByHash(&blkHash)
 			if err != nil {
 				Logger.log.Error(err)
@@ -350,7 +419,7 @@ func (netSync *NetSync) HandleMessageGetBlockBeacon(msg *wire.MessageGetBlockBea
 			netSync.config.Server.PushMessageToPeer(newMsg, peerID)
 		}
 	} else {
-		for index := msg.From; index <= msg.To; index++ {
+		for index, to := msg.From, netSync.capBlockRange(msg.From, msg.To); index <= to; index++ {
 			if index == 1 {
 				continue
 			}
@@ -384,7 +453,7 @@ func (netSync *NetSync) HandleMessageGetShardToBeacon(msg *wire.MessageGetShardT
 		if msg.FromPool {
 			// netSync.config.ShardToBeaconPool.
 		} else {
-			for _, blkHash := range msg.BlksHash {
+			for _, blkHash := range netSync.capBlockHashes(msg.BlksHash) {
 				blk, err := netSync.config.BlockChain.GetShardBlockByHash(&blkHash)
 				if err != nil {
 					Logger.log.Error(err)
@@ -401,7 +470,7 @@ func (netSync *NetSync) HandleMessageGetShardToBeacon(msg *wire.MessageGetShardT
 			}
 		}
 	} else {
-		for index := msg.From; index <= msg.To; index++ {
+		for index, to := msg.From, netSync.capBlockRange(msg.From, msg.To); index <= to; index++ {
 			if index == 1 {
 				continue
 			}
@@ -436,7 +505,7 @@ func (netSync *NetSync) HandleMessageGetCrossShard(msg *wire.MessageGetCrossShar
 		// netSync.config.CrossShardPool.GetBlock()
 	} else {
 		if msg.ByHash {
-			for _, blkHash := range msg.BlksHash {
+			for _, blkHash := range netSync.capBlockHashes(msg.BlksHash) {
 				blk, err := netSync.config.BlockChain.GetShardBlockByHash(&blkHash)
 				if err != nil {
 					Logger.log.Error(err)
@@ -457,7 +526,7 @@ func (netSync *NetSync) HandleMessageGetCrossShard(msg *wire.MessageGetCrossShar
 				netSync.config.Server.PushMessageToPeer(newMsg, peerID)
 			}
 		} else {
-			for _, blkHeight := range msg.BlksHeight {
+			for _, blkHeight := range netSync.capBlockHeights(msg.BlksHeight) {
 				blk, err := netSync.config.BlockChain.GetShardBlockByHeight(blkHeight, msg.FromShardID)
 				if err != nil {
 					Logger.log.Error(err)
@@ -0,0 +1,46 @@
+package netsync
+
+import "github.com/ninjadotorg/constant/common"
+
+// DefaultMaxBlocksPerRequest is the number of blocks which are served for one get block request
+const DefaultMaxBlocksPerRequest = 100
+
+func (netSync *NetSync) maxBlocksPerRequest() int {
+	if netSync.config.MaxBlocksPerRequest <= 0 {
+		return DefaultMaxBlocksPerRequest
+	}
+	return netSync.config.MaxBlocksPerRequest
+}
+
+/*
+capBlockRange - return the last height which is served for a request of blocks in [from, to],
+requester asks again for the rest of a range which is larger than the limit
+*/
+func (netSync *NetSync) capBlockRange(from uint64, to uint64) uint64 {
+	max := uint64(netSync.maxBlocksPerRequest())
+	if to >= from && to-from >= max {
+		Logger.log.Warnf("Requested block range %d-%d is capped to %d blocks", from, to, max)
+		return from + max - 1
+	}
+	return to
+}
+
+// capBlockHashes returns hashes which are served for a request of blocks by hash
+func (netSync *NetSync) capBlockHashes(hashes []common.Hash) []common.Hash {
+	max := netSync.maxBlocksPerRequest()
+	if len(hashes) > max {
+		Logger.log.Warnf("Requested %d blocks by hash are capped to %d blocks", len(hashes), max)
+		return hashes[:max]
+	}
+	return hashes
+}
+
+// capBlockHeights returns heights which are served for a request of blocks by height list
+func (netSync *NetSync) capBlockHeights(heights []uint64) []uint64 {
+	max := netSync.maxBlocksPerRequest()
+	if len(heights) > max {
+		Logger.log.Warnf("Requested %d blocks by height are capped to %d blocks", len(heights), max)
+		return heights[:max]
+	}
+	return heights
+}
@@ -0,0 +1,62 @@
+package netsync
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/ninjadotorg/constant/common"
+)
+
+func newRequestLimitNetSync(max int) *NetSync {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("NetSync test", true))
+	return &NetSync{config: &NetSyncConfig{MaxBlocksPerRequest: max}}
+}
+
+func TestCapBlockRange(t *testing.T) {
+	netSync := newRequestLimitNetSync(10)
+	tests := []struct {
+		from, to, expected uint64
+	}{
+		{1, 5, 5},
+		{1, 10, 10},
+		{1, 11, 10},
+		{5, 1000, 14},
+		// reversed range is left to the handler
+		{10, 5, 5},
+	}
+	for _, test := range tests {
+		if to := netSync.capBlockRange(test.from, test.to); to != test.expected {
+			t.Errorf("range %d-%d: expected %d, got %d", test.from, test.to, test.expected, to)
+		}
+	}
+
+	// default limit is used when it is not set
+	netSync = newRequestLimitNetSync(0)
+	if to := netSync.capBlockRange(1, 1000); to != DefaultMaxBlocksPerRequest {
+		t.Errorf("expected default limit, got %d", to)
+	}
+}
+
+func TestCapBlockHashesAndHeights(t *testing.T) {
+	netSync := newRequestLimitNetSync(3)
+	hashes := make([]common.Hash, 5)
+	for i := range hashes {
+		hashes[i] = common.HashH([]byte{byte(i)})
+	}
+	capped := netSync.capBlockHashes(hashes)
+	if len(capped) != 3 || capped[2] != hashes[2] {
+		t.Errorf("expected first 3 hashes, got %d", len(capped))
+	}
+	if len(netSync.capBlockHashes(hashes[:2])) != 2 {
+		t.Errorf("expected hashes under limit to be kept")
+	}
+
+	heights := []uint64{7, 8, 9, 10}
+	cappedHeights := netSync.capBlockHeights(heights)
+	if len(cappedHeights) != 3 || cappedHeights[0] != 7 || cappedHeights[2] != 9 {
+		t.Errorf("expected first 3 heights, got %+v", cappedHeights)
+	}
+	if len(netSync.capBlockHeights(heights[:3])) != 3 {
+		t.Errorf("expected heights at limit to be kept")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// path of metrics endpoint on metrics listener
+	metricsPath = "/metrics"
+	// role label of peers which are not in a committee
+	metricsNoRole = "none"
+	// time to read a scrape request of metrics endpoint
+	metricsReadTimeout = 10 * time.Second
+)
+
+var (
+	bestHeightDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "chain", "best_height"),
+		"Height of best block of a chain.", []string{"chain"}, nil)
+	mempoolTxsDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "mempool", "transactions"),
+		"Number of transactions in mempool.", nil, nil)
+	mempoolBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "mempool", "bytes"),
+		"Size of transactions in mempool in bytes.", nil, nil)
+	poolBlocksDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "pool", "blocks"),
+		"Number of blocks in a block pool, by pool and chain of blocks.", []string{"pool", "chain"}, nil)
+	peersDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "connections"),
+		"Number of connected peers by their committee role and shard.", []string{"role", "shard"}, nil)
+)
+
+/*
+nodeCollector reads state of chains, pools and peers of server when metrics are scraped,
+so they need not be updated wherever that state changes
+*/
+type nodeCollector struct {
+	server *Server
+}
+
+// Describe sends descriptors of all metrics of node state
+func (collector nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bestHeightDesc
+	ch <- mempoolTxsDesc
+	ch <- mempoolBytesDesc
+	ch <- poolBlocksDesc
+	ch <- peersDesc
+}
+
+// Collect sends current values of all metrics of node state
+func (collector nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	serverObj := collector.server
+	if bestState := serverObj.blockChain.BestState; bestState != nil {
+		if bestState.Beacon != nil && bestState.Beacon.BestBlock != nil {
+			ch <- prometheus.MustNewConstMetric(bestHeightDesc, prometheus.GaugeValue, float64(bestState.Beacon.BestBlock.Header.Height), metrics.BeaconChain)
+		}
+		for shardID, shardBestState := range bestState.Shard {
+			if shardBestState != nil && shardBestState.BestBlock != nil {
+				ch <- prometheus.MustNewConstMetric(bestHeightDesc, prometheus.GaugeValue, float64(shardBestState.BestBlock.Header.Height), metrics.ShardChain(shardID))
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(mempoolTxsDesc, prometheus.GaugeValue, float64(serverObj.memPool.Count()))
+	ch <- prometheus.MustNewConstMetric(mempoolBytesDesc, prometheus.GaugeValue, float64(serverObj.memPool.Size()))
+
+	// pools are counted under their locks since blocks are added to them while metrics are scraped
+	ch <- prometheus.MustNewConstMetric(poolBlocksDesc, prometheus.GaugeValue, float64(serverObj.beaconPool.CountBlocks()), "beacon", metrics.BeaconChain)
+	for shardID, shardPool := range serverObj.shardPool {
+		if pool, ok := shardPool.(*mempool.ShardPool); ok {
+			ch <- prometheus.MustNewConstMetric(poolBlocksDesc, prometheus.GaugeValue, float64(pool.CountBlocks()), "shard", metrics.ShardChain(shardID))
+		}
+	}
+	for shardID, count := range serverObj.shardToBeaconPool.CountPendingBlocks() {
+		ch <- prometheus.MustNewConstMetric(poolBlocksDesc, prometheus.GaugeValue, float64(count), "shardtobeacon", metrics.ShardChain(shardID))
+	}
+	// blocks of all cross shard pools are counted by the shard which sends them
+	crossShardBlocks := make(map[byte]int)
+	for _, crossShardPool := range serverObj.crossShardPool {
+		if pool, ok := crossShardPool.(*mempool.CrossShardPool_v2); ok {
+			for fromShardID, heights := range pool.GetAllBlockHeight() {
+				crossShardBlocks[fromShardID] += len(heights)
+			}
+		}
+	}
+	for fromShardID, count := range crossShardBlocks {
+		ch <- prometheus.MustNewConstMetric(poolBlocksDesc, prometheus.GaugeValue, float64(count), "crossshard", metrics.ShardChain(fromShardID))
+	}
+
+	type peerGroup struct {
+		role  string
+		shard string
+	}
+	peers := make(map[peerGroup]int)
+	for _, peerConn := range serverObj.connManager.GetPeerConnOfAll() {
+		role, shardID := serverObj.connManager.GetRoleShardOfPbk(peerConn.RemotePeer.PublicKey)
+		group := peerGroup{role: role}
+		if role == common.EmptyString {
+			group.role = metricsNoRole
+		}
+		if shardID != nil {
+			group.shard = metrics.ShardChain(*shardID)
+		}
+		peers[group]++
+	}
+	for group, count := range peers {
+		ch <- prometheus.MustNewConstMetric(peersDesc, prometheus.GaugeValue, float64(count), group.role, group.shard)
+	}
+}
+
+/*
+setupMetricsServer creates the HTTP server of metrics endpoint on listen address.
+A registry is created for each server so that each node of a simulated network reports state of its own chains,
+pools and peers, event metrics of package metrics are shared by all nodes which run in one process
+*/
+func (serverObj *Server) setupMetricsServer(listenAddr string) (*http.Server, net.Listener, error) {
+	registry := prometheus.NewRegistry()
+	collectors := append(metrics.Collectors(),
+		nodeCollector{server: serverObj},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return &http.Server{Handler: mux, ReadTimeout: metricsReadTimeout}, listener, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/connmanager"
+	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newMetricsTestServer(t *testing.T) *Server {
+	shardBestState := &blockchain.BestStateShard{BestBlock: &blockchain.ShardBlock{Header: blockchain.ShardHeader{Height: 4}}}
+	serverObj := &Server{
+		blockChain: &blockchain.BlockChain{BestState: &blockchain.BestState{
+			Beacon: &blockchain.BestStateBeacon{BestBlock: &blockchain.BeaconBlock{Header: blockchain.BeaconHeader{Height: 7}}},
+			Shard:  map[byte]*blockchain.BestStateShard{0: shardBestState},
+		}},
+		memPool:           &mempool.TxPool{},
+		beaconPool:        &mempool.BeaconPool{},
+		shardPool:         map[byte]blockchain.ShardPool{0: mempool.NewShardPool(0)},
+		shardToBeaconPool: mempool.NewShardToBeaconPool(),
+	}
+
+	for _, height := range []uint64{8, 10} {
+		if err := serverObj.beaconPool.AddBeaconBlock(&blockchain.BeaconBlock{Header: blockchain.BeaconHeader{Height: height}}); err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+	}
+	if err := serverObj.shardPool[0].AddShardBlock(&blockchain.ShardBlock{Header: blockchain.ShardHeader{Height: 5}}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	for _, height := range []uint64{2, 3, 5} {
+		if _, _, err := serverObj.shardToBeaconPool.AddShardToBeaconBlock(blockchain.ShardToBeaconBlock{Header: blockchain.ShardHeader{ShardID: 1, Height: height}}); err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+	}
+
+	// peers of beacon committee, of committee of shard 1 and a peer which is not in a committee
+	listener := &peer.Peer{PeerConns: make(map[string]*peer.PeerConn)}
+	for _, publicKey := range []string{"beacon1", "shard1a", "shard1b", "other"} {
+		listener.PeerConns[publicKey] = &peer.PeerConn{RemotePeer: &peer.Peer{PublicKey: publicKey}}
+	}
+	serverObj.connManager = &connmanager.ConnManager{Config: connmanager.Config{
+		ListenerPeer: listener,
+		ConsensusState: &connmanager.ConsensusState{
+			BeaconCommittee: []string{"beacon1"},
+			Committee:       map[string]byte{"shard1a": 1, "shard1b": 1},
+		},
+	}}
+	return serverObj
+}
+
+// gatherMetrics returns values of gathered metrics by their name and labels, like name{label="value",...}
+func gatherMetrics(t *testing.T, collector prometheus.Collector) map[string]float64 {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := []string{}
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+			sort.Strings(labels)
+			values[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = metric.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func TestNodeCollector(t *testing.T) {
+	values := gatherMetrics(t, nodeCollector{server: newMetricsTestServer(t)})
+	expected := map[string]float64{
+		`constant_chain_best_height{chain="beacon"}`:                       7,
+		`constant_chain_best_height{chain="0"}`:                            4,
+		`constant_mempool_transactions{}`:                                  0,
+		`constant_mempool_bytes{}`:                                         0,
+		`constant_pool_blocks{chain="beacon",pool="beacon"}`:               2,
+		`constant_pool_blocks{chain="0",pool="shard"}`:                     1,
+		`constant_pool_blocks{chain="1",pool="shardtobeacon"}`:             3,
+		`constant_peer_connections{role="beacon",shard=""}`:                1,
+		`constant_peer_connections{role="shard",shard="1"}`:                2,
+		`constant_peer_connections{role="` + metricsNoRole + `",shard=""}`: 1,
+	}
+	for name, value := range expected {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("expected metric %s", name)
+			continue
+		}
+		if got != value {
+			t.Errorf("expected %s %v, got %v", name, value, got)
+		}
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d metrics, got %+v", len(expected), values)
+	}
+}
+
+func TestNodeCollectorWhileAddingBlocks(t *testing.T) {
+	serverObj := newMetricsTestServer(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for height := uint64(11); height < 200; height++ {
+			serverObj.beaconPool.AddBeaconBlock(&blockchain.BeaconBlock{Header: blockchain.BeaconHeader{Height: height}})
+			serverObj.shardToBeaconPool.AddShardToBeaconBlock(blockchain.ShardToBeaconBlock{Header: blockchain.ShardHeader{ShardID: byte(height % 4), Height: height}})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		gatherMetrics(t, nodeCollector{server: serverObj})
+	}
+	<-done
+}
@@ -0,0 +1,138 @@
+package peer
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ninjadotorg/constant/wire"
+)
+
+// Offenses of a remote peer which are penalized with ban score
+const (
+	OffenseMalformedMessage = iota
+	OffenseInvalidMessage
+	OffenseInvalidSignature
+	OffenseInvalidBlock
+)
+
+// BanScoreHalfLife is the time which ban score of a peer decays by half in,
+// so that a peer is banned for many offenses in a short time rather than for rare ones over a long connection
+const BanScoreHalfLife = 10 * time.Minute
+
+// BAN_SCORE_WEIGHTS is the ban score which is added to a peer for each offense,
+// a peer is banned when its score reaches ban threshold of connection manager
+var BAN_SCORE_WEIGHTS = map[int]int32{
+	OffenseMalformedMessage: 10,
+	OffenseInvalidMessage:   20,
+	OffenseInvalidSignature: 50,
+	OffenseInvalidBlock:     100,
+}
+
+var offenseNames = map[int]string{
+	OffenseMalformedMessage: "malformed message",
+	OffenseInvalidMessage:   "invalid message",
+	OffenseInvalidSignature: "invalid signature",
+	OffenseInvalidBlock:     "invalid block",
+}
+
+// OffenseName returns readable name of an offense
+func OffenseName(offense int) string {
+	name, ok := offenseNames[offense]
+	if !ok {
+		return fmt.Sprintf("offense %d", offense)
+	}
+	return name
+}
+
+// banScore is a ban score which decays by half every BanScoreHalfLife
+type banScore struct {
+	score float64
+	last  time.Time
+	mtx   sync.Mutex
+}
+
+// decay reduces score by time elapsed since it was last changed, it MUST be called with mtx held
+func (score *banScore) decay(now time.Time) {
+	if !score.last.IsZero() && now.After(score.last) {
+		score.score *= math.Pow(0.5, float64(now.Sub(score.last))/float64(BanScoreHalfLife))
+	}
+	score.last = now
+}
+
+func (score *banScore) add(weight int32, now time.Time) int32 {
+	score.mtx.Lock()
+	defer score.mtx.Unlock()
+	score.decay(now)
+	score.score += float64(weight)
+	return int32(score.score)
+}
+
+func (score *banScore) get(now time.Time) int32 {
+	score.mtx.Lock()
+	defer score.mtx.Unlock()
+	score.decay(now)
+	return int32(score.score)
+}
+
+// now returns time of clock of peer
+func (peerConn *PeerConn) now() time.Time {
+	if peerConn.Config.Clock != nil {
+		return peerConn.Config.Clock.Now()
+	}
+	return time.Now()
+}
+
+/*
+AddBanScore - penalize remote peer for an offense and return its new ban score,
+HandleMisbehaved is invoked so that connection manager decides whether peer is banned
+*/
+func (peerConn *PeerConn) AddBanScore(offense int, reason string) int32 {
+	score := peerConn.banScore.add(BAN_SCORE_WEIGHTS[offense], peerConn.now())
+	Logger.log.Warnf("PEER %s misbehaved (%s: %s), ban score %d", peerConn.RemotePeerID.Pretty(), OffenseName(offense), reason, score)
+	if peerConn.HandleMisbehaved != nil {
+		peerConn.HandleMisbehaved(peerConn, offense, reason)
+	}
+	return score
+}
+
+// GetBanScore returns current ban score of remote peer
+func (peerConn *PeerConn) GetBanScore() int32 {
+	return peerConn.banScore.get(peerConn.now())
+}
+
+/*
+addBanScoreOfMessage - penalize remote peer for an invalid message only when remote peer made it.
+Messages to a shard or to beacon are forwarded as raw bytes by peers which are not in that shard or beacon,
+so such a message is blamed on remote peer only when remote peer signed it
+*/
+func (peerConn *PeerConn) addBanScoreOfMessage(offense int, reason string, message wire.Message, forwardType byte) {
+	if forwardType == MESSAGE_TO_SHARD || forwardType == MESSAGE_TO_BEACON {
+		signer := signerOfMessage(message)
+		if signer == "" || peerConn.RemotePeer == nil || signer != peerConn.RemotePeer.PublicKey {
+			Logger.log.Warnf("PEER %s relayed an invalid message (%s: %s), message is dropped", peerConn.RemotePeerID.Pretty(), OffenseName(offense), reason)
+			return
+		}
+	}
+	peerConn.AddBanScore(offense, reason)
+}
+
+// signerOfMessage returns public key which signed a message, it is empty for messages which are not signed
+func signerOfMessage(message wire.Message) string {
+	switch msg := message.(type) {
+	case *wire.MessageBFTPropose:
+		return msg.Pubkey
+	case *wire.MessageBFTPrepare:
+		return msg.Pubkey
+	case *wire.MessageBFTCommit:
+		return msg.Pubkey
+	case *wire.MessageBFTReady:
+		return msg.Pubkey
+	case *wire.MessageBFTReq:
+		return msg.Pubkey
+	case *wire.MessageShardToBeacon:
+		return msg.Block.Header.Producer
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package peer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+func newBanScoreTestConn(clock common.Clock, remotePublicKey string) *PeerConn {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("Peer test", true))
+	return &PeerConn{
+		ListenerPeer: &Peer{},
+		RemotePeer:   &Peer{PublicKey: remotePublicKey},
+		Config:       Config{Clock: clock},
+	}
+}
+
+func TestBanScoreDecay(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	peerConn := newBanScoreTestConn(clock, "")
+	var misbehaved []int
+	peerConn.HandleMisbehaved = func(peerConn *PeerConn, offense int, reason string) {
+		misbehaved = append(misbehaved, offense)
+	}
+
+	if score := peerConn.AddBanScore(OffenseInvalidBlock, "test"); score != 100 {
+		t.Errorf("expected score 100, got %d", score)
+	}
+	clock.Advance(BanScoreHalfLife)
+	if score := peerConn.GetBanScore(); score != 50 {
+		t.Errorf("expected score 50 after half life, got %d", score)
+	}
+	if score := peerConn.AddBanScore(OffenseInvalidMessage, "test"); score != 70 {
+		t.Errorf("expected score 70, got %d", score)
+	}
+	clock.Advance(10 * BanScoreHalfLife)
+	if score := peerConn.GetBanScore(); score != 0 {
+		t.Errorf("expected score to decay to 0, got %d", score)
+	}
+	if len(misbehaved) != 2 || misbehaved[0] != OffenseInvalidBlock || misbehaved[1] != OffenseInvalidMessage {
+		t.Errorf("expected misbehaved handler for each offense, got %+v", misbehaved)
+	}
+}
+
+// newProposeBytes returns a bft propose to shard 1 signed by pubkey with a bad signature, in format of a received message
+func newProposeBytes(t *testing.T, pubkey string, timestamp int64) []byte {
+	msg := &wire.MessageBFTPropose{Layer: "shard", ShardID: 1, Pubkey: pubkey, ContentSig: "bad", Timestamp: timestamp}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return append(body, wire.MessageHeaderBytes(wire.CmdBFTPropose, MESSAGE_TO_SHARD, 1)...)
+}
+
+func TestInvalidMessageIsNotForwarded(t *testing.T) {
+	peerConn := newBanScoreTestConn(common.NewManualClock(time.Unix(1000, 0)), "remote")
+	shardID := byte(0)
+	forwarded := 0
+	peerConn.Config.MessageListeners.GetCurrentRoleShard = func() (string, *byte) {
+		return "shard", &shardID
+	}
+	peerConn.Config.MessageListeners.PushRawBytesToShard = func(p *PeerConn, msgBytes *[]byte, shard byte) error {
+		forwarded++
+		return nil
+	}
+
+	// relay of a message signed by another node is not penalized
+	peerConn.processInMessage(newProposeBytes(t, "other", 1))
+	if forwarded != 0 {
+		t.Errorf("expected invalid message not to be forwarded")
+	}
+	if score := peerConn.GetBanScore(); score != 0 {
+		t.Errorf("expected relay not to be penalized, got score %d", score)
+	}
+
+	// remote peer signed the message itself
+	peerConn.processInMessage(newProposeBytes(t, "remote", 2))
+	if forwarded != 0 {
+		t.Errorf("expected invalid message not to be forwarded")
+	}
+	if score := peerConn.GetBanScore(); score != BAN_SCORE_WEIGHTS[OffenseInvalidSignature] {
+		t.Errorf("expected signer to be penalized, got score %d", score)
+	}
+
+	// malformed message to a shard has no known signer
+	malformed := append([]byte("{"), wire.MessageHeaderBytes(wire.CmdBFTPropose, MESSAGE_TO_SHARD, 1)...)
+	peerConn.processInMessage(malformed)
+	if forwarded != 0 || peerConn.GetBanScore() != BAN_SCORE_WEIGHTS[OffenseInvalidSignature] {
+		t.Errorf("expected malformed relayed message to be dropped without penalty")
+	}
+}
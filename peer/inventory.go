@@ -0,0 +1,51 @@
+package peer
+
+import (
+	"sync"
+
+	"github.com/ninjadotorg/constant/wire"
+)
+
+var MAX_KNOWN_INVENTORY = 1000
+
+// knownInventory is a bounded set of inventory vectors which a remote peer is known to have,
+// the oldest vector is evicted when the set is full
+type knownInventory struct {
+	items map[wire.InvVect]struct{}
+	order []wire.InvVect
+	mtx   sync.Mutex
+}
+
+func (known *knownInventory) add(invVect wire.InvVect) {
+	known.mtx.Lock()
+	defer known.mtx.Unlock()
+	if known.items == nil {
+		known.items = make(map[wire.InvVect]struct{})
+	}
+	if _, ok := known.items[invVect]; ok {
+		return
+	}
+	if len(known.order) >= MAX_KNOWN_INVENTORY {
+		delete(known.items, known.order[0])
+		known.order = known.order[1:]
+	}
+	known.items[invVect] = struct{}{}
+	known.order = append(known.order, invVect)
+}
+
+func (known *knownInventory) exists(invVect wire.InvVect) bool {
+	known.mtx.Lock()
+	defer known.mtx.Unlock()
+	_, ok := known.items[invVect]
+	return ok
+}
+
+// AddKnownInventory marks an item as known by remote peer so that it is not announced to the peer again
+func (peerConn *PeerConn) AddKnownInventory(invVect wire.InvVect) {
+	peerConn.knownInventory.add(invVect)
+}
+
+// IsKnownInventory returns whether remote peer has sent or was sent an item
+func (peerConn *PeerConn) IsKnownInventory(invVect wire.InvVect) bool {
+	return peerConn.knownInventory.exists(invVect)
+}
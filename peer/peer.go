@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -30,6 +31,7 @@ var MAX_RETRIES_CHECK_HASH_MESSAGE = 5
 var MAX_TIMEOUT_CHECK_HASH_MESSAGE = time.Duration(10)
 var HEAVY_MESSAGE_SIZE = 5 * 1024 * 1024
 var SPAM_MESSAGE_SIZE = 50 * 1024 * 1024
+var COMPRESS_MESSAGE_SIZE = 1024
 var MESSAGE_HASH_POOL_SIZE = 1000
 
 var MESSAGE_TO_ALL = byte('a')
@@ -70,6 +72,7 @@ type Peer struct {
 	HandleConnected    func(peerConn *PeerConn)
 	HandleDisconnected func(peerConn *PeerConn)
 	HandleFailed       func(peerConn *PeerConn)
+	HandleMisbehaved   func(peerConn *PeerConn, offense int, reason string)
 }
 
 type NewPeerMsg struct {
@@ -89,6 +92,13 @@ type Config struct {
 	MaxOutPeers      int
 	MaxInPeers       int
 	MaxPeers         int
+	// RateLimits are token buckets of each remote peer by message type, DEFAULT_RATE_LIMITS is used when it is nil
+	RateLimits map[string]RateLimit
+	// MessageFilter is called for each received message before it is processed, message is dropped when it returns false,
+	// it is used to simulate partitions and lossy links of a network, all messages are processed when it is nil
+	MessageFilter func(peerConn *PeerConn, command string) bool
+	// Clock is used to decay ban score and refill rate limits, system time is used when it is nil
+	Clock common.Clock
 }
 
 /*
@@ -103,19 +113,25 @@ type Config struct {
 // result in a deadlock.
 */
 type MessageListeners struct {
-	OnTx               func(p *PeerConn, msg *wire.MessageTx)
-	OnBlockShard       func(p *PeerConn, msg *wire.MessageBlockShard)
-	OnBlockBeacon      func(p *PeerConn, msg *wire.MessageBlockBeacon)
-	OnCrossShard       func(p *PeerConn, msg *wire.MessageCrossShard)
-	OnShardToBeacon    func(p *PeerConn, msg *wire.MessageShardToBeacon)
-	OnGetBlockBeacon   func(p *PeerConn, msg *wire.MessageGetBlockBeacon)
-	OnGetBlockShard    func(p *PeerConn, msg *wire.MessageGetBlockShard)
-	OnGetCrossShard    func(p *PeerConn, msg *wire.MessageGetCrossShard)
-	OnGetShardToBeacon func(p *PeerConn, msg *wire.MessageGetShardToBeacon)
-	OnVersion          func(p *PeerConn, msg *wire.MessageVersion)
-	OnVerAck           func(p *PeerConn, msg *wire.MessageVerAck)
-	OnGetAddr          func(p *PeerConn, msg *wire.MessageGetAddr)
-	OnAddr             func(p *PeerConn, msg *wire.MessageAddr)
+	OnTx                func(p *PeerConn, msg *wire.MessageTx)
+	OnStemTx            func(p *PeerConn, msg *wire.MessageStemTx)
+	OnBlockShard        func(p *PeerConn, msg *wire.MessageBlockShard)
+	OnBlockBeacon       func(p *PeerConn, msg *wire.MessageBlockBeacon)
+	OnCrossShard        func(p *PeerConn, msg *wire.MessageCrossShard)
+	OnShardToBeacon     func(p *PeerConn, msg *wire.MessageShardToBeacon)
+	OnGetBlockBeacon    func(p *PeerConn, msg *wire.MessageGetBlockBeacon)
+	OnGetBlockShard     func(p *PeerConn, msg *wire.MessageGetBlockShard)
+	OnGetCrossShard     func(p *PeerConn, msg *wire.MessageGetCrossShard)
+	OnGetShardToBeacon  func(p *PeerConn, msg *wire.MessageGetShardToBeacon)
+	OnVersion           func(p *PeerConn, msg *wire.MessageVersion)
+	OnVerAck            func(p *PeerConn, msg *wire.MessageVerAck)
+	OnGetAddr           func(p *PeerConn, msg *wire.MessageGetAddr)
+	OnAddr              func(p *PeerConn, msg *wire.MessageAddr)
+	OnInv               func(p *PeerConn, msg *wire.MessageInv)
+	OnGetData           func(p *PeerConn, msg *wire.MessageGetData)
+	OnCompactBlockShard func(p *PeerConn, msg *wire.MessageCompactBlockShard)
+	OnGetBlockTxn       func(p *PeerConn, msg *wire.MessageGetBlockTxn)
+	OnBlockTxn          func(p *PeerConn, msg *wire.MessageBlockTxn)
 
 	//PBFT
 	OnBFTMsg func(p *PeerConn, msg wire.Message)
@@ -139,6 +155,7 @@ type outMsg struct {
 	rawBytes     *[]byte
 	message      wire.Message
 	doneChan     chan<- struct{}
+	compress     bool // gzip payload of binary frame
 	//encoding wire.MessageEncoding
 }
 
@@ -176,39 +193,51 @@ func (peerObj *Peer) CheckHashPool(hash string) bool {
 NewPeer - create a new peer with go libp2p
 */
 func (peerObj Peer) NewPeer() (*Peer, error) {
-	// If the seed is zero, use real cryptographic randomness. Otherwise, use a
-	// deterministic randomness source to make generated keys stay the same
-	// across multiple runs
-	var r io.Reader
-	if peerObj.Seed == 0 {
-		r = rand.Reader
-	} else {
-		r = mrand.New(mrand.NewSource(peerObj.Seed))
-	}
+	basicHost := peerObj.Host
+	listeningAddressString := ""
+	port := ""
+	if basicHost == nil {
+		// If the seed is zero, use real cryptographic randomness. Otherwise, use a
+		// deterministic randomness source to make generated keys stay the same
+		// across multiple runs
+		var r io.Reader
+		if peerObj.Seed == 0 {
+			r = rand.Reader
+		} else {
+			r = mrand.New(mrand.NewSource(peerObj.Seed))
+		}
 
-	// Generate a key pair for this Host. We will use it
-	// to obtain a valid Host Id.
-	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
-	if err != nil {
-		return &peerObj, NewPeerError(PeerGenerateKeyPairErr, err, &peerObj)
-	}
+		// Generate a key pair for this Host. We will use it
+		// to obtain a valid Host Id.
+		priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
+		if err != nil {
+			return &peerObj, NewPeerError(PeerGenerateKeyPairErr, err, &peerObj)
+		}
 
-	ip := strings.Split(peerObj.ListeningAddress.String(), ":")[0]
-	if len(ip) == 0 {
-		ip = LocalHost
-	}
-	Logger.log.Info(ip)
-	port := strings.Split(peerObj.ListeningAddress.String(), ":")[1]
-	net := peerObj.ListeningAddress.Network()
-	listeningAddressString := fmt.Sprintf("/%s/%s/tcp/%s", net, ip, port)
-	opts := []libp2p.Option{
-		libp2p.ListenAddrStrings(listeningAddressString),
-		libp2p.Identity(priv),
-	}
+		ip := strings.Split(peerObj.ListeningAddress.String(), ":")[0]
+		if len(ip) == 0 {
+			ip = LocalHost
+		}
+		Logger.log.Info(ip)
+		port = strings.Split(peerObj.ListeningAddress.String(), ":")[1]
+		net := peerObj.ListeningAddress.Network()
+		listeningAddressString = fmt.Sprintf("/%s/%s/tcp/%s", net, ip, port)
+		opts := []libp2p.Option{
+			libp2p.ListenAddrStrings(listeningAddressString),
+			libp2p.Identity(priv),
+		}
 
-	basicHost, err := libp2p.New(context.Background(), opts...)
-	if err != nil {
-		return &peerObj, NewPeerError(CreateP2PNodeErr, err, &peerObj)
+		basicHost, err = libp2p.New(context.Background(), opts...)
+		if err != nil {
+			return &peerObj, NewPeerError(CreateP2PNodeErr, err, &peerObj)
+		}
+	} else {
+		// host is given by caller, e.g. an in-memory host of a simulated network
+		if len(basicHost.Addrs()) == 0 {
+			return &peerObj, NewPeerError(CreateP2PAddressErr, errors.New("host has no address"), &peerObj)
+		}
+		listeningAddressString = basicHost.Addrs()[0].String()
+		port, _ = basicHost.Addrs()[0].ValueForProtocol(ma.P_TCP)
 	}
 
 	// Build Host multiaddress
@@ -497,6 +526,7 @@ func (peerObj *Peer) handleConn(peer *Peer, cConn chan *PeerConn) (*PeerConn, er
 		HandleConnected:    peerObj.handleConnected,
 		HandleDisconnected: peerObj.handleDisconnected,
 		HandleFailed:       peerObj.handleFailed,
+		HandleMisbehaved:   peerObj.handleMisbehaved,
 	}
 
 	go peerConn.InMessageHandler(rw)
@@ -573,6 +603,7 @@ func (peerObj *Peer) handleStream(stream net.Stream, cDone chan *PeerConn) {
 		},
 		Config:             peerObj.Config,
 		RemotePeerID:       remotePeerID,
+		RemoteRawAddress:   stream.Conn().RemoteMultiaddr().String(), // network address of inbound peer, it is the source group of addresses which it shares
 		RWStream:           rw,
 		cDisconnect:        make(chan struct{}),
 		cClose:             make(chan struct{}),
@@ -583,6 +614,7 @@ func (peerObj *Peer) handleStream(stream net.Stream, cDone chan *PeerConn) {
 		HandleConnected:    peerObj.handleConnected,
 		HandleDisconnected: peerObj.handleDisconnected,
 		HandleFailed:       peerObj.handleFailed,
+		HandleMisbehaved:   peerObj.handleMisbehaved,
 	}
 
 	peerObj.SetPeerConn(&peerConn)
@@ -698,6 +730,15 @@ func (peerObj *Peer) handleFailed(peerConn *PeerConn) {
 	}
 }
 
+/*
+handleMisbehaved - pass offense of a connected peer to handler of listener
+*/
+func (peerObj *Peer) handleMisbehaved(peerConn *PeerConn, offense int, reason string) {
+	if peerObj.HandleMisbehaved != nil {
+		peerObj.HandleMisbehaved(peerConn, offense, reason)
+	}
+}
+
 /*
 retryPeerConnection - retry to connect to peer when being disconnected
 */
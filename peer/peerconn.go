@@ -8,6 +8,7 @@ import (
 	"errors"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	peer "github.com/libp2p/go-libp2p-peer"
@@ -16,6 +17,12 @@ import (
 )
 
 type PeerConn struct {
+	// The following variables must only be used atomically, it is the first field for 64-bit alignment
+	stats       TrafficStats
+	versionSent int64 // unix nano time when version message is sent, it is used to measure handshake latency
+
+	banScore banScore
+
 	connState      ConnState
 	stateMtx       sync.RWMutex
 	verAckReceived bool
@@ -41,6 +48,12 @@ type PeerConn struct {
 
 	RWStream       *bufio.ReadWriter
 	VerValid       bool
+	wireVersion    int    // messages are sent in hex encoded json until it is negotiated
+	compression    bool   // remote peer reads gzip payload in binary frames
+	capabilities   uint64 // capabilities which are supported by both nodes
+	wireVersionMtx sync.Mutex
+	knownInventory knownInventory
+	rateLimiter    messageRateLimiter
 	isConnected    bool
 	isConnectedMtx sync.Mutex
 
@@ -51,6 +64,7 @@ type PeerConn struct {
 	HandleConnected    func(peerConn *PeerConn)
 	HandleDisconnected func(peerConn *PeerConn)
 	HandleFailed       func(peerConn *PeerConn)
+	HandleMisbehaved   func(peerConn *PeerConn, offense int, reason string)
 }
 
 func (peerConn *PeerConn) GetIsOutbound() bool {
@@ -117,18 +131,7 @@ func (peerConn *PeerConn) InMessageHandler(rw *bufio.ReadWriter) {
 	peerConn.SetIsConnected(true)
 	for {
 		Logger.log.Infof("PEER %s (address: %s) Reading stream", peerConn.RemotePeer.PeerID.Pretty(), peerConn.RemotePeer.RawAddress)
-		//str, errR := rw.ReadString(DelimMessageByte)
-		//if errR != nil {
-		//	peerConn.SetIsConnected(false)
-		//	Logger.log.Error("---------------------------------------------------------------------")
-		//	Logger.log.Errorf("InMessageHandler ERROR %s %s", peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.RawAddress)
-		//	Logger.log.Error(errR)
-		//	Logger.log.Errorf("InMessageHandler QUIT %s %s", peerConn.RemotePeerID.Pretty(), peerConn.RemotePeer.RawAddress)
-		//	Logger.log.Error("---------------------------------------------------------------------")
-		//	close(peerConn.cWrite)
-		//	return
-		//}
-		str, errR := peerConn.ReadString(rw, DelimMessageByte, SPAM_MESSAGE_SIZE)
+		messageBytes, errR := peerConn.readMessage(rw)
 		if errR != nil {
 			peerConn.SetIsConnected(false)
 			Logger.log.Error("---------------------------------------------------------------------")
@@ -140,184 +143,282 @@ func (peerConn *PeerConn) InMessageHandler(rw *bufio.ReadWriter) {
 			return
 		}
 
-		if str != DelimMessageStr {
-			go func(msgStr string) {
-				// Parse Message header from last 24 bytes header message
-				jsonDecodeBytesRaw, _ := hex.DecodeString(msgStr)
+		if len(messageBytes) > wire.MessageHeaderSize {
+			go peerConn.processInMessage(messageBytes)
+		}
+	}
+}
 
-				// cache message hash S
-				hashMsgRaw := common.HashH(jsonDecodeBytesRaw).String()
-				if peerConn.ListenerPeer.CheckHashPool(hashMsgRaw) {
-					Logger.log.Infof("InMessageHandler existed raw hash message %s", hashMsgRaw)
-					return
-				}
-				peerConn.ListenerPeer.HashToPool(hashMsgRaw)
-				// cache message hash E
+/*
+readMessage - read a message from stream, it can be a binary frame or a hex encoded json message
+(for peers which only speak the old encoding) and return json body with the 24 bytes header.
+A message which can not be decoded is skipped, an error is returned when stream can not be read.
+*/
+func (peerConn *PeerConn) readMessage(rw *bufio.ReadWriter) ([]byte, error) {
+	firstBytes, err := rw.Reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if wire.IsMessageFrame(firstBytes[0]) {
+		header, payload, err := wire.ReadMessageFrame(rw.Reader, SPAM_MESSAGE_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(&peerConn.stats.BytesReceived, uint64(wire.MessageFrameHeaderSize+len(payload)))
+		if header.Flags&wire.FrameFlagGzip != 0 {
+			compressedLength := len(payload)
+			payload, err = common.GZipFromBytes(payload)
+			if err != nil {
+				Logger.log.Error("Can not unzip from message frame")
+				Logger.log.Error(err)
+				return nil, nil
+			}
+			peerConn.stats.addSavedReceived(len(payload), compressedLength)
+		}
+		return append(payload, wire.MessageHeaderBytes(header.Command, header.ForwardType, header.ForwardValue)...), nil
+	}
 
-				// unzip data before process
-				jsonDecodeBytes, err := common.GZipFromBytes(jsonDecodeBytesRaw)
-				if err != nil {
-					Logger.log.Error("Can not unzip from message")
-					Logger.log.Error(err)
-					return
-				}
-				// disconnect when received spam message
-				//if len(jsonDecodeBytes) >= SPAM_MESSAGE_SIZE {
-				//	Logger.log.Error("InMessageHandler received spam message")
-				//	peerConn.ForceClose()
-				//	return
-				//}
-
-				Logger.log.Infof("In message content : %s", string(jsonDecodeBytes))
-
-				// Parse Message body
-				messageBody := jsonDecodeBytes[:len(jsonDecodeBytes)-wire.MessageHeaderSize]
-
-				messageHeader := jsonDecodeBytes[len(jsonDecodeBytes)-wire.MessageHeaderSize:]
-				// check forward
-				if peerConn.Config.MessageListeners.GetCurrentRoleShard != nil {
-					cRole, cShard := peerConn.Config.MessageListeners.GetCurrentRoleShard()
-					if cShard != nil {
-						fT := messageHeader[wire.MessageCmdTypeSize]
-						if fT == MESSAGE_TO_SHARD {
-							fS := messageHeader[wire.MessageCmdTypeSize+1]
-							if *cShard != fS {
-								if peerConn.Config.MessageListeners.PushRawBytesToShard != nil {
-									peerConn.Config.MessageListeners.PushRawBytesToShard(peerConn, &jsonDecodeBytesRaw, *cShard)
-								}
-								return
-							}
-						}
-					}
-					if cRole != "" {
-						fT := messageHeader[wire.MessageCmdTypeSize]
-						if fT == MESSAGE_TO_BEACON && cRole != "beacon" {
-							if peerConn.Config.MessageListeners.PushRawBytesToBeacon != nil {
-								peerConn.Config.MessageListeners.PushRawBytesToBeacon(peerConn, &jsonDecodeBytesRaw)
-							}
-							return
-						}
-					}
-				}
+	str, err := peerConn.ReadString(rw, DelimMessageByte, SPAM_MESSAGE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&peerConn.stats.BytesReceived, uint64(len(str)+1))
+	if str == "" {
+		return nil, nil
+	}
+	jsonDecodeBytesRaw, err := hex.DecodeString(str)
+	if err != nil {
+		Logger.log.Error("Can not decode hex message")
+		Logger.log.Error(err)
+		return nil, nil
+	}
+	// unzip data before process
+	jsonDecodeBytes, err := common.GZipFromBytes(jsonDecodeBytesRaw)
+	if err != nil {
+		Logger.log.Error("Can not unzip from message")
+		Logger.log.Error(err)
+		return nil, nil
+	}
+	peerConn.stats.addSavedReceived(len(jsonDecodeBytes), len(jsonDecodeBytesRaw))
+	return jsonDecodeBytes, nil
+}
 
-				// get cmd type in header message
-				commandInHeader := bytes.Trim(messageHeader[:wire.MessageCmdTypeSize], "\x00")
-				commandType := string(messageHeader[:len(commandInHeader)])
-				// convert to particular message from message cmd type
-				message, err := wire.MakeEmptyMessage(string(commandType))
-				if err != nil {
-					Logger.log.Error("Can not find particular message for message cmd type")
-					Logger.log.Error(err)
-					return
-				}
+/*
+processInMessage - parse json body and header of a message then pass it to listeners
+*/
+func (peerConn *PeerConn) processInMessage(jsonDecodeBytes []byte) {
+	if len(jsonDecodeBytes) < wire.MessageHeaderSize {
+		Logger.log.Error("Message is shorter than its header")
+		return
+	}
+	// inv and getdata of the same items are expected from many peers, they are not deduplicated
+	command, _, _ := wire.ParseMessageHeaderBytes(jsonDecodeBytes[len(jsonDecodeBytes)-wire.MessageHeaderSize:])
+	dedupe := command != wire.CmdInv && command != wire.CmdGetData
 
-				err = json.Unmarshal(messageBody, &message)
-				if err != nil {
-					Logger.log.Error("Can not parse struct from json message")
-					Logger.log.Error(err)
-					return
-				}
-				realType := reflect.TypeOf(message)
-				Logger.log.Infof("Cmd message type of struct %s", realType.String())
+	if peerConn.Config.MessageFilter != nil && !peerConn.Config.MessageFilter(peerConn, command) {
+		return
+	}
+
+	if !peerConn.allowMessage(command) {
+		Logger.log.Warnf("PEER %s exceeded rate limit of %s message, message is dropped", peerConn.RemotePeerID.Pretty(), command)
+		return
+	}
+
+	// cache message hash S
+	hashMsgRaw := common.HashH(jsonDecodeBytes).String()
+	if dedupe && peerConn.ListenerPeer.CheckHashPool(hashMsgRaw) {
+		Logger.log.Infof("InMessageHandler existed raw hash message %s", hashMsgRaw)
+		return
+	}
+	peerConn.ListenerPeer.HashToPool(hashMsgRaw)
+	// cache message hash E
+
+	Logger.log.Infof("In message content : %s", string(jsonDecodeBytes))
+
+	// Parse Message body
+	messageBody := jsonDecodeBytes[:len(jsonDecodeBytes)-wire.MessageHeaderSize]
+
+	messageHeader := jsonDecodeBytes[len(jsonDecodeBytes)-wire.MessageHeaderSize:]
+	forwardType := messageHeader[wire.MessageCmdTypeSize]
+
+	// get cmd type in header message
+	commandInHeader := bytes.Trim(messageHeader[:wire.MessageCmdTypeSize], "\x00")
+	commandType := string(messageHeader[:len(commandInHeader)])
+	// convert to particular message from message cmd type
+	message, err := wire.MakeEmptyMessage(string(commandType))
+	if err != nil {
+		Logger.log.Error("Can not find particular message for message cmd type")
+		Logger.log.Error(err)
+		return
+	}
+
+	err = json.Unmarshal(messageBody, &message)
+	if err != nil {
+		Logger.log.Error("Can not parse struct from json message")
+		Logger.log.Error(err)
+		// signer of a malformed message is unknown, so only a message which is not relayed is blamed on remote peer
+		peerConn.addBanScoreOfMessage(OffenseMalformedMessage, err.Error(), nil, forwardType)
+		return
+	}
+	realType := reflect.TypeOf(message)
+	Logger.log.Infof("Cmd message type of struct %s", realType.String())
+
+	// cache message hash S
+	hashMsg := message.Hash()
+	if dedupe && peerConn.ListenerPeer.CheckHashPool(hashMsg) {
+		Logger.log.Infof("InMessageHandler existed hash message %s", hashMsg)
+		return
+	}
+	peerConn.ListenerPeer.HashToPool(hashMsg)
+	// cache message hash E
+
+	// message which fails sanity check is dropped before it is forwarded, bft messages fail it by invalid signature
+	err = message.VerifyMsgSanity()
+	if err != nil {
+		Logger.log.Error(err)
+		offense := OffenseInvalidMessage
+		switch message.(type) {
+		case *wire.MessageBFTPropose, *wire.MessageBFTPrepare, *wire.MessageBFTCommit, *wire.MessageBFTReady, *wire.MessageBFTReq:
+			offense = OffenseInvalidSignature
+		}
+		peerConn.addBanScoreOfMessage(offense, err.Error(), message, forwardType)
+		return
+	}
 
-				// cache message hash S
-				hashMsg := message.Hash()
-				if peerConn.ListenerPeer.CheckHashPool(hashMsg) {
-					Logger.log.Infof("InMessageHandler existed hash message %s", hashMsg)
+	// check forward
+	if peerConn.Config.MessageListeners.GetCurrentRoleShard != nil {
+		cRole, cShard := peerConn.Config.MessageListeners.GetCurrentRoleShard()
+		if cShard != nil {
+			if forwardType == MESSAGE_TO_SHARD {
+				fS := messageHeader[wire.MessageCmdTypeSize+1]
+				if *cShard != fS {
+					if peerConn.Config.MessageListeners.PushRawBytesToShard != nil {
+						peerConn.Config.MessageListeners.PushRawBytesToShard(peerConn, &jsonDecodeBytes, *cShard)
+					}
 					return
 				}
-				peerConn.ListenerPeer.HashToPool(hashMsg)
-				// cache message hash E
-
-				// process message for each of message type
-				switch realType {
-				case reflect.TypeOf(&wire.MessageTx{}):
-					if peerConn.Config.MessageListeners.OnTx != nil {
-						peerConn.Config.MessageListeners.OnTx(peerConn, message.(*wire.MessageTx))
-					}
-				case reflect.TypeOf(&wire.MessageBlockShard{}):
-					if peerConn.Config.MessageListeners.OnBlockShard != nil {
-						peerConn.Config.MessageListeners.OnBlockShard(peerConn, message.(*wire.MessageBlockShard))
-					}
-				case reflect.TypeOf(&wire.MessageBlockBeacon{}):
-					if peerConn.Config.MessageListeners.OnBlockBeacon != nil {
-						peerConn.Config.MessageListeners.OnBlockBeacon(peerConn, message.(*wire.MessageBlockBeacon))
-					}
-				case reflect.TypeOf(&wire.MessageCrossShard{}):
-					if peerConn.Config.MessageListeners.OnCrossShard != nil {
-						peerConn.Config.MessageListeners.OnCrossShard(peerConn, message.(*wire.MessageCrossShard))
-					}
-				case reflect.TypeOf(&wire.MessageShardToBeacon{}):
-					if peerConn.Config.MessageListeners.OnShardToBeacon != nil {
-						peerConn.Config.MessageListeners.OnShardToBeacon(peerConn, message.(*wire.MessageShardToBeacon))
-					}
-				case reflect.TypeOf(&wire.MessageGetBlockBeacon{}):
-					if peerConn.Config.MessageListeners.OnGetBlockBeacon != nil {
-						peerConn.Config.MessageListeners.OnGetBlockBeacon(peerConn, message.(*wire.MessageGetBlockBeacon))
-					}
-				case reflect.TypeOf(&wire.MessageGetBlockShard{}):
-					if peerConn.Config.MessageListeners.OnGetBlockShard != nil {
-						peerConn.Config.MessageListeners.OnGetBlockShard(peerConn, message.(*wire.MessageGetBlockShard))
-					}
-				case reflect.TypeOf(&wire.MessageGetCrossShard{}):
-					if peerConn.Config.MessageListeners.OnGetCrossShard != nil {
-						peerConn.Config.MessageListeners.OnGetCrossShard(peerConn, message.(*wire.MessageGetCrossShard))
-					}
-				case reflect.TypeOf(&wire.MessageGetShardToBeacon{}):
-					if peerConn.Config.MessageListeners.OnGetShardToBeacon != nil {
-						peerConn.Config.MessageListeners.OnGetShardToBeacon(peerConn, message.(*wire.MessageGetShardToBeacon))
-					}
-				case reflect.TypeOf(&wire.MessageVersion{}):
-					if peerConn.Config.MessageListeners.OnVersion != nil {
-						versionMessage := message.(*wire.MessageVersion)
-						peerConn.Config.MessageListeners.OnVersion(peerConn, versionMessage)
-					}
-				case reflect.TypeOf(&wire.MessageVerAck{}):
-					peerConn.verAckReceived = true
-					if peerConn.Config.MessageListeners.OnVerAck != nil {
-						peerConn.Config.MessageListeners.OnVerAck(peerConn, message.(*wire.MessageVerAck))
-					}
-				case reflect.TypeOf(&wire.MessageGetAddr{}):
-					if peerConn.Config.MessageListeners.OnGetAddr != nil {
-						peerConn.Config.MessageListeners.OnGetAddr(peerConn, message.(*wire.MessageGetAddr))
-					}
-				case reflect.TypeOf(&wire.MessageAddr{}):
-					if peerConn.Config.MessageListeners.OnGetAddr != nil {
-						peerConn.Config.MessageListeners.OnAddr(peerConn, message.(*wire.MessageAddr))
-					}
-				case reflect.TypeOf(&wire.MessageBFTPropose{}):
-					if peerConn.Config.MessageListeners.OnBFTMsg != nil {
-						peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTPropose))
-					}
-				case reflect.TypeOf(&wire.MessageBFTPrepare{}):
-					if peerConn.Config.MessageListeners.OnBFTMsg != nil {
-						peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTPrepare))
-					}
-				case reflect.TypeOf(&wire.MessageBFTCommit{}):
-					if peerConn.Config.MessageListeners.OnBFTMsg != nil {
-						peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTCommit))
-					}
-				case reflect.TypeOf(&wire.MessageBFTReady{}):
-					if peerConn.Config.MessageListeners.OnBFTMsg != nil {
-						peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTReady))
-					}
-				case reflect.TypeOf(&wire.MessageBFTReq{}):
-					if peerConn.Config.MessageListeners.OnBFTMsg != nil {
-						peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTReq))
-					}
-				case reflect.TypeOf(&wire.MessagePeerState{}):
-					if peerConn.Config.MessageListeners.OnPeerState != nil {
-						peerConn.Config.MessageListeners.OnPeerState(peerConn, message.(*wire.MessagePeerState))
-					}
-				case reflect.TypeOf(&wire.MessageMsgCheck{}):
-					peerConn.handleMsgCheck(message.(*wire.MessageMsgCheck))
-				case reflect.TypeOf(&wire.MessageMsgCheckResp{}):
-					peerConn.handleMsgCheckResp(message.(*wire.MessageMsgCheckResp))
-				default:
-					Logger.log.Warnf("InMessageHandler Received unhandled message of type % from %v", realType, peerConn)
+			}
+		}
+		if cRole != "" {
+			if forwardType == MESSAGE_TO_BEACON && cRole != "beacon" {
+				if peerConn.Config.MessageListeners.PushRawBytesToBeacon != nil {
+					peerConn.Config.MessageListeners.PushRawBytesToBeacon(peerConn, &jsonDecodeBytes)
 				}
-			}(str)
+				return
+			}
+		}
+	}
+
+	// process message for each of message type
+	switch realType {
+	case reflect.TypeOf(&wire.MessageTx{}):
+		if peerConn.Config.MessageListeners.OnTx != nil {
+			peerConn.Config.MessageListeners.OnTx(peerConn, message.(*wire.MessageTx))
+		}
+	case reflect.TypeOf(&wire.MessageStemTx{}):
+		if peerConn.Config.MessageListeners.OnStemTx != nil {
+			peerConn.Config.MessageListeners.OnStemTx(peerConn, message.(*wire.MessageStemTx))
+		}
+	case reflect.TypeOf(&wire.MessageBlockShard{}):
+		if peerConn.Config.MessageListeners.OnBlockShard != nil {
+			peerConn.Config.MessageListeners.OnBlockShard(peerConn, message.(*wire.MessageBlockShard))
+		}
+	case reflect.TypeOf(&wire.MessageBlockBeacon{}):
+		if peerConn.Config.MessageListeners.OnBlockBeacon != nil {
+			peerConn.Config.MessageListeners.OnBlockBeacon(peerConn, message.(*wire.MessageBlockBeacon))
+		}
+	case reflect.TypeOf(&wire.MessageCrossShard{}):
+		if peerConn.Config.MessageListeners.OnCrossShard != nil {
+			peerConn.Config.MessageListeners.OnCrossShard(peerConn, message.(*wire.MessageCrossShard))
+		}
+	case reflect.TypeOf(&wire.MessageShardToBeacon{}):
+		if peerConn.Config.MessageListeners.OnShardToBeacon != nil {
+			peerConn.Config.MessageListeners.OnShardToBeacon(peerConn, message.(*wire.MessageShardToBeacon))
+		}
+	case reflect.TypeOf(&wire.MessageGetBlockBeacon{}):
+		if peerConn.Config.MessageListeners.OnGetBlockBeacon != nil {
+			peerConn.Config.MessageListeners.OnGetBlockBeacon(peerConn, message.(*wire.MessageGetBlockBeacon))
+		}
+	case reflect.TypeOf(&wire.MessageGetBlockShard{}):
+		if peerConn.Config.MessageListeners.OnGetBlockShard != nil {
+			peerConn.Config.MessageListeners.OnGetBlockShard(peerConn, message.(*wire.MessageGetBlockShard))
+		}
+	case reflect.TypeOf(&wire.MessageGetCrossShard{}):
+		if peerConn.Config.MessageListeners.OnGetCrossShard != nil {
+			peerConn.Config.MessageListeners.OnGetCrossShard(peerConn, message.(*wire.MessageGetCrossShard))
+		}
+	case reflect.TypeOf(&wire.MessageGetShardToBeacon{}):
+		if peerConn.Config.MessageListeners.OnGetShardToBeacon != nil {
+			peerConn.Config.MessageListeners.OnGetShardToBeacon(peerConn, message.(*wire.MessageGetShardToBeacon))
+		}
+	case reflect.TypeOf(&wire.MessageVersion{}):
+		if peerConn.Config.MessageListeners.OnVersion != nil {
+			versionMessage := message.(*wire.MessageVersion)
+			peerConn.Config.MessageListeners.OnVersion(peerConn, versionMessage)
+		}
+	case reflect.TypeOf(&wire.MessageVerAck{}):
+		peerConn.verAckReceived = true
+		if peerConn.Config.MessageListeners.OnVerAck != nil {
+			peerConn.Config.MessageListeners.OnVerAck(peerConn, message.(*wire.MessageVerAck))
+		}
+	case reflect.TypeOf(&wire.MessageGetAddr{}):
+		if peerConn.Config.MessageListeners.OnGetAddr != nil {
+			peerConn.Config.MessageListeners.OnGetAddr(peerConn, message.(*wire.MessageGetAddr))
+		}
+	case reflect.TypeOf(&wire.MessageAddr{}):
+		if peerConn.Config.MessageListeners.OnGetAddr != nil {
+			peerConn.Config.MessageListeners.OnAddr(peerConn, message.(*wire.MessageAddr))
+		}
+	case reflect.TypeOf(&wire.MessageInv{}):
+		if peerConn.Config.MessageListeners.OnInv != nil {
+			peerConn.Config.MessageListeners.OnInv(peerConn, message.(*wire.MessageInv))
+		}
+	case reflect.TypeOf(&wire.MessageGetData{}):
+		if peerConn.Config.MessageListeners.OnGetData != nil {
+			peerConn.Config.MessageListeners.OnGetData(peerConn, message.(*wire.MessageGetData))
+		}
+	case reflect.TypeOf(&wire.MessageCompactBlockShard{}):
+		if peerConn.Config.MessageListeners.OnCompactBlockShard != nil {
+			peerConn.Config.MessageListeners.OnCompactBlockShard(peerConn, message.(*wire.MessageCompactBlockShard))
+		}
+	case reflect.TypeOf(&wire.MessageGetBlockTxn{}):
+		if peerConn.Config.MessageListeners.OnGetBlockTxn != nil {
+			peerConn.Config.MessageListeners.OnGetBlockTxn(peerConn, message.(*wire.MessageGetBlockTxn))
+		}
+	case reflect.TypeOf(&wire.MessageBlockTxn{}):
+		if peerConn.Config.MessageListeners.OnBlockTxn != nil {
+			peerConn.Config.MessageListeners.OnBlockTxn(peerConn, message.(*wire.MessageBlockTxn))
+		}
+	case reflect.TypeOf(&wire.MessageBFTPropose{}):
+		if peerConn.Config.MessageListeners.OnBFTMsg != nil {
+			peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTPropose))
+		}
+	case reflect.TypeOf(&wire.MessageBFTPrepare{}):
+		if peerConn.Config.MessageListeners.OnBFTMsg != nil {
+			peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTPrepare))
+		}
+	case reflect.TypeOf(&wire.MessageBFTCommit{}):
+		if peerConn.Config.MessageListeners.OnBFTMsg != nil {
+			peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTCommit))
+		}
+	case reflect.TypeOf(&wire.MessageBFTReady{}):
+		if peerConn.Config.MessageListeners.OnBFTMsg != nil {
+			peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTReady))
 		}
+	case reflect.TypeOf(&wire.MessageBFTReq{}):
+		if peerConn.Config.MessageListeners.OnBFTMsg != nil {
+			peerConn.Config.MessageListeners.OnBFTMsg(peerConn, message.(*wire.MessageBFTReq))
+		}
+	case reflect.TypeOf(&wire.MessagePeerState{}):
+		if peerConn.Config.MessageListeners.OnPeerState != nil {
+			peerConn.Config.MessageListeners.OnPeerState(peerConn, message.(*wire.MessagePeerState))
+		}
+	case reflect.TypeOf(&wire.MessageMsgCheck{}):
+		peerConn.handleMsgCheck(message.(*wire.MessageMsgCheck))
+	case reflect.TypeOf(&wire.MessageMsgCheckResp{}):
+		peerConn.handleMsgCheckResp(message.(*wire.MessageMsgCheckResp))
+	default:
+		Logger.log.Warnf("InMessageHandler Received unhandled message of type % from %v", realType, peerConn)
 	}
 }
 
@@ -332,16 +433,13 @@ func (peerConn *PeerConn) OutMessageHandler(rw *bufio.ReadWriter) {
 		select {
 		case outMsg := <-peerConn.sendMessageQueue:
 			{
-				var sendString string
+				var messageBytes []byte
 				if outMsg.rawBytes != nil && len(*outMsg.rawBytes) > 0 {
 					Logger.log.Infof("OutMessageHandler with raw bytes")
-					message := hex.EncodeToString(*outMsg.rawBytes)
-					message += DelimMessageStr
-					sendString = message
-					Logger.log.Infof("Send a messageHex raw bytes to %s", peerConn.RemotePeer.PeerID.Pretty())
+					messageBytes = *outMsg.rawBytes
 				} else {
 					// Create and send messageHex
-					messageBytes, err := outMsg.message.JsonSerialize()
+					jsonBytes, err := outMsg.message.JsonSerialize()
 					if err != nil {
 						Logger.log.Error("Can not serialize json format for messageHex:" + outMsg.message.MessageType())
 						Logger.log.Error(err)
@@ -349,35 +447,23 @@ func (peerConn *PeerConn) OutMessageHandler(rw *bufio.ReadWriter) {
 					}
 
 					// add 24 bytes headerBytes into messageHex
-					headerBytes := make([]byte, wire.MessageHeaderSize)
 					cmdType, _ := wire.GetCmdType(reflect.TypeOf(outMsg.message))
-					copy(headerBytes[:], []byte(cmdType))
-					copy(headerBytes[wire.MessageCmdTypeSize:], []byte{outMsg.forwardType})
+					forwardValue := byte(0)
 					if outMsg.forwardValue != nil {
-						copy(headerBytes[wire.MessageCmdTypeSize+1:], []byte{*outMsg.forwardValue})
+						forwardValue = *outMsg.forwardValue
 					}
-					messageBytes = append(messageBytes, headerBytes...)
+					messageBytes = append(jsonBytes, wire.MessageHeaderBytes(cmdType, outMsg.forwardType, forwardValue)...)
 					Logger.log.Infof("OutMessageHandler TYPE %s CONTENT %s", cmdType, string(messageBytes))
+				}
 
-					// zip data before send
-					messageBytes, err = common.GZipToBytes(messageBytes)
-					if err != nil {
-						Logger.log.Error("Can not gzip for messageHex:" + outMsg.message.MessageType())
-						Logger.log.Error(err)
-						continue
-					}
-					messageHex := hex.EncodeToString(messageBytes)
-					//Logger.log.Infof("Content in hex encode: %s", string(messageHex))
-					// add end character to messageHex (delim '\n')
-					messageHex += DelimMessageStr
-
-					// send on p2p stream
-					Logger.log.Infof("Send a messageHex %s to %s", outMsg.message.MessageType(), peerConn.RemotePeer.PeerID.Pretty())
-					sendString = messageHex
+				var err error
+				if peerConn.GetWireVersion() >= wire.WireVersionBinary {
+					err = peerConn.writeMessageFrame(rw, messageBytes, outMsg.compress)
+				} else {
+					err = peerConn.writeMessageHex(rw, messageBytes)
 				}
-				_, err := rw.Writer.WriteString(sendString)
 				if err != nil {
-					Logger.log.Critical("OutMessageHandler WriteString error", err)
+					Logger.log.Critical("OutMessageHandler write error", err)
 					continue
 				}
 				err = rw.Writer.Flush()
@@ -385,6 +471,7 @@ func (peerConn *PeerConn) OutMessageHandler(rw *bufio.ReadWriter) {
 					Logger.log.Critical("OutMessageHandler Flush error", err)
 					continue
 				}
+				Logger.log.Infof("Send a message to %s", peerConn.RemotePeer.PeerID.Pretty())
 				continue
 			}
 		case <-peerConn.cWrite:
@@ -403,6 +490,57 @@ func (peerConn *PeerConn) OutMessageHandler(rw *bufio.ReadWriter) {
 	}
 }
 
+// writeMessageHex writes json body with header in the old encoding: gzip, hex and a delimiter
+func (peerConn *PeerConn) writeMessageHex(rw *bufio.ReadWriter, messageBytes []byte) error {
+	// zip data before send
+	zipBytes, err := common.GZipToBytes(messageBytes)
+	if err != nil {
+		return err
+	}
+	peerConn.stats.addSavedSent(len(messageBytes), len(zipBytes))
+	messageHex := hex.EncodeToString(zipBytes)
+	// add end character to messageHex (delim '\n')
+	messageHex += DelimMessageStr
+	_, err = rw.Writer.WriteString(messageHex)
+	if err == nil {
+		atomic.AddUint64(&peerConn.stats.BytesSent, uint64(len(messageHex)))
+	}
+	return err
+}
+
+// writeMessageFrame writes json body with header as a binary frame, payload is gzip data when compress is set
+func (peerConn *PeerConn) writeMessageFrame(rw *bufio.ReadWriter, messageBytes []byte, compress bool) error {
+	if len(messageBytes) < wire.MessageHeaderSize {
+		return errors.New("message is shorter than its header")
+	}
+	body := messageBytes[:len(messageBytes)-wire.MessageHeaderSize]
+	command, forwardType, forwardValue := wire.ParseMessageHeaderBytes(messageBytes[len(messageBytes)-wire.MessageHeaderSize:])
+	payload := body
+	flags := byte(0)
+	if compress {
+		zipBytes, err := common.GZipToBytes(body)
+		if err != nil {
+			return err
+		}
+		// keep the original body when it is not compressible
+		if len(zipBytes) < len(body) {
+			peerConn.stats.addSavedSent(len(body), len(zipBytes))
+			payload = zipBytes
+			flags |= wire.FrameFlagGzip
+		}
+	}
+	err := wire.WriteMessageFrame(rw.Writer, command, forwardType, forwardValue, flags, payload)
+	if err == nil {
+		atomic.AddUint64(&peerConn.stats.BytesSent, uint64(wire.MessageFrameHeaderSize+len(payload)))
+	}
+	return err
+}
+
+// shouldCompress returns whether a message with length is compressed before sending to remote peer
+func (peerConn *PeerConn) shouldCompress(length int) bool {
+	return peerConn.GetCompression() && length >= COMPRESS_MESSAGE_SIZE
+}
+
 func (peerConn *PeerConn) checkMessageHashBeforeSend(hash string) bool {
 	numRetries := 0
 BeginCheckHashMessage:
@@ -477,6 +615,7 @@ func (peerConn *PeerConn) QueueMessageWithEncoding(msg wire.Message, doneChan ch
 						doneChan:     doneChan,
 						forwardType:  forwardType,
 						forwardValue: forwardValue,
+						compress:     peerConn.shouldCompress(len(data)),
 					}
 				}
 			} else {
@@ -485,6 +624,7 @@ func (peerConn *PeerConn) QueueMessageWithEncoding(msg wire.Message, doneChan ch
 					doneChan:     doneChan,
 					forwardType:  forwardType,
 					forwardValue: forwardValue,
+					compress:     peerConn.shouldCompress(len(data)),
 				}
 			}
 		}
@@ -506,12 +646,14 @@ func (peerConn *PeerConn) QueueMessageWithBytes(msgBytes *[]byte, doneChan chan<
 					peerConn.sendMessageQueue <- outMsg{
 						rawBytes: msgBytes,
 						doneChan: doneChan,
+						compress: peerConn.shouldCompress(len(*msgBytes)),
 					}
 				}
 			} else {
 				peerConn.sendMessageQueue <- outMsg{
 					rawBytes: msgBytes,
 					doneChan: doneChan,
+					compress: peerConn.shouldCompress(len(*msgBytes)),
 				}
 			}
 		}
@@ -543,6 +685,58 @@ func (p *PeerConn) handleMsgCheckResp(msg *wire.MessageMsgCheckResp) {
 	}
 }
 
+// GetWireVersion returns wire version which is used to send messages to remote peer
+func (peerConn *PeerConn) GetWireVersion() int {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	return peerConn.wireVersion
+}
+
+// SetWireVersion sets wire version after it is negotiated in version message
+func (peerConn *PeerConn) SetWireVersion(v int) {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	peerConn.wireVersion = v
+}
+
+// GetCompression returns whether messages to remote peer can be compressed
+func (peerConn *PeerConn) GetCompression() bool {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	return peerConn.compression
+}
+
+// SetCompression sets compression flag after it is negotiated in version message
+func (peerConn *PeerConn) SetCompression(v bool) {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	peerConn.compression = v
+}
+
+// HasCapability returns whether a capability is negotiated with remote peer in version message
+func (peerConn *PeerConn) HasCapability(capability uint64) bool {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	return peerConn.capabilities&capability != 0
+}
+
+// SetCapabilities sets capabilities which are supported by both nodes
+func (peerConn *PeerConn) SetCapabilities(capabilities uint64) {
+	peerConn.wireVersionMtx.Lock()
+	defer peerConn.wireVersionMtx.Unlock()
+	peerConn.capabilities = capabilities
+}
+
+// GetTrafficStats returns counters of bytes which are sent to and received from remote peer
+func (peerConn *PeerConn) GetTrafficStats() TrafficStats {
+	return TrafficStats{
+		BytesSent:          atomic.LoadUint64(&peerConn.stats.BytesSent),
+		BytesReceived:      atomic.LoadUint64(&peerConn.stats.BytesReceived),
+		BytesSavedSent:     atomic.LoadUint64(&peerConn.stats.BytesSavedSent),
+		BytesSavedReceived: atomic.LoadUint64(&peerConn.stats.BytesSavedReceived),
+	}
+}
+
 func (p *PeerConn) VerAckReceived() bool {
 	return p.verAckReceived
 }
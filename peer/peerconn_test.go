@@ -0,0 +1,140 @@
+package peer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+func newFrameTestConn() *PeerConn {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("Peer test", true))
+	peerConn := &PeerConn{}
+	peerConn.SetWireVersion(wire.WireVersionBinary)
+	return peerConn
+}
+
+// writeTestFrame writes body as a frame of tx message and returns the frame which is read back
+func writeTestFrame(t *testing.T, peerConn *PeerConn, body []byte, compress bool) (*wire.MessageFrameHeader, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	rw := bufio.NewReadWriter(bufio.NewReader(buf), bufio.NewWriter(buf))
+	messageBytes := append(append([]byte{}, body...), wire.MessageHeaderBytes(wire.CmdTx, 's', 2)...)
+	if err := peerConn.writeMessageFrame(rw, messageBytes, compress); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if err := rw.Writer.Flush(); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	header, _, err := wire.ReadMessageFrame(bytes.NewReader(buf.Bytes()), SPAM_MESSAGE_SIZE)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return header, buf
+}
+
+// readTestFrame reads a message from buf with a new connection and checks that its body and header are sent ones
+func readTestFrame(t *testing.T, buf *bytes.Buffer, body []byte) TrafficStats {
+	receiver := newFrameTestConn()
+	messageBytes, err := receiver.readMessage(bufio.NewReadWriter(bufio.NewReader(buf), nil))
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	expected := append(append([]byte{}, body...), wire.MessageHeaderBytes(wire.CmdTx, 's', 2)...)
+	if !bytes.Equal(messageBytes, expected) {
+		t.Errorf("expected read message to be sent message")
+	}
+	return receiver.GetTrafficStats()
+}
+
+func TestShouldCompress(t *testing.T) {
+	peerConn := newFrameTestConn()
+	if peerConn.shouldCompress(COMPRESS_MESSAGE_SIZE * 10) {
+		t.Errorf("expected message not to be compressed when compression is not negotiated")
+	}
+	peerConn.SetCompression(true)
+	if peerConn.shouldCompress(COMPRESS_MESSAGE_SIZE - 1) {
+		t.Errorf("expected message below threshold not to be compressed")
+	}
+	if !peerConn.shouldCompress(COMPRESS_MESSAGE_SIZE) {
+		t.Errorf("expected message at threshold to be compressed")
+	}
+}
+
+func TestWriteMessageFrame(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"Type":"n","Fee":0}`), 200)
+
+	// without negotiated compression, the body is sent as it is
+	sender := newFrameTestConn()
+	header, buf := writeTestFrame(t, sender, body, sender.shouldCompress(len(body)))
+	if header.Flags&wire.FrameFlagGzip != 0 || int(header.Length) != len(body) {
+		t.Errorf("expected plain frame, got %+v", header)
+	}
+	if stats := sender.GetTrafficStats(); stats.BytesSent != uint64(wire.MessageFrameHeaderSize+len(body)) || stats.BytesSavedSent != 0 {
+		t.Errorf("unexpected stats of sender %+v", stats)
+	}
+	if stats := readTestFrame(t, buf, body); stats.BytesReceived != uint64(wire.MessageFrameHeaderSize+len(body)) || stats.BytesSavedReceived != 0 {
+		t.Errorf("unexpected stats of receiver %+v", stats)
+	}
+
+	// with negotiated compression, the body is gzip data
+	sender = newFrameTestConn()
+	sender.SetCompression(true)
+	header, buf = writeTestFrame(t, sender, body, sender.shouldCompress(len(body)))
+	if header.Flags&wire.FrameFlagGzip == 0 || int(header.Length) >= len(body) {
+		t.Fatalf("expected gzip frame, got %+v", header)
+	}
+	saved := uint64(len(body) - int(header.Length))
+	if stats := sender.GetTrafficStats(); stats.BytesSent != uint64(wire.MessageFrameHeaderSize)+uint64(header.Length) || stats.BytesSavedSent != saved {
+		t.Errorf("unexpected stats of sender %+v, expected %d bytes saved", stats, saved)
+	}
+	if stats := readTestFrame(t, buf, body); stats.BytesReceived != uint64(wire.MessageFrameHeaderSize)+uint64(header.Length) || stats.BytesSavedReceived != saved {
+		t.Errorf("unexpected stats of receiver %+v, expected %d bytes saved", stats, saved)
+	}
+}
+
+func TestWriteMessageFrameBelowThreshold(t *testing.T) {
+	body := []byte(`{"Type":"n"}`)
+	sender := newFrameTestConn()
+	sender.SetCompression(true)
+	header, buf := writeTestFrame(t, sender, body, sender.shouldCompress(len(body)))
+	if header.Flags&wire.FrameFlagGzip != 0 || int(header.Length) != len(body) {
+		t.Errorf("expected small message to be sent as it is, got %+v", header)
+	}
+	readTestFrame(t, buf, body)
+}
+
+func TestWriteMessageFrameIncompressible(t *testing.T) {
+	body := make([]byte, COMPRESS_MESSAGE_SIZE*4)
+	if _, err := rand.Read(body); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	sender := newFrameTestConn()
+	sender.SetCompression(true)
+	header, buf := writeTestFrame(t, sender, body, sender.shouldCompress(len(body)))
+	if header.Flags&wire.FrameFlagGzip != 0 || int(header.Length) != len(body) {
+		t.Errorf("expected original body when gzip is not smaller, got %+v", header)
+	}
+	if stats := sender.GetTrafficStats(); stats.BytesSavedSent != 0 {
+		t.Errorf("expected no bytes saved, got %+v", stats)
+	}
+	readTestFrame(t, buf, body)
+
+	if err := sender.writeMessageFrame(nil, []byte("short"), true); err == nil {
+		t.Errorf("expected message shorter than its header to fail")
+	}
+}
+
+func TestTrafficStatsSaved(t *testing.T) {
+	stats := &TrafficStats{}
+	stats.addSavedSent(100, 40)
+	stats.addSavedSent(100, 120)
+	stats.addSavedReceived(50, 20)
+	stats.addSavedReceived(50, 50)
+	if stats.BytesSavedSent != 60 || stats.BytesSavedReceived != 30 {
+		t.Errorf("expected only saved bytes to be counted, got %+v", stats)
+	}
+}
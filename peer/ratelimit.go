@@ -0,0 +1,135 @@
+package peer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ninjadotorg/constant/wire"
+)
+
+// RateLimit is a token bucket which refills Rate messages per second up to Burst messages
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DEFAULT_RATE_LIMITS are limits of messages which make node read blocks from database or validate transactions,
+// other messages are not limited unless they are set in config
+var DEFAULT_RATE_LIMITS = map[string]RateLimit{
+	wire.CmdGetBlockShard:    {Rate: 2, Burst: 10},
+	wire.CmdGetBlockBeacon:   {Rate: 2, Burst: 10},
+	wire.CmdGetCrossShard:    {Rate: 2, Burst: 10},
+	wire.CmdGetShardToBeacon: {Rate: 2, Burst: 10},
+	wire.CmdGetBlockTxn:      {Rate: 5, Burst: 20},
+	wire.CmdGetData:          {Rate: 20, Burst: 100},
+	wire.CmdStemTx:           {Rate: 10, Burst: 50},
+}
+
+/*
+ParseRateLimit - parse a rate limit in format <command>:<rate per second>:<burst>
+*/
+func ParseRateLimit(value string) (string, RateLimit, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", RateLimit{}, fmt.Errorf("rate limit %s is not in format <command>:<rate>:<burst>", value)
+	}
+	rate, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || rate <= 0 {
+		return "", RateLimit{}, fmt.Errorf("rate of rate limit %s is invalid", value)
+	}
+	burst, err := strconv.Atoi(parts[2])
+	if err != nil || burst <= 0 {
+		return "", RateLimit{}, fmt.Errorf("burst of rate limit %s is invalid", value)
+	}
+	return parts[0], RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// take refills bucket by elapsed time then takes a token, it returns false when bucket is empty
+func (bucket *tokenBucket) take(now time.Time) bool {
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.limit.Rate
+	if bucket.tokens > float64(bucket.limit.Burst) {
+		bucket.tokens = float64(bucket.limit.Burst)
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// MessageRateStats houses counters of a message type which is received from a peer
+type MessageRateStats struct {
+	Received uint64
+	Dropped  uint64
+}
+
+// messageRateLimiter keeps token buckets and counters of a peer connection by message type
+type messageRateLimiter struct {
+	buckets map[string]*tokenBucket
+	stats   map[string]*MessageRateStats
+	mtx     sync.Mutex
+}
+
+func (limiter *messageRateLimiter) allow(command string, limits map[string]RateLimit, now time.Time) bool {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	if limiter.stats == nil {
+		limiter.stats = make(map[string]*MessageRateStats)
+		limiter.buckets = make(map[string]*tokenBucket)
+	}
+	stats, ok := limiter.stats[command]
+	if !ok {
+		stats = &MessageRateStats{}
+		limiter.stats[command] = stats
+	}
+	stats.Received++
+
+	limit, ok := limits[command]
+	if !ok {
+		return true
+	}
+	bucket, ok := limiter.buckets[command]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		limiter.buckets[command] = bucket
+	}
+	if !bucket.take(now) {
+		stats.Dropped++
+		return false
+	}
+	return true
+}
+
+// allowMessage returns whether a message of command is in rate limit of remote peer
+func (peerConn *PeerConn) allowMessage(command string) bool {
+	// unknown command is dropped later, it is not counted so that counters stay bounded
+	if _, err := wire.MakeEmptyMessage(command); err != nil {
+		return true
+	}
+	limits := peerConn.Config.RateLimits
+	if limits == nil {
+		limits = DEFAULT_RATE_LIMITS
+	}
+	return peerConn.rateLimiter.allow(command, limits, peerConn.now())
+}
+
+// GetRateStats returns counters of messages which are received from remote peer by message type
+func (peerConn *PeerConn) GetRateStats() map[string]MessageRateStats {
+	peerConn.rateLimiter.mtx.Lock()
+	defer peerConn.rateLimiter.mtx.Unlock()
+	result := make(map[string]MessageRateStats, len(peerConn.rateLimiter.stats))
+	for command, stats := range peerConn.rateLimiter.stats {
+		result[command] = *stats
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bucket := &tokenBucket{limit: RateLimit{Rate: 2, Burst: 3}, tokens: 3, last: now}
+	for i := 0; i < 3; i++ {
+		if !bucket.take(now) {
+			t.Fatalf("expected message %d in burst to be allowed", i)
+		}
+	}
+	if bucket.take(now) {
+		t.Errorf("expected message over burst to be dropped")
+	}
+
+	// 2 tokens per second, half a second refills one token
+	now = now.Add(500 * time.Millisecond)
+	if !bucket.take(now) {
+		t.Errorf("expected refilled token to be taken")
+	}
+	if bucket.take(now) {
+		t.Errorf("expected bucket to be empty again")
+	}
+
+	// a long idle time does not refill more than burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !bucket.take(now) {
+			t.Fatalf("expected message %d after idle to be allowed", i)
+		}
+	}
+	if bucket.take(now) {
+		t.Errorf("expected bucket to be capped at burst")
+	}
+}
+
+func TestAllowMessage(t *testing.T) {
+	clock := common.NewManualClock(time.Unix(1000, 0))
+	peerConn := &PeerConn{Config: Config{
+		Clock:      clock,
+		RateLimits: map[string]RateLimit{wire.CmdGetBlockShard: {Rate: 1, Burst: 1}},
+	}}
+	if !peerConn.allowMessage(wire.CmdGetBlockShard) || peerConn.allowMessage(wire.CmdGetBlockShard) {
+		t.Errorf("expected only one message in burst")
+	}
+	clock.Advance(time.Second)
+	if !peerConn.allowMessage(wire.CmdGetBlockShard) {
+		t.Errorf("expected message after refill")
+	}
+	// commands without a limit are always allowed
+	for i := 0; i < 10; i++ {
+		if !peerConn.allowMessage(wire.CmdTx) {
+			t.Fatalf("expected unlimited message to be allowed")
+		}
+	}
+	stats := peerConn.rateLimiter.stats[wire.CmdGetBlockShard]
+	if stats.Received != 3 || stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	command, limit, err := ParseRateLimit("getblockshard:2.5:10")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if command != "getblockshard" || limit.Rate != 2.5 || limit.Burst != 10 {
+		t.Errorf("unexpected rate limit %s %+v", command, limit)
+	}
+
+	invalids := []string{
+		"",
+		"getblockshard",
+		"getblockshard:2",
+		"getblockshard:2:10:1",
+		":2:10",
+		"getblockshard:rate:10",
+		"getblockshard:0:10",
+		"getblockshard:-1:10",
+		"getblockshard:2:burst",
+		"getblockshard:2:0",
+		"getblockshard:2:1.5",
+	}
+	for _, value := range invalids {
+		if _, _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("expected error of rate limit %s", value)
+		}
+	}
+}
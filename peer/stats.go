@@ -0,0 +1,41 @@
+package peer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TrafficStats houses counters of bytes on the stream of a peer connection,
+// bytes saved are the difference between message length and its gzip length
+type TrafficStats struct {
+	BytesSent          uint64
+	BytesReceived      uint64
+	BytesSavedSent     uint64
+	BytesSavedReceived uint64
+}
+
+func (stats *TrafficStats) addSavedSent(rawLength int, compressedLength int) {
+	if rawLength > compressedLength {
+		atomic.AddUint64(&stats.BytesSavedSent, uint64(rawLength-compressedLength))
+	}
+}
+
+func (stats *TrafficStats) addSavedReceived(rawLength int, compressedLength int) {
+	if rawLength > compressedLength {
+		atomic.AddUint64(&stats.BytesSavedReceived, uint64(rawLength-compressedLength))
+	}
+}
+
+// SetVersionSent records time when version message is sent to remote peer
+func (peerConn *PeerConn) SetVersionSent(sentTime time.Time) {
+	atomic.StoreInt64(&peerConn.versionSent, sentTime.UnixNano())
+}
+
+// HandshakeLatency returns duration from version message is sent until now, 0 when version message is not sent
+func (peerConn *PeerConn) HandshakeLatency(now time.Time) time.Duration {
+	versionSent := atomic.LoadInt64(&peerConn.versionSent)
+	if versionSent == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, versionSent))
+}
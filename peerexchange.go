@@ -0,0 +1,106 @@
+package main
+
+import (
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+/*
+localRecord - return record of this node which is signed by its key set,
+ok is false when node has no key set or its external address is not known
+*/
+func (serverObj *Server) localRecord() (wire.RawPeer, bool) {
+	rawAddress := serverObj.connManager.ExternalRawAddress()
+	if rawAddress == common.EmptyString || serverObj.userKeySet == nil || len(serverObj.userKeySet.PrivateKey) == 0 {
+		return wire.RawPeer{}, false
+	}
+	role, shardID := serverObj.connManager.GetCurrentRoleShard()
+	record := wire.RawPeer{
+		RawAddress: rawAddress,
+		Role:       role,
+	}
+	if shardID != nil {
+		shard := *shardID
+		record.ShardID = &shard
+	}
+	err := record.Sign(serverObj.userKeySet, time.Now())
+	if err != nil {
+		Logger.log.Error(err)
+		return wire.RawPeer{}, false
+	}
+	return record, true
+}
+
+/*
+makeAddrMessage - make addr message with signed record of this node and records of good known addresses,
+addresses of the remote peer are not sent back to it
+*/
+func (serverObj *Server) makeAddrMessage(peerConn *peer.PeerConn) (wire.Message, error) {
+	msg, err := wire.MakeEmptyMessage(wire.CmdAddr)
+	if err != nil {
+		return nil, err
+	}
+	rawPeers := []wire.RawPeer{}
+	if record, ok := serverObj.localRecord(); ok {
+		rawPeers = append(rawPeers, record)
+	}
+	for _, rawPeer := range serverObj.addrManager.KnownRecords(wire.MaxAddrPerMsg) {
+		if len(rawPeers) >= wire.MaxAddrPerMsg {
+			break
+		}
+		if peerConn.RemotePeerID.Pretty() != serverObj.connManager.GetPeerId(rawPeer.RawAddress) {
+			rawPeers = append(rawPeers, rawPeer)
+		}
+	}
+	msg.(*wire.MessageAddr).RawPeers = rawPeers
+	return msg, nil
+}
+
+/*
+addPeerRecords - add records which a peer shares into new buckets of address manager with the peer as source,
+role and shard are only kept from records which are signed by owner of public key,
+a peer which relays a forged record is penalized, a record which is expired or signed in the future
+is dropped without penalty because clocks of peers differ
+*/
+func (serverObj *Server) addPeerRecords(peerConn *peer.PeerConn, rawPeers []wire.RawPeer) {
+	now := time.Now()
+	localPeerID := serverObj.connManager.Config.ListenerPeer.PeerID.Pretty()
+	penalized := false
+	for i := range rawPeers {
+		rawPeer := &rawPeers[i]
+		peerID := serverObj.connManager.GetPeerId(rawPeer.RawAddress)
+		if peerID == common.EmptyString || peerID == localPeerID {
+			continue
+		}
+		decodedPeerID, err := libp2p.IDB58Decode(peerID)
+		if err != nil {
+			continue
+		}
+		var record *wire.RawPeer
+		if rawPeer.IsSigned() {
+			if err := rawPeer.CheckRecordTime(now); err != nil {
+				Logger.log.Debugf("Drop record of %s from peer %s: %+v", rawPeer.RawAddress, peerConn.RemotePeerID.Pretty(), err)
+				continue
+			}
+			err := rawPeer.VerifyRecord(now)
+			if err != nil {
+				Logger.log.Debugf("Invalid record of %s from peer %s: %+v", rawPeer.RawAddress, peerConn.RemotePeerID.Pretty(), err)
+				if !penalized {
+					peerConn.AddBanScore(peer.OffenseInvalidSignature, err.Error())
+					penalized = true
+				}
+				continue
+			}
+			record = rawPeer
+		}
+		serverObj.addrManager.AddAddress(&peer.Peer{
+			PeerID:     decodedPeerID,
+			RawAddress: rawPeer.RawAddress,
+			PublicKey:  rawPeer.PublicKey,
+		}, record, peerConn.RemoteRawAddress)
+	}
+}
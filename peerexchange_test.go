@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/addrmanager"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/connmanager"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	testLocalPeerID  = "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC"
+	testRemotePeerID = "QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+	testRecordPeerID = "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM"
+)
+
+func newPeerExchangeTestServer(t *testing.T) (*Server, *peer.PeerConn, func()) {
+	backend := common.NewBackend(ioutil.Discard)
+	Logger.Init(backend.Logger("Server test", true))
+	peer.Logger.Init(backend.Logger("Peer test", true))
+	connmanager.Logger.Init(backend.Logger("ConnManager test", true))
+	addrmanager.Logger.Init(backend.Logger("AddrManager test", true))
+
+	dataDir, err := ioutil.TempDir("", "peerexchange")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	localPeerID, _ := libp2p.IDB58Decode(testLocalPeerID)
+	remotePeerID, _ := libp2p.IDB58Decode(testRemotePeerID)
+	serverObj := &Server{
+		connManager: &connmanager.ConnManager{Config: connmanager.Config{ListenerPeer: &peer.Peer{PeerID: localPeerID}}},
+		addrManager: addrmanager.New(dataDir),
+	}
+	peerConn := &peer.PeerConn{
+		RemotePeerID:     remotePeerID,
+		RemoteRawAddress: "/ip4/5.6.7.8/tcp/9333/ipfs/" + testRemotePeerID,
+	}
+	return serverObj, peerConn, func() { os.RemoveAll(dataDir) }
+}
+
+func newTestRecord(t *testing.T, signedAt time.Time) wire.RawPeer {
+	keySet := (&cashec.KeySet{}).GenerateKey([]byte("peer exchange"))
+	shardID := byte(2)
+	record := wire.RawPeer{RawAddress: "/ip4/1.2.3.4/tcp/9333/ipfs/" + testRecordPeerID, Role: "shard", ShardID: &shardID}
+	if err := record.Sign(keySet, signedAt); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return record
+}
+
+func TestAddPeerRecords(t *testing.T) {
+	serverObj, peerConn, cleanup := newPeerExchangeTestServer(t)
+	defer cleanup()
+
+	record := newTestRecord(t, time.Now())
+	serverObj.addPeerRecords(peerConn, []wire.RawPeer{
+		record,
+		// address of this node and addresses without peer id are ignored
+		{RawAddress: "/ip4/9.9.9.9/tcp/9333/ipfs/" + testLocalPeerID},
+		{RawAddress: "/ip4/9.9.9.9/tcp/9333"},
+	})
+	records := serverObj.addrManager.KnownRecords(wire.MaxAddrPerMsg)
+	if len(records) != 1 || records[0].SignDataB58 != record.SignDataB58 {
+		t.Fatalf("expected signed record to be kept, got %+v", records)
+	}
+	if peerConn.GetBanScore() != 0 {
+		t.Errorf("expected no penalty, got %d", peerConn.GetBanScore())
+	}
+
+	// address of this node is not sent back to it
+	msg, err := serverObj.makeAddrMessage(peerConn)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if rawPeers := msg.(*wire.MessageAddr).RawPeers; len(rawPeers) != 1 || rawPeers[0].RawAddress != record.RawAddress {
+		t.Errorf("unexpected addr message %+v", rawPeers)
+	}
+}
+
+func TestAddPeerRecordsSkewedAndForged(t *testing.T) {
+	serverObj, peerConn, cleanup := newPeerExchangeTestServer(t)
+	defer cleanup()
+
+	// relay of a record whose signer clock differs is not penalized
+	serverObj.addPeerRecords(peerConn, []wire.RawPeer{
+		newTestRecord(t, time.Now().Add(time.Hour)),
+		newTestRecord(t, time.Now().Add(-2*wire.MaxRecordAge)),
+	})
+	if len(serverObj.addrManager.KnownRecords(wire.MaxAddrPerMsg)) != 0 {
+		t.Errorf("expected skewed records to be dropped")
+	}
+	if peerConn.GetBanScore() != 0 {
+		t.Errorf("expected no penalty for skewed records, got %d", peerConn.GetBanScore())
+	}
+
+	// forged records are penalized once per message
+	forged := newTestRecord(t, time.Now())
+	forged.Role = "beacon"
+	serverObj.addPeerRecords(peerConn, []wire.RawPeer{forged, forged})
+	if len(serverObj.addrManager.KnownRecords(wire.MaxAddrPerMsg)) != 0 {
+		t.Errorf("expected forged record to be dropped")
+	}
+	if score := peerConn.GetBanScore(); score != peer.BAN_SCORE_WEIGHTS[peer.OffenseInvalidSignature] {
+		t.Errorf("expected penalty for forged record, got %d", score)
+	}
+}
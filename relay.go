@@ -0,0 +1,333 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+const (
+	// an item which is requested with getdata is requested from another peer after this timeout
+	inventoryRequestTimeout = 30 * time.Second
+	// messages which are announced with inv are served from cache for this duration
+	inventoryCacheTimeout   = 5 * time.Minute
+	inventoryTickerInterval = 5 * time.Second
+)
+
+type inventoryRequest struct {
+	peerID     string
+	deadline   time.Time
+	announcers []string // other peers which announced the item, they are asked in order after timeout
+}
+
+type relayedMessage struct {
+	message wire.Message
+	expiry  time.Time
+}
+
+// inventoryRelay keeps messages which are announced to peers
+// and items which are requested from peers
+type inventoryRelay struct {
+	cache    map[wire.InvVect]relayedMessage
+	requests map[wire.InvVect]*inventoryRequest
+	mtx      sync.Mutex
+}
+
+func newInventoryRelay() *inventoryRelay {
+	return &inventoryRelay{
+		cache:    make(map[wire.InvVect]relayedMessage),
+		requests: make(map[wire.InvVect]*inventoryRequest),
+	}
+}
+
+/*
+inventoryOfMessage - return inventory vector of a message which is relayed by inv,
+ok is false for other messages
+*/
+func inventoryOfMessage(msg wire.Message) (wire.InvVect, bool) {
+	switch msg := msg.(type) {
+	case *wire.MessageTx:
+		if msg.Transaction == nil {
+			return wire.InvVect{}, false
+		}
+		return wire.InvVect{Type: wire.InvTypeTx, Hash: *msg.Transaction.Hash()}, true
+	case *wire.MessageBlockShard:
+		return wire.InvVect{Type: wire.InvTypeBlockShard, Hash: *msg.Block.Hash()}, true
+	case *wire.MessageCompactBlockShard:
+		return wire.InvVect{Type: wire.InvTypeBlockShard, Hash: *msg.Block.Hash()}, true
+	case *wire.MessageBlockBeacon:
+		return wire.InvVect{Type: wire.InvTypeBlockBeacon, Hash: *msg.Block.Hash()}, true
+	}
+	return wire.InvVect{}, false
+}
+
+func (relay *inventoryRelay) cacheMessage(invVect wire.InvVect, msg wire.Message) {
+	relay.mtx.Lock()
+	defer relay.mtx.Unlock()
+	relay.cache[invVect] = relayedMessage{
+		message: msg,
+		expiry:  time.Now().Add(inventoryCacheTimeout),
+	}
+}
+
+func (relay *inventoryRelay) cachedMessage(invVect wire.InvVect) wire.Message {
+	relay.mtx.Lock()
+	defer relay.mtx.Unlock()
+	cached, ok := relay.cache[invVect]
+	if !ok {
+		return nil
+	}
+	return cached.message
+}
+
+/*
+requestFrom - return true when item should be requested from peer now,
+an item which is waiting for another peer is only remembered as announced by this peer
+*/
+func (relay *inventoryRelay) requestFrom(invVect wire.InvVect, peerID string) bool {
+	relay.mtx.Lock()
+	defer relay.mtx.Unlock()
+	request, ok := relay.requests[invVect]
+	if ok && time.Now().Before(request.deadline) {
+		if request.peerID == peerID {
+			return false
+		}
+		for _, announcer := range request.announcers {
+			if announcer == peerID {
+				return false
+			}
+		}
+		request.announcers = append(request.announcers, peerID)
+		return false
+	}
+	if !ok {
+		request = &inventoryRequest{}
+		relay.requests[invVect] = request
+	}
+	request.peerID = peerID
+	request.deadline = time.Now().Add(inventoryRequestTimeout)
+	return true
+}
+
+// received removes pending request of an item
+func (relay *inventoryRelay) received(invVect wire.InvVect) {
+	relay.mtx.Lock()
+	defer relay.mtx.Unlock()
+	delete(relay.requests, invVect)
+}
+
+/*
+expire - remove expired messages in cache and move timed out requests to next announcer,
+returns peer ids which timed out items should be requested from
+*/
+func (relay *inventoryRelay) expire(now time.Time) map[string][]wire.InvVect {
+	relay.mtx.Lock()
+	defer relay.mtx.Unlock()
+	for invVect, cached := range relay.cache {
+		if now.After(cached.expiry) {
+			delete(relay.cache, invVect)
+		}
+	}
+	retries := make(map[string][]wire.InvVect)
+	for invVect, request := range relay.requests {
+		if now.Before(request.deadline) {
+			continue
+		}
+		if len(request.announcers) == 0 {
+			Logger.log.Debugf("Request of inventory %s from peer %s timed out", invVect.String(), request.peerID)
+			delete(relay.requests, invVect)
+			continue
+		}
+		request.peerID = request.announcers[0]
+		request.announcers = request.announcers[1:]
+		request.deadline = now.Add(inventoryRequestTimeout)
+		retries[request.peerID] = append(retries[request.peerID], invVect)
+	}
+	return retries
+}
+
+/*
+relayInventory - announce a message with inv to peers which do not know it,
+peers which do not read inv still receive the full message
+*/
+func (serverObj *Server) relayInventory(invVect wire.InvVect, msg wire.Message) {
+	serverObj.inventoryRelay.cacheMessage(invVect, msg)
+	listener := serverObj.connManager.Config.ListenerPeer
+	msg.SetSenderID(listener.PeerID)
+	for _, peerConn := range serverObj.connManager.GetPeerConnOfAll() {
+		if peerConn.IsKnownInventory(invVect) {
+			continue
+		}
+		peerConn.AddKnownInventory(invVect)
+		if !peerConn.HasCapability(wire.CapabilityInventory) {
+			go peerConn.QueueMessageWithEncoding(msg, nil, peer.MESSAGE_TO_ALL, nil)
+			continue
+		}
+		msgInv, err := wire.MakeEmptyMessage(wire.CmdInv)
+		if err != nil {
+			Logger.log.Error(err)
+			return
+		}
+		msgInv.(*wire.MessageInv).AddInvVect(invVect)
+		go peerConn.QueueMessageWithEncoding(msgInv, nil, peer.MESSAGE_TO_PEER, nil)
+	}
+}
+
+// haveInventory returns whether node already has an item
+func (serverObj *Server) haveInventory(invVect wire.InvVect) bool {
+	if serverObj.inventoryRelay.cachedMessage(invVect) != nil {
+		return true
+	}
+	switch invVect.Type {
+	case wire.InvTypeTx:
+		return serverObj.memPool.HaveTransaction(&invVect.Hash)
+	case wire.InvTypeBlockShard:
+		_, err := serverObj.blockChain.GetShardBlockByHash(&invVect.Hash)
+		return err == nil
+	case wire.InvTypeBlockBeacon:
+		_, err := serverObj.blockChain.GetBeaconBlockByHash(&invVect.Hash)
+		return err == nil
+	}
+	// unknown type is never requested
+	return true
+}
+
+// findInventory returns the full message of an item, nil if node does not have it
+func (serverObj *Server) findInventory(invVect wire.InvVect) wire.Message {
+	if msg := serverObj.inventoryRelay.cachedMessage(invVect); msg != nil {
+		return msg
+	}
+	switch invVect.Type {
+	case wire.InvTypeTx:
+		tx, err := serverObj.memPool.GetTx(&invVect.Hash)
+		if err != nil {
+			return nil
+		}
+		msg, err := wire.MakeEmptyMessage(wire.CmdTx)
+		if err != nil {
+			return nil
+		}
+		msg.(*wire.MessageTx).Transaction = tx
+		return msg
+	case wire.InvTypeBlockShard:
+		block, err := serverObj.blockChain.GetShardBlockByHash(&invVect.Hash)
+		if err != nil {
+			return nil
+		}
+		return &wire.MessageBlockShard{Block: *block}
+	case wire.InvTypeBlockBeacon:
+		block, err := serverObj.blockChain.GetBeaconBlockByHash(&invVect.Hash)
+		if err != nil {
+			return nil
+		}
+		return &wire.MessageBlockBeacon{Block: *block}
+	}
+	return nil
+}
+
+/*
+markInventoryReceived - remember that remote peer has an item which it sent in full
+*/
+func (serverObj *Server) markInventoryReceived(peerConn *peer.PeerConn, msg wire.Message) {
+	invVect, ok := inventoryOfMessage(msg)
+	if !ok {
+		return
+	}
+	if peerConn != nil {
+		peerConn.AddKnownInventory(invVect)
+	}
+	serverObj.inventoryRelay.received(invVect)
+}
+
+/*
+OnInv is invoked when a peer announces items,
+items which node does not have and which are not requested from another peer are requested with getdata
+*/
+func (serverObj *Server) OnInv(peerConn *peer.PeerConn, msg *wire.MessageInv) {
+	Logger.log.Debug("Receive inv message START")
+	invList := []wire.InvVect{}
+	for _, invVect := range msg.InvList {
+		peerConn.AddKnownInventory(invVect)
+		if serverObj.haveInventory(invVect) {
+			continue
+		}
+		if !serverObj.inventoryRelay.requestFrom(invVect, peerConn.RemotePeerID.Pretty()) {
+			continue
+		}
+		invList = append(invList, invVect)
+	}
+	queueGetData(peerConn, invList)
+	Logger.log.Debug("Receive inv message END")
+}
+
+/*
+queueGetData - request items from a peer,
+they are split into getdata messages of at most MaxGetDataPerMsg items
+*/
+func queueGetData(peerConn *peer.PeerConn, invList []wire.InvVect) {
+	for len(invList) > 0 {
+		msgGetData := &wire.MessageGetData{}
+		for len(invList) > 0 {
+			if err := msgGetData.AddInvVect(invList[0]); err != nil {
+				break
+			}
+			invList = invList[1:]
+		}
+		peerConn.QueueMessageWithEncoding(msgGetData, nil, peer.MESSAGE_TO_PEER, nil)
+	}
+}
+
+/*
+OnGetData is invoked when a peer requests items which were announced to it
+*/
+func (serverObj *Server) OnGetData(peerConn *peer.PeerConn, msg *wire.MessageGetData) {
+	Logger.log.Debug("Receive getdata message START")
+	// every item costs a read of database, a peer which repeats an item is served once
+	served := make(map[wire.InvVect]bool)
+	for _, invVect := range msg.InvList {
+		if served[invVect] {
+			continue
+		}
+		served[invVect] = true
+		msgData := serverObj.findInventory(invVect)
+		if msgData == nil {
+			Logger.log.Debugf("Inventory %s is not found", invVect.String())
+			continue
+		}
+		peerConn.AddKnownInventory(invVect)
+		// shard block is sent as compact block to peers which rebuild it from mempool
+		if msgBlock, ok := msgData.(*wire.MessageBlockShard); ok && peerConn.HasCapability(wire.CapabilityCompactBlock) {
+			msgData = &wire.MessageCompactBlockShard{
+				Block: *serverObj.netSync.CompactShardBlock(&msgBlock.Block),
+			}
+			msgData.SetSenderID(serverObj.connManager.Config.ListenerPeer.PeerID)
+		}
+		peerConn.QueueMessageWithEncoding(msgData, nil, peer.MESSAGE_TO_PEER, nil)
+	}
+	Logger.log.Debug("Receive getdata message END")
+}
+
+/*
+inventoryHandler - request timed out items from other peers which announced them
+*/
+func (serverObj *Server) inventoryHandler() {
+	ticker := time.NewTicker(inventoryTickerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			retries := serverObj.inventoryRelay.expire(now)
+			for peerID, invList := range retries {
+				peerConn := serverObj.connManager.Config.ListenerPeer.GetPeerConnByPeerID(peerID)
+				if peerConn == nil {
+					continue
+				}
+				queueGetData(peerConn, invList)
+			}
+		case <-serverObj.cQuit:
+			return
+		}
+	}
+}
@@ -491,6 +491,21 @@ func (rpcServer RpcServer) estimateFeeWithEstimator(defaultFee int64, shardID by
 	return estimateFeeCoinPerKb
 }
 
+// estimateTokenFeeWithEstimator returns fee per kb in privacy custom token,
+// it is zero when no fee in this token has been observed
+func (rpcServer RpcServer) estimateTokenFeeWithEstimator(tokenID *common.Hash, shardID byte, numBlock uint64) uint64 {
+	feeEstimator, ok := rpcServer.config.FeeEstimator[shardID]
+	if !ok {
+		return 0
+	}
+	estimateFeeTokenPerKb, err := feeEstimator.EstimateTokenFee(tokenID, numBlock)
+	if err != nil {
+		Logger.log.Debugf("Can not estimate fee of token %+v: %+v", tokenID.String(), err)
+		return 0
+	}
+	return uint64(estimateFeeTokenPerKb)
+}
+
 func (rpcServer RpcServer) estimateFee(defaultFee int64, candidateOutputCoins []*privacy.OutputCoin,
 	paymentInfos []*privacy.PaymentInfo, shardID byte,
 	numBlock uint64, hasPrivacy bool,
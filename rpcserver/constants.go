@@ -5,12 +5,17 @@ const (
 	GetNetworkInfo           = "getnetworkinfo"
 	GetConnectionCount       = "getconnectioncount"
 	GetAllPeers              = "getallpeers"
+	ListBanned               = "listbanned"
+	SetBan                   = "setban"
+	ClearBanned              = "clearbanned"
+	GetTopologyStatus        = "gettopologystatus"
 	GetRawMempool            = "getrawmempool"
 	GetMempoolEntry          = "getmempoolentry"
 	EstimateFee              = "estimatefee"
 	EstimateFeeWithEstimator = "estimatefeewithestimator"
 	GetGenerate              = "getgenerate"
 	GetMiningInfo            = "getmininginfo"
+	GetBlockTemplate         = "getblocktemplate"
 
 	GetBestBlock        = "getbestblock"
 	GetBestBlockHash    = "getbestblockhash"
@@ -24,6 +29,8 @@ const (
 	ListOutputCoins                            = "listoutputcoins"
 	CreateRawTransaction                       = "createtransaction"
 	SendRawTransaction                         = "sendtransaction"
+	DecodeRawTransaction                       = "decoderawtransaction"
+	SimulateTransaction                        = "simulatetransaction"
 	CreateAndSendTransaction                   = "createandsendtransaction"
 	CreateAndSendCustomTokenTransaction        = "createandsendcustomtokentransaction"
 	SendRawCustomTokenTransaction              = "sendrawcustomtokentransaction"
@@ -32,6 +39,7 @@ const (
 	SendRawPrivacyCustomTokenTransaction       = "sendrawprivacycustomtokentransaction"
 	CreateAndSendPrivacyCustomTokenTransaction = "createandsendprivacycustomtokentransaction"
 	GetMempoolInfo                             = "getmempoolinfo"
+	GetMempoolPolicy                           = "getmempoolpolicy"
 	GetCandidateList                           = "getcandidatelist"
 	GetCommitteeList                           = "getcommitteelist"
 	CanPubkeyStake                             = "canpubkeystake"
@@ -181,6 +189,30 @@ const (
 	// wallet
 	GetPublicKeyFromPaymentAddress = "getpublickeyfrompaymentaddress"
 	DefragmentAccount              = "defragmentaccount"
+
+	// introspection
+	Help        = "help"
+	ListMethods = "listmethods"
+	RPCDiscover = "rpc.discover"
+)
+
+// websocket only rpc cmd method, they return id of subscription
+const (
+	SubscribeBeaconBlocks      = "subscribebeaconblocks"
+	SubscribeShardBlocks       = "subscribeshardblocks"
+	SubscribeMempool           = "subscribemempool"
+	SubscribeTransactionsByKey = "subscribetransactionsbykey"
+	SubscribeCommittees        = "subscribecommittees"
+	Unsubscribe                = "unsubscribe"
+)
+
+// websocket notification method
+const (
+	BeaconBlockNotification = "beaconblock"
+	ShardBlockNotification  = "shardblock"
+	MempoolTxNotification   = "mempooltx"
+	KeyTxNotification       = "keytransaction"
+	CommitteeNotification   = "committeechange"
 )
 
 //Fee of specific transaction
@@ -25,6 +25,8 @@ const (
 	ErrCreateTxData
 	ErrSendTxData
 	ErrTxTypeInvalid
+	ErrRPCRateLimited
+	ErrResourceNotFound
 )
 
 // Standard JSON-RPC 2.0 errors.
@@ -52,6 +54,8 @@ var ErrCodeMessage = map[int]struct {
 	ErrGetOutputCoin:                 {-1013, "Can not get output coin"},
 	ErrTxTypeInvalid:                 {-1014, "Invalid tx type"},
 	ErrInvalidSenderViewingKey:       {-1015, "Invalid viewing key"},
+	ErrRPCRateLimited:                {-1016, "Rate limit exceeded"},
+	ErrResourceNotFound:              {-1017, "Resource not found"},
 
 	// processing -2xxx
 	ErrCreateTxData: {-2001, "Can not create tx"},
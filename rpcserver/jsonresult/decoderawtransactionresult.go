@@ -0,0 +1,61 @@
+package jsonresult
+
+// DecodeRawTransactionResult is a transaction decoded from its base58 check data, it is not broadcasted
+type DecodeRawTransactionResult struct {
+	TxID         string      `json:"TxID"`
+	Version      int8        `json:"Version"`
+	Type         string      `json:"Type"`
+	LockTime     int64       `json:"LockTime"`
+	Fee          uint64      `json:"Fee"`
+	ShardID      byte        `json:"ShardID"`
+	IsPrivacy    bool        `json:"IsPrivacy"`
+	MetadataType int         `json:"MetadataType"`
+	Metadata     interface{} `json:"Metadata"`
+
+	InputSerialNumbers []string         `json:"InputSerialNumbers"`
+	OutputCommitments  []string         `json:"OutputCommitments"`
+	ProofSize          DecodedProofSize `json:"ProofSize"`
+
+	// TokenData is nil for a constant transaction
+	TokenData *DecodedTokenData `json:"TokenData,omitempty"`
+
+	Checks []TxCheckResult `json:"Checks"`
+}
+
+// DecodedProofSize is size in bytes of a payment proof and of its parts
+type DecodedProofSize struct {
+	Total            int `json:"Total"`
+	OneOfMany        int `json:"OneOfMany"`
+	SerialNumber     int `json:"SerialNumber"`
+	SNNoPrivacy      int `json:"SNNoPrivacy"`
+	AggregatedRange  int `json:"AggregatedRange"`
+	InputCoinsCount  int `json:"InputCoinsCount"`
+	OutputCoinsCount int `json:"OutputCoinsCount"`
+}
+
+// DecodedTokenData is token part of a custom token or privacy custom token transaction
+type DecodedTokenData struct {
+	PropertyID     string `json:"PropertyID"`
+	PropertyName   string `json:"PropertyName"`
+	PropertySymbol string `json:"PropertySymbol"`
+	Type           int    `json:"Type"`
+	Mintable       bool   `json:"Mintable"`
+	Amount         uint64 `json:"Amount"`
+
+	// Vins and Vouts are counts of token inputs and outputs of a custom token transaction
+	Vins  int `json:"Vins"`
+	Vouts int `json:"Vouts"`
+
+	// privacy token transaction keeps its coins in a normal transaction with its own proof
+	InputSerialNumbers []string          `json:"InputSerialNumbers,omitempty"`
+	OutputCommitments  []string          `json:"OutputCommitments,omitempty"`
+	ProofSize          *DecodedProofSize `json:"ProofSize,omitempty"`
+}
+
+// TxCheckResult is result of a validation of a transaction against current chain, it is skipped when a previous validation failed
+type TxCheckResult struct {
+	Name    string `json:"Name"`
+	Passed  bool   `json:"Passed"`
+	Skipped bool   `json:"Skipped"`
+	Error   string `json:"Error"`
+}
@@ -5,4 +5,8 @@ type EstimateFeeResult struct {
 	EstimateFeeCoinPerKb uint64
 	EstimateTxSizeInKb   uint64
 	GOVFeePerKbTx        uint64
+
+	// fee paid in privacy custom token, only for estimating with a token ID
+	TokenID               string `json:",omitempty"`
+	EstimateFeeTokenPerKb uint64 `json:",omitempty"`
 }
@@ -1,5 +1,26 @@
 package jsonresult
 
 type GetAllPeersResult struct {
-	Peers []string `json:"Peers"`
+	Peers     []string          `json:"Peers"`
+	PeerStats []PeerStatsResult `json:"PeerStats"`
+}
+
+// PeerStatsResult is traffic of a connected peer
+type PeerStatsResult struct {
+	PeerID             string `json:"PeerID"`
+	RawAddress         string `json:"RawAddress"`
+	WireVersion        int    `json:"WireVersion"`
+	Compression        bool   `json:"Compression"`
+	BytesSent          uint64 `json:"BytesSent"`
+	BytesReceived      uint64 `json:"BytesReceived"`
+	BytesSavedSent     uint64 `json:"BytesSavedSent"`
+	BytesSavedReceived uint64 `json:"BytesSavedReceived"`
+	BanScore           int32  `json:"BanScore"`
+	// Messages are counters of received messages by message type, dropped messages exceeded rate limit
+	Messages map[string]MessageRateResult `json:"Messages"`
+}
+
+type MessageRateResult struct {
+	Received uint64 `json:"Received"`
+	Dropped  uint64 `json:"Dropped"`
 }
@@ -0,0 +1,50 @@
+package jsonresult
+
+import "github.com/ninjadotorg/constant/blockchain"
+
+type GetBlockTemplateResult struct {
+	MaxBlockSize    uint64                     `json:"MaxBlockSize"`
+	MaxVerifyCost   uint64                     `json:"MaxVerifyCost"`
+	MaxTxs          int                        `json:"MaxTxs"`
+	TotalFee        uint64                     `json:"TotalFee"`
+	TotalSize       uint64                     `json:"TotalSize"`
+	TotalVerifyCost uint64                     `json:"TotalVerifyCost"`
+	NumSkipped      int                        `json:"NumSkipped"`
+	Txs             []GetBlockTemplateTxResult `json:"Txs"`
+}
+
+type GetBlockTemplateTxResult struct {
+	Hash         string `json:"Hash"`
+	Type         string `json:"Type"`
+	MetadataType int    `json:"MetadataType"`
+	Fee          uint64 `json:"Fee"`
+	FeePerKb     uint64 `json:"FeePerKb"`
+	Size         uint64 `json:"Size"`
+	VerifyCost   uint64 `json:"VerifyCost"`
+	Group        int    `json:"Group"`
+	Added        int64  `json:"Added"`
+}
+
+func (getBlockTemplateResult *GetBlockTemplateResult) Init(template *blockchain.BlockTemplate) {
+	getBlockTemplateResult.MaxBlockSize = template.Policy.MaxBlockSize
+	getBlockTemplateResult.MaxVerifyCost = template.Policy.MaxVerifyCost
+	getBlockTemplateResult.MaxTxs = template.Policy.MaxTxs
+	getBlockTemplateResult.TotalFee = template.TotalFee
+	getBlockTemplateResult.TotalSize = template.TotalSize
+	getBlockTemplateResult.TotalVerifyCost = template.TotalVerifyCost
+	getBlockTemplateResult.NumSkipped = template.NumSkipped
+	getBlockTemplateResult.Txs = make([]GetBlockTemplateTxResult, 0, len(template.Txs))
+	for _, tx := range template.Txs {
+		getBlockTemplateResult.Txs = append(getBlockTemplateResult.Txs, GetBlockTemplateTxResult{
+			Hash:         tx.Desc.Tx.Hash().String(),
+			Type:         tx.Desc.Tx.GetType(),
+			MetadataType: tx.Desc.Tx.GetMetadataType(),
+			Fee:          tx.Desc.Fee,
+			FeePerKb:     tx.FeePerKb,
+			Size:         tx.Size,
+			VerifyCost:   tx.VerifyCost,
+			Group:        tx.Group,
+			Added:        tx.Desc.Added.Unix(),
+		})
+	}
+}
@@ -8,4 +8,6 @@ type GetMempoolInfo struct {
 	MempoolMinFee uint64   `json:"MempoolMinFee"`
 	MempoolMaxFee uint64   `json:"MempoolMaxFee"`
 	ListTxs       []string `json:"ListTxs"`
+	OrphanSize    int      `json:"OrphanSize"`
+	ListOrphanTxs []string `json:"ListOrphanTxs"`
 }
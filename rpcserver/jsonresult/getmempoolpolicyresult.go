@@ -0,0 +1,45 @@
+package jsonresult
+
+import (
+	"sort"
+
+	"github.com/ninjadotorg/constant/mempool"
+)
+
+type MetadataPolicyRuleResult struct {
+	MetadataType  int      `json:"MetadataType"`
+	MinFeePerKbTx uint64   `json:"MinFeePerKbTx"`
+	MaxPerBlock   int      `json:"MaxPerBlock"`
+	MaxPerSender  int      `json:"MaxPerSender"`
+	AllowList     []string `json:"AllowList"`
+	DenyList      []string `json:"DenyList"`
+}
+
+type GetMempoolPolicyResult struct {
+	MinFeePerKbTx uint64                     `json:"MinFeePerKbTx"`
+	MetadataRules []MetadataPolicyRuleResult `json:"MetadataRules"`
+}
+
+func (self *GetMempoolPolicyResult) Init(minFeePerKbTx uint64, rules []*mempool.MetadataPolicyRule) {
+	self.MinFeePerKbTx = minFeePerKbTx
+	self.MetadataRules = make([]MetadataPolicyRuleResult, 0, len(rules))
+	for _, rule := range rules {
+		self.MetadataRules = append(self.MetadataRules, MetadataPolicyRuleResult{
+			MetadataType:  rule.MetadataType,
+			MinFeePerKbTx: rule.MinFeePerKbTx,
+			MaxPerBlock:   rule.MaxPerBlock,
+			MaxPerSender:  rule.MaxPerSender,
+			AllowList:     sortedKeys(rule.AllowList),
+			DenyList:      sortedKeys(rule.DenyList),
+		})
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
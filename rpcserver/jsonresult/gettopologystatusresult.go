@@ -0,0 +1,26 @@
+package jsonresult
+
+// GetTopologyStatusResult is connectivity of node against targets of its topology policy
+type GetTopologyStatusResult struct {
+	Role         string                        `json:"Role"`
+	ShardID      *byte                         `json:"ShardID"`
+	Beacon       TopologyTargetResult          `json:"Beacon"`
+	SameShard    TopologyTargetResult          `json:"SameShard"`
+	OtherShards  map[byte]TopologyTargetResult `json:"OtherShards"`
+	NoShard      TopologyTargetResult          `json:"NoShard"`
+	PinnedPeers  []PinnedPeerResult            `json:"PinnedPeers"`
+	LastRotation int64                         `json:"LastRotation"`
+	MeetsTargets bool                          `json:"MeetsTargets"`
+}
+
+// TopologyTargetResult is the number of connections of a group of peers against its target
+type TopologyTargetResult struct {
+	Target    int `json:"Target"`
+	Connected int `json:"Connected"`
+}
+
+type PinnedPeerResult struct {
+	ShardID    byte   `json:"ShardID"`
+	RawAddress string `json:"RawAddress"`
+	Connected  bool   `json:"Connected"`
+}
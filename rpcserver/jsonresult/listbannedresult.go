@@ -0,0 +1,15 @@
+package jsonresult
+
+type ListBannedResult struct {
+	BannedPeers []BannedPeerResult `json:"BannedPeers"`
+}
+
+// BannedPeerResult is a banned peer, times are unix seconds
+type BannedPeerResult struct {
+	PeerID      string `json:"PeerID"`
+	PublicKey   string `json:"PublicKey"`
+	RawAddress  string `json:"RawAddress"`
+	Reason      string `json:"Reason"`
+	BannedSince int64  `json:"BannedSince"`
+	BannedUntil int64  `json:"BannedUntil"`
+}
@@ -0,0 +1,13 @@
+package jsonresult
+
+type ListMethodsResult struct {
+	Methods []MethodResult `json:"Methods"`
+}
+
+// MethodResult is a command of RPC server, Websocket is true when command is only served to websocket clients
+type MethodResult struct {
+	Name        string `json:"Name"`
+	Usage       string `json:"Usage"`
+	Description string `json:"Description"`
+	Websocket   bool   `json:"Websocket"`
+}
@@ -0,0 +1,33 @@
+package jsonresult
+
+// OpenRPCResult is an OpenRPC document which describes commands of RPC server
+type OpenRPCResult struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenRPCMethod struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Params      []OpenRPCContentDescriptor `json:"params"`
+	Result      OpenRPCContentDescriptor   `json:"result"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      OpenRPCSchema `json:"schema"`
+}
+
+// OpenRPCSchema is a JSON schema, Type is a type name or a list of type names when null is valid
+type OpenRPCSchema struct {
+	Type interface{} `json:"type,omitempty"`
+	Enum []string    `json:"enum,omitempty"`
+}
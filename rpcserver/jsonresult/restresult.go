@@ -0,0 +1,16 @@
+package jsonresult
+
+// RestPageResult is a page of a list of REST gateway, Total is number of items of the whole list
+type RestPageResult struct {
+	Total  int         `json:"Total"`
+	Offset int         `json:"Offset"`
+	Limit  int         `json:"Limit"`
+	Items  interface{} `json:"Items"`
+}
+
+// RestErrorResult is body of a REST gateway response which is not successful
+type RestErrorResult struct {
+	Code    int    `json:"Code"`
+	Message string `json:"Message"`
+	Detail  string `json:"Detail"`
+}
@@ -0,0 +1,33 @@
+package jsonresult
+
+import "github.com/ninjadotorg/constant/blockchain"
+
+// SimulateTransactionResult is report of validation pipeline of a transaction which is neither added to mempool nor broadcasted
+type SimulateTransactionResult struct {
+	TxID         string `json:"TxID"`
+	Type         string `json:"Type"`
+	ShardID      byte   `json:"ShardID"`
+	MetadataType int    `json:"MetadataType"`
+
+	// Accepted is true when transaction passes all stages
+	Accepted bool            `json:"Accepted"`
+	Stages   []TxCheckResult `json:"Stages"`
+
+	// Actions are built by shard from metadata, Instructions are built by beacon from Actions
+	Actions      [][]string `json:"Actions"`
+	Instructions [][]string `json:"Instructions"`
+
+	StateChanges SimulatedStateChanges `json:"StateChanges"`
+}
+
+// SimulatedStateChanges are changes of chain which a transaction would make when it is accepted
+type SimulatedStateChanges struct {
+	Fee                uint64   `json:"Fee"`
+	SpentSerialNumbers []string `json:"SpentSerialNumbers"`
+	NewCommitments     []string `json:"NewCommitments"`
+
+	// TokenData is nil for a constant transaction
+	TokenData *DecodedTokenData `json:"TokenData,omitempty"`
+	// Stability is nil when transaction has no metadata or beacon instructions are not built
+	Stability *blockchain.StabilityChanges `json:"Stability,omitempty"`
+}
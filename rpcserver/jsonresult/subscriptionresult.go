@@ -0,0 +1,59 @@
+package jsonresult
+
+// SubscriptionNotification is params of a notification which is pushed to a websocket client
+type SubscriptionNotification struct {
+	Subscription uint64      `json:"Subscription"`
+	Result       interface{} `json:"Result"`
+}
+
+type BeaconBlockNotification struct {
+	Hash          string `json:"Hash"`
+	Height        uint64 `json:"Height"`
+	Epoch         uint64 `json:"Epoch"`
+	Round         int    `json:"Round"`
+	Producer      string `json:"Producer"`
+	Time          int64  `json:"Time"`
+	PrevBlockHash string `json:"PrevBlockHash"`
+}
+
+type ShardBlockNotification struct {
+	Hash          string   `json:"Hash"`
+	ShardID       byte     `json:"ShardID"`
+	Height        uint64   `json:"Height"`
+	BeaconHeight  uint64   `json:"BeaconHeight"`
+	Epoch         uint64   `json:"Epoch"`
+	Round         int      `json:"Round"`
+	Producer      string   `json:"Producer"`
+	Time          int64    `json:"Time"`
+	PrevBlockHash string   `json:"PrevBlockHash"`
+	TxHashes      []string `json:"TxHashes"`
+}
+
+// MempoolTxNotification is sent when a tx enters (Added) or leaves (Removed) mempool
+type MempoolTxNotification struct {
+	TxID    string `json:"TxID"`
+	ShardID byte   `json:"ShardID"`
+	Type    string `json:"Type"`
+	Fee     uint64 `json:"Fee"`
+	Event   string `json:"Event"`
+}
+
+// KeyTxNotification is sent when a tx which sends from or to a subscribed key enters mempool (Status mempool)
+// or is included in a shard block (Status block), Received is only known for readonly keys
+type KeyTxNotification struct {
+	Key         string `json:"Key"`
+	TxID        string `json:"TxID"`
+	ShardID     byte   `json:"ShardID"`
+	Status      string `json:"Status"`
+	BlockHash   string `json:"BlockHash,omitempty"`
+	BlockHeight uint64 `json:"BlockHeight,omitempty"`
+	IsSender    bool   `json:"IsSender"`
+	IsReceiver  bool   `json:"IsReceiver"`
+	Received    uint64 `json:"Received"`
+}
+
+type CommitteeNotification struct {
+	Epoch           uint64            `json:"Epoch"`
+	BeaconCommittee []string          `json:"BeaconCommittee"`
+	ShardCommittee  map[byte][]string `json:"ShardCommittee"`
+}
@@ -0,0 +1,175 @@
+package rpcserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	jsonrpcVersion2 = "2.0"
+	// maxBatchRequests caps requests of a batch
+	maxBatchRequests = 100
+)
+
+// Standard error codes of JSON-RPC 2.0
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternalError  = -32603
+)
+
+// jsonrpc2ErrorCodes maps codes of RPCError to standard codes of JSON-RPC 2.0,
+// other codes are application errors and they are kept
+var jsonrpc2ErrorCodes = map[int]int{
+	ErrCodeMessage[ErrRPCParse].code:          jsonrpcParseError,
+	ErrCodeMessage[ErrRPCInvalidRequest].code: jsonrpcInvalidRequest,
+	ErrCodeMessage[ErrRPCMethodNotFound].code: jsonrpcMethodNotFound,
+	ErrCodeMessage[ErrRPCInvalidParams].code:  jsonrpcInvalidParams,
+	ErrCodeMessage[ErrRPCInternal].code:       jsonrpcInternalError,
+}
+
+// rpcRequestExecutor executes a parsed request, http and websocket clients have their own executors
+type rpcRequestExecutor func(request RpcRequest) (interface{}, *RPCError)
+
+/*
+processRequestBody - parse body which is a request or a batch of requests, execute them and return the marshalled reply.
+Requests are JSON-RPC 2.0 when their "jsonrpc" member is "2.0", otherwise they are served as before in the 1.0 format
+of RpcRequest and Response. Names of members are case insensitive so lowercase and capitalized envelopes are both accepted.
+Reply is nil when there is nothing to reply, that is when body only has notifications.
+*/
+func (rpcServer RpcServer) processRequestBody(body []byte, execute rpcRequestExecutor) []byte {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return rpcServer.processBatch(trimmed, execute)
+	}
+	return rpcServer.processRequest(body, false, execute)
+}
+
+// processBatch executes requests of a batch one by one, replies of requests which are not notifications are returned in an array
+func (rpcServer RpcServer) processBatch(body []byte, execute rpcRequestExecutor) []byte {
+	var rawRequests []json.RawMessage
+	if err := json.Unmarshal(body, &rawRequests); err != nil {
+		return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCParse, err))
+	}
+	if len(rawRequests) == 0 {
+		return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, errors.New("batch is empty")))
+	}
+	if len(rawRequests) > maxBatchRequests {
+		return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, fmt.Errorf("batch has more than %d requests", maxBatchRequests)))
+	}
+	replies := []json.RawMessage{}
+	for _, rawRequest := range rawRequests {
+		reply := rpcServer.processRequest(rawRequest, true, execute)
+		if reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	msg, err := json.Marshal(replies)
+	if err != nil {
+		Logger.log.Errorf("Failed to marshal batch reply: %s", err.Error())
+		return nil
+	}
+	return msg
+}
+
+// processRequest executes a request and returns its marshalled reply, a request which is not valid JSON
+// is replied in the 1.0 format unless it is a part of a batch
+func (rpcServer RpcServer) processRequest(rawRequest []byte, inBatch bool, execute rpcRequestExecutor) []byte {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(rawRequest, &members); err != nil {
+		if inBatch {
+			return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, err))
+		}
+		return rpcServer.createMarshalledReplyV1(nil, nil, NewRPCError(ErrRPCParse, err))
+	}
+	var request RpcRequest
+	if err := json.Unmarshal(rawRequest, &request); err != nil {
+		if inBatch || requestVersion(members) == jsonrpcVersion2 {
+			return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, err))
+		}
+		return rpcServer.createMarshalledReplyV1(nil, nil, NewRPCError(ErrRPCParse, err))
+	}
+
+	if request.Jsonrpc != jsonrpcVersion2 {
+		// The JSON-RPC 1.0 spec defines that notifications must have their "id"
+		// set to null and states that notifications do not have a response.
+		//
+		// Rpc does not respond to any 1.0 request without and "id" or "id":null
+		// unless RPC quirks are enabled. With RPC quirks enabled, such requests
+		// will be responded to if the reqeust does not indicate JSON-RPC version.
+		if request.Id == nil && !(rpcServer.config.RPCQuirks && request.Jsonrpc == "") {
+			return nil
+		}
+		result, rpcErr := execute(request)
+		return rpcServer.createMarshalledReplyV1(request.Id, result, rpcErr)
+	}
+
+	// A JSON-RPC 2.0 notification is a request without an "id" member, it is
+	// executed but not responded to. The null value is a valid request id so
+	// requests with "id":null are responded to.
+	_, hasID := requestMember(members, "id")
+	if request.Method == "" {
+		return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, errors.New("method is required")))
+	}
+	if !IsValidIDType(request.Id) {
+		return rpcServer.createMarshalledReplyV2(nil, nil, NewRPCError(ErrRPCInvalidRequest, errors.New("id must be a string, a number or null")))
+	}
+	result, rpcErr := execute(request)
+	if !hasID {
+		return nil
+	}
+	return rpcServer.createMarshalledReplyV2(request.Id, result, rpcErr)
+}
+
+// requestMember returns a member of a request, names are matched case insensitively like encoding/json does
+func requestMember(members map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	for key, value := range members {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// requestVersion returns "jsonrpc" member of a request, it is empty when member is missing or is not a string
+func requestVersion(members map[string]json.RawMessage) string {
+	value, ok := requestMember(members, "jsonrpc")
+	if !ok {
+		return ""
+	}
+	version := ""
+	if err := json.Unmarshal(value, &version); err != nil {
+		return ""
+	}
+	return version
+}
+
+func (rpcServer RpcServer) createMarshalledReplyV1(id, result interface{}, rpcErr *RPCError) []byte {
+	var replyErr error
+	if rpcErr != nil {
+		replyErr = rpcErr
+	}
+	msg, err := rpcServer.createMarshalledReply(id, result, replyErr)
+	if err != nil {
+		Logger.log.Errorf("Failed to marshal reply: %s", err.Error())
+		return nil
+	}
+	return msg
+}
+
+func (rpcServer RpcServer) createMarshalledReplyV2(id, result interface{}, rpcErr *RPCError) []byte {
+	msg, err := MarshalResponseV2(id, result, rpcErr)
+	if err != nil {
+		Logger.log.Errorf("Failed to marshal reply: %s", err.Error())
+		return nil
+	}
+	return msg
+}
@@ -0,0 +1,297 @@
+package rpcserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testExecutor echoes params of method echo, fails method badparams with invalid params
+// and records every executed method
+func testExecutor(executed *[]string) rpcRequestExecutor {
+	return func(request RpcRequest) (interface{}, *RPCError) {
+		*executed = append(*executed, request.Method)
+		switch request.Method {
+		case "echo":
+			return request.Params, nil
+		case "badparams":
+			return nil, NewRPCError(ErrRPCInvalidParams, errors.New("wrong params"))
+		}
+		return nil, NewRPCError(ErrRPCMethodNotFound, nil)
+	}
+}
+
+// compactJSON removes indentation of 1.0 replies so that results can be compared
+func compactJSON(raw json.RawMessage) string {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+func processTestBody(t *testing.T, body string) ([]byte, []string) {
+	initTestLogger()
+	executed := []string{}
+	reply := (RpcServer{}).processRequestBody([]byte(body), testExecutor(&executed))
+	return reply, executed
+}
+
+func unmarshalResponseV2(t *testing.T, data []byte) ResponseV2 {
+	var response ResponseV2
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if response.Jsonrpc != jsonrpcVersion2 {
+		t.Errorf("expected 2.0 response, got %s", data)
+	}
+	return response
+}
+
+func unmarshalBatchReply(t *testing.T, data []byte) []map[string]json.RawMessage {
+	var replies []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &replies); err != nil {
+		t.Fatalf("expected batch reply, got %s: %+v", data, err)
+	}
+	return replies
+}
+
+func TestProcessRequestV2(t *testing.T) {
+	reply, _ := processTestBody(t, `{"jsonrpc":"2.0","method":"echo","params":[1,"a"],"id":7}`)
+	response := unmarshalResponseV2(t, reply)
+	if response.Id != float64(7) || string(response.Result) != `[1,"a"]` || response.Error != nil {
+		t.Errorf("unexpected response %s", reply)
+	}
+
+	// result is omitted when there is an error, codes of RPCError are mapped to standard codes
+	reply, _ = processTestBody(t, `{"jsonrpc":"2.0","method":"badparams","id":"x"}`)
+	response = unmarshalResponseV2(t, reply)
+	if response.Id != "x" || response.Result != nil || response.Error == nil || response.Error.Code != jsonrpcInvalidParams {
+		t.Fatalf("expected invalid params error, got %s", reply)
+	}
+	if response.Error.Data == nil || response.Error.Data.Code != ErrCodeMessage[ErrRPCInvalidParams].code {
+		t.Errorf("expected RPCError in data of error, got %s", reply)
+	}
+}
+
+func TestProcessRequestV1(t *testing.T) {
+	reply, _ := processTestBody(t, `{"Method":"echo","Params":[1],"Id":1}`)
+	var response Response
+	if err := json.Unmarshal(reply, &response); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if response.Id == nil || *response.Id != float64(1) || compactJSON(response.Result) != "[1]" || response.Error != nil {
+		t.Errorf("unexpected 1.0 response %s", reply)
+	}
+	if strings.Contains(string(reply), "jsonrpc") {
+		t.Errorf("expected 1.0 response without jsonrpc member, got %s", reply)
+	}
+
+	// 1.0 requests without id are notifications
+	reply, executed := processTestBody(t, `{"Method":"echo","Params":[1]}`)
+	if reply != nil || len(executed) != 0 {
+		t.Errorf("expected 1.0 notification not to be executed nor replied, got %s %v", reply, executed)
+	}
+
+	// body which is not JSON is replied in 1.0 format
+	reply, _ = processTestBody(t, `{"Method":`)
+	if err := json.Unmarshal(reply, &response); err != nil || response.Error == nil || response.Error.Code != ErrCodeMessage[ErrRPCParse].code {
+		t.Errorf("expected 1.0 parse error, got %s", reply)
+	}
+}
+
+func TestProcessRequestLowercaseEnvelope(t *testing.T) {
+	reply, executed := processTestBody(t, `{"jsonrpc":"2.0","method":"echo","params":["a"],"id":1}`)
+	if response := unmarshalResponseV2(t, reply); string(response.Result) != `["a"]` || len(executed) != 1 {
+		t.Errorf("unexpected response of lowercase 2.0 request %s", reply)
+	}
+	reply, _ = processTestBody(t, `{"JSONRPC":"2.0","METHOD":"echo","PARAMS":["a"],"ID":1}`)
+	if response := unmarshalResponseV2(t, reply); string(response.Result) != `["a"]` {
+		t.Errorf("unexpected response of uppercase 2.0 request %s", reply)
+	}
+	reply, _ = processTestBody(t, `{"method":"echo","params":["a"],"id":1}`)
+	var response Response
+	if err := json.Unmarshal(reply, &response); err != nil || compactJSON(response.Result) != `["a"]` {
+		t.Errorf("unexpected response of lowercase 1.0 request %s", reply)
+	}
+}
+
+func TestProcessRequestNotificationV2(t *testing.T) {
+	reply, executed := processTestBody(t, `{"jsonrpc":"2.0","method":"echo","params":[1]}`)
+	if reply != nil {
+		t.Errorf("expected no reply of notification, got %s", reply)
+	}
+	if len(executed) != 1 {
+		t.Errorf("expected notification to be executed, got %v", executed)
+	}
+
+	// null is a valid id, request is not a notification
+	reply, _ = processTestBody(t, `{"jsonrpc":"2.0","method":"echo","params":[1],"id":null}`)
+	if reply == nil {
+		t.Fatalf("expected reply of request with null id")
+	}
+	if response := unmarshalResponseV2(t, reply); response.Id != nil || string(response.Result) != "[1]" {
+		t.Errorf("unexpected response %s", reply)
+	}
+}
+
+func TestProcessRequestInvalidV2(t *testing.T) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","params":[1],"id":1}`, jsonrpcInvalidRequest},
+		{`{"jsonrpc":"2.0","method":"echo","id":{"a":1}}`, jsonrpcInvalidRequest},
+		{`{"jsonrpc":"2.0","method":"echo","id":[1]}`, jsonrpcInvalidRequest},
+		{`{"jsonrpc":"2.0","method":1,"id":1}`, jsonrpcInvalidRequest},
+		{`{"jsonrpc":"2.0","method":"unknown","id":1}`, jsonrpcMethodNotFound},
+	}
+	for _, test := range tests {
+		reply, executed := processTestBody(t, test.body)
+		response := unmarshalResponseV2(t, reply)
+		if response.Error == nil || response.Error.Code != test.code {
+			t.Errorf("body %s: expected error %d, got %s", test.body, test.code, reply)
+		}
+		if test.code == jsonrpcInvalidRequest && (response.Id != nil || len(executed) != 0) {
+			t.Errorf("body %s: expected invalid request not to be executed and to have null id, got %s", test.body, reply)
+		}
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	// 1.0 and 2.0 requests are mixed, notifications are left out of reply and invalid requests are replied in order
+	reply, executed := processTestBody(t, ` [
+		{"jsonrpc":"2.0","method":"echo","params":[1],"id":1},
+		{"Method":"echo","Params":[2],"Id":2},
+		{"jsonrpc":"2.0","method":"echo","params":[3]},
+		1,
+		{"jsonrpc":"2.0","method":"badparams","id":5}
+	]`)
+	replies := unmarshalBatchReply(t, reply)
+	if len(replies) != 4 {
+		t.Fatalf("expected 4 replies, got %s", reply)
+	}
+	if string(replies[0]["jsonrpc"]) != `"2.0"` || string(replies[0]["result"]) != "[1]" || string(replies[0]["id"]) != "1" {
+		t.Errorf("unexpected reply of 2.0 request %+v", replies[0])
+	}
+	if _, ok := replies[1]["jsonrpc"]; ok || compactJSON(replies[1]["Result"]) != "[2]" || string(replies[1]["Id"]) != "2" {
+		t.Errorf("expected 1.0 reply of 1.0 request in batch, got %s", reply)
+	}
+	var invalid ErrorV2
+	if err := json.Unmarshal(replies[2]["error"], &invalid); err != nil || invalid.Code != jsonrpcInvalidRequest {
+		t.Errorf("expected invalid request error, got %s", replies[2]["error"])
+	}
+	var badParams ErrorV2
+	if err := json.Unmarshal(replies[3]["error"], &badParams); err != nil || badParams.Code != jsonrpcInvalidParams || string(replies[3]["id"]) != "5" {
+		t.Errorf("expected invalid params error, got %+v", replies[3])
+	}
+	if len(executed) != 4 {
+		t.Errorf("expected 4 executed requests, got %v", executed)
+	}
+
+	// batch of only notifications has no reply
+	reply, executed = processTestBody(t, `[{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","method":"echo"}]`)
+	if reply != nil || len(executed) != 2 {
+		t.Errorf("expected notifications to be executed without reply, got %s %v", reply, executed)
+	}
+}
+
+func TestProcessBatchInvalid(t *testing.T) {
+	reply, _ := processTestBody(t, `[]`)
+	if response := unmarshalResponseV2(t, reply); response.Error == nil || response.Error.Code != jsonrpcInvalidRequest || response.Id != nil {
+		t.Errorf("expected invalid request of empty batch, got %s", reply)
+	}
+	reply, _ = processTestBody(t, `[{"jsonrpc":"2.0"`)
+	if response := unmarshalResponseV2(t, reply); response.Error == nil || response.Error.Code != jsonrpcParseError {
+		t.Errorf("expected parse error of batch, got %s", reply)
+	}
+
+	batch := func(size int) string {
+		requests := make([]string, size)
+		for i := range requests {
+			requests[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","id":%d}`, i)
+		}
+		return "[" + strings.Join(requests, ",") + "]"
+	}
+	reply, executed := processTestBody(t, batch(maxBatchRequests))
+	if replies := unmarshalBatchReply(t, reply); len(replies) != maxBatchRequests || len(executed) != maxBatchRequests {
+		t.Errorf("expected %d replies, got %d", maxBatchRequests, len(replies))
+	}
+	reply, executed = processTestBody(t, batch(maxBatchRequests+1))
+	if response := unmarshalResponseV2(t, reply); response.Error == nil || response.Error.Code != jsonrpcInvalidRequest || len(executed) != 0 {
+		t.Errorf("expected batch over cap to be rejected, got %s", reply)
+	}
+}
+
+func TestMarshalResponseV2(t *testing.T) {
+	if _, err := MarshalResponseV2(map[string]int{}, nil, nil); err == nil {
+		t.Errorf("expected invalid id type to fail")
+	}
+	tests := []struct {
+		key  int
+		code int
+	}{
+		{ErrRPCParse, jsonrpcParseError},
+		{ErrRPCInvalidRequest, jsonrpcInvalidRequest},
+		{ErrRPCMethodNotFound, jsonrpcMethodNotFound},
+		{ErrRPCInvalidParams, jsonrpcInvalidParams},
+		{ErrRPCInternal, jsonrpcInternalError},
+		{ErrAuthFail, ErrCodeMessage[ErrAuthFail].code},
+	}
+	for _, test := range tests {
+		data, err := MarshalResponseV2(1, "ignored", NewRPCError(test.key, errors.New("test")))
+		if err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+		response := unmarshalResponseV2(t, data)
+		if response.Error == nil || response.Error.Code != test.code || response.Result != nil {
+			t.Errorf("error %d: expected code %d, got %s", ErrCodeMessage[test.key].code, test.code, data)
+		}
+	}
+}
+
+func TestProcessRpcRequestNoContent(t *testing.T) {
+	initTestLogger()
+	rpcServer := RpcServer{}
+	if err := rpcServer.initAccess(&RpcServerConfig{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rpcServer.ProcessRpcRequest(w, r, &rpcClient{role: rpcServer.roles[RoleAdmin], credential: credentialNoAuth, remoteAddr: r.RemoteAddr})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"jsonrpc":"2.0","method":"unknowncommand"}`, http.StatusNoContent},
+		{`[{"jsonrpc":"2.0","method":"unknowncommand"},{"jsonrpc":"2.0","method":"unknowncommand"}]`, http.StatusNoContent},
+		{`{"jsonrpc":"2.0","method":"unknowncommand","id":1}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("body %s: expected status %d, got %d", test.body, test.status, resp.StatusCode)
+		}
+		if test.status == http.StatusNoContent && len(body) != 0 {
+			t.Errorf("body %s: expected no content, got %s", test.body, body)
+		}
+		if test.status == http.StatusOK {
+			if response := unmarshalResponseV2(t, body); response.Error == nil || response.Error.Code != jsonrpcMethodNotFound {
+				t.Errorf("expected method not found, got %s", body)
+			}
+		}
+	}
+}
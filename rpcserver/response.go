@@ -77,3 +77,56 @@ func MarshalResponse(id interface{}, result interface{}, rpcErr *RPCError) ([]by
 	}
 	return resultResp, nil
 }
+
+// ResponseV2 is the form of a JSON-RPC 2.0 response, it has either a result or an error
+type ResponseV2 struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ErrorV2        `json:"error,omitempty"`
+	Id      interface{}     `json:"id"`
+}
+
+// ErrorV2 is the error object of a JSON-RPC 2.0 response, code is a standard code when RPCError has one
+// and data is the RPCError which 1.0 clients receive
+type ErrorV2 struct {
+	Code    int       `json:"code"`
+	Message string    `json:"message"`
+	Data    *RPCError `json:"data,omitempty"`
+}
+
+// NewErrorV2 converts a RPCError to the error object of JSON-RPC 2.0
+func NewErrorV2(rpcErr *RPCError) *ErrorV2 {
+	code, ok := jsonrpc2ErrorCodes[rpcErr.Code]
+	if !ok {
+		code = rpcErr.Code
+	}
+	rpcErr.StackTrace = rpcErr.Error()
+	return &ErrorV2{
+		Code:    code,
+		Message: rpcErr.Message,
+		Data:    rpcErr,
+	}
+}
+
+// MarshalResponseV2 marshals the passed id, result, and RPCError to a JSON-RPC 2.0
+// response byte slice, result is omitted when there is an error.
+func MarshalResponseV2(id interface{}, result interface{}, rpcErr *RPCError) ([]byte, error) {
+	if !IsValidIDType(id) {
+		str := fmt.Sprintf("The id of type '%T' is invalid", id)
+		return nil, NewRPCError(ErrInvalidType, errors.New(str))
+	}
+	response := &ResponseV2{
+		Jsonrpc: jsonrpcVersion2,
+		Id:      id,
+	}
+	if rpcErr != nil {
+		response.Error = NewErrorV2(rpcErr)
+	} else {
+		marshalledResult, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		response.Result = marshalledResult
+	}
+	return json.Marshal(response)
+}
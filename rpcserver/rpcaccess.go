@@ -0,0 +1,574 @@
+package rpcserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles of RPC clients
+const (
+	// RoleReadOnly can call commands of readOnlyCommands, which read public data of chain and node
+	RoleReadOnly = "readonly"
+	// RoleWallet can also call commands of wallet of node and send transactions and tokens
+	RoleWallet = "wallet"
+	// RoleStability can also send transactions of stability operations: loans, crowdsales, reserve, boards, votes and proposals
+	RoleStability = "stability"
+	// RoleAdmin can call all commands
+	RoleAdmin = "admin"
+	// RoleUser is role of --rpcuser, it can call all commands except commands of RpcLimited like before roles were added
+	RoleUser = "user"
+)
+
+// Credentials which are not API keys, they are names of clients in audit log and keys of rate limiters
+const (
+	credentialRPCUser      = "rpcuser"
+	credentialRPCLimitUser = "rpclimituser"
+	credentialNoAuth       = "noauth"
+)
+
+// Commands which only read public data of chain and node and take no private keys, they are allowed for all roles
+var readOnlyCommands = map[string]bool{
+	// node
+	GetNetworkInfo:           true,
+	GetConnectionCount:       true,
+	GetAllPeers:              true,
+	ListBanned:               true,
+	GetTopologyStatus:        true,
+	GetRawMempool:            true,
+	GetMempoolEntry:          true,
+	EstimateFee:              true,
+	EstimateFeeWithEstimator: true,
+	GetGenerate:              true,
+	GetMiningInfo:            true,
+	GetBlockTemplate:         true,
+
+	// block
+	GetBestBlock:        true,
+	GetBestBlockHash:    true,
+	RetrieveBlock:       true,
+	RetrieveBeaconBlock: true,
+	GetBlocks:           true,
+	GetBlockChainInfo:   true,
+	GetBlockCount:       true,
+	GetBlockHash:        true,
+	CheckHashValue:      true,
+	GetBlockHeader:      true,
+
+	// transaction
+	DecodeRawTransaction: true,
+	SimulateTransaction:  true,
+	GetMempoolInfo:       true,
+	GetMempoolPolicy:     true,
+	GetTransactionByHash: true,
+	RandomCommitments:    true,
+	HasSerialNumbers:     true,
+	HasSnDerivators:      true,
+
+	// best state
+	GetCandidateList:              true,
+	GetCommitteeList:              true,
+	GetBlockProducerList:          true,
+	GetShardBestState:             true,
+	GetBeaconBestState:            true,
+	GetBeaconPoolState:            true,
+	GetShardPoolState:             true,
+	GetShardPoolLatestValidHeight: true,
+	GetShardToBeaconPoolState:     true,
+	GetCrossShardPoolState:        true,
+	CanPubkeyStake:                true,
+
+	// custom token
+	ListUnspentCustomToken:    true,
+	ListCustomToken:           true,
+	CustomToken:               true,
+	GetListCustomTokenBalance: true,
+	ListPrivacyCustomToken:    true,
+	PrivacyCustomToken:        true,
+
+	// loan, crowdsale and reserve
+	GetLoanParams:                   true,
+	GetLoanResponseApproved:         true,
+	GetLoanResponseRejected:         true,
+	GetLoanPaymentInfo:              true,
+	GetBankFund:                     true,
+	GetLoanRequestTxStatus:          true,
+	GetListOngoingCrowdsale:         true,
+	GetListDCBProposalBuyingAssets:  true,
+	GetListDCBProposalSellingAssets: true,
+	GetIssuingStatus:                true,
+	GetContractingStatus:            true,
+	ConvertETHToDCBTokenAmount:      true,
+	ConvertCSTToETHAmount:           true,
+
+	// board, vote, dcb and gov
+	GetListDCBBoard:                  true,
+	GetListGOVBoard:                  true,
+	GetAmountVoteToken:               true,
+	GetEncryptionFlag:                true,
+	GetEncryptionLastBlockHeightFlag: true,
+	GetDCBParams:                     true,
+	GetDCBConstitution:               true,
+	GetDCBBoardIndex:                 true,
+	GetGOVBoardIndex:                 true,
+	GetBondTypes:                     true,
+	GetCurrentSellingBondTypes:       true,
+	GetCurrentStabilityInfo:          true,
+	GetGOVConstitution:               true,
+	GetGOVParams:                     true,
+	GetCurrentSellingGOVTokens:       true,
+
+	// wallet
+	GetPublicKeyFromPaymentAddress: true,
+
+	// introspection
+	Help:        true,
+	ListMethods: true,
+	RPCDiscover: true,
+}
+
+// Commands which take private keys or create transactions without sending them, they are allowed for wallet role
+var walletCommands = map[string]bool{
+	ListOutputCoins:                        true,
+	CreateRawTransaction:                   true,
+	CreateRawCustomTokenTransaction:        true,
+	CreateRawPrivacyCustomTokenTransaction: true,
+	GetListPrivacyCustomTokenBalance:       true,
+	CreateSignatureOnCustomTokenTx:         true,
+}
+
+// Commands which create transactions of stability operations without sending them, they are allowed for stability role
+var stabilityRawCommands = map[string]bool{
+	CreateCrowdsaleRequestToken:    true,
+	CreateCrowdsaleRequestConstant: true,
+	CreateIssuingRequest:           true,
+	CreateRawVoteDCBBoardTx:        true,
+	CreateRawVoteGOVBoardTx:        true,
+	CreateRawSubmitDCBProposalTx:   true,
+	CreateRawSubmitGOVProposalTx:   true,
+}
+
+// Commands which change state of chain, wallet or node, their calls are written to audit log
+var stateChangingCommands = map[string]bool{
+	// node
+	SetBan:      true,
+	ClearBanned: true,
+
+	// wallet
+	ImportAccount:     true,
+	RemoveAccount:     true,
+	SetTxFee:          true,
+	DefragmentAccount: true,
+
+	// transaction and token
+	SendRawTransaction:                         true,
+	CreateAndSendTransaction:                   true,
+	CreateAndSendStakingTransaction:            true,
+	SendRawCustomTokenTransaction:              true,
+	CreateAndSendCustomTokenTransaction:        true,
+	SendRawPrivacyCustomTokenTransaction:       true,
+	CreateAndSendPrivacyCustomTokenTransaction: true,
+}
+
+// Commands of stability operations which change state of chain, they are allowed for stability role
+var stabilityCommands = map[string]bool{
+	// loan
+	CreateAndSendLoanRequest:  true,
+	CreateAndSendLoanResponse: true,
+	CreateAndSendLoanWithdraw: true,
+	CreateAndSendLoanPayment:  true,
+
+	// crowdsale
+	SendCrowdsaleRequestToken:             true,
+	CreateAndSendCrowdsaleRequestToken:    true,
+	SendCrowdsaleRequestConstant:          true,
+	CreateAndSendCrowdsaleRequestConstant: true,
+
+	// reserve
+	SendIssuingRequest:              true,
+	CreateAndSendIssuingRequest:     true,
+	CreateAndSendContractingRequest: true,
+
+	// multisig and board
+	AppendListDCBBoard:                   true,
+	AppendListGOVBoard:                   true,
+	CreateAndSendTxWithMultiSigsReg:      true,
+	CreateAndSendTxWithMultiSigsSpending: true,
+
+	// vote
+	CreateAndSendVoteDCBBoardTransaction:      true,
+	CreateAndSendVoteGOVBoardTransaction:      true,
+	SetAmountVoteToken:                        true,
+	SetEncryptionFlag:                         true,
+	CreateAndSendSealLv3VoteProposal:          true,
+	CreateAndSendSealLv2VoteProposal:          true,
+	CreateAndSendSealLv1VoteProposal:          true,
+	CreateAndSendNormalVoteProposalFromOwner:  true,
+	CreateAndSendNormalVoteProposalFromSealer: true,
+	CreateAndSendSubmitDCBProposalTx:          true,
+	CreateAndSendSubmitGOVProposalTx:          true,
+
+	// gov
+	CreateAndSendTxWithBuyBackRequest:      true,
+	CreateAndSendTxWithBuySellRequest:      true,
+	CreateAndSendTxWithOracleFeed:          true,
+	CreateAndSendTxWithUpdatingOracleBoard: true,
+	CreateAndSendTxWithSenderAddress:       true,
+	CreateAndSendTxWithBuyGOVTokensRequest: true,
+
+	// cmb
+	CreateAndSendTxWithCMBInitRequest:     true,
+	CreateAndSendTxWithCMBInitResponse:    true,
+	CreateAndSendTxWithCMBDepositContract: true,
+	CreateAndSendTxWithCMBDepositSend:     true,
+	CreateAndSendTxWithCMBWithdrawRequest: true,
+}
+
+// rpcRole is a role of RPC clients, methods is nil when role can call all commands
+type rpcRole struct {
+	name    string
+	methods map[string]bool
+	rate    float64 // requests per second of each client of role, requests are not limited when it is 0
+	burst   int
+}
+
+func (role *rpcRole) allows(method string) bool {
+	return role.methods == nil || role.methods[method]
+}
+
+// rpcClient is who sent a request: its role, its credential and its address
+type rpcClient struct {
+	role       *rpcRole
+	credential string
+	remoteAddr string
+}
+
+// isStateChanging returns whether a command changes state of chain, wallet or node
+func isStateChanging(method string) bool {
+	return stateChangingCommands[method] || stabilityCommands[method]
+}
+
+// isKnownCommand returns whether method is a command of http or websocket clients
+func isKnownCommand(method string) bool {
+	if _, ok := RpcHandler[method]; ok {
+		return true
+	}
+	if _, ok := RpcLimited[method]; ok {
+		return true
+	}
+	_, ok := wsHandlers[method]
+	return ok
+}
+
+/*
+defaultRoles - return roles with their default allowed commands and without rate limits,
+commands are allowed by lists, so a new command is only allowed for admin and user until it is added to a list
+*/
+func defaultRoles() map[string]*rpcRole {
+	readOnly := map[string]bool{}
+	for method := range readOnlyCommands {
+		readOnly[method] = true
+	}
+	for method := range wsHandlers {
+		readOnly[method] = true
+	}
+
+	wallet := map[string]bool{}
+	stability := map[string]bool{}
+	user := map[string]bool{}
+	for method := range readOnly {
+		wallet[method] = true
+		stability[method] = true
+		user[method] = true
+	}
+	for method := range RpcHandler {
+		user[method] = true
+	}
+	for method := range walletCommands {
+		wallet[method] = true
+	}
+	for method := range RpcLimited {
+		if method != SetBan && method != ClearBanned {
+			wallet[method] = true
+		}
+	}
+	for method := range stateChangingCommands {
+		if method != SetBan && method != ClearBanned {
+			wallet[method] = true
+		}
+	}
+	for method := range stabilityRawCommands {
+		stability[method] = true
+	}
+	for method := range stabilityCommands {
+		stability[method] = true
+	}
+
+	return map[string]*rpcRole{
+		RoleReadOnly:  {name: RoleReadOnly, methods: readOnly},
+		RoleWallet:    {name: RoleWallet, methods: wallet},
+		RoleStability: {name: RoleStability, methods: stability},
+		RoleAdmin:     {name: RoleAdmin},
+		RoleUser:      {name: RoleUser, methods: user},
+	}
+}
+
+// splitRoleValue splits a config value in format role:value and checks that role exists
+func splitRoleValue(roles map[string]*rpcRole, configValue string) (*rpcRole, string, error) {
+	parts := strings.SplitN(configValue, ":", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("%s is not in format role:value", configValue)
+	}
+	role, ok := roles[strings.TrimSpace(parts[0])]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown RPC role %s", parts[0])
+	}
+	return role, strings.TrimSpace(parts[1]), nil
+}
+
+/*
+initAccess - build roles from their defaults and RPCRoleMethods, RPCRateLimits of config,
+and build credentials of basic auth pairs and RPCAPIKeys with their roles and rate limiters.
+*/
+func (rpcServer *RpcServer) initAccess(config *RpcServerConfig) error {
+	roles := defaultRoles()
+	for _, roleMethods := range config.RPCRoleMethods {
+		role, value, err := splitRoleValue(roles, roleMethods)
+		if err != nil {
+			return err
+		}
+		if value == "*" {
+			role.methods = nil
+			continue
+		}
+		role.methods = map[string]bool{}
+		for _, method := range strings.Split(value, ",") {
+			method = strings.TrimSpace(method)
+			if !isKnownCommand(method) {
+				return fmt.Errorf("unknown RPC command %s of role %s", method, role.name)
+			}
+			role.methods[method] = true
+		}
+	}
+	for _, rateLimit := range config.RPCRateLimits {
+		role, value, err := splitRoleValue(roles, rateLimit)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(value, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("rate limit %s is not in format role:rate:burst", rateLimit)
+		}
+		rate, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("invalid rate of rate limit %s", rateLimit)
+		}
+		burst, err := strconv.Atoi(parts[1])
+		if err != nil || burst <= 0 {
+			return fmt.Errorf("invalid burst of rate limit %s", rateLimit)
+		}
+		role.rate = rate
+		role.burst = burst
+	}
+
+	rpcServer.roles = roles
+	rpcServer.apiKeys = []rpcAPIKey{}
+	for _, apiKey := range config.RPCAPIKeys {
+		role, value, err := splitRoleValue(roles, apiKey)
+		if err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(value)
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("API key of role %s must be a hex encoded sha256 hash", role.name)
+		}
+		key := rpcAPIKey{role: role, credential: "apikey:" + value[:8]}
+		copy(key.hash[:], hash)
+		rpcServer.apiKeys = append(rpcServer.apiKeys, key)
+	}
+
+	rpcServer.rateLimiters = map[string]*rateLimiter{}
+	addLimiter := func(credential string, role *rpcRole) {
+		if role.rate > 0 {
+			rpcServer.rateLimiters[credential] = newRateLimiter(role.rate, role.burst)
+		}
+	}
+	addLimiter(credentialRPCUser, roles[RoleUser])
+	addLimiter(credentialRPCLimitUser, roles[RoleAdmin])
+	addLimiter(credentialNoAuth, roles[RoleAdmin])
+	for _, key := range rpcServer.apiKeys {
+		addLimiter(key.credential, key.role)
+	}
+
+	auditLog, err := newRPCAuditLog(config.RPCAuditLog)
+	if err != nil {
+		return err
+	}
+	rpcServer.auditLog = auditLog
+	return nil
+}
+
+// rpcAPIKey is an API key of config, only sha256 hash of key is kept
+type rpcAPIKey struct {
+	hash       [sha256.Size]byte
+	role       *rpcRole
+	credential string
+}
+
+// apiKeyOfRequest returns API key which is sent in X-Api-Key header or as a bearer token of Authorization header
+func apiKeyOfRequest(r *http.Request) string {
+	if apiKey := r.Header.Get("X-Api-Key"); apiKey != "" {
+		return apiKey
+	}
+	authhdr := r.Header.Get("Authorization")
+	if strings.HasPrefix(authhdr, "Bearer ") {
+		return strings.TrimPrefix(authhdr, "Bearer ")
+	}
+	return ""
+}
+
+// checkAPIKey returns client of an API key, all keys are compared so this check is time-constant
+func (rpcServer RpcServer) checkAPIKey(apiKey string, remoteAddr string) *rpcClient {
+	hash := sha256.Sum256([]byte(apiKey))
+	var client *rpcClient
+	for _, key := range rpcServer.apiKeys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 {
+			client = &rpcClient{role: key.role, credential: key.credential, remoteAddr: remoteAddr}
+		}
+	}
+	return client
+}
+
+// authorizeCommand checks that role of client allows method and that client is not over rate limit of its role
+func (rpcServer RpcServer) authorizeCommand(client *rpcClient, method string) *RPCError {
+	if !client.role.allows(method) {
+		return NewRPCError(ErrRPCInvalidMethodPermission, fmt.Errorf("role %s is not allowed to call %s", client.role.name, method))
+	}
+	if limiter, ok := rpcServer.rateLimiters[client.credential]; ok && !limiter.Allow() {
+		return NewRPCError(ErrRPCRateLimited, fmt.Errorf("rate limit of role %s is exceeded", client.role.name))
+	}
+	return nil
+}
+
+/*
+auditCommand - write a call of a state changing command to audit log, calls which are denied by role or rate limit are written too.
+Params are not written because they may have private keys
+*/
+func (rpcServer RpcServer) auditCommand(client *rpcClient, method string, rpcErr *RPCError) {
+	if rpcServer.auditLog == nil || !isStateChanging(method) {
+		return
+	}
+	entry := rpcAuditEntry{
+		Time:       time.Now().Unix(),
+		Method:     method,
+		Role:       client.role.name,
+		Credential: client.credential,
+		RemoteAddr: client.remoteAddr,
+		Success:    rpcErr == nil,
+	}
+	if rpcErr != nil {
+		entry.ErrorCode = rpcErr.Code
+		entry.Denied = rpcErr.Code == ErrCodeMessage[ErrRPCInvalidMethodPermission].code || rpcErr.Code == ErrCodeMessage[ErrRPCRateLimited].code
+	}
+	rpcServer.auditLog.Write(entry)
+}
+
+// rateLimiter is a token bucket which is refilled by rate tokens per second up to burst tokens
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mtx    sync.Mutex
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token, it returns false when there is no token
+func (limiter *rateLimiter) Allow() bool {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	if limiter.tokens < 1 {
+		return false
+	}
+	limiter.tokens--
+	return true
+}
+
+// rpcAuditEntry is a line of audit log
+type rpcAuditEntry struct {
+	Time       int64  `json:"Time"`
+	Method     string `json:"Method"`
+	Role       string `json:"Role"`
+	Credential string `json:"Credential"`
+	RemoteAddr string `json:"RemoteAddr"`
+	Success    bool   `json:"Success"`
+	ErrorCode  int    `json:"ErrorCode,omitempty"`
+	// Denied is true when call was not run because role of client does not allow it or client is over rate limit
+	Denied bool `json:"Denied,omitempty"`
+}
+
+// rpcAuditLog appends entries as json lines to a file, or writes them to log of RPC server when there is no file
+type rpcAuditLog struct {
+	file *os.File
+	mtx  sync.Mutex
+}
+
+func newRPCAuditLog(path string) (*rpcAuditLog, error) {
+	if path == "" {
+		return &rpcAuditLog{}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.New("can not open RPC audit log: " + err.Error())
+	}
+	return &rpcAuditLog{file: file}, nil
+}
+
+func (auditLog *rpcAuditLog) Write(entry rpcAuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	auditLog.mtx.Lock()
+	defer auditLog.mtx.Unlock()
+	if auditLog.file == nil {
+		Logger.log.Infof("RPC audit %s", line)
+		return
+	}
+	if _, err := auditLog.file.Write(append(line, '\n')); err != nil {
+		Logger.log.Errorf("Failed to write RPC audit log: %s", err.Error())
+	}
+}
+
+func (auditLog *rpcAuditLog) Close() {
+	auditLog.mtx.Lock()
+	defer auditLog.mtx.Unlock()
+	if auditLog.file != nil {
+		auditLog.file.Close()
+		auditLog.file = nil
+	}
+}
@@ -0,0 +1,188 @@
+package rpcserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testAPIKeyHash(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestCommandsAreClassified(t *testing.T) {
+	lists := []map[string]bool{readOnlyCommands, walletCommands, stabilityRawCommands, stateChangingCommands, stabilityCommands}
+	for method := range RpcHandler {
+		count := 0
+		for _, list := range lists {
+			if list[method] {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("command %s is in %d lists of access, expected 1", method, count)
+		}
+	}
+}
+
+func TestReadOnlyRole(t *testing.T) {
+	readOnly := defaultRoles()[RoleReadOnly]
+	for _, method := range []string{GetBlockCount, GetBeaconBestState, GetTransactionByHash, SubscribeBeaconBlocks} {
+		if !readOnly.allows(method) {
+			t.Errorf("expected readonly role to allow %s", method)
+		}
+	}
+	for _, method := range []string{CreateRawTransaction, CreateRawCustomTokenTransaction, CreateRawVoteDCBBoardTx, ListOutputCoins, GetListPrivacyCustomTokenBalance, SendRawTransaction, GetBalance, SetBan} {
+		if readOnly.allows(method) {
+			t.Errorf("expected readonly role not to allow %s", method)
+		}
+	}
+	for method := range readOnly.methods {
+		if isStateChanging(method) || strings.HasPrefix(strings.ToLower(method), "create") {
+			t.Errorf("expected readonly role not to allow %s", method)
+		}
+	}
+}
+
+func TestDefaultRoles(t *testing.T) {
+	roles := defaultRoles()
+	tests := []struct {
+		role    string
+		method  string
+		allowed bool
+	}{
+		{RoleWallet, CreateRawTransaction, true},
+		{RoleWallet, SendRawTransaction, true},
+		{RoleWallet, GetBalance, true},
+		{RoleWallet, SetBan, false},
+		{RoleWallet, CreateAndSendLoanRequest, false},
+		{RoleStability, CreateAndSendLoanRequest, true},
+		{RoleStability, CreateRawSubmitDCBProposalTx, true},
+		{RoleStability, SendRawTransaction, false},
+		{RoleUser, CreateAndSendLoanRequest, true},
+		{RoleUser, GetBalance, false},
+		{RoleAdmin, SetBan, true},
+	}
+	for _, test := range tests {
+		if allowed := roles[test.role].allows(test.method); allowed != test.allowed {
+			t.Errorf("role %s, command %s: expected allowed %v, got %v", test.role, test.method, test.allowed, allowed)
+		}
+	}
+}
+
+func TestInitAccessConfig(t *testing.T) {
+	initTestLogger()
+	tests := []struct {
+		config RpcServerConfig
+		valid  bool
+	}{
+		{RpcServerConfig{RPCAPIKeys: []string{"readonly:" + testAPIKeyHash("key")}}, true},
+		{RpcServerConfig{RPCAPIKeys: []string{"readonly:abcd"}}, false},
+		{RpcServerConfig{RPCAPIKeys: []string{"owner:" + testAPIKeyHash("key")}}, false},
+		{RpcServerConfig{RPCAPIKeys: []string{testAPIKeyHash("key")}}, false},
+		{RpcServerConfig{RPCRoleMethods: []string{"readonly:" + GetBlockCount + "," + GetBestBlock}}, true},
+		{RpcServerConfig{RPCRoleMethods: []string{"readonly:unknowncommand"}}, false},
+		{RpcServerConfig{RPCRateLimits: []string{"readonly:10:20"}}, true},
+		{RpcServerConfig{RPCRateLimits: []string{"readonly:10"}}, false},
+	}
+	for _, test := range tests {
+		rpcServer := &RpcServer{}
+		err := rpcServer.initAccess(&test.config)
+		if (err == nil) != test.valid {
+			t.Errorf("config %+v: expected valid %v, got error %+v", test.config, test.valid, err)
+		}
+	}
+
+	rpcServer := &RpcServer{}
+	err := rpcServer.initAccess(&RpcServerConfig{RPCRoleMethods: []string{"readonly:" + GetBlockCount}})
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if readOnly := rpcServer.roles[RoleReadOnly]; !readOnly.allows(GetBlockCount) || readOnly.allows(GetBestBlock) {
+		t.Errorf("expected commands of readonly role to be replaced, got %+v", readOnly.methods)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	initTestLogger()
+	rpcServer := &RpcServer{}
+	err := rpcServer.initAccess(&RpcServerConfig{RPCAPIKeys: []string{
+		"readonly:" + testAPIKeyHash("reader"),
+		"wallet:" + testAPIKeyHash("spender"),
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Api-Key", "reader")
+	client, err := rpcServer.checkAuth(r, true)
+	if err != nil || client == nil || client.role.name != RoleReadOnly {
+		t.Fatalf("expected readonly client, got %+v %+v", client, err)
+	}
+	if client.credential != "apikey:"+testAPIKeyHash("reader")[:8] {
+		t.Errorf("unexpected credential %s", client.credential)
+	}
+
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Authorization", "Bearer spender")
+	if client, err := rpcServer.checkAuth(r, true); err != nil || client == nil || client.role.name != RoleWallet {
+		t.Errorf("expected wallet client of bearer token, got %+v %+v", client, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Api-Key", "wrong")
+	if client, err := rpcServer.checkAuth(r, true); err == nil || client != nil {
+		t.Errorf("expected unknown API key to fail")
+	}
+}
+
+func TestAuditDeniedCalls(t *testing.T) {
+	initTestLogger()
+	dir, err := ioutil.TempDir("", "rpcaudit")
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	rpcServer := &RpcServer{}
+	if err := rpcServer.initAccess(&RpcServerConfig{RPCAuditLog: auditPath}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	defer rpcServer.auditLog.Close()
+	client := &rpcClient{role: rpcServer.roles[RoleReadOnly], credential: "apikey:reader", remoteAddr: "127.0.0.1:1"}
+
+	// denied call of a command which does not change state is not audited
+	if _, rpcErr := rpcServer.executeCommand(RpcRequest{Method: CreateRawTransaction}, client, nil); rpcErr == nil {
+		t.Fatalf("expected readonly client to be denied")
+	}
+	if _, rpcErr := rpcServer.executeCommand(RpcRequest{Method: SendRawTransaction}, client, nil); rpcErr == nil {
+		t.Fatalf("expected readonly client to be denied")
+	}
+
+	data, err := ioutil.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 audit entry, got %q", data)
+	}
+	var entry rpcAuditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if entry.Method != SendRawTransaction || entry.Role != RoleReadOnly || entry.Credential != "apikey:reader" || entry.Success || !entry.Denied {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+	if entry.ErrorCode != ErrCodeMessage[ErrRPCInvalidMethodPermission].code {
+		t.Errorf("expected permission error code, got %d", entry.ErrorCode)
+	}
+}
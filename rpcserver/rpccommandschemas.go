@@ -0,0 +1,529 @@
+package rpcserver
+
+// Params which are shared by commands
+var (
+	privateKeyParam   = requiredParam("PrivateKey", paramTypeString, "serialized private key of sender")
+	paymentAddrParam  = requiredParam("PaymentAddress", paramTypeString, "serialized payment address")
+	shardIDParam      = requiredParam("ShardID", paramTypeInteger, "id of shard")
+	txIDParam         = requiredParam("TxID", paramTypeString, "hash of transaction")
+	tokenIDParam      = requiredParam("TokenID", paramTypeString, "id of token")
+	optionalTokenID   = optionalParam("TokenID", paramTypeString, "id of token, constant when it is not given")
+	base58TxDataParam = requiredParam("Base58CheckData", paramTypeString, "base58 check encoded transaction which is created by a create command")
+	tokenParamsParam  = requiredParam("TokenParams", paramTypeObject, "params of token: TokenID, TokenName, TokenSymbol, TokenTxType, TokenAmount and TokenReceivers")
+	loanIDsParam      = paramSchema{Name: "LoanID", Type: paramTypeString, Description: "hex encoded loan id", Variadic: true}
+)
+
+// txParams returns params of commands which build a constant transaction from a private key, see buildRawTransaction
+func txParams(extraParams ...paramSchema) []paramSchema {
+	return append([]paramSchema{
+		privateKeyParam,
+		{Name: "Receivers", Type: paramTypeObject, Required: true, Nullable: true, Description: "map of payment addresses of receivers to amounts in nano constant"},
+		requiredParam("Fee", paramTypeInteger, "default fee per kb in nano constant which is used when fee estimator has no data"),
+		requiredParam("Privacy", paramTypeInteger, "1 to create a transaction with privacy, 0 or -1 without privacy"),
+	}, extraParams...)
+}
+
+// tokenTxParams returns params of commands which build a custom token transaction, see buildRawCustomTokenTransaction
+func tokenTxParams(extraParams ...paramSchema) []paramSchema {
+	return txParams(append([]paramSchema{tokenParamsParam}, extraParams...)...)
+}
+
+func metadataParam(description string) paramSchema {
+	return requiredParam("Metadata", paramTypeObject, description)
+}
+
+const (
+	createTxResult = "TxID and Base58CheckData of created transaction"
+	sendTxResult   = "TxID of sent transaction"
+)
+
+// rpcCommandSchemas are schemas of commands of RpcHandler, RpcLimited and websocket clients
+var rpcCommandSchemas = map[string]*commandSchema{
+	// node
+	GetNetworkInfo:     {Description: "Return version, connections, local addresses and networks of node.", Result: "network info"},
+	GetConnectionCount: {Description: "Return number of connected peers.", Result: "number of connections"},
+	GetAllPeers:        {Description: "Return addresses of all known peers.", Result: "list of peer addresses"},
+	ListBanned:         {Description: "Return banned peers with their ban expiry and reason.", Result: "list of banned peers"},
+	SetBan: {
+		Description: "Ban a peer or remove a ban of a peer.",
+		Params: []paramSchema{
+			requiredParam("PeerID", paramTypeString, "id of peer"),
+			{Name: "Command", Type: paramTypeString, Required: true, Description: "add to ban peer or remove to remove its ban", Enum: []string{"add", "remove"}},
+			optionalParam("BanTime", paramTypeInteger, "ban duration in seconds, default ban duration when it is 0"),
+			optionalParam("Reason", paramTypeString, "reason of ban"),
+		},
+		Result: "null",
+	},
+	ClearBanned:       {Description: "Remove bans of all peers.", Result: "null"},
+	GetTopologyStatus: {Description: "Return pinned, beacon, cross shard and rotating peers of topology policy.", Result: "topology status"},
+	GetRawMempool:     {Description: "Return hashes of transactions in mempool.", Result: "list of transaction hashes"},
+	GetMempoolEntry: {
+		Description: "Return a transaction in mempool.",
+		Params:      []paramSchema{txIDParam},
+		SingleParam: true,
+		Result:      "transaction",
+	},
+	EstimateFee: {
+		Description: "Estimate fee per kb and size of a transaction.",
+		Params:      txParams(optionalParam("TokenParams", paramTypeObject, "params of token when transaction is a custom token transaction")),
+		Result:      "EstimateFeeCoinPerKb and EstimateTxSizeInKb",
+	},
+	EstimateFeeWithEstimator: {
+		Description: "Estimate fee per kb with fee estimator of shard of a key.",
+		Params: []paramSchema{
+			requiredParam("DefaultFee", paramTypeInteger, "fee per kb in nano constant which is used when fee estimator has no data"),
+			requiredParam("Key", paramTypeString, "serialized key whose shard is estimated"),
+			{Name: "NumBlock", Type: paramTypeInteger, Nullable: true, Description: "number of blocks which transaction is expected to be included in"},
+			{Name: "TokenID", Type: paramTypeString, Nullable: true, Description: "id of token to estimate fee in token"},
+		},
+		Result: "EstimateFeeCoinPerKb and EstimateFeeTokenPerKb",
+	},
+	GetGenerate:      {Description: "Return whether node generates blocks.", Result: "boolean"},
+	GetMiningInfo:    {Description: "Return mining info of node.", Result: "mining info"},
+	GetBlockTemplate: {Description: "Return block template of node.", Result: "block template"},
+
+	// block
+	GetBestBlock:     {Description: "Return height, hash and number of transactions of best blocks of shards, beacon is shard -1.", Result: "best blocks"},
+	GetBestBlockHash: {Description: "Return hashes of best blocks of shards, beacon is shard -1.", Result: "best block hashes"},
+	RetrieveBlock: {
+		Description: "Return a shard block.",
+		Params: []paramSchema{
+			requiredParam("BlockHash", paramTypeString, "hash of block"),
+			{Name: "Verbosity", Type: paramTypeString, Required: true, Description: "0 for hex data, 1 for block with tx hashes, 2 for block with txs", Enum: []string{"0", "1", "2"}},
+		},
+		Result: "block",
+	},
+	RetrieveBeaconBlock: {
+		Description: "Return a beacon block.",
+		Params: []paramSchema{
+			requiredParam("BlockHash", paramTypeString, "hash of block"),
+			requiredParam("Verbosity", paramTypeString, "verbosity of block"),
+		},
+		Result: "beacon block",
+	},
+	GetBlocks: {
+		Description: "Return latest blocks of a shard or of beacon.",
+		Params: []paramSchema{
+			requiredParam("NumBlock", paramTypeInteger, "number of blocks"),
+			requiredParam("ShardID", paramTypeInteger, "id of shard, -1 for beacon"),
+		},
+		Result: "list of blocks",
+	},
+	GetBlockChainInfo: {Description: "Return chain name, best blocks and active shards.", Result: "blockchain info"},
+	GetBlockCount: {
+		Description: "Return height of best block of a shard or of beacon.",
+		Params:      []paramSchema{requiredParam("ShardID", paramTypeInteger, "id of shard, -1 for beacon")},
+		Result:      "height",
+	},
+	GetBlockHash: {
+		Description: "Return hash of a block of a shard or of beacon at a height.",
+		Params: []paramSchema{
+			requiredParam("ShardID", paramTypeInteger, "id of shard, -1 for beacon"),
+			requiredParam("Height", paramTypeInteger, "height of block"),
+		},
+		Result: "hash of block",
+	},
+	CheckHashValue: {
+		Description: "Return whether a hash is a block, a transaction or a custom token.",
+		Params:      []paramSchema{requiredParam("Hash", paramTypeString, "hash value")},
+		Result:      "IsBlock, IsTransaction and IsCustomToken flags",
+	},
+	GetBlockHeader: {
+		Description: "Return header of a shard block by hash or by height.",
+		Params: []paramSchema{
+			{Name: "GetBy", Type: paramTypeString, Required: true, Description: "blockhash or blocknum", Enum: []string{"blockhash", "blocknum"}},
+			requiredParam("Block", paramTypeString, "hash or height of block"),
+			shardIDParam,
+		},
+		Result: "header, block number and shard id",
+	},
+
+	// transaction
+	ListOutputCoins: {
+		Description: "Return output coins of keys.",
+		Params: []paramSchema{
+			requiredParam("Min", paramTypeInteger, "min confirmations"),
+			requiredParam("Max", paramTypeInteger, "max confirmations"),
+			requiredParam("Keys", paramTypeArray, "list of objects with PrivateKey or PaymentAddress and ReadonlyKey"),
+			optionalTokenID,
+		},
+		Result: "output coins of each key",
+	},
+	CreateRawTransaction:     {Description: "Create a constant transaction without sending it.", Params: txParams(), Result: createTxResult},
+	SendRawTransaction:       {Description: "Send a constant transaction which is created by createtransaction.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendTransaction: {Description: "Create and send a constant transaction.", Params: txParams(), Result: sendTxResult},
+	DecodeRawTransaction: {
+		Description: "Decode a constant, custom token or privacy custom token transaction without broadcasting it: version, type, fee, metadata, serial numbers, commitments, proof sizes and which checks of mempool pass against current chain.",
+		Params:      []paramSchema{base58TxDataParam},
+		Result:      "decoded transaction",
+	},
+	SimulateTransaction: {
+		Description: "Run validation of mempool, shard and beacon on a transaction without adding it to mempool or broadcasting it: result of each stage, actions of shard, instructions of beacon and state changes.",
+		Params:      []paramSchema{base58TxDataParam},
+		Result:      "report of stages and state changes",
+	},
+	GetMempoolInfo:   {Description: "Return size, bytes and transactions of mempool.", Result: "mempool info"},
+	GetMempoolPolicy: {Description: "Return policy of mempool.", Result: "mempool policy"},
+	GetTransactionByHash: {
+		Description: "Return a transaction in chain or in mempool.",
+		Params:      []paramSchema{txIDParam},
+		Result:      "transaction detail",
+	},
+	CreateAndSendStakingTransaction: {
+		Description: "Create and send a transaction which stakes for a shard or beacon committee.",
+		Params:      txParams(requiredParam("StakingType", paramTypeInteger, "0 to stake for shard, 1 to stake for beacon")),
+		Result:      sendTxResult,
+	},
+	RandomCommitments: {
+		Description: "Return random commitments of a shard for output coins which are spent in a privacy transaction.",
+		Params: []paramSchema{
+			paymentAddrParam,
+			requiredParam("OutputCoins", paramTypeArray, "output coins which are spent"),
+			optionalTokenID,
+		},
+		Result: "commitment indices and commitments",
+	},
+	HasSerialNumbers: {
+		Description: "Return whether serial numbers are spent in shard of a payment address.",
+		Params: []paramSchema{
+			paymentAddrParam,
+			requiredParam("SerialNumbers", paramTypeArray, "base58 check encoded serial numbers"),
+			optionalTokenID,
+		},
+		Result: "list of booleans",
+	},
+	HasSnDerivators: {
+		Description: "Return whether serial number derivators are used in shard of a payment address.",
+		Params: []paramSchema{
+			paymentAddrParam,
+			requiredParam("SnDerivators", paramTypeArray, "base58 check encoded serial number derivators"),
+			optionalTokenID,
+		},
+		Result: "list of booleans",
+	},
+
+	// best state
+	GetCandidateList:              {Description: "Return candidates of beacon and shard committees.", Result: "candidates"},
+	GetCommitteeList:              {Description: "Return beacon and shard committees.", Result: "committees"},
+	GetBlockProducerList:          {Description: "Return block producers of beacon and shards.", Result: "block producers"},
+	GetShardBestState:             {Description: "Return best state of a shard.", Params: []paramSchema{shardIDParam}, Result: "shard best state"},
+	GetBeaconBestState:            {Description: "Return best state of beacon.", Result: "beacon best state"},
+	GetBeaconPoolState:            {Description: "Return blocks in beacon pool.", Result: "beacon pool state"},
+	GetShardPoolState:             {Description: "Return blocks in pool of a shard.", Params: []paramSchema{shardIDParam}, Result: "shard pool state"},
+	GetShardPoolLatestValidHeight: {Description: "Return latest valid height of pool of a shard.", Params: []paramSchema{shardIDParam}, Result: "height"},
+	GetShardToBeaconPoolState:     {Description: "Return shard to beacon blocks in pool.", Result: "shard to beacon pool state"},
+	GetCrossShardPoolState:        {Description: "Return cross shard blocks in pool of a shard.", Params: []paramSchema{shardIDParam}, Result: "cross shard pool state"},
+	CanPubkeyStake: {
+		Description: "Return whether a public key can stake.",
+		Params:      []paramSchema{requiredParam("PublicKey", paramTypeString, "base58 check encoded public key")},
+		Result:      "PublicKey and CanStake",
+	},
+
+	// custom token
+	CreateRawCustomTokenTransaction:     {Description: "Create a custom token transaction without sending it.", Params: tokenTxParams(), Result: createTxResult},
+	SendRawCustomTokenTransaction:       {Description: "Send a custom token transaction which is created by createrawcustomtokentransaction.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendCustomTokenTransaction: {Description: "Create and send a custom token transaction.", Params: tokenTxParams(), Result: sendTxResult},
+	ListUnspentCustomToken: {
+		Description: "Return unspent outputs of a custom token of a private key.",
+		Params:      []paramSchema{privateKeyParam, tokenIDParam},
+		Result:      "unspent outputs",
+	},
+	ListCustomToken: {Description: "Return custom tokens.", Result: "list of custom tokens"},
+	CustomToken: {
+		Description: "Return transactions of a custom token.",
+		Params:      []paramSchema{tokenIDParam},
+		Result:      "list of transaction hashes",
+	},
+	GetListCustomTokenBalance: {
+		Description: "Return balances of custom tokens of a payment address.",
+		Params:      []paramSchema{paymentAddrParam},
+		Result:      "balances of custom tokens",
+	},
+
+	// custom token which supports privacy
+	CreateRawPrivacyCustomTokenTransaction:     {Description: "Create a privacy custom token transaction without sending it.", Params: tokenTxParams(), Result: createTxResult},
+	SendRawPrivacyCustomTokenTransaction:       {Description: "Send a privacy custom token transaction which is created by createrawprivacycustomtokentransaction.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendPrivacyCustomTokenTransaction: {Description: "Create and send a privacy custom token transaction.", Params: tokenTxParams(), Result: sendTxResult},
+	ListPrivacyCustomToken:                     {Description: "Return privacy custom tokens.", Result: "list of privacy custom tokens"},
+	PrivacyCustomToken: {
+		Description: "Return transactions of a privacy custom token.",
+		Params:      []paramSchema{tokenIDParam},
+		Result:      "list of transaction hashes",
+	},
+	GetListPrivacyCustomTokenBalance: {
+		Description: "Return balances of privacy custom tokens of a private key.",
+		Params:      []paramSchema{privateKeyParam},
+		Result:      "balances of privacy custom tokens",
+	},
+
+	// loan
+	GetLoanParams:             {Description: "Return loan params of DCB.", Result: "list of loan params"},
+	CreateAndSendLoanRequest:  {Description: "Create and send a loan request.", Params: txParams(metadataParam("loan request: Params, CollateralType, CollateralAmount, LoanAmount, ReceiveAddress, LoanID and KeyDigest")), Result: sendTxResult},
+	CreateAndSendLoanResponse: {Description: "Create and send a response of a DCB governor to a loan request.", Params: txParams(metadataParam("loan response: LoanID and Response")), Result: sendTxResult},
+	CreateAndSendLoanWithdraw: {Description: "Create and send a withdrawal of an accepted loan.", Params: txParams(metadataParam("loan withdraw: LoanID and Key")), Result: sendTxResult},
+	CreateAndSendLoanPayment:  {Description: "Create and send a payment of a loan.", Params: txParams(metadataParam("loan payment: LoanID and PayPrinciple")), Result: sendTxResult},
+	GetLoanResponseApproved:   {Description: "Return approvers of loans.", Params: []paramSchema{loanIDsParam}, Result: "approvers and approved flag of each loan"},
+	GetLoanResponseRejected:   {Description: "Return rejectors of loans.", Params: []paramSchema{loanIDsParam}, Result: "rejectors and rejected flag of each loan"},
+	GetLoanPaymentInfo:        {Description: "Return principle, interest and deadline of loans.", Params: []paramSchema{loanIDsParam}, Result: "payment info of each loan"},
+	GetBankFund:               {Description: "Return bank fund of DCB.", Result: "bank fund"},
+	GetLoanRequestTxStatus: {
+		Description: "Return whether loan requests are accepted by beacon.",
+		Params:      []paramSchema{{Name: "TxID", Type: paramTypeString, Description: "hash of loan request transaction", Variadic: true}},
+		Result:      "status of each loan request",
+	},
+
+	// crowdsale
+	GetListOngoingCrowdsale:               {Description: "Return ongoing crowdsales.", Result: "list of crowdsales"},
+	CreateCrowdsaleRequestToken:           {Description: "Create a crowdsale request which pays with custom token without sending it.", Params: tokenTxParams(metadataParam("crowdsale request: SaleID, PriceLimit, LimitSellingAssetPrice and PaymentAddress")), Result: createTxResult},
+	SendCrowdsaleRequestToken:             {Description: "Send a crowdsale request which is created by createcrowdsalerequesttoken.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendCrowdsaleRequestToken:    {Description: "Create and send a crowdsale request which pays with custom token.", Params: tokenTxParams(metadataParam("crowdsale request: SaleID, PriceLimit, LimitSellingAssetPrice and PaymentAddress")), Result: sendTxResult},
+	CreateCrowdsaleRequestConstant:        {Description: "Create a crowdsale request which pays with constant without sending it.", Params: txParams(metadataParam("crowdsale request: SaleID, PriceLimit, LimitSellingAssetPrice and PaymentAddress")), Result: createTxResult},
+	SendCrowdsaleRequestConstant:          {Description: "Send a crowdsale request which is created by createcrowdsalerequestconstant.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendCrowdsaleRequestConstant: {Description: "Create and send a crowdsale request which pays with constant.", Params: txParams(metadataParam("crowdsale request: SaleID, PriceLimit, LimitSellingAssetPrice and PaymentAddress")), Result: sendTxResult},
+	GetListDCBProposalBuyingAssets:        {Description: "Return assets which DCB proposal buys.", Result: "assets"},
+	GetListDCBProposalSellingAssets:       {Description: "Return assets which DCB proposal sells.", Result: "assets"},
+
+	// reserve
+	CreateIssuingRequest:            {Description: "Create an issuing request without sending it.", Params: txParams(metadataParam("issuing request: ReceiverAddress, DepositedAmount, AssetType, CurrencyType")), Result: createTxResult},
+	SendIssuingRequest:              {Description: "Send an issuing request which is created by createissuingrequest.", Params: []paramSchema{base58TxDataParam}, Result: sendTxResult},
+	CreateAndSendIssuingRequest:     {Description: "Create and send an issuing request.", Params: txParams(metadataParam("issuing request: ReceiverAddress, DepositedAmount, AssetType, CurrencyType")), Result: sendTxResult},
+	CreateAndSendContractingRequest: {Description: "Create and send a contracting request.", Params: tokenTxParams(metadataParam("contracting request: DepositedAmount, CurrencyType and ExternalAddress")), Result: sendTxResult},
+	GetIssuingStatus:                {Description: "Return status of an issuing request.", Params: []paramSchema{txIDParam}, Result: "status"},
+	GetContractingStatus:            {Description: "Return status of a contracting request.", Params: []paramSchema{txIDParam}, Result: "status"},
+	ConvertETHToDCBTokenAmount: {
+		Description: "Convert an amount of ETH in wei to DCB token.",
+		Params:      []paramSchema{requiredParam("Amount", paramTypeString, "amount of wei")},
+		Result:      "amount of DCB token",
+	},
+	ConvertCSTToETHAmount: {
+		Description: "Convert an amount of constant to ETH in wei.",
+		Params:      []paramSchema{requiredParam("Amount", paramTypeInteger, "amount of nano constant")},
+		Result:      "amount of wei",
+	},
+
+	// multisig
+	CreateSignatureOnCustomTokenTx: {
+		Description: "Sign a custom token transaction with a private key.",
+		Params:      []paramSchema{base58TxDataParam, privateKeyParam},
+		Result:      "signature",
+	},
+	GetListDCBBoard:                      {Description: "Return members of DCB board.", Result: "list of payment addresses"},
+	GetListGOVBoard:                      {Description: "Return members of GOV board.", Result: "list of payment addresses"},
+	AppendListDCBBoard:                   {Description: "Add payment address of a private key to DCB board, for testing only.", Params: []paramSchema{privateKeyParam}, Result: "list of payment addresses"},
+	AppendListGOVBoard:                   {Description: "Add payment address of a private key to GOV board, for testing only.", Params: []paramSchema{privateKeyParam}, Result: "list of payment addresses"},
+	CreateAndSendTxWithMultiSigsReg:      {Description: "Create and send a registration of multisigs.", Params: txParams(metadataParam("multisigs registration: PaymentAddress, SpendableMembers and NumOfRequiredSigs")), Result: sendTxResult},
+	CreateAndSendTxWithMultiSigsSpending: {Description: "Create and send a spending of multisigs.", Params: txParams(metadataParam("multisigs spending: Signs")), Result: sendTxResult},
+
+	// vote board
+	CreateAndSendVoteDCBBoardTransaction: {Description: "Create and send a vote of DCB board with DCB tokens.", Params: tokenTxParams(metadataParam("vote: CandidatePaymentAddress")), Result: sendTxResult},
+	CreateRawVoteDCBBoardTx:              {Description: "Create a vote of DCB board without sending it.", Params: tokenTxParams(metadataParam("vote: CandidatePaymentAddress")), Result: createTxResult},
+	CreateAndSendVoteGOVBoardTransaction: {Description: "Create and send a vote of GOV board with GOV tokens.", Params: tokenTxParams(metadataParam("vote: CandidatePaymentAddress")), Result: sendTxResult},
+	CreateRawVoteGOVBoardTx:              {Description: "Create a vote of GOV board without sending it.", Params: tokenTxParams(metadataParam("vote: CandidatePaymentAddress")), Result: createTxResult},
+	GetAmountVoteToken: {
+		Description: "Return amounts of DCB and GOV vote tokens of a payment address.",
+		Params:      []paramSchema{paymentAddrParam},
+		Result:      "amounts of vote tokens",
+	},
+	SetAmountVoteToken: {
+		Description: "Set amounts of DCB and GOV vote tokens of payment address of a private key, for testing only.",
+		Params: []paramSchema{
+			privateKeyParam,
+			requiredParam("AmountDCBVote", paramTypeInteger, "amount of DCB vote tokens"),
+			requiredParam("AmountGOVVote", paramTypeInteger, "amount of GOV vote tokens"),
+		},
+		Result: "null",
+	},
+
+	// vote proposal
+	GetEncryptionFlag: {Description: "Return encryption flags of DCB and GOV proposal votes.", Result: "DCBFlag and GOVFlag"},
+	SetEncryptionFlag: {Description: "Move encryption flags to their next step, for testing only.", Result: "previous DCB flag"},
+	GetEncryptionLastBlockHeightFlag: {
+		Description: "Return height of last block which changed encryption flag of a board.",
+		Params:      []paramSchema{{Name: "BoardType", Type: paramTypeString, Required: true, Description: "dcb or gov", Enum: []string{"dcb", "gov"}}},
+		Result:      "block height",
+	},
+	CreateAndSendSealLv3VoteProposal:          {Description: "Create and send a vote of a proposal which is sealed by 3 board members.", Params: txParams(metadataParam("vote: BoardType, SealLv3Data and PaymentAddresses")), Result: sendTxResult},
+	CreateAndSendSealLv2VoteProposal:          {Description: "Create and send an unsealing of a level 3 sealed vote.", Params: txParams(metadataParam("vote: BoardType, Lv3TxID and FirstPrivateKey")), Result: sendTxResult},
+	CreateAndSendSealLv1VoteProposal:          {Description: "Create and send an unsealing of a level 2 sealed vote.", Params: txParams(metadataParam("vote: BoardType, Lv3TxID, Lv2TxID and SecondPrivateKey")), Result: sendTxResult},
+	CreateAndSendNormalVoteProposalFromOwner:  {Description: "Create and send a vote of a proposal which is unsealed by its owner.", Params: txParams(metadataParam("vote: BoardType, Lv3TxID and VoteProposalData")), Result: sendTxResult},
+	CreateAndSendNormalVoteProposalFromSealer: {Description: "Create and send an unsealing of a level 1 sealed vote.", Params: txParams(metadataParam("vote: BoardType, Lv3TxID, Lv1TxID and ThirdPrivateKey")), Result: sendTxResult},
+
+	// submit proposal
+	CreateAndSendSubmitDCBProposalTx: {Description: "Create and send a DCB proposal.", Params: txParams(metadataParam("DCB proposal: DCBParams, ExecuteDuration and Explanation")), Result: sendTxResult},
+	CreateRawSubmitDCBProposalTx:     {Description: "Create a DCB proposal without sending it.", Params: txParams(metadataParam("DCB proposal: DCBParams, ExecuteDuration and Explanation")), Result: createTxResult},
+	CreateAndSendSubmitGOVProposalTx: {Description: "Create and send a GOV proposal.", Params: txParams(metadataParam("GOV proposal: GOVParams, ExecuteDuration and Explanation")), Result: sendTxResult},
+	CreateRawSubmitGOVProposalTx:     {Description: "Create a GOV proposal without sending it.", Params: txParams(metadataParam("GOV proposal: GOVParams, ExecuteDuration and Explanation")), Result: createTxResult},
+
+	// dcb
+	GetDCBParams:       {Description: "Return params of DCB.", Result: "DCB params"},
+	GetDCBConstitution: {Description: "Return current constitution of DCB.", Result: "DCB constitution"},
+	GetDCBBoardIndex:   {Description: "Return index of current DCB board.", Result: "board index"},
+	GetGOVBoardIndex:   {Description: "Return index of current GOV board.", Result: "board index"},
+
+	// gov
+	GetBondTypes:                           {Description: "Return types of bonds.", Result: "bond types"},
+	GetCurrentSellingBondTypes:             {Description: "Return types of bonds which GOV sells.", Result: "bond types"},
+	GetCurrentStabilityInfo:                {Description: "Return stability info of beacon.", Result: "stability info"},
+	GetGOVConstitution:                     {Description: "Return current constitution of GOV.", Result: "GOV constitution"},
+	GetGOVParams:                           {Description: "Return params of GOV.", Result: "GOV params"},
+	CreateAndSendTxWithBuyBackRequest:      {Description: "Create and send a buy back request of bonds.", Params: tokenTxParams(), Result: sendTxResult},
+	CreateAndSendTxWithBuySellRequest:      {Description: "Create and send a request to buy bonds.", Params: txParams(metadataParam("buy request: PaymentAddress, TokenID, Amount and BuyPrice")), Result: sendTxResult},
+	CreateAndSendTxWithOracleFeed:          {Description: "Create and send a price feed of an oracle.", Params: txParams(metadataParam("oracle feed: AssetType and Price")), Result: sendTxResult},
+	CreateAndSendTxWithUpdatingOracleBoard: {Description: "Create and send an update of oracle board.", Params: txParams(metadataParam("update: OraclePubKeys, Action and Signs")), Result: sendTxResult},
+	CreateAndSendTxWithSenderAddress:       {Description: "Create and send a transaction which carries address of its sender.", Params: txParams(), Result: sendTxResult},
+	CreateAndSendTxWithBuyGOVTokensRequest: {Description: "Create and send a request to buy GOV tokens.", Params: txParams(metadataParam("buy request: PaymentAddress, TokenID, Amount and BuyPrice")), Result: sendTxResult},
+	GetCurrentSellingGOVTokens:             {Description: "Return GOV tokens which GOV sells.", Result: "selling GOV tokens"},
+
+	// cmb
+	CreateAndSendTxWithCMBInitRequest:     {Description: "Create and send an init request of a CMB.", Params: txParams(metadataParam("CMB init request")), Result: sendTxResult},
+	CreateAndSendTxWithCMBInitResponse:    {Description: "Create and send a response to an init request of a CMB.", Params: txParams(metadataParam("CMB init response")), Result: sendTxResult},
+	CreateAndSendTxWithCMBDepositContract: {Description: "Create and send a deposit contract of a CMB.", Params: txParams(metadataParam("CMB deposit contract")), Result: sendTxResult},
+	CreateAndSendTxWithCMBDepositSend:     {Description: "Create and send a deposit to a CMB.", Params: txParams(metadataParam("CMB deposit send")), Result: sendTxResult},
+	CreateAndSendTxWithCMBWithdrawRequest: {Description: "Create and send a withdraw request of a CMB.", Params: txParams(metadataParam("CMB withdraw request")), Result: sendTxResult},
+
+	// wallet
+	GetPublicKeyFromPaymentAddress: {
+		Description: "Return public key of a payment address.",
+		Params:      []paramSchema{paymentAddrParam},
+		Result:      "base58 check encoded public key",
+	},
+	DefragmentAccount: {
+		Description: "Merge output coins of a private key whose values are less than a max value.",
+		Params: []paramSchema{
+			privateKeyParam,
+			requiredParam("MaxValue", paramTypeInteger, "max value of output coins which are merged"),
+			requiredParam("Fee", paramTypeInteger, "default fee per kb in nano constant which is used when fee estimator has no data"),
+			requiredParam("Privacy", paramTypeInteger, "1 to create a transaction with privacy, 0 or -1 without privacy"),
+		},
+		Result: sendTxResult,
+	},
+
+	// local wallet
+	ListAccounts: {Description: "Return accounts of wallet with their balances.", Result: "accounts"},
+	GetAccount: {
+		Description: "Return name of account of a payment address in wallet.",
+		Params:      []paramSchema{paymentAddrParam},
+		SingleParam: true,
+		Result:      "name of account",
+	},
+	GetAddressesByAccount: {
+		Description: "Return addresses of an account of wallet.",
+		Params:      []paramSchema{requiredParam("AccountName", paramTypeString, "name of account")},
+		SingleParam: true,
+		Result:      "addresses",
+	},
+	GetAccountAddress: {
+		Description: "Return address of an account of wallet, account is created when it does not exist.",
+		Params:      []paramSchema{requiredParam("AccountName", paramTypeString, "name of account")},
+		SingleParam: true,
+		Result:      "address",
+	},
+	DumpPrivkey: {
+		Description: "Return private key of a payment address in wallet.",
+		Params:      []paramSchema{paymentAddrParam},
+		SingleParam: true,
+		Result:      "private key",
+	},
+	ImportAccount: {
+		Description: "Import an account into wallet.",
+		Params: []paramSchema{
+			privateKeyParam,
+			requiredParam("AccountName", paramTypeString, "name of account"),
+			requiredParam("PassPhrase", paramTypeString, "pass phrase of wallet"),
+		},
+		Result: "account",
+	},
+	RemoveAccount: {
+		Description: "Remove an account from wallet.",
+		Params: []paramSchema{
+			privateKeyParam,
+			requiredParam("AccountName", paramTypeString, "name of account"),
+			requiredParam("PassPhrase", paramTypeString, "pass phrase of wallet"),
+		},
+		Result: "boolean",
+	},
+	ListUnspentOutputCoins: {
+		Description: "Return unspent output coins of keys.",
+		Params: []paramSchema{
+			requiredParam("Min", paramTypeInteger, "min confirmations"),
+			requiredParam("Max", paramTypeInteger, "max confirmations"),
+			requiredParam("Keys", paramTypeArray, "list of objects with PrivateKey"),
+		},
+		Result: "unspent output coins of each key",
+	},
+	GetBalance: {
+		Description: "Return balance of an account of wallet, all accounts when account name is *.",
+		Params: []paramSchema{
+			requiredParam("AccountName", paramTypeString, "name of account or *"),
+			requiredParam("Min", paramTypeInteger, "min confirmations"),
+			requiredParam("PassPhrase", paramTypeString, "pass phrase of wallet"),
+		},
+		Result: "balance",
+	},
+	GetBalanceByPrivatekey: {
+		Description: "Return balance of a private key.",
+		Params:      []paramSchema{privateKeyParam},
+		Result:      "balance",
+	},
+	GetBalanceByPaymentAddress: {
+		Description: "Return balance of a payment address.",
+		Params:      []paramSchema{optionalParam("PaymentAddress", paramTypeString, "serialized payment address")},
+		Result:      "balance",
+	},
+	GetReceivedByAccount: {
+		Description: "Return amount which is received by an account of wallet.",
+		Params: []paramSchema{
+			requiredParam("AccountName", paramTypeString, "name of account"),
+			requiredParam("Min", paramTypeInteger, "min confirmations"),
+			requiredParam("PassPhrase", paramTypeString, "pass phrase of wallet"),
+		},
+		Result: "amount",
+	},
+	SetTxFee: {
+		Description: "Set incremental fee per kb of wallet.",
+		Params:      []paramSchema{requiredParam("Fee", paramTypeInteger, "fee per kb in nano constant")},
+		SingleParam: true,
+		Result:      "boolean",
+	},
+	GetRecentTransactionsByBlockNumber: {
+		Description: "Return transactions of a key in recent blocks.",
+		Params: []paramSchema{
+			requiredParam("NumBlock", paramTypeInteger, "number of recent blocks"),
+			requiredParam("Key", paramTypeString, "serialized key"),
+		},
+		Result: "transactions",
+	},
+
+	// websocket
+	SubscribeBeaconBlocks: {Description: "Subscribe to new beacon blocks, websocket only.", Result: "id of subscription"},
+	SubscribeShardBlocks: {
+		Description: "Subscribe to new shard blocks, websocket only.",
+		Params:      []paramSchema{optionalParam("ShardIDs", paramTypeArray, "ids of shards, all shards when it is empty")},
+		Result:      "id of subscription",
+	},
+	SubscribeMempool: {
+		Description: "Subscribe to transactions which enter or leave mempool, websocket only.",
+		Params:      []paramSchema{optionalParam("ShardIDs", paramTypeArray, "ids of shards of senders, all shards when it is empty")},
+		Result:      "id of subscription",
+	},
+	SubscribeTransactionsByKey: {
+		Description: "Subscribe to transactions which send from or to keys, websocket only.",
+		Params:      []paramSchema{requiredParam("Keys", paramTypeArray, "payment addresses, readonly keys or public keys")},
+		Result:      "id of subscription",
+	},
+	SubscribeCommittees: {Description: "Subscribe to changes of beacon and shard committees, websocket only.", Result: "id of subscription"},
+	Unsubscribe: {
+		Description: "Remove a subscription, websocket only.",
+		Params:      []paramSchema{requiredParam("SubscriptionID", paramTypeInteger, "id of subscription")},
+		Result:      "whether subscription existed",
+	},
+
+	// introspection
+	Help: {
+		Description: "Return usage of all commands or help of a command.",
+		Params:      []paramSchema{optionalParam("Command", paramTypeString, "name of command")},
+		Result:      "help text",
+	},
+	ListMethods: {Description: "Return commands with their descriptions and whether they are websocket only.", Result: "list of commands"},
+	RPCDiscover: {Description: "Return OpenRPC document of commands.", Result: "OpenRPC document"},
+}
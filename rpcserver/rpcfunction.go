@@ -23,12 +23,15 @@ var RpcHandler = map[string]commandHandler{
 	GetNetworkInfo:           RpcServer.handleGetNetWorkInfo,
 	GetConnectionCount:       RpcServer.handleGetConnectionCount,
 	GetAllPeers:              RpcServer.handleGetAllPeers,
+	ListBanned:               RpcServer.handleListBanned,
+	GetTopologyStatus:        RpcServer.handleGetTopologyStatus,
 	GetRawMempool:            RpcServer.handleGetRawMempool,
 	GetMempoolEntry:          RpcServer.handleMempoolEntry,
 	EstimateFee:              RpcServer.handleEstimateFee,
 	EstimateFeeWithEstimator: RpcServer.handleEstimateFeeWithEstimator,
 	GetGenerate:              RpcServer.handleGetGenerate,
 	GetMiningInfo:            RpcServer.handleGetMiningInfo,
+	GetBlockTemplate:         RpcServer.handleGetBlockTemplate,
 
 	// block
 	GetBestBlock:      RpcServer.handleGetBestBlock,
@@ -46,8 +49,11 @@ var RpcHandler = map[string]commandHandler{
 	ListOutputCoins:                 RpcServer.handleListOutputCoins,
 	CreateRawTransaction:            RpcServer.handleCreateRawTransaction,
 	SendRawTransaction:              RpcServer.handleSendRawTransaction,
+	DecodeRawTransaction:            RpcServer.handleDecodeRawTransaction,
+	SimulateTransaction:             RpcServer.handleSimulateTransaction,
 	CreateAndSendTransaction:        RpcServer.handleCreateAndSendTx,
 	GetMempoolInfo:                  RpcServer.handleGetMempoolInfo,
+	GetMempoolPolicy:                RpcServer.handleGetMempoolPolicy,
 	GetTransactionByHash:            RpcServer.handleGetTransactionByHash,
 	CreateAndSendStakingTransaction: RpcServer.handleCreateAndSendStakingTx,
 	RandomCommitments:               RpcServer.handleRandomCommitments,
@@ -182,6 +188,11 @@ var RpcHandler = map[string]commandHandler{
 	// wallet
 	GetPublicKeyFromPaymentAddress: RpcServer.handleGetPublicKeyFromPaymentAddress,
 	DefragmentAccount:              RpcServer.handleDefragmentAccount,
+
+	// introspection
+	Help:        RpcServer.handleHelp,
+	ListMethods: RpcServer.handleListMethods,
+	RPCDiscover: RpcServer.handleRPCDiscover,
 }
 
 // Commands that are available to a limited user
@@ -201,6 +212,10 @@ var RpcLimited = map[string]commandHandler{
 	GetReceivedByAccount:       RpcServer.handleGetReceivedByAccount,
 	SetTxFee:                   RpcServer.handleSetTxFee,
 	GetRecentTransactionsByBlockNumber: RpcServer.handleGetRecentTransactionsByBlockNumber,
+
+	// ban of misbehaving peers
+	SetBan:      RpcServer.handleSetBan,
+	ClearBanned: RpcServer.handleClearBanned,
 }
 
 func (rpcServer RpcServer) handleGetNetWorkInfo(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
@@ -403,6 +418,20 @@ func (rpcServer RpcServer) handleGetMiningInfo(params interface{}, closeChan <-c
 	return jsonresult.GetMiningInfoResult{}, nil
 }
 
+/*
+handleGetBlockTemplate - RPC returns txs in mempool which would be selected for the next shard block,
+ordered by fee per kb and limited by block size and proof verification cost
+*/
+func (rpcServer RpcServer) handleGetBlockTemplate(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	if rpcServer.config.BlockGen == nil {
+		return nil, NewRPCError(ErrUnexpected, errors.New("Block template generator not init"))
+	}
+	template := rpcServer.config.BlockGen.GetBlockTemplate()
+	result := jsonresult.GetBlockTemplateResult{}
+	result.Init(template)
+	return result, nil
+}
+
 /*
 handleGetRawMempool - RPC returns all transaction ids in memory pool as a json array of string transaction ids
 Hint: use getmempoolentry to fetch a specific transaction from the mempool.
@@ -541,14 +570,37 @@ func (rpcServer RpcServer) handleEstimateFeeWithEstimator(params interface{}, cl
 	lastByte := senderKeySet.PaymentAddress.Pk[len(senderKeySet.PaymentAddress.Pk)-1]
 	shardIDSender := common.GetShardIDFromLastByte(lastByte)
 
-	// param #2: numblocl
-	estimateFeeCoinPerKb := rpcServer.estimateFeeWithEstimator(defaultFeeCoinPerKb, shardIDSender, 8)
+	// param #3: number of blocks to confirm tx, default is 8
+	numBlock := uint64(8)
+	if len(arrayParams) > 2 && arrayParams[2] != nil {
+		numBlockParam, ok := arrayParams[2].(float64)
+		if !ok || numBlockParam <= 0 {
+			return nil, NewRPCError(ErrRPCInvalidParams, errors.New("number of blocks is invalid"))
+		}
+		numBlock = uint64(numBlockParam)
+	}
+
+	estimateFeeCoinPerKb := rpcServer.estimateFeeWithEstimator(defaultFeeCoinPerKb, shardIDSender, numBlock)
 	govFeePerKbTx := rpcServer.config.BlockChain.BestState.Beacon.StabilityInfo.GOVConstitution.GOVParams.FeePerKbTx
 
 	result := jsonresult.EstimateFeeResult{
 		EstimateFeeCoinPerKb: estimateFeeCoinPerKb,
 		GOVFeePerKbTx:        govFeePerKbTx,
 	}
+
+	// param #4: token ID of privacy custom token, fee paid in this token is estimated
+	if len(arrayParams) > 3 && arrayParams[3] != nil {
+		tokenIDParam, ok := arrayParams[3].(string)
+		if !ok {
+			return nil, NewRPCError(ErrRPCInvalidParams, errors.New("token ID is invalid"))
+		}
+		tokenID, err := common.Hash{}.NewHashFromStr(tokenIDParam)
+		if err != nil {
+			return nil, NewRPCError(ErrRPCInvalidParams, err)
+		}
+		result.TokenID = tokenID.String()
+		result.EstimateFeeTokenPerKb = rpcServer.estimateTokenFeeWithEstimator(tokenID, shardIDSender, numBlock)
+	}
 	return result, nil
 }
 
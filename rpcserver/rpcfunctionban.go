@@ -0,0 +1,106 @@
+package rpcserver
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/connmanager"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+/*
+handleListBanned - return peers which are banned for misbehavior or by setban
+*/
+func (rpcServer RpcServer) handleListBanned(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	result := jsonresult.ListBannedResult{
+		BannedPeers: []jsonresult.BannedPeerResult{},
+	}
+	for _, bannedPeer := range rpcServer.config.AddrMgr.BannedPeers() {
+		result.BannedPeers = append(result.BannedPeers, jsonresult.BannedPeerResult{
+			PeerID:      bannedPeer.PeerID,
+			PublicKey:   bannedPeer.PublicKey,
+			RawAddress:  bannedPeer.RawAddress,
+			Reason:      bannedPeer.Reason,
+			BannedSince: bannedPeer.Since.Unix(),
+			BannedUntil: bannedPeer.Until.Unix(),
+		})
+	}
+	return result, nil
+}
+
+/*
+handleSetBan - add or remove ban of a peer
+param #1: peer id
+param #2: "add" or "remove"
+param #3: ban time in seconds, optional, default is ban duration of node
+param #4: reason, optional
+*/
+func (rpcServer RpcServer) handleSetBan(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	arrayParams := common.InterfaceSlice(params)
+	if len(arrayParams) < 2 {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("peer id and command are required"))
+	}
+	peerID, ok := arrayParams[0].(string)
+	if !ok || peerID == common.EmptyString {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("peer id is invalid"))
+	}
+	command, ok := arrayParams[1].(string)
+	if !ok {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("command is invalid"))
+	}
+
+	switch command {
+	case "add":
+		duration := rpcServer.config.ConnMgr.Config.BanDuration
+		if duration <= 0 {
+			duration = connmanager.DefaultBanDuration
+		}
+		if len(arrayParams) > 2 {
+			banTime, ok := arrayParams[2].(float64)
+			if !ok || banTime <= 0 {
+				return nil, NewRPCError(ErrRPCInvalidParams, errors.New("ban time is invalid"))
+			}
+			duration = time.Duration(banTime) * time.Second
+		}
+		reason := "manually banned"
+		if len(arrayParams) > 3 {
+			reason, ok = arrayParams[3].(string)
+			if !ok {
+				return nil, NewRPCError(ErrRPCInvalidParams, errors.New("reason is invalid"))
+			}
+		}
+		// public key and address are taken from a connection or a known address of peer
+		publicKey, rawAddress := common.EmptyString, common.EmptyString
+		for _, peerConn := range rpcServer.config.ConnMgr.GetPeerConnOfAll() {
+			if peerConn.RemotePeerID.Pretty() == peerID {
+				publicKey, rawAddress = peerConn.RemotePeer.PublicKey, peerConn.RemoteRawAddress
+				break
+			}
+		}
+		if rawAddress == common.EmptyString {
+			for _, knownPeer := range rpcServer.config.AddrMgr.AddressCache() {
+				if knownPeer.PeerID.Pretty() == peerID {
+					publicKey, rawAddress = knownPeer.PublicKey, knownPeer.RawAddress
+					break
+				}
+			}
+		}
+		rpcServer.config.ConnMgr.BanPeer(peerID, publicKey, rawAddress, duration, reason)
+		return true, nil
+	case "remove":
+		if !rpcServer.config.ConnMgr.UnbanPeer(peerID) {
+			return nil, NewRPCError(ErrRPCInvalidParams, errors.New("peer is not banned"))
+		}
+		return true, nil
+	}
+	return nil, NewRPCError(ErrRPCInvalidParams, errors.New("command must be add or remove"))
+}
+
+/*
+handleClearBanned - remove all bans
+*/
+func (rpcServer RpcServer) handleClearBanned(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	rpcServer.config.AddrMgr.ClearBans()
+	return true, nil
+}
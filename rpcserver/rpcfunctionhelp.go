@@ -0,0 +1,97 @@
+package rpcserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+// openRPCVersion is version of OpenRPC specification which rpc.discover document follows
+const openRPCVersion = "1.2.6"
+
+/*
+handleHelp - return usage lines of all commands when no command is given,
+otherwise return usage, description, params and result of the command
+*/
+func (rpcServer RpcServer) handleHelp(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	arrayParams := common.InterfaceSlice(params)
+	if len(arrayParams) == 0 {
+		lines := []string{}
+		for _, method := range schemaMethods() {
+			lines = append(lines, rpcCommandSchemas[method].usage(method))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	method := arrayParams[0].(string)
+	schema, ok := rpcCommandSchemas[method]
+	if !ok {
+		return nil, invalidParamsError("unknown command %s", method)
+	}
+	return schema.help(method), nil
+}
+
+// handleListMethods - return sorted commands with their usage and description
+func (rpcServer RpcServer) handleListMethods(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	result := jsonresult.ListMethodsResult{Methods: []jsonresult.MethodResult{}}
+	for _, method := range schemaMethods() {
+		schema := rpcCommandSchemas[method]
+		_, websocket := wsHandlers[method]
+		result.Methods = append(result.Methods, jsonresult.MethodResult{
+			Name:        method,
+			Usage:       schema.usage(method),
+			Description: schema.Description,
+			Websocket:   websocket,
+		})
+	}
+	return result, nil
+}
+
+// handleRPCDiscover - return OpenRPC document which is generated from schemas of commands
+func (rpcServer RpcServer) handleRPCDiscover(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	result := jsonresult.OpenRPCResult{
+		OpenRPC: openRPCVersion,
+		Info: jsonresult.OpenRPCInfo{
+			Title:   "Constant RPC",
+			Version: RpcServerVersion,
+		},
+		Methods: []jsonresult.OpenRPCMethod{},
+	}
+	for _, method := range schemaMethods() {
+		schema := rpcCommandSchemas[method]
+		openRPCMethod := jsonresult.OpenRPCMethod{
+			Name:        method,
+			Description: schema.Description,
+			Params:      []jsonresult.OpenRPCContentDescriptor{},
+			Result: jsonresult.OpenRPCContentDescriptor{
+				Name:        fmt.Sprintf("%sResult", method),
+				Description: schema.Result,
+			},
+		}
+		for _, param := range schema.Params {
+			openRPCMethod.Params = append(openRPCMethod.Params, jsonresult.OpenRPCContentDescriptor{
+				Name:        param.Name,
+				Description: param.Description,
+				Required:    param.Required,
+				Schema:      param.openRPCSchema(),
+			})
+		}
+		result.Methods = append(result.Methods, openRPCMethod)
+	}
+	return result, nil
+}
+
+// openRPCSchema returns JSON schema of a param, a param of any type has no type
+func (param paramSchema) openRPCSchema() jsonresult.OpenRPCSchema {
+	schema := jsonresult.OpenRPCSchema{Enum: param.Enum}
+	if param.Type == paramTypeAny {
+		return schema
+	}
+	if param.Nullable {
+		schema.Type = []string{param.Type, "null"}
+	} else {
+		schema.Type = param.Type
+	}
+	return schema
+}
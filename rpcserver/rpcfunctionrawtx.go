@@ -0,0 +1,327 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// Names of checks of a transaction against current chain
+const (
+	checkTxVersion              = "CheckTxVersion"
+	checkTransactionFee         = "CheckTransactionFee"
+	checkType                   = "ValidateType"
+	checkSanityData             = "ValidateSanityData"
+	checkTxByItself             = "ValidateTxByItself"
+	checkMetadataWithBlockChain = "MetadataValidateTxWithBlockChain"
+	checkMetadataBeforeNewBlock = "MetadataValidateBeforeNewBlock"
+	checkTxWithBlockChain       = "ValidateTxWithBlockChain"
+	checkBuildReqActions        = "BuildReqActions"
+	checkBeaconInstructions     = "BuildBeaconInstructions"
+)
+
+/*
+decodeRawTransaction decodes base58 check data of a Tx, TxCustomToken or TxCustomTokenPrivacy,
+concrete type is chosen by Type field of transaction, metadata is parsed by metadata.ParseMetadata when it is unmarshalled
+*/
+func decodeRawTransaction(base58CheckData string) (metadata.Transaction, *RPCError) {
+	rawTxBytes, _, err := base58.Base58Check{}.Decode(base58CheckData)
+	if err != nil {
+		return nil, NewRPCError(ErrRPCParse, err)
+	}
+	var txType struct {
+		Type string
+	}
+	err = json.Unmarshal(rawTxBytes, &txType)
+	if err != nil {
+		return nil, NewRPCError(ErrRPCParse, err)
+	}
+
+	var tx metadata.Transaction
+	switch txType.Type {
+	case common.TxNormalType, common.TxSalaryType:
+		tx = &transaction.Tx{}
+	case common.TxCustomTokenType:
+		tx = &transaction.TxCustomToken{}
+	case common.TxCustomTokenPrivacyType:
+		tx = &transaction.TxCustomTokenPrivacy{}
+	default:
+		return nil, NewRPCError(ErrTxTypeInvalid, errors.New("unknown type of transaction: "+txType.Type))
+	}
+	err = json.Unmarshal(rawTxBytes, tx)
+	if err != nil {
+		return nil, NewRPCError(ErrRPCParse, err)
+	}
+	return tx, nil
+}
+
+/*
+handleDecodeRawTransaction - RPC decodes a transaction which is created by a create command
+and shows which checks of mempool it passes against current chain, it is not broadcasted
+*/
+func (rpcServer RpcServer) handleDecodeRawTransaction(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	arrayParams := common.InterfaceSlice(params)
+	tx, rpcErr := decodeRawTransaction(arrayParams[0].(string))
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
+	result := jsonresult.DecodeRawTransactionResult{
+		TxID:         tx.Hash().String(),
+		Type:         tx.GetType(),
+		LockTime:     tx.GetLockTime(),
+		Fee:          tx.GetTxFee(),
+		ShardID:      shardID,
+		IsPrivacy:    tx.IsPrivacy(),
+		MetadataType: tx.GetMetadataType(),
+		Metadata:     tx.GetMetadata(),
+		Checks:       rpcServer.checkRawTransaction(tx, shardID),
+	}
+	result.InputSerialNumbers, result.OutputCommitments, result.ProofSize = decodeProof(tx.GetProof())
+
+	switch tx := tx.(type) {
+	case *transaction.Tx:
+		result.Version = tx.Version
+	case *transaction.TxCustomToken:
+		result.Version = tx.Version
+	case *transaction.TxCustomTokenPrivacy:
+		result.Version = tx.Version
+	}
+	result.TokenData = decodeTokenData(tx)
+	return result, nil
+}
+
+// decodeTokenData returns token part of a custom token or privacy custom token transaction, it is nil for a constant transaction
+func decodeTokenData(tx metadata.Transaction) *jsonresult.DecodedTokenData {
+	switch tx := tx.(type) {
+	case *transaction.TxCustomToken:
+		tokenData := tx.TxTokenData
+		return &jsonresult.DecodedTokenData{
+			PropertyID:     tokenData.PropertyID.String(),
+			PropertyName:   tokenData.PropertyName,
+			PropertySymbol: tokenData.PropertySymbol,
+			Type:           tokenData.Type,
+			Mintable:       tokenData.Mintable,
+			Amount:         tokenData.Amount,
+			Vins:           len(tokenData.Vins),
+			Vouts:          len(tokenData.Vouts),
+		}
+	case *transaction.TxCustomTokenPrivacy:
+		tokenData := tx.TxTokenPrivacyData
+		result := &jsonresult.DecodedTokenData{
+			PropertyID:     tokenData.PropertyID.String(),
+			PropertyName:   tokenData.PropertyName,
+			PropertySymbol: tokenData.PropertySymbol,
+			Type:           tokenData.Type,
+			Mintable:       tokenData.Mintable,
+			Amount:         tokenData.Amount,
+		}
+		var proofSize jsonresult.DecodedProofSize
+		result.InputSerialNumbers, result.OutputCommitments, proofSize = decodeProof(tokenData.TxNormal.Proof)
+		result.ProofSize = &proofSize
+		return result
+	}
+	return nil
+}
+
+// decodeProof returns serial numbers of input coins, commitments of output coins and sizes of a payment proof, proof may be nil
+func decodeProof(proof *zkp.PaymentProof) ([]string, []string, jsonresult.DecodedProofSize) {
+	serialNumbers := []string{}
+	commitments := []string{}
+	size := jsonresult.DecodedProofSize{}
+	if proof == nil {
+		return serialNumbers, commitments, size
+	}
+
+	for _, inputCoin := range proof.InputCoins {
+		if inputCoin.CoinDetails != nil && inputCoin.CoinDetails.SerialNumber != nil {
+			serialNumbers = append(serialNumbers, base58.Base58Check{}.Encode(inputCoin.CoinDetails.SerialNumber.Compress(), common.ZeroByte))
+		}
+	}
+	for _, outputCoin := range proof.OutputCoins {
+		if outputCoin.CoinDetails != nil && outputCoin.CoinDetails.CoinCommitment != nil {
+			commitments = append(commitments, base58.Base58Check{}.Encode(outputCoin.CoinDetails.CoinCommitment.Compress(), common.ZeroByte))
+		}
+	}
+
+	size.Total = len(proof.Bytes())
+	for _, oneOfManyProof := range proof.OneOfManyProof {
+		size.OneOfMany += len(oneOfManyProof.Bytes())
+	}
+	for _, serialNumberProof := range proof.SerialNumberProof {
+		size.SerialNumber += len(serialNumberProof.Bytes())
+	}
+	for _, snNoPrivacyProof := range proof.SNNoPrivacyProof {
+		size.SNNoPrivacy += len(snNoPrivacyProof.Bytes())
+	}
+	if proof.AggregatedRangeProof != nil {
+		size.AggregatedRange = len(proof.AggregatedRangeProof.Bytes())
+	}
+	size.InputCoinsCount = len(proof.InputCoins)
+	size.OutputCoinsCount = len(proof.OutputCoins)
+	return serialNumbers, commitments, size
+}
+
+// txStage is a stage of validation of a transaction, it returns nil when transaction passes it
+type txStage struct {
+	name string
+	run  func() error
+}
+
+// runTxStages runs stages in order, stages after the first failed stage are skipped like mempool stops at it
+func runTxStages(stages []txStage) ([]jsonresult.TxCheckResult, bool) {
+	results := make([]jsonresult.TxCheckResult, 0, len(stages))
+	passed := true
+	for _, stage := range stages {
+		result := jsonresult.TxCheckResult{Name: stage.name}
+		if !passed {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+		if err := stage.run(); err != nil {
+			result.Error = err.Error()
+			passed = false
+		} else {
+			result.Passed = true
+		}
+		results = append(results, result)
+	}
+	return results, passed
+}
+
+// sanityStage runs ValidateSanityData of transaction against current chain
+func (rpcServer RpcServer) sanityStage(tx metadata.Transaction) txStage {
+	return txStage{name: checkSanityData, run: func() error {
+		ok, err := tx.ValidateSanityData(rpcServer.config.BlockChain)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("invalid sanity data")
+		}
+		return nil
+	}}
+}
+
+// byItselfStage runs ValidateTxByItself of transaction which verifies its proof and metadata against current chain
+func (rpcServer RpcServer) byItselfStage(tx metadata.Transaction, shardID byte) txStage {
+	return txStage{name: checkTxByItself, run: func() error {
+		if !tx.ValidateTxByItself(tx.IsPrivacy(), rpcServer.config.BlockChain.GetDatabase(), rpcServer.config.BlockChain, shardID) {
+			return errors.New("invalid tx")
+		}
+		return nil
+	}}
+}
+
+// checkRawTransaction runs checks of mempool which depend on transaction and current chain only, like mempool ValidateTxByItself is run after sanity check passes
+func (rpcServer RpcServer) checkRawTransaction(tx metadata.Transaction, shardID byte) []jsonresult.TxCheckResult {
+	results, _ := runTxStages([]txStage{
+		rpcServer.sanityStage(tx),
+		rpcServer.byItselfStage(tx, shardID),
+	})
+	return results
+}
+
+/*
+handleSimulateTransaction - RPC runs validation pipeline of mempool, shard and beacon on a transaction
+without adding it to mempool or broadcasting it: checks of transaction and its metadata against current chain,
+actions which shard would build from its metadata and instructions which beacon would build from them.
+Checks which depend on other transactions in mempool are not run
+*/
+func (rpcServer RpcServer) handleSimulateTransaction(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	arrayParams := common.InterfaceSlice(params)
+	tx, rpcErr := decodeRawTransaction(arrayParams[0].(string))
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
+	result := jsonresult.SimulateTransactionResult{
+		TxID:         tx.Hash().String(),
+		Type:         tx.GetType(),
+		ShardID:      shardID,
+		MetadataType: tx.GetMetadataType(),
+		Actions:      [][]string{},
+		Instructions: [][]string{},
+	}
+	bc := rpcServer.config.BlockChain
+	db := bc.GetDatabase()
+	stages := []txStage{
+		{name: checkTxVersion, run: func() error {
+			if !tx.CheckTxVersion(mempool.MaxVersion) {
+				return errors.New("invalid version of tx")
+			}
+			return nil
+		}},
+		{name: checkTransactionFee, run: func() error {
+			minFeePerKbTx := bc.GetFeePerKbTx()
+			if !tx.CheckTransactionFee(minFeePerKbTx) {
+				return fmt.Errorf("tx has %d fees which is under the required amount of %d", tx.GetTxFee(), minFeePerKbTx)
+			}
+			return nil
+		}},
+		{name: checkType, run: func() error {
+			if !tx.ValidateType() {
+				return errors.New("wrong tx type")
+			}
+			return nil
+		}},
+		rpcServer.sanityStage(tx),
+		rpcServer.byItselfStage(tx, shardID),
+	}
+	meta := tx.GetMetadata()
+	if meta != nil {
+		stages = append(stages,
+			txStage{name: checkMetadataWithBlockChain, run: func() error {
+				_, err := meta.ValidateTxWithBlockChain(tx, bc, shardID, db)
+				return err
+			}},
+			txStage{name: checkMetadataBeforeNewBlock, run: func() error {
+				if !meta.ValidateBeforeNewBlock(tx, bc, shardID) {
+					return errors.New("metadata is invalid for new block")
+				}
+				return nil
+			}},
+		)
+	}
+	stages = append(stages, txStage{name: checkTxWithBlockChain, run: func() error {
+		return tx.ValidateTxWithBlockChain(bc, shardID, db)
+	}})
+	if meta != nil {
+		stages = append(stages,
+			txStage{name: checkBuildReqActions, run: func() error {
+				actions, err := meta.BuildReqActions(tx, bc, shardID)
+				if err != nil {
+					return err
+				}
+				result.Actions = append(result.Actions, actions...)
+				return nil
+			}},
+			txStage{name: checkBeaconInstructions, run: func() error {
+				instructions, changes, err := bc.SimulateStabilityInstructions(shardID, result.Actions)
+				if err != nil {
+					return err
+				}
+				result.Instructions = append(result.Instructions, instructions...)
+				result.StateChanges.Stability = changes
+				return nil
+			}},
+		)
+	}
+	result.Stages, result.Accepted = runTxStages(stages)
+
+	result.StateChanges.Fee = tx.GetTxFee()
+	result.StateChanges.SpentSerialNumbers, result.StateChanges.NewCommitments, _ = decodeProof(tx.GetProof())
+	result.StateChanges.TokenData = decodeTokenData(tx)
+	return result, nil
+}
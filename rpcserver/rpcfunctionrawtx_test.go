@@ -0,0 +1,210 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+	"github.com/ninjadotorg/constant/transaction"
+)
+
+// encodeRawTestTx returns base58 check data of a transaction like create commands return it
+func encodeRawTestTx(t *testing.T, tx interface{}) string {
+	txBytes, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return base58.Base58Check{}.Encode(txBytes, common.ZeroByte)
+}
+
+func newRawTestTx(txType string) transaction.Tx {
+	return transaction.Tx{
+		Version:  1,
+		Type:     txType,
+		LockTime: 1000,
+		Fee:      5,
+		Metadata: &metadata.LoanWithdraw{LoanID: []byte("loan"), MetadataBase: *metadata.NewMetadataBase(metadata.LoanWithdrawMeta)},
+	}
+}
+
+func TestRunTxStages(t *testing.T) {
+	runs := []string{}
+	stage := func(name string, err error) txStage {
+		return txStage{name: name, run: func() error {
+			runs = append(runs, name)
+			return err
+		}}
+	}
+
+	results, passed := runTxStages([]txStage{stage("a", nil), stage("b", nil)})
+	if !passed || len(results) != 2 || !results[0].Passed || !results[1].Passed {
+		t.Errorf("expected all stages to pass, got %+v %v", results, passed)
+	}
+
+	runs = []string{}
+	results, passed = runTxStages([]txStage{
+		stage("a", nil),
+		stage("b", errors.New("invalid tx")),
+		stage("c", nil),
+		stage("d", nil),
+	})
+	if passed {
+		t.Errorf("expected stages to fail")
+	}
+	if len(runs) != 2 || runs[1] != "b" {
+		t.Errorf("expected stages after failed stage not to run, got %v", runs)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected result of every stage, got %+v", results)
+	}
+	if results[1].Name != "b" || results[1].Passed || results[1].Skipped || results[1].Error != "invalid tx" {
+		t.Errorf("unexpected result of failed stage %+v", results[1])
+	}
+	for _, result := range results[2:] {
+		if !result.Skipped || result.Passed || result.Error != "" {
+			t.Errorf("expected stage %s to be skipped, got %+v", result.Name, result)
+		}
+	}
+
+	if results, passed := runTxStages(nil); !passed || len(results) != 0 {
+		t.Errorf("expected empty stages to pass, got %+v %v", results, passed)
+	}
+}
+
+func TestSimulateTransactionInvalidParams(t *testing.T) {
+	initTestLogger()
+	rpcServer := RpcServer{}
+	_, rpcErr := rpcServer.handleSimulateTransaction([]interface{}{"abc"}, nil)
+	if rpcErr == nil || rpcErr.Code != ErrCodeMessage[ErrRPCParse].code {
+		t.Fatalf("expected invalid raw transaction to fail to parse, got %+v", rpcErr)
+	}
+}
+
+func TestDecodeRawTransaction(t *testing.T) {
+	normalTx := newRawTestTx(common.TxNormalType)
+	tokenTx := transaction.TxCustomToken{
+		Tx:          newRawTestTx(common.TxCustomTokenType),
+		TxTokenData: transaction.TxTokenData{PropertyName: "token", PropertySymbol: "TKN", Amount: 100},
+	}
+	privacyTokenTx := transaction.TxCustomTokenPrivacy{
+		Tx: newRawTestTx(common.TxCustomTokenPrivacyType),
+		TxTokenPrivacyData: transaction.TxTokenPrivacyData{
+			TxNormal:     transaction.Tx{Version: 1, Type: common.TxNormalType},
+			PropertyName: "privacy token",
+			Amount:       200,
+		},
+	}
+
+	tests := []struct {
+		tx           interface{}
+		txType       string
+		propertyName string
+	}{
+		{&normalTx, common.TxNormalType, ""},
+		{&tokenTx, common.TxCustomTokenType, "token"},
+		{&privacyTokenTx, common.TxCustomTokenPrivacyType, "privacy token"},
+	}
+	for _, test := range tests {
+		tx, rpcErr := decodeRawTransaction(encodeRawTestTx(t, test.tx))
+		if rpcErr != nil {
+			t.Fatalf("unexpected error %+v", rpcErr)
+		}
+		if tx.GetType() != test.txType || tx.GetLockTime() != 1000 || tx.GetTxFee() != 5 {
+			t.Errorf("unexpected decoded tx %+v", tx)
+		}
+
+		switch decoded := tx.(type) {
+		case *transaction.Tx:
+			if test.txType != common.TxNormalType {
+				t.Errorf("expected type %s to be decoded as Tx", test.txType)
+			}
+		case *transaction.TxCustomToken:
+			if test.txType != common.TxCustomTokenType || decoded.TxTokenData.PropertySymbol != "TKN" || decoded.TxTokenData.Amount != 100 {
+				t.Errorf("unexpected decoded custom token tx %+v", decoded)
+			}
+		case *transaction.TxCustomTokenPrivacy:
+			if test.txType != common.TxCustomTokenPrivacyType || decoded.TxTokenPrivacyData.Amount != 200 || decoded.TxTokenPrivacyData.TxNormal.Type != common.TxNormalType {
+				t.Errorf("unexpected decoded privacy custom token tx %+v", decoded)
+			}
+		default:
+			t.Errorf("unexpected type of decoded tx %T", tx)
+		}
+
+		// metadata is parsed to its concrete type
+		meta, ok := tx.GetMetadata().(*metadata.LoanWithdraw)
+		if !ok || tx.GetMetadataType() != metadata.LoanWithdrawMeta || string(meta.LoanID) != "loan" {
+			t.Errorf("unexpected metadata %+v", tx.GetMetadata())
+		}
+
+		tokenData := decodeTokenData(tx)
+		if (tokenData == nil) != (test.propertyName == "") || (tokenData != nil && tokenData.PropertyName != test.propertyName) {
+			t.Errorf("unexpected token data %+v", tokenData)
+		}
+	}
+
+	// salary tx is decoded as Tx
+	salaryTx := newRawTestTx(common.TxSalaryType)
+	salaryTx.Metadata = nil
+	tx, rpcErr := decodeRawTransaction(encodeRawTestTx(t, &salaryTx))
+	if rpcErr != nil {
+		t.Fatalf("unexpected error %+v", rpcErr)
+	}
+	if _, ok := tx.(*transaction.Tx); !ok || tx.GetMetadata() != nil {
+		t.Errorf("expected salary tx without metadata, got %+v", tx)
+	}
+}
+
+func TestDecodeRawTransactionInvalid(t *testing.T) {
+	initTestLogger()
+	unknownTx := newRawTestTx("x")
+	tests := []struct {
+		data string
+		code int
+	}{
+		{"abc", ErrCodeMessage[ErrRPCParse].code},
+		{base58.Base58Check{}.Encode([]byte("not json"), common.ZeroByte), ErrCodeMessage[ErrRPCParse].code},
+		{encodeRawTestTx(t, &unknownTx), ErrCodeMessage[ErrTxTypeInvalid].code},
+	}
+	rpcServer := RpcServer{}
+	for _, test := range tests {
+		if _, rpcErr := decodeRawTransaction(test.data); rpcErr == nil || rpcErr.Code != test.code {
+			t.Errorf("expected error code %d, got %+v", test.code, rpcErr)
+		}
+		if _, rpcErr := rpcServer.handleDecodeRawTransaction([]interface{}{test.data}, nil); rpcErr == nil || rpcErr.Code != test.code {
+			t.Errorf("expected error code %d of handler, got %+v", test.code, rpcErr)
+		}
+	}
+}
+
+func TestDecodeProof(t *testing.T) {
+	serialNumbers, commitments, size := decodeProof(nil)
+	if len(serialNumbers) != 0 || len(commitments) != 0 || size != (jsonresult.DecodedProofSize{}) {
+		t.Errorf("expected empty result of nil proof, got %v %v %+v", serialNumbers, commitments, size)
+	}
+
+	serialNumber := privacy.PedCom.G[1]
+	commitment := privacy.PedCom.G[2]
+	proof := &zkp.PaymentProof{
+		// coins without serial number or commitment are skipped
+		InputCoins:  []*privacy.InputCoin{{CoinDetails: &privacy.Coin{SerialNumber: serialNumber}}, {CoinDetails: &privacy.Coin{}}},
+		OutputCoins: []*privacy.OutputCoin{{CoinDetails: &privacy.Coin{CoinCommitment: commitment}}},
+	}
+	serialNumbers, commitments, size = decodeProof(proof)
+	expectedSerialNumber := base58.Base58Check{}.Encode(serialNumber.Compress(), common.ZeroByte)
+	if len(serialNumbers) != 1 || serialNumbers[0] != expectedSerialNumber {
+		t.Errorf("expected serial numbers [%s], got %v", expectedSerialNumber, serialNumbers)
+	}
+	expectedCommitment := base58.Base58Check{}.Encode(commitment.Compress(), common.ZeroByte)
+	if len(commitments) != 1 || commitments[0] != expectedCommitment {
+		t.Errorf("expected commitments [%s], got %v", expectedCommitment, commitments)
+	}
+	if size.Total != len(proof.Bytes()) || size.InputCoinsCount != 2 || size.OutputCoinsCount != 1 || size.OneOfMany != 0 || size.AggregatedRange != 0 {
+		t.Errorf("unexpected proof size %+v", size)
+	}
+}
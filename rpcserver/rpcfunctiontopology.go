@@ -0,0 +1,40 @@
+package rpcserver
+
+import (
+	"errors"
+
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+/*
+handleGetTopologyStatus - return connections of node against targets of its topology policy:
+beacon and same shard peers, min links to other shards, peers without shard and pinned peers
+*/
+func (rpcServer RpcServer) handleGetTopologyStatus(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	if rpcServer.config.ConnMgr == nil || rpcServer.config.ConnMgr.ListeningPeer == nil {
+		return nil, NewRPCError(ErrUnexpected, errors.New("Connection manager not init"))
+	}
+	status := rpcServer.config.ConnMgr.TopologyStatus()
+	result := jsonresult.GetTopologyStatusResult{
+		Role:         status.Role,
+		ShardID:      status.ShardID,
+		Beacon:       jsonresult.TopologyTargetResult{Target: status.Beacon.Target, Connected: status.Beacon.Connected},
+		SameShard:    jsonresult.TopologyTargetResult{Target: status.SameShard.Target, Connected: status.SameShard.Connected},
+		OtherShards:  make(map[byte]jsonresult.TopologyTargetResult),
+		NoShard:      jsonresult.TopologyTargetResult{Target: status.NoShard.Target, Connected: status.NoShard.Connected},
+		PinnedPeers:  []jsonresult.PinnedPeerResult{},
+		LastRotation: status.LastRotation.Unix(),
+		MeetsTargets: status.MeetsTargets,
+	}
+	for shardID, target := range status.OtherShards {
+		result.OtherShards[shardID] = jsonresult.TopologyTargetResult{Target: target.Target, Connected: target.Connected}
+	}
+	for _, pinned := range status.PinnedPeers {
+		result.PinnedPeers = append(result.PinnedPeers, jsonresult.PinnedPeerResult{
+			ShardID:    pinned.ShardID,
+			RawAddress: pinned.RawAddress,
+			Connected:  pinned.Connected,
+		})
+	}
+	return result, nil
+}
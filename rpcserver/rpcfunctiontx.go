@@ -207,6 +207,17 @@ func (rpcServer RpcServer) handleGetMempoolInfo(params interface{}, closeChan <-
 	result.Bytes = rpcServer.config.TxMemPool.Size()
 	result.MempoolMaxFee = rpcServer.config.TxMemPool.MaxFee()
 	result.ListTxs = rpcServer.config.TxMemPool.ListTxs()
+	result.OrphanSize = rpcServer.config.TxMemPool.OrphanCount()
+	result.ListOrphanTxs = rpcServer.config.TxMemPool.ListOrphanTxs()
+	return result, nil
+}
+
+/*
+handleGetMempoolPolicy - RPC returns rules of metadata types which are applied by mempool
+*/
+func (rpcServer RpcServer) handleGetMempoolPolicy(params interface{}, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	result := jsonresult.GetMempoolPolicyResult{}
+	result.Init(rpcServer.config.BlockChain.GetFeePerKbTx(), rpcServer.config.TxMemPool.MetadataPolicyRules())
 	return result, nil
 }
 
@@ -731,7 +742,7 @@ func (rpcServer RpcServer) handleHasSnDerivators(params interface{}, closeChan <
 	tokenID := &common.Hash{}
 	tokenID.SetBytes(common.ConstantID[:]) // default is constant
 	if len(arrayParams) > 2 {
-		tokenID, err = (common.Hash{}).NewHashFromStr(arrayParams[1].(string))
+		tokenID, err = (common.Hash{}).NewHashFromStr(arrayParams[2].(string))
 		if err != nil {
 			return nil, NewRPCError(ErrListCustomTokenNotFound, err)
 		}
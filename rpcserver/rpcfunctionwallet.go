@@ -151,6 +151,30 @@ func (rpcServer RpcServer) handleGetAllPeers(params interface{}, closeChan <-cha
 		}
 	}
 	result.Peers = peersMap
+
+	result.PeerStats = []jsonresult.PeerStatsResult{}
+	for _, peerConn := range rpcServer.config.ConnMgr.GetPeerConnOfAll() {
+		stats := peerConn.GetTrafficStats()
+		messages := make(map[string]jsonresult.MessageRateResult)
+		for command, rateStats := range peerConn.GetRateStats() {
+			messages[command] = jsonresult.MessageRateResult{
+				Received: rateStats.Received,
+				Dropped:  rateStats.Dropped,
+			}
+		}
+		result.PeerStats = append(result.PeerStats, jsonresult.PeerStatsResult{
+			PeerID:             peerConn.RemotePeerID.Pretty(),
+			RawAddress:         peerConn.RemoteRawAddress,
+			WireVersion:        peerConn.GetWireVersion(),
+			Compression:        peerConn.GetCompression(),
+			BytesSent:          stats.BytesSent,
+			BytesReceived:      stats.BytesReceived,
+			BytesSavedSent:     stats.BytesSavedSent,
+			BytesSavedReceived: stats.BytesSavedReceived,
+			BanScore:           peerConn.GetBanScore(),
+			Messages:           messages,
+		})
+	}
 	return result, nil
 }
 
@@ -0,0 +1,443 @@
+package rpcserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+const (
+	// restPrefix is path of REST gateway on RPC listeners
+	restPrefix = "/rest/"
+	// restDefaultLimit and restMaxLimit are default and max number of items of a page
+	restDefaultLimit = 20
+	restMaxLimit     = 100
+	// restImmutableMaxAge is max age in seconds of resources which do not change: blocks below best block,
+	// confirmed transactions and committees of past epochs
+	restImmutableMaxAge = 86400
+	// restVolatileMaxAge is max age in seconds of resources which change with new blocks
+	restVolatileMaxAge = 5
+)
+
+// restRequest is a GET request of REST gateway, params are named segments of path
+type restRequest struct {
+	params map[string]string
+	query  url.Values
+}
+
+// restHandler returns result of a request and max age in seconds which clients may cache it
+type restHandler func(rpcServer RpcServer, request *restRequest) (interface{}, int, *RPCError)
+
+// restRoute is a path of REST gateway, segments in braces are params, permission and rate limit of command apply to route
+type restRoute struct {
+	path    []string
+	command string
+	handler restHandler
+}
+
+var restRoutes = []restRoute{
+	{path: []string{"beacon", "blocks"}, command: GetBlocks, handler: RpcServer.restGetBeaconBlocks},
+	{path: []string{"beacon", "blocks", "{height}"}, command: RetrieveBeaconBlock, handler: RpcServer.restGetBeaconBlock},
+	{path: []string{"shards", "{id}", "blocks"}, command: GetBlocks, handler: RpcServer.restGetShardBlocks},
+	{path: []string{"shards", "{id}", "blocks", "{hash}"}, command: RetrieveBlock, handler: RpcServer.restGetShardBlock},
+	{path: []string{"tx", "{hash}"}, command: GetTransactionByHash, handler: RpcServer.restGetTransaction},
+	{path: []string{"tokens"}, command: ListCustomToken, handler: RpcServer.restGetTokens},
+	{path: []string{"committees"}, command: GetCommitteeList, handler: RpcServer.restGetCommittees},
+	{path: []string{"committees", "{epoch}"}, command: GetCommitteeList, handler: RpcServer.restGetCommittees},
+	{path: []string{"mempool"}, command: GetRawMempool, handler: RpcServer.restGetMempool},
+	{path: []string{"mempool", "{hash}"}, command: GetMempoolEntry, handler: RpcServer.restGetMempoolEntry},
+}
+
+// match returns params of path when it matches route
+func (route restRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(route.path) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range route.path {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+/*
+RestHandleRequest - serve read-only chain data under /rest/ of RPC listeners. Clients authenticate like RPC clients
+and each route is allowed and rate limited as the RPC command which returns the same data. Responses have an ETag
+and a Cache-Control header, which is private unless authentication is disabled, lists are paginated by offset and limit query params.
+*/
+func (rpcServer RpcServer) RestHandleRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+
+	if rpcServer.limitConnections(w, r.RemoteAddr) {
+		return
+	}
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		rpcServer.writeRestError(w, http.StatusMethodNotAllowed, NewRPCError(ErrRPCInvalidRequest, fmt.Errorf("method %s is not allowed", r.Method)))
+		return
+	}
+
+	rpcServer.IncrementClients()
+	defer rpcServer.DecrementClients()
+	client, err := rpcServer.checkAuth(r, true)
+	if err != nil || client == nil {
+		Logger.log.Error(err)
+		rpcServer.AuthFail(w)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, restPrefix), "/"), "/")
+	for _, route := range restRoutes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if rpcErr := rpcServer.authorizeCommand(client, route.command); rpcErr != nil {
+			rpcServer.writeRestError(w, restStatusCode(rpcErr), rpcErr)
+			return
+		}
+		result, maxAge, rpcErr := route.handler(rpcServer, &restRequest{params: params, query: r.URL.Query()})
+		if rpcErr != nil {
+			rpcServer.writeRestError(w, restStatusCode(rpcErr), rpcErr)
+			return
+		}
+		rpcServer.writeRestResult(w, r, result, maxAge, client.credential != credentialNoAuth)
+		return
+	}
+	rpcServer.writeRestError(w, http.StatusNotFound, NewRPCError(ErrResourceNotFound, fmt.Errorf("no route for %s", r.URL.Path)))
+}
+
+/*
+writeRestResult - write result with its ETag, it writes 304 Not Modified when client has the same ETag.
+Result of an authenticated request is private, so that shared caches do not serve it to clients without credentials
+*/
+func (rpcServer RpcServer) writeRestResult(w http.ResponseWriter, r *http.Request, result interface{}, maxAge int, private bool) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		rpcServer.writeRestError(w, http.StatusInternalServerError, NewRPCError(ErrUnexpected, err))
+		return
+	}
+	hash := sha256.Sum256(body)
+	etag := "\"" + hex.EncodeToString(hash[:16]) + "\""
+	w.Header().Set("ETag", etag)
+	cacheScope := "public"
+	if private {
+		cacheScope = "private"
+		w.Header().Set("Vary", "Authorization, X-Api-Key")
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", cacheScope, maxAge))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == "HEAD" {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		Logger.log.Errorf("Failed to write REST response: %s", err.Error())
+	}
+}
+
+func (rpcServer RpcServer) writeRestError(w http.ResponseWriter, statusCode int, rpcErr *RPCError) {
+	result := jsonresult.RestErrorResult{Code: rpcErr.Code, Message: rpcErr.Message}
+	if rpcErr.GetErr() != nil {
+		result.Detail = rpcErr.GetErr().Error()
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		Logger.log.Errorf("Failed to marshal REST error: %s", err.Error())
+		body = []byte{}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		Logger.log.Errorf("Failed to write REST response: %s", err.Error())
+	}
+}
+
+// restStatusCode returns HTTP status code of an error
+func restStatusCode(rpcErr *RPCError) int {
+	switch rpcErr.Code {
+	case ErrCodeMessage[ErrRPCInvalidParams].code:
+		return http.StatusBadRequest
+	case ErrCodeMessage[ErrResourceNotFound].code:
+		return http.StatusNotFound
+	case ErrCodeMessage[ErrRPCInvalidMethodPermission].code:
+		return http.StatusForbidden
+	case ErrCodeMessage[ErrRPCRateLimited].code:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// page returns offset and limit of request, items [offset, offset+limit) of a list are returned
+func (request *restRequest) page() (int, int, *RPCError) {
+	offset, limit := 0, restDefaultLimit
+	if value := request.query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, invalidParamsError("offset must be a non-negative integer, %q is given", value)
+		}
+		offset = parsed
+	}
+	if value := request.query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > restMaxLimit {
+			return 0, 0, invalidParamsError("limit must be an integer from 1 to %d, %q is given", restMaxLimit, value)
+		}
+		limit = parsed
+	}
+	return offset, limit, nil
+}
+
+// pageRange returns bounds of a page of a list of total items
+func pageRange(total, offset, limit int) (int, int) {
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return offset, end
+}
+
+func (request *restRequest) uintParam(name string) (uint64, *RPCError) {
+	value, err := strconv.ParseUint(request.params[name], 10, 64)
+	if err != nil {
+		return 0, invalidParamsError("%s must be a non-negative integer, %q is given", name, request.params[name])
+	}
+	return value, nil
+}
+
+func (request *restRequest) hashParam(name string) (*common.Hash, *RPCError) {
+	hash, err := common.Hash{}.NewHashFromStr(request.params[name])
+	if err != nil {
+		return nil, invalidParamsError("%s must be a hash, %q is given", name, request.params[name])
+	}
+	return hash, nil
+}
+
+// restGetBeaconBlocks - return a page of beacon blocks from best block down to genesis block
+func (rpcServer RpcServer) restGetBeaconBlocks(request *restRequest) (interface{}, int, *RPCError) {
+	offset, limit, rpcErr := request.page()
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	bestHeight := rpcServer.config.BlockChain.BestState.Beacon.BestBlock.Header.Height
+	start, end := pageRange(int(bestHeight), offset, limit)
+	blocks := make([]jsonresult.GetBlocksBeaconResult, 0, end-start)
+	for i := start; i < end; i++ {
+		block, err := rpcServer.config.BlockChain.GetBeaconBlockByHeight(bestHeight - uint64(i))
+		if err != nil {
+			return nil, 0, NewRPCError(ErrUnexpected, err)
+		}
+		blockResult := jsonresult.GetBlocksBeaconResult{}
+		blockResult.Init(block)
+		blocks = append(blocks, blockResult)
+	}
+	return jsonresult.RestPageResult{Total: int(bestHeight), Offset: offset, Limit: limit, Items: blocks}, restVolatileMaxAge, nil
+}
+
+// restGetBeaconBlock - return beacon block at a height, it is cached for long when it is not best block
+func (rpcServer RpcServer) restGetBeaconBlock(request *restRequest) (interface{}, int, *RPCError) {
+	height, rpcErr := request.uintParam("height")
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	bestHeight := rpcServer.config.BlockChain.BestState.Beacon.BestBlock.Header.Height
+	if height == 0 || height > bestHeight {
+		return nil, 0, NewRPCError(ErrResourceNotFound, fmt.Errorf("beacon block at height %d does not exist, best height is %d", height, bestHeight))
+	}
+	block, err := rpcServer.config.BlockChain.GetBeaconBlockByHeight(height)
+	if err != nil {
+		return nil, 0, NewRPCError(ErrResourceNotFound, err)
+	}
+	result := jsonresult.GetBlocksBeaconResult{}
+	result.Init(block)
+	if height < bestHeight {
+		return result, restImmutableMaxAge, nil
+	}
+	return result, restVolatileMaxAge, nil
+}
+
+// restGetShardBlocks - return a page of blocks of a shard from best block down to genesis block
+func (rpcServer RpcServer) restGetShardBlocks(request *restRequest) (interface{}, int, *RPCError) {
+	shardID, rpcErr := rpcServer.restShardID(request)
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	offset, limit, rpcErr := request.page()
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	bestHeight := rpcServer.config.BlockChain.BestState.Shard[shardID].BestBlock.Header.Height
+	start, end := pageRange(int(bestHeight), offset, limit)
+	blocks := make([]jsonresult.GetBlockResult, 0, end-start)
+	for i := start; i < end; i++ {
+		block, err := rpcServer.config.BlockChain.GetShardBlockByHeight(bestHeight-uint64(i), shardID)
+		if err != nil {
+			return nil, 0, NewRPCError(ErrUnexpected, err)
+		}
+		blockResult := jsonresult.GetBlockResult{}
+		blockResult.Init(block)
+		blocks = append(blocks, blockResult)
+	}
+	return jsonresult.RestPageResult{Total: int(bestHeight), Offset: offset, Limit: limit, Items: blocks}, restVolatileMaxAge, nil
+}
+
+// restGetShardBlock - return block of a shard by its hash, content of a block never changes
+func (rpcServer RpcServer) restGetShardBlock(request *restRequest) (interface{}, int, *RPCError) {
+	shardID, rpcErr := rpcServer.restShardID(request)
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	hash, rpcErr := request.hashParam("hash")
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	block, err := rpcServer.config.BlockChain.GetShardBlockByHash(hash)
+	if err != nil {
+		return nil, 0, NewRPCError(ErrResourceNotFound, err)
+	}
+	if block.Header.ShardID != shardID {
+		return nil, 0, NewRPCError(ErrResourceNotFound, fmt.Errorf("block %s is a block of shard %d", hash.String(), block.Header.ShardID))
+	}
+	result := jsonresult.GetBlockResult{}
+	result.Init(block)
+	return result, restImmutableMaxAge, nil
+}
+
+func (rpcServer RpcServer) restShardID(request *restRequest) (byte, *RPCError) {
+	id, err := strconv.Atoi(request.params["id"])
+	if err != nil || id < 0 || id > 255 {
+		return 0, invalidParamsError("id of shard must be an integer from 0 to 255, %q is given", request.params["id"])
+	}
+	if _, ok := rpcServer.config.BlockChain.BestState.Shard[byte(id)]; !ok {
+		return 0, NewRPCError(ErrResourceNotFound, fmt.Errorf("shard %d does not exist", id))
+	}
+	return byte(id), nil
+}
+
+// restGetTransaction - return a transaction in chain, a transaction in mempool is returned by /mempool/{hash}
+func (rpcServer RpcServer) restGetTransaction(request *restRequest) (interface{}, int, *RPCError) {
+	hash, rpcErr := request.hashParam("hash")
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	if _, _, _, _, err := rpcServer.config.BlockChain.GetTransactionByHash(hash); err != nil {
+		if _, err := rpcServer.config.TxMemPool.GetTx(hash); err == nil {
+			return nil, 0, NewRPCError(ErrResourceNotFound, fmt.Errorf("transaction %s is in mempool, see %smempool/%s", hash.String(), restPrefix, hash.String()))
+		}
+		return nil, 0, NewRPCError(ErrResourceNotFound, err)
+	}
+	result, rpcErr := rpcServer.handleGetTransactionByHash([]interface{}{hash.String()}, nil)
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	return result, restImmutableMaxAge, nil
+}
+
+// restGetTokens - return a page of custom tokens and privacy custom tokens which are sorted by id
+func (rpcServer RpcServer) restGetTokens(request *restRequest) (interface{}, int, *RPCError) {
+	offset, limit, rpcErr := request.page()
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	customTokens, err := rpcServer.config.BlockChain.ListCustomToken()
+	if err != nil {
+		return nil, 0, NewRPCError(ErrUnexpected, err)
+	}
+	privacyCustomTokens, err := rpcServer.config.BlockChain.ListPrivacyCustomToken()
+	if err != nil {
+		return nil, 0, NewRPCError(ErrUnexpected, err)
+	}
+	tokens := make([]jsonresult.CustomToken, 0, len(customTokens)+len(privacyCustomTokens))
+	for _, token := range customTokens {
+		item := jsonresult.CustomToken{}
+		item.Init(token)
+		tokens = append(tokens, item)
+	}
+	for _, token := range privacyCustomTokens {
+		item := jsonresult.CustomToken{}
+		item.InitPrivacy(token)
+		tokens = append(tokens, item)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	start, end := pageRange(len(tokens), offset, limit)
+	return jsonresult.RestPageResult{Total: len(tokens), Offset: offset, Limit: limit, Items: tokens[start:end]}, restVolatileMaxAge, nil
+}
+
+// restGetCommittees - return committees of current epoch or shard committees of a past epoch
+func (rpcServer RpcServer) restGetCommittees(request *restRequest) (interface{}, int, *RPCError) {
+	currentEpoch := rpcServer.config.BlockChain.BestState.Beacon.Epoch
+	epoch := currentEpoch
+	if _, ok := request.params["epoch"]; ok {
+		var rpcErr *RPCError
+		epoch, rpcErr = request.uintParam("epoch")
+		if rpcErr != nil {
+			return nil, 0, rpcErr
+		}
+	}
+	if epoch == currentEpoch {
+		result, rpcErr := rpcServer.handleGetCommitteeList(nil, nil)
+		return result, restVolatileMaxAge, rpcErr
+	}
+	if epoch > currentEpoch {
+		return nil, 0, NewRPCError(ErrResourceNotFound, fmt.Errorf("epoch %d has not started, current epoch is %d", epoch, currentEpoch))
+	}
+	shardCommittee, err := rpcServer.config.BlockChain.GetShardCommitteeByEpoch(epoch)
+	if err != nil {
+		return nil, 0, NewRPCError(ErrResourceNotFound, err)
+	}
+	return jsonresult.CommitteeListsResult{Epoch: epoch, ShardCommittee: shardCommittee}, restImmutableMaxAge, nil
+}
+
+// restGetMempool - return a page of hashes of transactions in mempool which are sorted
+func (rpcServer RpcServer) restGetMempool(request *restRequest) (interface{}, int, *RPCError) {
+	offset, limit, rpcErr := request.page()
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	txHashes := rpcServer.config.TxMemPool.ListTxs()
+	sort.Strings(txHashes)
+	start, end := pageRange(len(txHashes), offset, limit)
+	return jsonresult.RestPageResult{Total: len(txHashes), Offset: offset, Limit: limit, Items: txHashes[start:end]}, restVolatileMaxAge, nil
+}
+
+// restGetMempoolEntry - return a transaction in mempool, it is not cached because it leaves mempool when it is included in a block
+func (rpcServer RpcServer) restGetMempoolEntry(request *restRequest) (interface{}, int, *RPCError) {
+	hash, rpcErr := request.hashParam("hash")
+	if rpcErr != nil {
+		return nil, 0, rpcErr
+	}
+	tx, err := rpcServer.config.TxMemPool.GetTx(hash)
+	if err != nil {
+		return nil, 0, NewRPCError(ErrResourceNotFound, errors.New("transaction is not in mempool"))
+	}
+	return jsonresult.GetMempoolEntryResult{Tx: tx}, 0, nil
+}
@@ -0,0 +1,144 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+func newTestRestServer(t *testing.T, config RpcServerConfig) *RpcServer {
+	initTestLogger()
+	config.RPCMaxClients = 10
+	config.TxMemPool = &mempool.TxPool{}
+	rpcServer := &RpcServer{config: config}
+	if err := rpcServer.initAccess(&config); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return rpcServer
+}
+
+func doRestRequest(rpcServer *RpcServer, method string, target string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	rpcServer.RestHandleRequest(w, r)
+	return w
+}
+
+func TestRestRouteMatch(t *testing.T) {
+	route := restRoute{path: []string{"shards", "{id}", "blocks", "{hash}"}}
+	params, ok := route.match([]string{"shards", "1", "blocks", "abc"})
+	if !ok || params["id"] != "1" || params["hash"] != "abc" {
+		t.Errorf("unexpected params %+v %v", params, ok)
+	}
+	if _, ok := route.match([]string{"shards", "1", "blocks"}); ok {
+		t.Errorf("expected shorter path not to match")
+	}
+	if _, ok := route.match([]string{"beacon", "1", "blocks", "abc"}); ok {
+		t.Errorf("expected other path not to match")
+	}
+}
+
+func TestRestPage(t *testing.T) {
+	tests := []struct {
+		query  string
+		offset int
+		limit  int
+		valid  bool
+	}{
+		{"", 0, restDefaultLimit, true},
+		{"offset=40&limit=10", 40, 10, true},
+		{"limit=100", 0, 100, true},
+		{"limit=101", 0, 0, false},
+		{"limit=0", 0, 0, false},
+		{"offset=-1", 0, 0, false},
+		{"offset=a", 0, 0, false},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		offset, limit, rpcErr := (&restRequest{query: query}).page()
+		if (rpcErr == nil) != test.valid {
+			t.Errorf("query %q: expected valid %v, got %+v", test.query, test.valid, rpcErr)
+			continue
+		}
+		if test.valid && (offset != test.offset || limit != test.limit) {
+			t.Errorf("query %q: expected page %d %d, got %d %d", test.query, test.offset, test.limit, offset, limit)
+		}
+	}
+
+	ranges := []struct{ total, offset, limit, start, end int }{
+		{50, 0, 20, 0, 20},
+		{50, 40, 20, 40, 50},
+		{50, 60, 20, 50, 50},
+		{0, 0, 20, 0, 0},
+	}
+	for _, r := range ranges {
+		if start, end := pageRange(r.total, r.offset, r.limit); start != r.start || end != r.end {
+			t.Errorf("range of %+v: got %d %d", r, start, end)
+		}
+	}
+}
+
+func TestRestCacheHeaders(t *testing.T) {
+	rpcServer := newTestRestServer(t, RpcServerConfig{RPCAPIKeys: []string{"readonly:" + testAPIKeyHash("reader")}})
+
+	w := doRestRequest(rpcServer, "GET", "/rest/mempool?limit=5", map[string]string{"X-Api-Key": "reader"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %s", w.Code, w.Body.String())
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "private, max-age=5" {
+		t.Errorf("expected private response of authenticated request, got %q", cacheControl)
+	}
+	if w.Header().Get("Vary") == "" {
+		t.Errorf("expected Vary header of authenticated request")
+	}
+	var page jsonresult.RestPageResult
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if page.Total != 0 || page.Offset != 0 || page.Limit != 5 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	// same ETag is not modified
+	etag := w.Header().Get("ETag")
+	w = doRestRequest(rpcServer, "GET", "/rest/mempool?limit=5", map[string]string{"X-Api-Key": "reader", "If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 without body, got %d %q", w.Code, w.Body.String())
+	}
+
+	// errors are not cached
+	w = doRestRequest(rpcServer, "GET", "/rest/mempool?limit=500", map[string]string{"X-Api-Key": "reader"})
+	if w.Code != http.StatusBadRequest || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected 400 which is not stored, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	w = doRestRequest(rpcServer, "GET", "/rest/unknown", map[string]string{"X-Api-Key": "reader"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 of unknown route, got %d", w.Code)
+	}
+	w = doRestRequest(rpcServer, "GET", "/rest/mempool", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", w.Code)
+	}
+	w = doRestRequest(rpcServer, "POST", "/rest/mempool", map[string]string{"X-Api-Key": "reader"})
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 of POST, got %d", w.Code)
+	}
+
+	// responses are public only when authentication is disabled
+	rpcServer = newTestRestServer(t, RpcServerConfig{DisableAuth: true})
+	w = doRestRequest(rpcServer, "HEAD", "/rest/mempool", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected HEAD without body, got %d %q", w.Code, w.Body.String())
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "public, max-age=5" {
+		t.Errorf("expected public response without authentication, got %q", cacheControl)
+	}
+}
@@ -0,0 +1,294 @@
+package rpcserver
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of params of commands, they are JSON types which handlers receive after params are converted
+const (
+	paramTypeString  = "string"
+	paramTypeInteger = "integer" // JSON number without fraction
+	paramTypeNumber  = "number"
+	paramTypeBoolean = "boolean"
+	paramTypeObject  = "object"
+	paramTypeArray   = "array"
+	paramTypeAny     = "any"
+)
+
+// paramSchema describes a positional param of a command
+type paramSchema struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Nullable    bool     // null is a valid value
+	Variadic    bool     // last param which is repeated until end of params
+	Enum        []string // valid values of a string param
+}
+
+// commandSchema describes params and result of a command, it is used to validate and convert params,
+// to generate help and to generate OpenRPC document
+type commandSchema struct {
+	Description string
+	Params      []paramSchema
+	// SingleParam is true when handler receives value of its only param as params instead of an array
+	SingleParam bool
+	Result      string
+}
+
+func requiredParam(name, paramType, description string) paramSchema {
+	return paramSchema{Name: name, Type: paramType, Description: description, Required: true}
+}
+
+func optionalParam(name, paramType, description string) paramSchema {
+	return paramSchema{Name: name, Type: paramType, Description: description}
+}
+
+/*
+validateParams - check params of a request against schema of its command and convert them into
+the positional array which handler expects. Params can be an array, an object whose keys are names of params
+or a single value for commands of a single param. Numbers are converted from numeric strings and booleans,
+strings from numbers, and booleans from "true" and "false". Commands without schema receive params as they are.
+*/
+func validateParams(method string, params interface{}) (interface{}, *RPCError) {
+	schema, ok := rpcCommandSchemas[method]
+	if !ok {
+		return params, nil
+	}
+	var values []interface{}
+	switch typedParams := params.(type) {
+	case nil:
+		values = []interface{}{}
+	case []interface{}:
+		values = typedParams
+	case map[string]interface{}:
+		var rpcErr *RPCError
+		values, rpcErr = schema.positionalParams(method, typedParams)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+	default:
+		if !schema.SingleParam {
+			return nil, invalidParamsError("params of %s must be an array or an object", method)
+		}
+		values = []interface{}{params}
+	}
+
+	maxParams := len(schema.Params)
+	variadic := maxParams > 0 && schema.Params[maxParams-1].Variadic
+	if !variadic && len(values) > maxParams {
+		return nil, invalidParamsError("%s takes at most %d params, %d are given", method, maxParams, len(values))
+	}
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		param := schema.Params[len(schema.Params)-1]
+		if i < len(schema.Params) {
+			param = schema.Params[i]
+		}
+		convertedValue, err := param.convert(value)
+		if err != nil {
+			return nil, invalidParamsError("param #%d %s of %s %s", i+1, param.Name, method, err.Error())
+		}
+		converted[i] = convertedValue
+	}
+	for i := len(values); i < maxParams; i++ {
+		if schema.Params[i].Required && !schema.Params[i].Variadic {
+			return nil, invalidParamsError("param #%d %s of %s is required", i+1, schema.Params[i].Name, method)
+		}
+	}
+
+	if schema.SingleParam {
+		if len(converted) == 0 {
+			return nil, nil
+		}
+		return converted[0], nil
+	}
+	return converted, nil
+}
+
+// positionalParams orders params which are given by name, names are case insensitive,
+// an optional param which is missing before a given param is null when it is nullable
+func (schema *commandSchema) positionalParams(method string, namedParams map[string]interface{}) ([]interface{}, *RPCError) {
+	values := make([]interface{}, len(schema.Params))
+	given := make([]bool, len(schema.Params))
+	for name, value := range namedParams {
+		found := false
+		for i, param := range schema.Params {
+			if strings.EqualFold(param.Name, name) {
+				if param.Variadic {
+					return nil, invalidParamsError("param %s of %s can only be given by position", param.Name, method)
+				}
+				values[i] = value
+				given[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, invalidParamsError("%s has no param %s", method, name)
+		}
+	}
+	last := -1
+	for i := range given {
+		if given[i] {
+			last = i
+		}
+	}
+	for i := 0; i < last; i++ {
+		if !given[i] && !schema.Params[i].Nullable {
+			return nil, invalidParamsError("param #%d %s of %s is required when param %s is given", i+1, schema.Params[i].Name, method, schema.Params[last].Name)
+		}
+	}
+	return values[:last+1], nil
+}
+
+// convert checks type of a value and converts it to the JSON type of param
+func (param paramSchema) convert(value interface{}) (interface{}, error) {
+	if value == nil {
+		if param.Nullable || param.Type == paramTypeAny {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("must not be null")
+	}
+	switch param.Type {
+	case paramTypeString:
+		switch typedValue := value.(type) {
+		case string:
+			return param.checkEnum(typedValue)
+		case float64:
+			return param.checkEnum(strconv.FormatFloat(typedValue, 'f', -1, 64))
+		}
+	case paramTypeInteger, paramTypeNumber:
+		var number float64
+		switch typedValue := value.(type) {
+		case float64:
+			number = typedValue
+		case string:
+			parsed, err := strconv.ParseFloat(typedValue, 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a %s, %q is not a number", param.Type, typedValue)
+			}
+			number = parsed
+		case bool:
+			if typedValue {
+				number = 1
+			}
+		default:
+			return nil, fmt.Errorf("must be a %s", param.Type)
+		}
+		if param.Type == paramTypeInteger && number != math.Trunc(number) {
+			return nil, fmt.Errorf("must be an integer, %v has a fraction", number)
+		}
+		return number, nil
+	case paramTypeBoolean:
+		switch typedValue := value.(type) {
+		case bool:
+			return typedValue, nil
+		case string:
+			parsed, err := strconv.ParseBool(typedValue)
+			if err == nil {
+				return parsed, nil
+			}
+		}
+	case paramTypeObject:
+		if _, ok := value.(map[string]interface{}); ok {
+			return value, nil
+		}
+	case paramTypeArray:
+		if _, ok := value.([]interface{}); ok {
+			return value, nil
+		}
+	case paramTypeAny:
+		return value, nil
+	}
+	return nil, fmt.Errorf("must be a %s", param.Type)
+}
+
+func (param paramSchema) checkEnum(value string) (interface{}, error) {
+	if len(param.Enum) == 0 {
+		return value, nil
+	}
+	for _, enumValue := range param.Enum {
+		if value == enumValue {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("must be one of %s, %q is given", strings.Join(param.Enum, ", "), value)
+}
+
+func invalidParamsError(format string, args ...interface{}) *RPCError {
+	return NewRPCError(ErrRPCInvalidParams, fmt.Errorf(format, args...))
+}
+
+// checkCommandSchemas logs commands which have no schema, their params are not validated
+func checkCommandSchemas() {
+	for _, commands := range []map[string]commandHandler{RpcHandler, RpcLimited} {
+		for method := range commands {
+			if _, ok := rpcCommandSchemas[method]; !ok {
+				Logger.log.Warnf("RPC command %s has no param schema", method)
+			}
+		}
+	}
+}
+
+// schemaMethods returns sorted names of commands which have schemas
+func schemaMethods() []string {
+	methods := make([]string, 0, len(rpcCommandSchemas))
+	for method := range rpcCommandSchemas {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// usage returns a line of method and its params, optional params are in brackets
+func (schema *commandSchema) usage(method string) string {
+	usage := method
+	for _, param := range schema.Params {
+		name := param.Name
+		if param.Variadic {
+			name += " ..."
+		}
+		if param.Required {
+			usage += " " + name
+		} else {
+			usage += " [" + name + "]"
+		}
+	}
+	return usage
+}
+
+// help returns help text of a command: its usage, description, params and result
+func (schema *commandSchema) help(method string) string {
+	lines := []string{schema.usage(method), "", schema.Description}
+	if len(schema.Params) > 0 {
+		lines = append(lines, "", "Arguments:")
+		for i, param := range schema.Params {
+			attributes := []string{param.Type}
+			if param.Required {
+				attributes = append(attributes, "required")
+			} else {
+				attributes = append(attributes, "optional")
+			}
+			if param.Nullable {
+				attributes = append(attributes, "nullable")
+			}
+			if param.Variadic {
+				attributes = append(attributes, "repeated")
+			}
+			line := fmt.Sprintf("%d. %s (%s) %s", i+1, param.Name, strings.Join(attributes, ", "), param.Description)
+			if len(param.Enum) > 0 {
+				line += fmt.Sprintf(", one of: %s", strings.Join(param.Enum, ", "))
+			}
+			lines = append(lines, line)
+		}
+	}
+	if schema.Result != "" {
+		lines = append(lines, "", "Result:", schema.Result)
+	}
+	return strings.Join(lines, "\n")
+}
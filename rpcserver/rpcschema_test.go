@@ -0,0 +1,186 @@
+package rpcserver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+)
+
+const testSchemaMethod = "testschema"
+
+// addTestSchema registers schema of a command which exists only in tests, the returned function removes it
+func addTestSchema() func() {
+	rpcCommandSchemas[testSchemaMethod] = &commandSchema{
+		Description: "Test command.",
+		Params: []paramSchema{
+			requiredParam("Name", paramTypeString, "name"),
+			{Name: "Receivers", Type: paramTypeObject, Required: true, Nullable: true, Description: "receivers"},
+			optionalParam("Amount", paramTypeInteger, "amount"),
+			optionalParam("Rate", paramTypeNumber, "rate"),
+			optionalParam("Privacy", paramTypeBoolean, "privacy"),
+		},
+	}
+	return func() {
+		delete(rpcCommandSchemas, testSchemaMethod)
+	}
+}
+
+func TestCommandsHaveSchemas(t *testing.T) {
+	for _, commands := range []map[string]commandHandler{RpcHandler, RpcLimited} {
+		for method := range commands {
+			if _, ok := rpcCommandSchemas[method]; !ok {
+				t.Errorf("command %s has no param schema", method)
+			}
+		}
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	defer addTestSchema()()
+	tests := []struct {
+		params   interface{}
+		expected interface{}
+		valid    bool
+	}{
+		// positional params
+		{[]interface{}{"a", nil}, []interface{}{"a", nil}, true},
+		{[]interface{}{"a", map[string]interface{}{}, 1.0, 0.5, true}, []interface{}{"a", map[string]interface{}{}, 1.0, 0.5, true}, true},
+		{nil, nil, false},
+		{[]interface{}{"a"}, nil, false},
+		{[]interface{}{"a", nil, 1.0, 0.5, true, "extra"}, nil, false},
+		{"a", nil, false},
+		// named params, names are case insensitive
+		{map[string]interface{}{"name": "a", "RECEIVERS": nil}, []interface{}{"a", nil}, true},
+		{map[string]interface{}{"Name": "a", "Receivers": nil, "Amount": "3"}, []interface{}{"a", nil, 3.0}, true},
+		{map[string]interface{}{"Name": "a", "Unknown": 1.0}, nil, false},
+		// nullable param before a given param is null, a param which is not nullable must be given
+		{map[string]interface{}{"Name": "a", "Amount": 3.0}, []interface{}{"a", nil, 3.0}, true},
+		{map[string]interface{}{"Receivers": nil}, nil, false},
+		{map[string]interface{}{"Name": "a", "Receivers": nil, "Rate": 1.0}, nil, false},
+		// conversion of numbers and booleans
+		{[]interface{}{"a", nil, "12", "0.25", "true"}, []interface{}{"a", nil, 12.0, 0.25, true}, true},
+		{[]interface{}{12.0, nil, true}, []interface{}{"12", nil, 1.0}, true},
+		{[]interface{}{"a", nil, "abc"}, nil, false},
+		{[]interface{}{"a", nil, 1.5}, nil, false},
+		{[]interface{}{"a", nil, "1.5"}, nil, false},
+		{[]interface{}{"a", nil, 1.0, 1.5}, []interface{}{"a", nil, 1.0, 1.5}, true},
+		{[]interface{}{"a", nil, 1.0, 1.0, "maybe"}, nil, false},
+		{[]interface{}{nil, nil}, nil, false},
+		{[]interface{}{"a", "receivers"}, nil, false},
+	}
+	for i, test := range tests {
+		params, rpcErr := validateParams(testSchemaMethod, test.params)
+		if (rpcErr == nil) != test.valid {
+			t.Errorf("test %d: expected valid %v, got %+v", i, test.valid, rpcErr)
+			continue
+		}
+		if !test.valid {
+			if rpcErr.Code != ErrCodeMessage[ErrRPCInvalidParams].code {
+				t.Errorf("test %d: expected invalid params error, got %+v", i, rpcErr)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(params, test.expected) {
+			t.Errorf("test %d: expected params %+v, got %+v", i, test.expected, params)
+		}
+	}
+}
+
+func TestValidateParamsOfCommands(t *testing.T) {
+	tests := []struct {
+		method   string
+		params   interface{}
+		expected interface{}
+		valid    bool
+	}{
+		// single param is given as it is, in an array or by name
+		{GetMempoolEntry, "abc", "abc", true},
+		{GetMempoolEntry, []interface{}{"abc"}, "abc", true},
+		{GetMempoolEntry, map[string]interface{}{"txid": "abc"}, "abc", true},
+		{GetMempoolEntry, nil, nil, false},
+		{GetMempoolEntry, []interface{}{"abc", "def"}, nil, false},
+		// variadic params are repeated, they are only given by position
+		{GetLoanPaymentInfo, nil, []interface{}{}, true},
+		{GetLoanPaymentInfo, []interface{}{"a", "b", "c"}, []interface{}{"a", "b", "c"}, true},
+		{GetLoanPaymentInfo, []interface{}{"a", 1.0}, []interface{}{"a", "1"}, true},
+		{GetLoanPaymentInfo, []interface{}{"a", true}, nil, false},
+		{GetLoanPaymentInfo, map[string]interface{}{"LoanID": "a"}, nil, false},
+		// enum
+		{SetBan, []interface{}{"peer", "add"}, []interface{}{"peer", "add"}, true},
+		{SetBan, []interface{}{"peer", "ban"}, nil, false},
+		{RetrieveBlock, []interface{}{"hash", 2.0}, []interface{}{"hash", "2"}, true},
+		{RetrieveBlock, []interface{}{"hash", 3.0}, nil, false},
+		// numeric strings are numbers
+		{GetBlockCount, []interface{}{"-1"}, []interface{}{-1.0}, true},
+		// commands without params take no params
+		{GetBestBlock, []interface{}{1.0}, nil, false},
+		// commands without schema receive params as they are
+		{"unknowncommand", "abc", "abc", true},
+	}
+	for _, test := range tests {
+		params, rpcErr := validateParams(test.method, test.params)
+		if (rpcErr == nil) != test.valid {
+			t.Errorf("%s %+v: expected valid %v, got %+v", test.method, test.params, test.valid, rpcErr)
+			continue
+		}
+		if test.valid && !reflect.DeepEqual(params, test.expected) {
+			t.Errorf("%s %+v: expected params %+v, got %+v", test.method, test.params, test.expected, params)
+		}
+	}
+}
+
+func TestHandleHelp(t *testing.T) {
+	rpcServer := RpcServer{}
+	result, rpcErr := rpcServer.handleHelp([]interface{}{}, nil)
+	if rpcErr != nil {
+		t.Fatalf("unexpected error %+v", rpcErr)
+	}
+	lines := strings.Split(result.(string), "\n")
+	if len(lines) != len(rpcCommandSchemas) {
+		t.Errorf("expected usage line of each command, got %d lines", len(lines))
+	}
+
+	result, rpcErr = rpcServer.handleHelp([]interface{}{SetBan}, nil)
+	if rpcErr != nil {
+		t.Fatalf("unexpected error %+v", rpcErr)
+	}
+	help := result.(string)
+	if !strings.HasPrefix(help, SetBan+" PeerID Command [BanTime] [Reason]\n") || !strings.Contains(help, "one of: add, remove") {
+		t.Errorf("unexpected help %s", help)
+	}
+	if _, rpcErr := rpcServer.handleHelp([]interface{}{"unknowncommand"}, nil); rpcErr == nil {
+		t.Errorf("expected help of unknown command to fail")
+	}
+}
+
+func TestHandleRPCDiscover(t *testing.T) {
+	result, rpcErr := (RpcServer{}).handleRPCDiscover(nil, nil)
+	if rpcErr != nil {
+		t.Fatalf("unexpected error %+v", rpcErr)
+	}
+	document := result.(jsonresult.OpenRPCResult)
+	if document.OpenRPC != openRPCVersion || len(document.Methods) != len(rpcCommandSchemas) {
+		t.Fatalf("unexpected document %+v", document)
+	}
+	var estimateFee *jsonresult.OpenRPCMethod
+	for i := range document.Methods {
+		if i > 0 && document.Methods[i-1].Name >= document.Methods[i].Name {
+			t.Errorf("expected methods to be sorted, got %s before %s", document.Methods[i-1].Name, document.Methods[i].Name)
+		}
+		if document.Methods[i].Name == EstimateFee {
+			estimateFee = &document.Methods[i]
+		}
+	}
+	if estimateFee == nil || len(estimateFee.Params) != 5 {
+		t.Fatalf("expected method %s with 5 params, got %+v", EstimateFee, estimateFee)
+	}
+	receivers := estimateFee.Params[1]
+	if receivers.Name != "Receivers" || !receivers.Required || !reflect.DeepEqual(receivers.Schema.Type, []string{paramTypeObject, "null"}) {
+		t.Errorf("unexpected nullable param %+v", receivers)
+	}
+	if fee := estimateFee.Params[2]; fee.Schema.Type != paramTypeInteger {
+		t.Errorf("unexpected param %+v", fee)
+	}
+}
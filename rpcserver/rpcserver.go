@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,6 +22,7 @@ import (
 	"github.com/ninjadotorg/constant/connmanager"
 	"github.com/ninjadotorg/constant/database"
 	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/metrics"
 	"github.com/ninjadotorg/constant/wallet"
 	"github.com/ninjadotorg/constant/wire"
 )
@@ -55,6 +55,15 @@ type RpcServer struct {
 	authSHA      [sha256.Size]byte
 	limitAuthSHA [sha256.Size]byte
 
+	// access control: roles and their API keys, rate limiters of credentials and audit log of state changing commands
+	roles        map[string]*rpcRole
+	apiKeys      []rpcAPIKey
+	rateLimiters map[string]*rateLimiter
+	auditLog     *rpcAuditLog
+
+	// wsManager pushes notifications to websocket clients, it is nil when websockets are disabled
+	wsManager *wsNotificationManager
+
 	// channel
 	cRequestProcessShutdown chan struct{}
 }
@@ -76,8 +85,15 @@ type RpcServerConfig struct {
 	}
 
 	TxMemPool     *mempool.TxPool
+	BlockGen      *blockchain.BlkTmplGenerator
 	RPCMaxClients int
 	RPCQuirks     bool
+	// RPCMaxWebsockets is max number of websocket clients, websockets are disabled when it is 0
+	RPCMaxWebsockets int
+	// RPCWSOrigins are origins which browsers may open websockets from besides origin of RPC listener
+	RPCWSOrigins []string
+	// RPCRest serves REST gateway of read-only chain data under /rest/
+	RPCRest bool
 
 	// Authentication
 	RPCUser      string
@@ -85,13 +101,21 @@ type RpcServerConfig struct {
 	RPCLimitUser string
 	RPCLimitPass string
 	DisableAuth  bool
+	// RPCAPIKeys are API keys of roles in format role:sha256 hex of key
+	RPCAPIKeys []string
+	// RPCRoleMethods replace allowed commands of roles in format role:command1,command2 or role:*
+	RPCRoleMethods []string
+	// RPCRateLimits limit requests of each client of roles in format role:requests per second:burst
+	RPCRateLimits []string
+	// RPCAuditLog is file which calls of state changing commands are appended to, they are logged when it is empty
+	RPCAuditLog string
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator map[byte]*mempool.FeeEstimator
 }
 
-func (rpcServer *RpcServer) Init(config *RpcServerConfig) {
+func (rpcServer *RpcServer) Init(config *RpcServerConfig) error {
 	rpcServer.config = *config
 	rpcServer.statusLines = make(map[int]string)
 	if config.RPCUser != "" && config.RPCPass != "" {
@@ -104,6 +128,11 @@ func (rpcServer *RpcServer) Init(config *RpcServerConfig) {
 		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
 		rpcServer.limitAuthSHA = sha256.Sum256([]byte(auth))
 	}
+	if config.RPCMaxWebsockets > 0 {
+		rpcServer.wsManager = newWsNotificationManager()
+	}
+	checkCommandSchemas()
+	return rpcServer.initAccess(config)
 }
 
 // RequestedProcessShutdown returns a channel that is sent to when an authorized
@@ -146,6 +175,17 @@ func (rpcServer *RpcServer) Start() error {
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		rpcServer.RpcHandleRequest(w, r)
 	})
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.Start()
+		rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+			rpcServer.WebsocketHandler(w, r)
+		})
+	}
+	if rpcServer.config.RPCRest {
+		rpcServeMux.HandleFunc(restPrefix, func(w http.ResponseWriter, r *http.Request) {
+			rpcServer.RestHandleRequest(w, r)
+		})
+	}
 	for _, listen := range rpcServer.config.Listenters {
 		go func(listen net.Listener) {
 			Logger.log.Infof("RPC server listening on %s", listen.Addr())
@@ -166,6 +206,12 @@ func (rpcServer RpcServer) Stop() {
 	if rpcServer.started != 0 {
 		rpcServer.httpServer.Close()
 	}
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.Stop()
+	}
+	if rpcServer.auditLog != nil {
+		rpcServer.auditLog.Close()
+	}
 	for _, listen := range rpcServer.config.Listenters {
 		listen.Close()
 	}
@@ -199,40 +245,47 @@ func (rpcServer RpcServer) RpcHandleRequest(w http.ResponseWriter, r *http.Reque
 	rpcServer.IncrementClients()
 	defer rpcServer.DecrementClients()
 	// Check authentication for rpc user
-	ok, isLimitUser, err := rpcServer.checkAuth(r, true)
-	if err != nil || !ok {
+	client, err := rpcServer.checkAuth(r, true)
+	if err != nil || client == nil {
 		Logger.log.Error(err)
 		rpcServer.AuthFail(w)
 		return
 	}
 
-	rpcServer.ProcessRpcRequest(w, r, isLimitUser)
+	rpcServer.ProcessRpcRequest(w, r, client)
 }
 
-// checkAuth checks the HTTP Basic authentication supplied by a wallet
+// checkAuth checks the API key or the HTTP Basic authentication supplied by a wallet
 // or RPC client in the HTTP request r.  If the supplied authentication
-// does not match the username and password expected, a non-nil error is
-// returned.
+// does not match an API key or the username and password expected, a non-nil
+// error is returned.
 //
 // This check is time-constant.
 //
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (rpcServer RpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
+// The returned client has the role of the credential: the role of the API key,
+// admin for the limited user which can call all commands, or user for the
+// rpcuser which can call all commands except commands of RpcLimited. It is nil
+// if authentication is not supplied or fails.
+func (rpcServer RpcServer) checkAuth(r *http.Request, require bool) (*rpcClient, error) {
 	if rpcServer.config.DisableAuth {
-		return true, true, nil
+		return &rpcClient{role: rpcServer.roles[RoleAdmin], credential: credentialNoAuth, remoteAddr: r.RemoteAddr}, nil
+	}
+	if apiKey := apiKeyOfRequest(r); apiKey != "" {
+		if client := rpcServer.checkAPIKey(apiKey, r.RemoteAddr); client != nil {
+			return client, nil
+		}
+		Logger.log.Warnf("RPC authentication failure from %s", r.RemoteAddr)
+		return nil, NewRPCError(ErrAuthFail, nil)
 	}
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
 		if require {
 			Logger.log.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
+		return nil, nil
 	}
 
 	authsha := sha256.Sum256([]byte(authhdr[0]))
@@ -241,18 +294,18 @@ func (rpcServer RpcServer) checkAuth(r *http.Request, require bool) (bool, bool,
 	// are probably expected to have a higher volume of calls
 	limitcmp := subtle.ConstantTimeCompare(authsha[:], rpcServer.limitAuthSHA[:])
 	if limitcmp == 1 {
-		return true, true, nil
+		return &rpcClient{role: rpcServer.roles[RoleAdmin], credential: credentialRPCLimitUser, remoteAddr: r.RemoteAddr}, nil
 	}
 
 	// Check for admin-level auth
 	cmp := subtle.ConstantTimeCompare(authsha[:], rpcServer.authSHA[:])
 	if cmp == 1 {
-		return true, false, nil
+		return &rpcClient{role: rpcServer.roles[RoleUser], credential: credentialRPCUser, remoteAddr: r.RemoteAddr}, nil
 	}
 
 	// RpcRequest's auth doesn't match either user
 	Logger.log.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, NewRPCError(ErrAuthFail, nil)
+	return nil, NewRPCError(ErrAuthFail, nil)
 }
 
 // IncrementClients adds one to the number of connected RPC clients.  Note
@@ -280,7 +333,7 @@ func (rpcServer RpcServer) AuthFail(w http.ResponseWriter) {
 /*
 handles reading and responding to RPC messages.
 */
-func (rpcServer RpcServer) ProcessRpcRequest(w http.ResponseWriter, r *http.Request, isLimitedUser bool) {
+func (rpcServer RpcServer) ProcessRpcRequest(w http.ResponseWriter, r *http.Request, client *rpcClient) {
 	if atomic.LoadInt32(&rpcServer.shutdown) != 0 {
 		return
 	}
@@ -321,87 +374,32 @@ func (rpcServer RpcServer) ProcessRpcRequest(w http.ResponseWriter, r *http.Requ
 	defer buf.Flush()
 	conn.SetReadDeadline(timeZeroVal)
 
-	// Attempt to parse the raw body into a JSON-RPC request.
-	var responseID interface{}
-	var jsonErr error
-	var result interface{}
-	var request RpcRequest
-	if err := json.Unmarshal(body, &request); err != nil {
-		jsonErr = NewRPCError(ErrRPCParse, err)
-	}
-
-	if jsonErr == nil {
-		// The JSON-RPC 1.0 spec defines that notifications must have their "id"
-		// set to null and states that notifications do not have a response.
-		//
-		// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
-		// without an "id" member. The specification states that notifications
-		// must not be responded to. JSON-RPC 2.0 permits the null value as a
-		// valid request id, therefore such requests are not notifications.
-		//
-		// coin Core serves requests with "id":null or even an absent "id",
-		// and responds to such requests with "id":null in the response.
-		//
-		// Rpc does not respond to any request without and "id" or "id":null,
-		// regardless the indicated JSON-RPC protocol version unless RPC quirks
-		// are enabled. With RPC quirks enabled, such requests will be responded
-		// to if the reqeust does not indicate JSON-RPC version.
-		//
-		// RPC quirks can be enabled by the user to avoid compatibility issues
-		// with software relying on Core's behavior.
-		if request.Id == nil && !(rpcServer.config.RPCQuirks && request.Jsonrpc == "") {
-			return
+	// Setup a close notifier.  Since the connection is hijacked,
+	// the CloseNotifer on the ResponseWriter is not available.
+	closeChan := make(chan struct{}, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		if err != nil {
+			close(closeChan)
 		}
-
-		// The parse was at least successful enough to have an Id so
-		// set it for the response.
-		responseID = request.Id
-
-		// Setup a close notifier.  Since the connection is hijacked,
-		// the CloseNotifer on the ResponseWriter is not available.
-		closeChan := make(chan struct{}, 1)
-		go func() {
-			_, err := conn.Read(make([]byte, 1))
-			if err != nil {
-				close(closeChan)
-			}
-		}()
-
-		// Check if the user is limited and set error if method unauthorized
-		if !isLimitedUser {
-			if function, ok := RpcLimited[request.Method]; ok {
-				_ = function
-				jsonErr = NewRPCError(ErrRPCInvalidMethodPermission, errors.New(""))
-			}
+	}()
+
+	// Parse the raw body into a JSON-RPC request or a batch of requests and execute them.
+	msg := rpcServer.processRequestBody(body, func(request RpcRequest) (interface{}, *RPCError) {
+		result, jsonErr := rpcServer.executeCommand(request, client, closeChan)
+		if jsonErr != nil {
+			// Logger.log.Errorf("RPC function process with err \n %+v", jsonErr)
+			log.Printf("RPC function process with err \n %+v", jsonErr)
 		}
-		if jsonErr == nil {
-			// Attempt to parse the JSON-RPC request into a known concrete
-			// command.
-			command := RpcHandler[request.Method]
-			if command == nil {
-				if isLimitedUser {
-					command = RpcLimited[request.Method]
-				} else {
-					result = nil
-					jsonErr = NewRPCError(ErrRPCMethodNotFound, nil)
-				}
-			}
-			if command != nil {
-				result, jsonErr = command(rpcServer, request.Params, closeChan)
-			} else {
-				jsonErr = NewRPCError(ErrRPCMethodNotFound, nil)
-			}
+		return result, jsonErr
+	})
+
+	// Notifications are not responded to, client only receives headers.
+	if msg == nil {
+		err = rpcServer.writeHTTPResponseHeaders(r, w.Header(), http.StatusNoContent, buf)
+		if err != nil {
+			Logger.log.Error(err)
 		}
-	}
-	if jsonErr.(*RPCError) != nil && r.Method != "OPTIONS" {
-		// Logger.log.Errorf("RPC function process with err \n %+v", jsonErr)
-		log.Printf("RPC function process with err \n %+v", jsonErr)
-	}
-	// Marshal the response.
-	msg, err := rpcServer.createMarshalledReply(responseID, result, jsonErr)
-	if err != nil {
-		Logger.log.Errorf("Failed to marshal reply: %s", err.Error())
-		Logger.log.Error(err)
 		return
 	}
 
@@ -423,6 +421,43 @@ func (rpcServer RpcServer) ProcessRpcRequest(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// executeCommand runs the command of a request for a http or websocket client
+// when role of client allows it, calls of state changing commands are audited whether they are allowed or denied
+func (rpcServer RpcServer) executeCommand(request RpcRequest, client *rpcClient, closeChan <-chan struct{}) (interface{}, *RPCError) {
+	// Attempt to parse the JSON-RPC request into a known concrete
+	// command.
+	command := RpcHandler[request.Method]
+	if command == nil {
+		command = RpcLimited[request.Method]
+	}
+	if command == nil {
+		return nil, NewRPCError(ErrRPCMethodNotFound, nil)
+	}
+	// Check if role of client allows method and client is not over its rate limit
+	if rpcErr := rpcServer.authorizeCommand(client, request.Method); rpcErr != nil {
+		rpcServer.auditCommand(client, request.Method, rpcErr)
+		return nil, rpcErr
+	}
+	// Check params against schema of method and convert them into the positional params which handler expects
+	params, rpcErr := validateParams(request.Method, request.Params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	start := time.Now()
+	result, rpcErr := command(rpcServer, params, closeChan)
+	observeCommand(request.Method, start, rpcErr)
+	rpcServer.auditCommand(client, request.Method, rpcErr)
+	return result, rpcErr
+}
+
+// observeCommand records latency of a command of known method and whether it failed
+func observeCommand(method string, start time.Time, rpcErr *RPCError) {
+	metrics.ObserveSince(metrics.RPCRequestDuration.WithLabelValues(method), start)
+	if rpcErr != nil {
+		metrics.RPCRequestErrors.WithLabelValues(method).Inc()
+	}
+}
+
 // createMarshalledReply returns a new marshalled JSON-RPC response given the
 // passed parameters.  It will automatically convert errors that are not of
 // the type *btcjson.RPCError to the appropriate type as needed.
@@ -0,0 +1,785 @@
+package rpcserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/rpcserver/jsonresult"
+	"github.com/ninjadotorg/constant/wallet"
+)
+
+const (
+	// websocketSendBufferSize is the number of messages which are queued for a client,
+	// a client which does not read its messages is disconnected when its queue is full
+	websocketSendBufferSize = 256
+	// websocketMaxQueuedEvents is the number of chain and mempool events which wait for notificationHandler,
+	// the oldest events are dropped when it is full
+	websocketMaxQueuedEvents = 1000
+	websocketWriteWait       = 10 * time.Second
+	websocketPongWait        = 60 * time.Second
+	websocketPingInterval    = websocketPongWait / 2
+	// maxSubscriptionsPerClient caps subscriptions of a websocket client
+	maxSubscriptionsPerClient = 100
+	// maxKeysPerSubscription caps keys of a subscription of transactions by key
+	maxKeysPerSubscription = 100
+)
+
+type wsCommandHandler func(*wsClient, interface{}) (interface{}, *RPCError)
+
+// Commands which are only valid for websocket clients, other commands are served by RpcHandler and RpcLimited
+var wsHandlers = map[string]wsCommandHandler{
+	SubscribeBeaconBlocks:      (*wsClient).handleSubscribeBeaconBlocks,
+	SubscribeShardBlocks:       (*wsClient).handleSubscribeShardBlocks,
+	SubscribeMempool:           (*wsClient).handleSubscribeMempool,
+	SubscribeTransactionsByKey: (*wsClient).handleSubscribeTransactionsByKey,
+	SubscribeCommittees:        (*wsClient).handleSubscribeCommittees,
+	Unsubscribe:                (*wsClient).handleUnsubscribe,
+}
+
+// wsNotification is a JSON-RPC notification which is pushed to a websocket client, it has no id
+type wsNotification struct {
+	Jsonrpc string                              `json:"Jsonrpc"`
+	Method  string                              `json:"Method"`
+	Params  jsonresult.SubscriptionNotification `json:"Params"`
+}
+
+// wsNotificationV2 is a JSON-RPC 2.0 notification, it is pushed to subscriptions which are created by 2.0 requests
+type wsNotificationV2 struct {
+	Jsonrpc string                 `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  wsNotificationParamsV2 `json:"params"`
+}
+
+type wsNotificationParamsV2 struct {
+	Subscription uint64      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// events which are queued by chain and mempool hooks
+type (
+	beaconBlockEvent struct {
+		block *blockchain.BeaconBlock
+	}
+	shardBlockEvent struct {
+		block *blockchain.ShardBlock
+	}
+	mempoolTxEvent struct {
+		tx    metadata.Transaction
+		event string
+	}
+	committeeEvent struct {
+		epoch           uint64
+		beaconCommittee []string
+		shardCommittee  map[byte][]string
+	}
+)
+
+const (
+	mempoolTxAdded   = "Added"
+	mempoolTxRemoved = "Removed"
+	keyTxInMempool   = "mempool"
+	keyTxInBlock     = "block"
+)
+
+// wsSubscription is a subscription of a websocket client, shards filters blocks and txs of shards, nil is all shards
+type wsSubscription struct {
+	id      uint64
+	method  string // notification method
+	jsonrpc string // version of notifications
+	shards  map[byte]bool
+	keys    []wsKey
+}
+
+// wsKey is a key of a subscription of transactions by key
+type wsKey struct {
+	serialized  string
+	publicKey   []byte
+	readonlyKey *privacy.ViewingKey // amounts received are decrypted when key is a readonly key
+}
+
+/*
+wsNotificationManager - keep websocket clients and push notifications of chain and mempool to their subscriptions.
+Hooks of chain and mempool are called with their locks held, so they never wait for clients,
+events are queued up to websocketMaxQueuedEvents and are processed by notificationHandler.
+*/
+type wsNotificationManager struct {
+	clients    map[*wsClient]struct{}
+	numClients int32
+	clientsMtx sync.Mutex
+
+	queue         chan interface{}
+	notifications chan interface{}
+	quit          chan struct{}
+	state         int32 // 0 is not started, 1 is running and 2 is stopped
+}
+
+func newWsNotificationManager() *wsNotificationManager {
+	return &wsNotificationManager{
+		clients:       make(map[*wsClient]struct{}),
+		queue:         make(chan interface{}),
+		notifications: make(chan interface{}),
+		quit:          make(chan struct{}),
+	}
+}
+
+func (manager *wsNotificationManager) Start() {
+	if !atomic.CompareAndSwapInt32(&manager.state, 0, 1) {
+		return
+	}
+	go queueHandler(manager.queue, manager.notifications, websocketMaxQueuedEvents, manager.quit)
+	go manager.notificationHandler()
+}
+
+func (manager *wsNotificationManager) Stop() {
+	if !atomic.CompareAndSwapInt32(&manager.state, 1, 2) {
+		return
+	}
+	close(manager.quit)
+	manager.clientsMtx.Lock()
+	clients := make([]*wsClient, 0, len(manager.clients))
+	for client := range manager.clients {
+		clients = append(clients, client)
+	}
+	manager.clientsMtx.Unlock()
+	for _, client := range clients {
+		client.Disconnect()
+	}
+}
+
+// queueHandler moves events of in to out in order, it keeps max events which are not read yet and drops the oldest ones
+func queueHandler(in <-chan interface{}, out chan<- interface{}, max int, quit <-chan struct{}) {
+	pending := []interface{}{}
+	dropped := 0
+	for {
+		var next interface{}
+		var sendChan chan<- interface{}
+		if len(pending) > 0 {
+			next = pending[0]
+			sendChan = out
+		}
+		select {
+		case event := <-in:
+			if len(pending) >= max {
+				pending[0] = nil
+				pending = pending[1:]
+				dropped++
+				if dropped%max == 1 {
+					Logger.log.Warnf("Websocket notifications fall behind, %d events are dropped", dropped)
+				}
+			}
+			pending = append(pending, event)
+		case sendChan <- next:
+			pending[0] = nil
+			pending = pending[1:]
+		case <-quit:
+			return
+		}
+	}
+}
+
+// enqueue queues an event, events are dropped when manager is not running or has no clients
+func (manager *wsNotificationManager) enqueue(event interface{}) {
+	if atomic.LoadInt32(&manager.state) != 1 || atomic.LoadInt32(&manager.numClients) == 0 {
+		return
+	}
+	select {
+	case manager.queue <- event:
+	case <-manager.quit:
+	}
+}
+
+func (manager *wsNotificationManager) addClient(client *wsClient) {
+	manager.clientsMtx.Lock()
+	defer manager.clientsMtx.Unlock()
+	manager.clients[client] = struct{}{}
+	atomic.StoreInt32(&manager.numClients, int32(len(manager.clients)))
+}
+
+func (manager *wsNotificationManager) removeClient(client *wsClient) {
+	manager.clientsMtx.Lock()
+	defer manager.clientsMtx.Unlock()
+	delete(manager.clients, client)
+	atomic.StoreInt32(&manager.numClients, int32(len(manager.clients)))
+}
+
+func (manager *wsNotificationManager) getClients() []*wsClient {
+	manager.clientsMtx.Lock()
+	defer manager.clientsMtx.Unlock()
+	clients := make([]*wsClient, 0, len(manager.clients))
+	for client := range manager.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func (manager *wsNotificationManager) notificationHandler() {
+	for {
+		select {
+		case event := <-manager.notifications:
+			switch event := event.(type) {
+			case *beaconBlockEvent:
+				manager.notifyBeaconBlock(event.block)
+			case *shardBlockEvent:
+				manager.notifyShardBlock(event.block)
+			case *mempoolTxEvent:
+				manager.notifyMempoolTx(event.tx, event.event)
+			case *committeeEvent:
+				manager.notifyCommittee(event)
+			}
+		case <-manager.quit:
+			return
+		}
+	}
+}
+
+func (manager *wsNotificationManager) notifyBeaconBlock(block *blockchain.BeaconBlock) {
+	result := jsonresult.BeaconBlockNotification{
+		Hash:          block.Hash().String(),
+		Height:        block.Header.Height,
+		Epoch:         block.Header.Epoch,
+		Round:         block.Header.Round,
+		Producer:      block.Header.Producer,
+		Time:          block.Header.Timestamp,
+		PrevBlockHash: block.Header.PrevBlockHash.String(),
+	}
+	for _, client := range manager.getClients() {
+		for _, subscription := range client.subscriptionsOf(BeaconBlockNotification) {
+			client.notify(subscription, result)
+		}
+	}
+}
+
+func (manager *wsNotificationManager) notifyShardBlock(block *blockchain.ShardBlock) {
+	shardID := block.Header.ShardID
+	result := jsonresult.ShardBlockNotification{
+		Hash:          block.Hash().String(),
+		ShardID:       shardID,
+		Height:        block.Header.Height,
+		BeaconHeight:  block.Header.BeaconHeight,
+		Epoch:         block.Header.Epoch,
+		Round:         block.Header.Round,
+		Producer:      block.Header.Producer,
+		Time:          block.Header.Timestamp,
+		PrevBlockHash: block.Header.PrevBlockHash.String(),
+		TxHashes:      []string{},
+	}
+	for _, tx := range block.Body.Transactions {
+		result.TxHashes = append(result.TxHashes, tx.Hash().String())
+	}
+	for _, client := range manager.getClients() {
+		for _, subscription := range client.subscriptionsOf(ShardBlockNotification) {
+			if subscription.matchShard(shardID) {
+				client.notify(subscription, result)
+			}
+		}
+		// txs of subscribed keys are confirmed
+		for _, subscription := range client.subscriptionsOf(KeyTxNotification) {
+			for _, tx := range block.Body.Transactions {
+				for _, keyResult := range subscription.matchTx(tx, shardID) {
+					keyResult.Status = keyTxInBlock
+					keyResult.BlockHash = result.Hash
+					keyResult.BlockHeight = result.Height
+					client.notify(subscription, keyResult)
+				}
+			}
+		}
+	}
+}
+
+func (manager *wsNotificationManager) notifyMempoolTx(tx metadata.Transaction, event string) {
+	shardID := common.GetShardIDFromLastByte(tx.GetSenderAddrLastByte())
+	result := jsonresult.MempoolTxNotification{
+		TxID:    tx.Hash().String(),
+		ShardID: shardID,
+		Type:    tx.GetType(),
+		Fee:     tx.GetTxFee(),
+		Event:   event,
+	}
+	for _, client := range manager.getClients() {
+		for _, subscription := range client.subscriptionsOf(MempoolTxNotification) {
+			if subscription.matchShard(shardID) {
+				client.notify(subscription, result)
+			}
+		}
+		if event != mempoolTxAdded {
+			continue
+		}
+		for _, subscription := range client.subscriptionsOf(KeyTxNotification) {
+			for _, keyResult := range subscription.matchTx(tx, shardID) {
+				keyResult.Status = keyTxInMempool
+				client.notify(subscription, keyResult)
+			}
+		}
+	}
+}
+
+func (manager *wsNotificationManager) notifyCommittee(event *committeeEvent) {
+	result := jsonresult.CommitteeNotification{
+		Epoch:           event.epoch,
+		BeaconCommittee: event.beaconCommittee,
+		ShardCommittee:  event.shardCommittee,
+	}
+	for _, client := range manager.getClients() {
+		for _, subscription := range client.subscriptionsOf(CommitteeNotification) {
+			client.notify(subscription, result)
+		}
+	}
+}
+
+func (subscription *wsSubscription) matchShard(shardID byte) bool {
+	return subscription.shards == nil || subscription.shards[shardID]
+}
+
+// matchTx returns a notification for each key of subscription which sends or receives tx
+func (subscription *wsSubscription) matchTx(tx metadata.Transaction, shardID byte) []jsonresult.KeyTxNotification {
+	results := []jsonresult.KeyTxNotification{}
+	var outputCoins []*privacy.OutputCoin
+	if proof := tx.GetProof(); proof != nil {
+		outputCoins = proof.OutputCoins
+	}
+	for _, key := range subscription.keys {
+		result := jsonresult.KeyTxNotification{
+			Key:      key.serialized,
+			TxID:     tx.Hash().String(),
+			ShardID:  shardID,
+			IsSender: bytes.Equal(tx.GetSigPubKey(), key.publicKey),
+		}
+		for _, coin := range outputCoins {
+			if coin.CoinDetails == nil || coin.CoinDetails.PublicKey == nil || !bytes.Equal(coin.CoinDetails.PublicKey.Compress(), key.publicKey) {
+				continue
+			}
+			result.IsReceiver = true
+			if coin.CoinDetailsEncrypted == nil {
+				result.Received += coin.CoinDetails.Value
+				continue
+			}
+			if key.readonlyKey != nil {
+				// coin of tx is copied since decrypting sets value and randomness of coin
+				details := *coin.CoinDetails
+				decrypted := &privacy.OutputCoin{
+					CoinDetails:          &details,
+					CoinDetailsEncrypted: coin.CoinDetailsEncrypted,
+				}
+				if err := decrypted.Decrypt(*key.readonlyKey); err == nil {
+					result.Received += details.Value
+				}
+			}
+		}
+		if result.IsSender || result.IsReceiver {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// wsClient is a websocket connection of a RPC client
+type wsClient struct {
+	rpcServer  RpcServer
+	conn       *websocket.Conn
+	remoteAddr string
+	auth       *rpcClient // role and credential of client
+
+	subscriptions      map[uint64]*wsSubscription
+	nextSubscriptionID uint64
+	mtx                sync.Mutex
+
+	send      chan []byte
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// jsonrpc is version of request which is being executed, requests of a client are executed one by one by inHandler
+	// and subscriptions are notified in version of request which created them
+	jsonrpc string
+}
+
+/*
+WebsocketHandler - upgrade a RPC request to a websocket connection which serves commands of RpcHandler and RpcLimited
+with the same authentication and roles as http requests, and websocket only commands which subscribe to notifications
+*/
+func (rpcServer RpcServer) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&rpcServer.shutdown) != 0 || rpcServer.wsManager == nil {
+		return
+	}
+	auth, err := rpcServer.checkAuth(r, true)
+	if err != nil || auth == nil {
+		Logger.log.Error(err)
+		rpcServer.AuthFail(w)
+		return
+	}
+	if int(atomic.LoadInt32(&rpcServer.wsManager.numClients)+1) > rpcServer.config.RPCMaxWebsockets {
+		Logger.log.Infof("Max websocket clients exceeded [%d] - disconnecting client %s", rpcServer.config.RPCMaxWebsockets, r.RemoteAddr)
+		http.Error(w, "503 Too busy.  Try again later.", http.StatusServiceUnavailable)
+		return
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: rpcServer.checkWebsocketOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		Logger.log.Errorf("Failed to upgrade websocket connection of %s: %+v", r.RemoteAddr, err)
+		return
+	}
+	// read timeout of http server is replaced by pings of websocket
+	conn.UnderlyingConn().SetReadDeadline(timeZeroVal)
+
+	client := &wsClient{
+		rpcServer:     rpcServer,
+		conn:          conn,
+		remoteAddr:    r.RemoteAddr,
+		auth:          auth,
+		subscriptions: make(map[uint64]*wsSubscription),
+		send:          make(chan []byte, websocketSendBufferSize),
+		quit:          make(chan struct{}),
+	}
+	rpcServer.wsManager.addClient(client)
+	Logger.log.Infof("New websocket client %s", client.remoteAddr)
+	go client.outHandler()
+	client.inHandler()
+}
+
+/*
+checkWebsocketOrigin - allow websockets from origin of RPC listener and origins of config,
+so that pages of other sites can not use credentials of a browser. Requests without Origin header are not sent by browsers and are allowed
+*/
+func (rpcServer RpcServer) checkWebsocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	originURL, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(originURL.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range rpcServer.config.RPCWSOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	Logger.log.Warnf("Websocket origin %s of %s is not allowed", origin, r.RemoteAddr)
+	return false
+}
+
+// Disconnect closes connection of client, it is safe to call it more than once
+func (client *wsClient) Disconnect() {
+	client.closeOnce.Do(func() {
+		close(client.quit)
+		client.conn.Close()
+		client.rpcServer.wsManager.removeClient(client)
+		Logger.log.Infof("Disconnected websocket client %s", client.remoteAddr)
+	})
+}
+
+// inHandler reads requests of client until connection is closed, requests are served in order
+func (client *wsClient) inHandler() {
+	defer client.Disconnect()
+	client.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+	})
+	for {
+		_, msg, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.conn.SetReadDeadline(time.Now().Add(websocketPongWait))
+		if atomic.LoadInt32(&client.rpcServer.shutdown) != 0 {
+			return
+		}
+		if reply := client.processRequest(msg); reply != nil {
+			client.queueMessage(reply)
+		}
+	}
+}
+
+// processRequest returns reply of a request or a batch of requests, it is nil when there is nothing to reply
+func (client *wsClient) processRequest(msg []byte) []byte {
+	return client.rpcServer.processRequestBody(msg, client.executeRequest)
+}
+
+// executeRequest runs a websocket only command or a command of RpcHandler and RpcLimited
+func (client *wsClient) executeRequest(request RpcRequest) (interface{}, *RPCError) {
+	client.jsonrpc = request.Jsonrpc
+	var result interface{}
+	var jsonErr *RPCError
+	if handler, ok := wsHandlers[request.Method]; ok {
+		jsonErr = client.rpcServer.authorizeCommand(client.auth, request.Method)
+		var params interface{}
+		if jsonErr == nil {
+			params, jsonErr = validateParams(request.Method, request.Params)
+		}
+		if jsonErr == nil {
+			start := time.Now()
+			result, jsonErr = handler(client, params)
+			observeCommand(request.Method, start, jsonErr)
+		}
+	} else {
+		result, jsonErr = client.rpcServer.executeCommand(request, client.auth, client.quit)
+	}
+	if jsonErr != nil {
+		Logger.log.Errorf("Websocket RPC function process with err \n %+v", jsonErr)
+	}
+	return result, jsonErr
+}
+
+// outHandler writes queued messages and pings to client
+func (client *wsClient) outHandler() {
+	pingTicker := time.NewTicker(websocketPingInterval)
+	defer pingTicker.Stop()
+	defer client.Disconnect()
+	for {
+		select {
+		case msg := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
+			if err := client.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-pingTicker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-client.quit:
+			return
+		}
+	}
+}
+
+// queueMessage queues a message for client, client is disconnected when it does not read its messages fast enough
+func (client *wsClient) queueMessage(msg []byte) {
+	select {
+	case client.send <- msg:
+	case <-client.quit:
+	default:
+		Logger.log.Warnf("Websocket client %s is too slow, disconnecting", client.remoteAddr)
+		client.Disconnect()
+	}
+}
+
+func (client *wsClient) notify(subscription *wsSubscription, result interface{}) {
+	var notification interface{}
+	if subscription.jsonrpc == jsonrpcVersion2 {
+		notification = wsNotificationV2{
+			Jsonrpc: jsonrpcVersion2,
+			Method:  subscription.method,
+			Params: wsNotificationParamsV2{
+				Subscription: subscription.id,
+				Result:       result,
+			},
+		}
+	} else {
+		notification = wsNotification{
+			Jsonrpc: "1.0",
+			Method:  subscription.method,
+			Params: jsonresult.SubscriptionNotification{
+				Subscription: subscription.id,
+				Result:       result,
+			},
+		}
+	}
+	msg, err := json.Marshal(notification)
+	if err != nil {
+		Logger.log.Errorf("Failed to marshal notification: %s", err.Error())
+		return
+	}
+	client.queueMessage(msg)
+}
+
+func (client *wsClient) subscriptionsOf(method string) []*wsSubscription {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	subscriptions := []*wsSubscription{}
+	for _, subscription := range client.subscriptions {
+		if subscription.method == method {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions
+}
+
+func (client *wsClient) addSubscription(subscription *wsSubscription) (interface{}, *RPCError) {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	if len(client.subscriptions) >= maxSubscriptionsPerClient {
+		return nil, NewRPCError(ErrRPCInvalidRequest, fmt.Errorf("client has max %d subscriptions", maxSubscriptionsPerClient))
+	}
+	client.nextSubscriptionID++
+	subscription.id = client.nextSubscriptionID
+	subscription.jsonrpc = client.jsonrpc
+	client.subscriptions[subscription.id] = subscription
+	return subscription.id, nil
+}
+
+// parseShardsParam reads optional shard ids of first param, nil means all shards
+func parseShardsParam(params interface{}) (map[byte]bool, *RPCError) {
+	paramsArray := common.InterfaceSlice(params)
+	if len(paramsArray) == 0 || paramsArray[0] == nil {
+		return nil, nil
+	}
+	shardParams := common.InterfaceSlice(paramsArray[0])
+	if shardParams == nil {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("shard ids must be an array"))
+	}
+	shards := make(map[byte]bool)
+	for _, shardParam := range shardParams {
+		shardID, ok := shardParam.(float64)
+		if !ok || shardID < 0 || shardID >= common.MAX_SHARD_NUMBER {
+			return nil, NewRPCError(ErrRPCInvalidParams, fmt.Errorf("invalid shard id %+v", shardParam))
+		}
+		shards[byte(shardID)] = true
+	}
+	return shards, nil
+}
+
+// parseKey reads a payment address, a readonly key or a base58 public key, private keys are rejected
+func parseKey(keyParam interface{}) (*wsKey, *RPCError) {
+	keyStr, ok := keyParam.(string)
+	if !ok {
+		return nil, NewRPCError(ErrRPCInvalidParams, fmt.Errorf("invalid key %+v", keyParam))
+	}
+	key := &wsKey{serialized: keyStr}
+	keyWallet, err := wallet.Base58CheckDeserialize(keyStr)
+	if err == nil && len(keyWallet.KeySet.PrivateKey) > 0 {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("private keys can not be subscribed, use a payment address or a readonly key"))
+	}
+	if err == nil && len(keyWallet.KeySet.ReadonlyKey.Pk) > 0 {
+		readonlyKey := keyWallet.KeySet.ReadonlyKey
+		key.publicKey = readonlyKey.Pk
+		key.readonlyKey = &readonlyKey
+		return key, nil
+	}
+	if err == nil && len(keyWallet.KeySet.PaymentAddress.Pk) > 0 {
+		key.publicKey = keyWallet.KeySet.PaymentAddress.Pk
+		return key, nil
+	}
+	publicKey, _, err := base58.Base58Check{}.Decode(keyStr)
+	if err != nil || len(publicKey) != privacy.CompressedPointSize {
+		return nil, NewRPCError(ErrRPCInvalidParams, fmt.Errorf("invalid key %+v", keyStr))
+	}
+	key.publicKey = publicKey
+	return key, nil
+}
+
+// handleSubscribeBeaconBlocks - notify new beacon blocks
+func (client *wsClient) handleSubscribeBeaconBlocks(params interface{}) (interface{}, *RPCError) {
+	return client.addSubscription(&wsSubscription{method: BeaconBlockNotification})
+}
+
+// handleSubscribeShardBlocks - notify new shard blocks
+// Parameter #1 - optional - list of shard ids, all shards when it is empty
+func (client *wsClient) handleSubscribeShardBlocks(params interface{}) (interface{}, *RPCError) {
+	shards, rpcErr := parseShardsParam(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return client.addSubscription(&wsSubscription{method: ShardBlockNotification, shards: shards})
+}
+
+// handleSubscribeMempool - notify txs which enter or leave mempool
+// Parameter #1 - optional - list of shard ids of senders, all shards when it is empty
+func (client *wsClient) handleSubscribeMempool(params interface{}) (interface{}, *RPCError) {
+	shards, rpcErr := parseShardsParam(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return client.addSubscription(&wsSubscription{method: MempoolTxNotification, shards: shards})
+}
+
+// handleSubscribeTransactionsByKey - notify txs which send from or to keys when they enter mempool and when they are in a block
+// Parameter #1 - list of payment addresses, readonly keys or public keys, amounts received are only notified for readonly keys
+func (client *wsClient) handleSubscribeTransactionsByKey(params interface{}) (interface{}, *RPCError) {
+	paramsArray := common.InterfaceSlice(params)
+	if len(paramsArray) < 1 {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("list of keys is required"))
+	}
+	keyParams := common.InterfaceSlice(paramsArray[0])
+	if len(keyParams) == 0 || len(keyParams) > maxKeysPerSubscription {
+		return nil, NewRPCError(ErrRPCInvalidParams, fmt.Errorf("number of keys must be from 1 to %d", maxKeysPerSubscription))
+	}
+	subscription := &wsSubscription{method: KeyTxNotification}
+	for _, keyParam := range keyParams {
+		key, rpcErr := parseKey(keyParam)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		subscription.keys = append(subscription.keys, *key)
+	}
+	return client.addSubscription(subscription)
+}
+
+// handleSubscribeCommittees - notify beacon and shard committees when they change
+func (client *wsClient) handleSubscribeCommittees(params interface{}) (interface{}, *RPCError) {
+	return client.addSubscription(&wsSubscription{method: CommitteeNotification})
+}
+
+// handleUnsubscribe - remove a subscription
+// Parameter #1 - id of subscription
+func (client *wsClient) handleUnsubscribe(params interface{}) (interface{}, *RPCError) {
+	paramsArray := common.InterfaceSlice(params)
+	if len(paramsArray) < 1 {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("subscription id is required"))
+	}
+	id, ok := paramsArray[0].(float64)
+	if !ok {
+		return nil, NewRPCError(ErrRPCInvalidParams, errors.New("invalid subscription id"))
+	}
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	if _, ok := client.subscriptions[uint64(id)]; !ok {
+		return false, nil
+	}
+	delete(client.subscriptions, uint64(id))
+	return true, nil
+}
+
+// NotifyBeaconBlock notifies websocket clients of a beacon block which is inserted into chain
+func (rpcServer RpcServer) NotifyBeaconBlock(block *blockchain.BeaconBlock) {
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.enqueue(&beaconBlockEvent{block: block})
+	}
+}
+
+// NotifyShardBlock notifies websocket clients of a shard block which is inserted into chain
+func (rpcServer RpcServer) NotifyShardBlock(block *blockchain.ShardBlock) {
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.enqueue(&shardBlockEvent{block: block})
+	}
+}
+
+// NotifyTxAdded notifies websocket clients of a tx which enters mempool
+func (rpcServer RpcServer) NotifyTxAdded(tx metadata.Transaction) {
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.enqueue(&mempoolTxEvent{tx: tx, event: mempoolTxAdded})
+	}
+}
+
+// NotifyTxRemoved notifies websocket clients of a tx which leaves mempool
+func (rpcServer RpcServer) NotifyTxRemoved(tx metadata.Transaction) {
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.enqueue(&mempoolTxEvent{tx: tx, event: mempoolTxRemoved})
+	}
+}
+
+// NotifyCommitteeChange notifies websocket clients of beacon and shard committees which are changed by a beacon block
+func (rpcServer RpcServer) NotifyCommitteeChange(epoch uint64, beaconCommittee []string, shardCommittee map[byte][]string) {
+	if rpcServer.wsManager != nil {
+		rpcServer.wsManager.enqueue(&committeeEvent{
+			epoch:           epoch,
+			beaconCommittee: beaconCommittee,
+			shardCommittee:  shardCommittee,
+		})
+	}
+}
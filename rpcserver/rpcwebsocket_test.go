@@ -0,0 +1,125 @@
+package rpcserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ninjadotorg/constant/common"
+)
+
+func initTestLogger() {
+	Logger.Init(common.NewBackend(ioutil.Discard).Logger("RPC test", true))
+}
+
+func TestWebsocketCheckOrigin(t *testing.T) {
+	initTestLogger()
+	rpcServer := RpcServer{config: RpcServerConfig{RPCWSOrigins: []string{"https://explorer.example.com/"}}}
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"http://127.0.0.1:9334", true},
+		{"https://explorer.example.com", true},
+		{"https://EXPLORER.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://127.0.0.1:9335", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://127.0.0.1:9334/ws", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if allowed := rpcServer.checkWebsocketOrigin(r); allowed != test.allowed {
+			t.Errorf("origin %q: expected allowed %v, got %v", test.origin, test.allowed, allowed)
+		}
+	}
+}
+
+func TestQueueHandlerDropsOldestEvents(t *testing.T) {
+	initTestLogger()
+	in := make(chan interface{})
+	out := make(chan interface{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go queueHandler(in, out, 3, quit)
+
+	for i := 0; i < 5; i++ {
+		in <- i
+	}
+	for _, expected := range []int{2, 3, 4} {
+		if event := <-out; event != expected {
+			t.Errorf("expected event %d, got %v", expected, event)
+		}
+	}
+	select {
+	case event := <-out:
+		t.Errorf("unexpected event %v", event)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// newTestWsClient returns a client whose connection is read by nobody, outHandler of client is not started
+func newTestWsClient(t *testing.T, bufferSize int) (*wsClient, func()) {
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+	}))
+	remote, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("unexpected error %+v", err)
+	}
+	rpcServer := RpcServer{wsManager: newWsNotificationManager()}
+	client := &wsClient{
+		rpcServer:     rpcServer,
+		conn:          <-conns,
+		remoteAddr:    "remote",
+		subscriptions: make(map[uint64]*wsSubscription),
+		send:          make(chan []byte, bufferSize),
+		quit:          make(chan struct{}),
+	}
+	rpcServer.wsManager.addClient(client)
+	return client, func() {
+		remote.Close()
+		server.Close()
+	}
+}
+
+func TestSlowWebsocketClientIsDisconnected(t *testing.T) {
+	initTestLogger()
+	client, closeClient := newTestWsClient(t, 2)
+	defer closeClient()
+
+	client.queueMessage([]byte("1"))
+	client.queueMessage([]byte("2"))
+	select {
+	case <-client.quit:
+		t.Fatalf("expected client to be kept while its queue has room")
+	default:
+	}
+
+	client.queueMessage([]byte("3"))
+	select {
+	case <-client.quit:
+	default:
+		t.Fatalf("expected client to be disconnected when its queue is full")
+	}
+	if numClients := atomic.LoadInt32(&client.rpcServer.wsManager.numClients); numClients != 0 {
+		t.Errorf("expected disconnected client to be removed, got %d clients", numClients)
+	}
+	if len(client.send) != 2 {
+		t.Errorf("expected queue of client to stay bounded, got %d messages", len(client.send))
+	}
+}
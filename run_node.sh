@@ -16,3 +16,7 @@ fi
 if [ "$1" == "beacon-proposer" ]; then
 go run *.go --spendingkey "112t8rxTdWfGCtgWvAMHnnEw9vN3R1D7YgD1SSHjAnVGL82HCrMq9yyXrHv3kB4gr84cejnMZRQ973RyHhq2G3MksoTWejNKdSWoQYDFf4gQ" --nodemode "auto" --datadir "data/beacon-1" --listen "127.0.0.1:9430" --externaladdress "127.0.0.1:9430" --norpcauth --rpclisten "127.0.0.1:9337"
 fi
+# Shard: 0, Role: Normal, peers are discovered through two bootnodes (run the second one with: cd bootnode && go run *.go -p 9331 --httpport 9341 --datafile bootnode_peers_9331.json), peer exchange and DHT
+if [ "$1" == "shard0-dht" ]; then
+go run *.go --nodemode "relay" --datadir "data/shard0-dht" --listen "127.0.0.1:9437" --externaladdress "127.0.0.1:9437" --norpcauth --rpclisten "127.0.0.1:9339" --relayshards "0" --discoverpeersaddress "127.0.0.1:9330,127.0.0.1:9331" --dht
+fi
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,6 +15,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	host "github.com/libp2p/go-libp2p-host"
 	libp2p "github.com/libp2p/go-libp2p-peer"
 	"github.com/ninjadotorg/constant/addrmanager"
 	"github.com/ninjadotorg/constant/blockchain"
@@ -23,6 +25,7 @@ import (
 	"github.com/ninjadotorg/constant/consensus/constantbft"
 	"github.com/ninjadotorg/constant/database"
 	"github.com/ninjadotorg/constant/mempool"
+	"github.com/ninjadotorg/constant/metadata"
 	"github.com/ninjadotorg/constant/netsync"
 	"github.com/ninjadotorg/constant/peer"
 	"github.com/ninjadotorg/constant/rewardagent"
@@ -60,6 +63,23 @@ type Server struct {
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	feeEstimator map[byte]*mempool.FeeEstimator
+	// inventoryRelay keeps messages announced by inv and pending getdata requests
+	inventoryRelay *inventoryRelay
+	// dandelion routes transactions of this node and stem transactions of peers
+	dandelion *dandelionRouter
+	// nodeMode is mode of node in config, it is kept by server since nodes of a simulated network run in one process
+	nodeMode string
+	// listenerHost is libp2p host of listener, a new host is created when it is nil, it is set by simulated networks
+	listenerHost host.Host
+	// messageFilter drops received messages to simulate partitions and lossy links, see peer.Config
+	messageFilter func(peerConn *peer.PeerConn, command string) bool
+	// clock is the source of time of chain, mempool and consensus, simulated networks set a manual clock
+	clock common.Clock
+	// cfg is config of node, it is config of process unless simulated networks set one for each node
+	cfg *config
+	// metricsServer serves metrics endpoint on metricsListener, it is nil when metrics are disabled
+	metricsServer   *http.Server
+	metricsListener net.Listener
 
 	cQuit     chan struct{}
 	cNewPeers chan *peer.Peer
@@ -71,17 +91,17 @@ type Server struct {
 func (serverObj *Server) setupRPCListeners() ([]net.Listener, error) {
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
-	if !cfg.DisableTLS {
+	if !serverObj.cfg.DisableTLS {
 		Logger.log.Debug("Disable TLS for RPC is false")
 		// Generate the TLS cert and key file if both don't already
 		// exist.
-		if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
-			err := rpcserver.GenCertPair(cfg.RPCCert, cfg.RPCKey)
+		if !fileExists(serverObj.cfg.RPCKey) && !fileExists(serverObj.cfg.RPCCert) {
+			err := rpcserver.GenCertPair(serverObj.cfg.RPCCert, serverObj.cfg.RPCKey)
 			if err != nil {
 				return nil, err
 			}
 		}
-		keyPair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+		keyPair, err := tls.LoadX509KeyPair(serverObj.cfg.RPCCert, serverObj.cfg.RPCKey)
 		if err != nil {
 			return nil, err
 		}
@@ -99,7 +119,7 @@ func (serverObj *Server) setupRPCListeners() ([]net.Listener, error) {
 		Logger.log.Debug("Disable TLS for RPC is true")
 	}
 
-	netAddrs, err := common.ParseListeners(cfg.RPCListeners, "tcp")
+	netAddrs, err := common.ParseListeners(serverObj.cfg.RPCListeners, "tcp")
 	if err != nil {
 		return nil, err
 	}
@@ -122,16 +142,25 @@ NewServer - create server object which control all process of node
 */
 func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInterface, chainParams *blockchain.Params, protocolVer string, interrupt <-chan struct{}) error {
 	// Init data for Server
+	if serverObj.cfg == nil {
+		serverObj.cfg = cfg
+	}
 	serverObj.protocolVersion = protocolVer
 	serverObj.chainParams = chainParams
 	serverObj.cQuit = make(chan struct{})
+	serverObj.inventoryRelay = newInventoryRelay()
 	serverObj.cNewPeers = make(chan *peer.Peer)
 	serverObj.dataBase = db
+	serverObj.nodeMode = serverObj.cfg.NodeMode
+	if serverObj.clock == nil {
+		serverObj.clock = common.NewRealClock()
+	}
+	serverObj.dandelion = newDandelionRouter(serverObj.clock, serverObj.cfg.DandelionEpoch, serverObj.cfg.DandelionFluff, serverObj.cfg.DandelionEmbargo)
 
 	var err error
 
 	// Create a new block chain instance with the appropriate configuration.9
-	// if cfg.Light {
+	// if serverObj.cfg.Light {
 	// 	if serverObj.wallet == nil {
 	// 		return errors.New("Wallet NOT FOUND. Light Mode required Wallet with at least one child account")
 	// 	}
@@ -139,9 +168,9 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 	// 		return errors.New("No child account in wallet. Light Mode required Wallet with at least one child account")
 	// 	}
 	// }
-	serverObj.userKeySet, err = cfg.GetUserKeySet()
+	serverObj.userKeySet, err = serverObj.cfg.GetUserKeySet()
 	if err != nil {
-		if cfg.NodeMode == "auto" || cfg.NodeMode == "beacon" || cfg.NodeMode == "shard" {
+		if serverObj.cfg.NodeMode == "auto" || serverObj.cfg.NodeMode == "beacon" || serverObj.cfg.NodeMode == "shard" {
 			Logger.log.Critical(err)
 			return err
 		} else {
@@ -150,19 +179,19 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 	}
 	serverObj.beaconPool = &mempool.BeaconPool{}
 
-	serverObj.shardToBeaconPool = mempool.GetShardToBeaconPool()
+	serverObj.shardToBeaconPool = mempool.NewShardToBeaconPool()
 	serverObj.crossShardPool = make(map[byte]blockchain.CrossShardPool)
 	serverObj.shardPool = make(map[byte]blockchain.ShardPool)
 	serverObj.blockChain = &blockchain.BlockChain{}
 
 	relayShards := []byte{}
-	if cfg.RelayShards == "all" {
+	if serverObj.cfg.RelayShards == "all" {
 		for index := 0; index < common.MAX_SHARD_NUMBER; index++ {
 			relayShards = append(relayShards, byte(index))
 		}
 	} else {
 		var validPath = regexp.MustCompile(`(?s)[[:digit:]]+`)
-		relayShardsStr := validPath.FindAllString(cfg.RelayShards, -1)
+		relayShardsStr := validPath.FindAllString(serverObj.cfg.RelayShards, -1)
 		for index := 0; index < len(relayShardsStr); index++ {
 			s, err := strconv.Atoi(relayShardsStr[index])
 			if err == nil {
@@ -183,7 +212,12 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		CrossShardPool:    serverObj.crossShardPool,
 		Server:            serverObj,
 		UserKeySet:        serverObj.userKeySet,
-		NodeMode:          cfg.NodeMode,
+		NodeMode:          serverObj.cfg.NodeMode,
+		Clock:             serverObj.clock,
+
+		OnBeaconBlockInserted: serverObj.OnBeaconBlockInserted,
+		OnShardBlockInserted:  serverObj.OnShardBlockInserted,
+		OnCommitteeChanged:    serverObj.OnCommitteeChanged,
 	})
 
 	if err != nil {
@@ -191,17 +225,12 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 	}
 	//init beacon pol
 	mempool.InitBeaconPool()
-	//init shard pool
-	mempool.InitShardPool(serverObj.shardPool)
-	//init cross shard pool
-	mempool.InitCrossShardPool(serverObj.crossShardPool, db)
-
-	//init shard to beacon bool
-	mempool.InitShardToBeaconPool()
+	//init shard pool, cross shard pool and shard to beacon pool of this node
+	mempool.InitNodePools(serverObj.blockChain.BestState, serverObj.shardToBeaconPool, serverObj.shardPool, serverObj.crossShardPool, db)
 
 	// TODO: 0xbahamooth Search for a feeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
-	if cfg.FastStartup {
+	if serverObj.cfg.FastStartup {
 		Logger.log.Debug("Load chain dependencies from DB")
 		serverObj.feeEstimator = make(map[byte]*mempool.FeeEstimator)
 		for shardID, bestState := range serverObj.blockChain.BestState.Shard {
@@ -218,6 +247,10 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 				} else {
 					serverObj.feeEstimator[shardID] = feeEstimator
 				}
+			} else {
+				serverObj.feeEstimator[shardID] = mempool.NewFeeEstimator(
+					mempool.DefaultEstimateFeeMaxRollback,
+					mempool.DefaultEstimateFeeMinRegisteredBlocks)
 			}
 		}
 	} else {
@@ -238,14 +271,38 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		}
 
 		serverObj.feeEstimator = make(map[byte]*mempool.FeeEstimator)
+		for shardID := range serverObj.blockChain.BestState.Shard {
+			serverObj.feeEstimator[shardID] = mempool.NewFeeEstimator(
+				mempool.DefaultEstimateFeeMaxRollback,
+				mempool.DefaultEstimateFeeMinRegisteredBlocks)
+		}
+	}
+	// load rules of metadata types for mempool
+	txPolicy := &mempool.Policy{
+		MaxTxVersion: mempool.MaxVersion,
+		BlockChain:   serverObj.blockChain,
+	}
+	for _, ruleStr := range serverObj.cfg.TxPolicies {
+		rule, err := mempool.ParseMetadataPolicyRule(ruleStr)
+		if err != nil {
+			Logger.log.Error(err)
+			return err
+		}
+		txPolicy.AddMetadataRule(rule)
 	}
 	// create mempool tx
 	serverObj.memPool = &mempool.TxPool{}
 	serverObj.memPool.Init(&mempool.Config{
-		BlockChain:   serverObj.blockChain,
-		DataBase:     serverObj.dataBase,
-		ChainParams:  chainParams,
-		FeeEstimator: serverObj.feeEstimator,
+		BlockChain:       serverObj.blockChain,
+		DataBase:         serverObj.dataBase,
+		ChainParams:      chainParams,
+		FeeEstimator:     serverObj.feeEstimator,
+		MaxOrphanTxs:     serverObj.cfg.MaxOrphanTxs,
+		OnAcceptOrphanTx: serverObj.OnAcceptOrphanTx,
+		OnTxAdded:        serverObj.OnTxAdded,
+		OnTxRemoved:      serverObj.OnTxRemoved,
+		Policy:           txPolicy,
+		Clock:            serverObj.clock,
 	})
 	//add tx pool
 	serverObj.blockChain.AddTxPool(serverObj.memPool)
@@ -257,10 +314,11 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		DataBase:     serverObj.dataBase,
 		ChainParams:  chainParams,
 		FeeEstimator: serverObj.feeEstimator,
+		Clock:        serverObj.clock,
 	})
 	serverObj.blockChain.AddTempTxPool(serverObj.tempMemPool)
 	//===============
-	serverObj.addrManager = addrmanager.New(cfg.DataDir)
+	serverObj.addrManager = addrmanager.New(serverObj.cfg.DataDir)
 
 	// Init reward agent
 	serverObj.rewardAgent = rewardagent.RewardAgent{}.Init(&rewardagent.RewardAgentConfig{
@@ -272,6 +330,7 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 	if err != nil {
 		return err
 	}
+	serverObj.blockgen.SetMaxTxsPerMetadataType(txPolicy.MaxTxsPerBlockByMetadataType())
 
 	// Init consensus engine
 	serverObj.consensusEngine, err = constantbft.Engine{}.Init(&constantbft.EngineConfig{
@@ -281,8 +340,10 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		BlockChain:        serverObj.blockChain,
 		Server:            serverObj,
 		BlockGen:          serverObj.blockgen,
-		NodeMode:          cfg.NodeMode,
+		NodeMode:          serverObj.cfg.NodeMode,
 		UserKeySet:        serverObj.userKeySet,
+		CompactPropose:    serverObj.cfg.CompactPropose,
+		Clock:             serverObj.clock,
 	})
 	if err != nil {
 		return err
@@ -298,12 +359,14 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 
 		ShardToBeaconPool: serverObj.shardToBeaconPool,
 		CrossShardPool:    serverObj.crossShardPool,
+
+		MaxBlocksPerRequest: serverObj.cfg.MaxBlocksPerRequest,
 	})
 	// Create a connection manager.
 	var peer *peer.Peer
-	if !cfg.DisableListen {
+	if !serverObj.cfg.DisableListen {
 		var err error
-		peer, err = serverObj.InitListenerPeer(serverObj.addrManager, listenAddrs, cfg.MaxPeers, cfg.MaxOutPeers, cfg.MaxInPeers)
+		peer, err = serverObj.InitListenerPeer(serverObj.addrManager, listenAddrs, serverObj.cfg.MaxPeers, serverObj.cfg.MaxOutPeers, serverObj.cfg.MaxInPeers)
 		if err != nil {
 			Logger.log.Error(err)
 			return err
@@ -313,29 +376,43 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		OnInboundAccept:      serverObj.InboundPeerConnected,
 		OnOutboundConnection: serverObj.OutboundPeerConnected,
 		ListenerPeer:         peer,
-		DiscoverPeers:        cfg.DiscoverPeers,
-		DiscoverPeersAddress: cfg.DiscoverPeersAddress,
-		ExternalAddress:      cfg.ExternalAddress,
+		DiscoverPeers:        serverObj.cfg.DiscoverPeers,
+		DiscoverPeersAddress: serverObj.cfg.DiscoverPeersAddress,
+		DiscoverDHT:          serverObj.cfg.DiscoverDHT,
+		ExternalAddress:      serverObj.cfg.ExternalAddress,
 		// config for connection of shard
-		MaxPeersSameShard:  cfg.MaxPeersSameShard,
-		MaxPeersOtherShard: cfg.MaxPeersOtherShard,
-		MaxPeersOther:      cfg.MaxPeersOther,
-		MaxPeersNoShard:    cfg.MaxPeersNoShard,
-		MaxPeersBeacon:     cfg.MaxPeersBeacon,
+		MaxPeersSameShard:  serverObj.cfg.MaxPeersSameShard,
+		MaxPeersOtherShard: serverObj.cfg.MaxPeersOtherShard,
+		MaxPeersOther:      serverObj.cfg.MaxPeersOther,
+		MaxPeersNoShard:    serverObj.cfg.MaxPeersNoShard,
+		MaxPeersBeacon:     serverObj.cfg.MaxPeersBeacon,
+		// config for ban of misbehaving peers
+		BanThreshold: serverObj.cfg.BanThreshold,
+		BanDuration:  serverObj.cfg.BanDuration,
+		BanList:      serverObj.addrManager,
+		// address manager records connection attempts of known addresses
+		AddrBook: serverObj.addrManager,
+		// policy of connection topology
+		Topology: connmanager.NewTopologyPolicy(connmanager.TopologyConfig{
+			PinnedPeers:        serverObj.cfg.pinnedPeers,
+			BeaconFullMesh:     serverObj.cfg.BeaconFullMesh,
+			MinCrossShardLinks: serverObj.cfg.MinCrossShardLinks,
+			RotationInterval:   serverObj.cfg.RotatePeersInterval,
+		}),
 	})
 	serverObj.connManager = connManager
 
 	// Start up persistent peers.
-	permanentPeers := cfg.ConnectPeers
+	permanentPeers := serverObj.cfg.ConnectPeers
 	if len(permanentPeers) == 0 {
-		permanentPeers = cfg.AddPeers
+		permanentPeers = serverObj.cfg.AddPeers
 	}
 
 	for _, addr := range permanentPeers {
 		go serverObj.connManager.Connect(addr, "", nil)
 	}
 
-	if !cfg.DisableRPC {
+	if !serverObj.cfg.DisableRPC {
 		// Setup listeners for the configured RPC listen addresses and
 		// TLS settings.
 		fmt.Println("settingup RPCListeners")
@@ -348,28 +425,39 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		}
 
 		rpcConfig := rpcserver.RpcServerConfig{
-			Listenters:      rpcListeners,
-			RPCQuirks:       cfg.RPCQuirks,
-			RPCMaxClients:   cfg.RPCMaxClients,
-			ChainParams:     chainParams,
-			BlockChain:      serverObj.blockChain,
-			TxMemPool:       serverObj.memPool,
-			Server:          serverObj,
-			Wallet:          serverObj.wallet,
-			ConnMgr:         serverObj.connManager,
-			AddrMgr:         serverObj.addrManager,
-			RPCUser:         cfg.RPCUser,
-			RPCPass:         cfg.RPCPass,
-			RPCLimitUser:    cfg.RPCLimitUser,
-			RPCLimitPass:    cfg.RPCLimitPass,
-			DisableAuth:     cfg.RPCDisableAuth,
-			NodeMode:        cfg.NodeMode,
-			FeeEstimator:    serverObj.feeEstimator,
-			ProtocolVersion: serverObj.protocolVersion,
-			Database:        &serverObj.dataBase,
+			Listenters:       rpcListeners,
+			RPCQuirks:        serverObj.cfg.RPCQuirks,
+			RPCMaxClients:    serverObj.cfg.RPCMaxClients,
+			RPCMaxWebsockets: serverObj.cfg.RPCMaxWebsockets,
+			RPCWSOrigins:     serverObj.cfg.RPCWSOrigins,
+			RPCRest:          serverObj.cfg.RPCRest,
+			ChainParams:      chainParams,
+			BlockChain:       serverObj.blockChain,
+			TxMemPool:        serverObj.memPool,
+			BlockGen:         serverObj.blockgen,
+			Server:           serverObj,
+			Wallet:           serverObj.wallet,
+			ConnMgr:          serverObj.connManager,
+			AddrMgr:          serverObj.addrManager,
+			RPCUser:          serverObj.cfg.RPCUser,
+			RPCPass:          serverObj.cfg.RPCPass,
+			RPCLimitUser:     serverObj.cfg.RPCLimitUser,
+			RPCLimitPass:     serverObj.cfg.RPCLimitPass,
+			DisableAuth:      serverObj.cfg.RPCDisableAuth,
+			RPCAPIKeys:       serverObj.cfg.RPCAPIKeys,
+			RPCRoleMethods:   serverObj.cfg.RPCRoleMethods,
+			RPCRateLimits:    serverObj.cfg.RPCRateLimits,
+			RPCAuditLog:      serverObj.cfg.RPCAuditLog,
+			NodeMode:         serverObj.cfg.NodeMode,
+			FeeEstimator:     serverObj.feeEstimator,
+			ProtocolVersion:  serverObj.protocolVersion,
+			Database:         &serverObj.dataBase,
 		}
 		serverObj.rpcServer = &rpcserver.RpcServer{}
-		serverObj.rpcServer.Init(&rpcConfig)
+		err = serverObj.rpcServer.Init(&rpcConfig)
+		if err != nil {
+			return err
+		}
 
 		// Signal process shutdown when the RPC server requests it.
 		go func() {
@@ -378,6 +466,13 @@ func (serverObj *Server) NewServer(listenAddrs string, db database.DatabaseInter
 		}()
 	}
 
+	if serverObj.cfg.MetricsListen != common.EmptyString {
+		serverObj.metricsServer, serverObj.metricsListener, err = serverObj.setupMetricsServer(serverObj.cfg.MetricsListen)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -419,10 +514,14 @@ func (serverObj *Server) Stop() error {
 	serverObj.connManager.Stop()
 
 	// Shutdown the RPC server if it's not disabled.
-	if !cfg.DisableRPC && serverObj.rpcServer != nil {
+	if !serverObj.cfg.DisableRPC && serverObj.rpcServer != nil {
 		serverObj.rpcServer.Stop()
 	}
 
+	if serverObj.metricsServer != nil {
+		serverObj.metricsServer.Close()
+	}
+
 	// Save fee estimator in the db
 	for shardID, feeEstimator := range serverObj.feeEstimator {
 		feeEstimatorData := feeEstimator.Save()
@@ -459,13 +558,15 @@ func (serverObj *Server) peerHandler() {
 
 	Logger.log.Debug("Start peer handler")
 
-	if len(cfg.ConnectPeers) == 0 {
+	if len(serverObj.cfg.ConnectPeers) == 0 {
 		for _, addr := range serverObj.addrManager.AddressCache() {
 			go serverObj.connManager.Connect(addr.RawAddress, addr.PublicKey, nil)
 		}
 	}
 
-	go serverObj.connManager.Start(cfg.DiscoverPeersAddress)
+	go serverObj.connManager.Start(serverObj.cfg.DiscoverPeersAddress)
+	go serverObj.inventoryHandler()
+	go serverObj.dandelionHandler()
 
 out:
 	for {
@@ -493,7 +594,7 @@ func (serverObj Server) Start() {
 	}
 
 	Logger.log.Debug("Starting server")
-	if cfg.TestNet {
+	if serverObj.cfg.TestNet {
 		Logger.log.Critical("************************")
 		Logger.log.Critical("* Testnet is active *")
 		Logger.log.Critical("************************")
@@ -506,7 +607,7 @@ func (serverObj Server) Start() {
 	serverObj.waitGroup.Add(1)
 
 	go serverObj.peerHandler()
-	if !cfg.DisableRPC && serverObj.rpcServer != nil {
+	if !serverObj.cfg.DisableRPC && serverObj.rpcServer != nil {
 		serverObj.waitGroup.Add(1)
 
 		// Start the rebroadcastHandler, which ensures user tx received by
@@ -515,9 +616,18 @@ func (serverObj Server) Start() {
 
 		serverObj.rpcServer.Start()
 	}
+	if serverObj.metricsServer != nil {
+		go func() {
+			Logger.log.Infof("Metrics server listening on %s", serverObj.metricsListener.Addr())
+			err := serverObj.metricsServer.Serve(serverObj.metricsListener)
+			if err != nil && err != http.ErrServerClosed {
+				Logger.log.Error(err)
+			}
+		}()
+	}
 	go serverObj.blockChain.StartSyncBlk()
 
-	if cfg.NodeMode != "relay" {
+	if serverObj.nodeMode != "relay" {
 		err := serverObj.consensusEngine.Start()
 		if err != nil {
 			Logger.log.Error(err)
@@ -540,7 +650,7 @@ func (serverObj *Server) InitListenerPeer(amgr *addrmanager.AddrManager, listenA
 
 	// use keycache to save listener peer into file, this will make peer id of listener not change after turn off node
 	kc := KeyCache{}
-	kc.Load(filepath.Join(cfg.DataDir, "listenerpeer.json"))
+	kc.Load(filepath.Join(serverObj.cfg.DataDir, "listenerpeer.json"))
 
 	// load seed of libp2p from keycache file, if not exist -> save a new data into keycache file
 	seed := int64(0)
@@ -560,6 +670,7 @@ func (serverObj *Server) InitListenerPeer(amgr *addrmanager.AddrManager, listenA
 
 	peer, err := peer.Peer{
 		Seed:             seed,
+		Host:             serverObj.listenerHost,
 		ListeningAddress: *netAddr,
 		Config:           *serverObj.NewPeerConfig(),
 		PeerConns:        make(map[string]*peer.PeerConn),
@@ -583,19 +694,25 @@ func (serverObj *Server) NewPeerConfig() *peer.Config {
 	KeySetUser := serverObj.userKeySet
 	config := &peer.Config{
 		MessageListeners: peer.MessageListeners{
-			OnBlockShard:       serverObj.OnBlockShard,
-			OnBlockBeacon:      serverObj.OnBlockBeacon,
-			OnCrossShard:       serverObj.OnCrossShard,
-			OnShardToBeacon:    serverObj.OnShardToBeacon,
-			OnTx:               serverObj.OnTx,
-			OnVersion:          serverObj.OnVersion,
-			OnGetBlockBeacon:   serverObj.OnGetBlockBeacon,
-			OnGetBlockShard:    serverObj.OnGetBlockShard,
-			OnGetCrossShard:    serverObj.OnGetCrossShard,
-			OnGetShardToBeacon: serverObj.OnGetShardToBeacon,
-			OnVerAck:           serverObj.OnVerAck,
-			OnGetAddr:          serverObj.OnGetAddr,
-			OnAddr:             serverObj.OnAddr,
+			OnBlockShard:        serverObj.OnBlockShard,
+			OnBlockBeacon:       serverObj.OnBlockBeacon,
+			OnCrossShard:        serverObj.OnCrossShard,
+			OnShardToBeacon:     serverObj.OnShardToBeacon,
+			OnTx:                serverObj.OnTx,
+			OnStemTx:            serverObj.OnStemTx,
+			OnVersion:           serverObj.OnVersion,
+			OnGetBlockBeacon:    serverObj.OnGetBlockBeacon,
+			OnGetBlockShard:     serverObj.OnGetBlockShard,
+			OnGetCrossShard:     serverObj.OnGetCrossShard,
+			OnGetShardToBeacon:  serverObj.OnGetShardToBeacon,
+			OnVerAck:            serverObj.OnVerAck,
+			OnGetAddr:           serverObj.OnGetAddr,
+			OnAddr:              serverObj.OnAddr,
+			OnInv:               serverObj.OnInv,
+			OnGetData:           serverObj.OnGetData,
+			OnCompactBlockShard: serverObj.OnCompactBlockShard,
+			OnGetBlockTxn:       serverObj.OnGetBlockTxn,
+			OnBlockTxn:          serverObj.OnBlockTxn,
 
 			//constantbft
 			OnBFTMsg: serverObj.OnBFTMsg,
@@ -610,6 +727,9 @@ func (serverObj *Server) NewPeerConfig() *peer.Config {
 	if len(KeySetUser.PrivateKey) != 0 {
 		config.UserKeySet = KeySetUser
 	}
+	config.RateLimits = serverObj.cfg.rateLimits
+	config.MessageFilter = serverObj.messageFilter
+	config.Clock = serverObj.clock
 	return config
 }
 
@@ -618,6 +738,15 @@ func (serverObj *Server) NewPeerConfig() *peer.Config {
 func (serverObj *Server) OnBlockShard(p *peer.PeerConn,
 	msg *wire.MessageBlockShard) {
 	Logger.log.Debug("Receive a new blockshard START")
+	// block with invalid producer signature is dropped before it is queued, genesis block is not signed
+	if msg.Block.Header.Height > 1 {
+		blockHash := msg.Block.Header.Hash()
+		if err := cashec.ValidateDataB58(msg.Block.Header.Producer, msg.Block.ProducerSig, blockHash.GetBytes()); err != nil {
+			p.AddBanScore(peer.OffenseInvalidBlock, err.Error())
+			return
+		}
+	}
+	serverObj.markInventoryReceived(p, msg)
 
 	var txProcessed chan struct{}
 	serverObj.netSync.QueueBlock(nil, msg, txProcessed)
@@ -626,9 +755,43 @@ func (serverObj *Server) OnBlockShard(p *peer.PeerConn,
 	Logger.log.Debug("Receive a new blockshard END")
 }
 
+func (serverObj *Server) OnCompactBlockShard(p *peer.PeerConn,
+	msg *wire.MessageCompactBlockShard) {
+	Logger.log.Debug("Receive a new compact blockshard START")
+	serverObj.markInventoryReceived(p, msg)
+
+	var txProcessed chan struct{}
+	serverObj.netSync.QueueMessageFromPeer(p.RemotePeerID, msg, txProcessed)
+
+	Logger.log.Debug("Receive a new compact blockshard END")
+}
+
+func (serverObj *Server) OnGetBlockTxn(_ *peer.PeerConn, msg *wire.MessageGetBlockTxn) {
+	Logger.log.Debug("Receive a " + msg.MessageType() + " message START")
+	var txProcessed chan struct{}
+	serverObj.netSync.QueueMessage(nil, msg, txProcessed)
+	Logger.log.Debug("Receive a " + msg.MessageType() + " message END")
+}
+
+func (serverObj *Server) OnBlockTxn(_ *peer.PeerConn, msg *wire.MessageBlockTxn) {
+	Logger.log.Debug("Receive a " + msg.MessageType() + " message START")
+	var txProcessed chan struct{}
+	serverObj.netSync.QueueMessage(nil, msg, txProcessed)
+	Logger.log.Debug("Receive a " + msg.MessageType() + " message END")
+}
+
 func (serverObj *Server) OnBlockBeacon(p *peer.PeerConn,
 	msg *wire.MessageBlockBeacon) {
 	Logger.log.Debug("Receive a new blockbeacon START")
+	// block with invalid producer signature is dropped before it is queued, genesis block is not signed
+	if msg.Block.Header.Height > 1 {
+		blockHash := msg.Block.Header.Hash()
+		if err := cashec.ValidateDataB58(msg.Block.Header.Producer, msg.Block.ProducerSig, blockHash.GetBytes()); err != nil {
+			p.AddBanScore(peer.OffenseInvalidBlock, err.Error())
+			return
+		}
+	}
+	serverObj.markInventoryReceived(p, msg)
 
 	var txProcessed chan struct{}
 	serverObj.netSync.QueueBlock(nil, msg, txProcessed)
@@ -651,6 +814,17 @@ func (serverObj *Server) OnCrossShard(p *peer.PeerConn,
 func (serverObj *Server) OnShardToBeacon(p *peer.PeerConn,
 	msg *wire.MessageShardToBeacon) {
 	Logger.log.Debug("Receive a new shardToBeacon START")
+	// block with invalid producer signature is dropped before it is queued, genesis block is not signed
+	if msg.Block.Header.Height > 1 {
+		blockHash := msg.Block.Header.Hash()
+		if err := cashec.ValidateDataB58(msg.Block.Header.Producer, msg.Block.ProducerSig, blockHash.GetBytes()); err != nil {
+			// shard to beacon block is relayed by peers out of beacon, only its producer is penalized
+			if p.RemotePeer != nil && p.RemotePeer.PublicKey == msg.Block.Header.Producer {
+				p.AddBanScore(peer.OffenseInvalidBlock, err.Error())
+			}
+			return
+		}
+	}
 
 	var txProcessed chan struct{}
 	serverObj.netSync.QueueBlock(nil, msg, txProcessed)
@@ -696,6 +870,7 @@ func (serverObj *Server) OnGetShardToBeacon(_ *peer.PeerConn, msg *wire.MessageG
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (serverObj *Server) OnTx(peer *peer.PeerConn, msg *wire.MessageTx) {
 	Logger.log.Debug("Receive a new transaction START")
+	serverObj.markInventoryReceived(peer, msg)
 	var txProcessed chan struct{}
 	serverObj.netSync.QueueTx(nil, msg, txProcessed)
 	//<-txProcessed
@@ -732,6 +907,18 @@ func (serverObj *Server) OnVersion(peerConn *peer.PeerConn, msg *wire.MessageVer
 	if msg.ProtocolVersion == serverObj.protocolVersion {
 		valid = true
 	}
+	// remote peer reads the lower wire version of both,
+	// a peer which does not send wire version only reads hex encoded json
+	wireVersion := msg.WireVersion
+	if wireVersion > wire.CurrentWireVersion {
+		wireVersion = wire.CurrentWireVersion
+	}
+	peerConn.SetWireVersion(wireVersion)
+	// compressed payload is only sent in binary frames when both nodes support it
+	compression := wireVersion >= wire.WireVersionBinary && msg.Capabilities&wire.CapabilityCompression != 0 && wire.CurrentCapabilities&wire.CapabilityCompression != 0
+	peerConn.SetCompression(compression)
+	// inventory relay and compact blocks are used when both nodes support them
+	peerConn.SetCapabilities(msg.Capabilities & serverObj.localCapabilities())
 
 	// check for accept connection
 	if !serverObj.connManager.CheckForAcceptConn(peerConn) {
@@ -770,7 +957,8 @@ func (serverObj *Server) OnVerAck(peerConn *peer.PeerConn, msg *wire.MessageVerA
 		peerConn.VerValid = true
 
 		if peerConn.GetIsOutbound() {
-			serverObj.addrManager.Good(peerConn.RemotePeer)
+			role, shardID := serverObj.connManager.GetRoleShardOfPbk(peerConn.RemotePeer.PublicKey)
+			serverObj.addrManager.Good(peerConn.RemotePeer, peerConn.HandshakeLatency(time.Now()), role, shardID)
 		}
 
 		// send message for get addr
@@ -783,19 +971,10 @@ func (serverObj *Server) OnVerAck(peerConn *peer.PeerConn, msg *wire.MessageVerA
 
 		//	broadcast addr to all peer
 		listen := serverObj.connManager.ListeningPeer
-		msgSA, err := wire.MakeEmptyMessage(wire.CmdAddr)
+		msgSA, err := serverObj.makeAddrMessage(peerConn)
 		if err != nil {
 			return
 		}
-
-		rawPeers := []wire.RawPeer{}
-		peers := serverObj.addrManager.AddressCache()
-		for _, peer := range peers {
-			if peerConn.RemotePeerID.Pretty() != serverObj.connManager.GetPeerId(peer.RawAddress) {
-				rawPeers = append(rawPeers, wire.RawPeer{peer.RawAddress, peer.PublicKey})
-			}
-		}
-		msgSA.(*wire.MessageAddr).RawPeers = rawPeers
 		var doneChan chan<- struct{}
 		for _, _peerConn := range listen.PeerConns {
 			go _peerConn.QueueMessageWithEncoding(msgSA, doneChan, peer.MESSAGE_TO_PEER, nil)
@@ -822,19 +1001,10 @@ func (serverObj *Server) OnGetAddr(peerConn *peer.PeerConn, msg *wire.MessageGet
 	Logger.log.Debug("Receive getaddr message START")
 
 	// send message for addr
-	msgS, err := wire.MakeEmptyMessage(wire.CmdAddr)
+	msgS, err := serverObj.makeAddrMessage(peerConn)
 	if err != nil {
 		return
 	}
-
-	peers := serverObj.addrManager.AddressCache()
-	rawPeers := []wire.RawPeer{}
-	for _, peer := range peers {
-		if peerConn.RemotePeerID.Pretty() != serverObj.connManager.GetPeerId(peer.RawAddress) {
-			rawPeers = append(rawPeers, wire.RawPeer{peer.RawAddress, peer.PublicKey})
-		}
-	}
-	msgS.(*wire.MessageAddr).RawPeers = rawPeers
 	var dc chan<- struct{}
 	peerConn.QueueMessageWithEncoding(msgS, dc, peer.MESSAGE_TO_PEER, nil)
 
@@ -843,11 +1013,18 @@ func (serverObj *Server) OnGetAddr(peerConn *peer.PeerConn, msg *wire.MessageGet
 
 func (serverObj *Server) OnAddr(peerConn *peer.PeerConn, msg *wire.MessageAddr) {
 	Logger.log.Debugf("Receive addr message %v", msg.RawPeers)
+	serverObj.addPeerRecords(peerConn, msg.RawPeers)
 }
 
-func (serverObj *Server) OnBFTMsg(_ *peer.PeerConn, msg wire.Message) {
+func (serverObj *Server) OnBFTMsg(p *peer.PeerConn, msg wire.Message) {
 	Logger.log.Debug("Receive a BFTMsg START")
 	var txProcessed chan struct{}
+	// block of a compact proposal is requested from the peer which forwarded it
+	if msgPropose, ok := msg.(*wire.MessageBFTPropose); ok && msgPropose.CompactBlock && p != nil {
+		serverObj.netSync.QueueMessageFromPeer(p.RemotePeerID, msg, txProcessed)
+		Logger.log.Debug("Receive a BFTMsg END")
+		return
+	}
 	serverObj.netSync.QueueMessage(nil, msg, txProcessed)
 	Logger.log.Debug("Receive a BFTMsg END")
 }
@@ -882,11 +1059,82 @@ func (serverObj *Server) GetPeerIDsFromPublicKey(pubKey string) []libp2p.ID {
 	return result
 }
 
+/*
+OnAcceptOrphanTx is invoked when an orphan tx is moved into mempool,
+tx was not broadcasted when it was received so broadcast it now
+*/
+func (serverObj *Server) OnAcceptOrphanTx(tx metadata.Transaction) {
+	msg, err := wire.MakeEmptyMessage(wire.CmdTx)
+	if err != nil {
+		Logger.log.Error(err)
+		return
+	}
+	msg.(*wire.MessageTx).Transaction = tx
+	err = serverObj.RelayMessageTx(msg.(*wire.MessageTx))
+	if err != nil {
+		Logger.log.Error(err)
+	}
+}
+
+// OnBeaconBlockInserted is invoked when a beacon block is inserted into chain, it notifies RPC subscribers
+func (serverObj *Server) OnBeaconBlockInserted(block *blockchain.BeaconBlock) {
+	if serverObj.rpcServer != nil {
+		serverObj.rpcServer.NotifyBeaconBlock(block)
+	}
+}
+
+// OnShardBlockInserted is invoked when a shard block is inserted into chain, it notifies RPC subscribers
+func (serverObj *Server) OnShardBlockInserted(block *blockchain.ShardBlock) {
+	if serverObj.rpcServer != nil {
+		serverObj.rpcServer.NotifyShardBlock(block)
+	}
+}
+
+// OnCommitteeChanged is invoked when a beacon block changes committees, it notifies RPC subscribers
+func (serverObj *Server) OnCommitteeChanged(epoch uint64, beaconCommittee []string, shardCommittee map[byte][]string) {
+	if serverObj.rpcServer != nil {
+		serverObj.rpcServer.NotifyCommitteeChange(epoch, beaconCommittee, shardCommittee)
+	}
+}
+
+// OnTxAdded is invoked when a tx enters mempool, it notifies RPC subscribers
+func (serverObj *Server) OnTxAdded(tx metadata.Transaction) {
+	if serverObj.rpcServer != nil {
+		serverObj.rpcServer.NotifyTxAdded(tx)
+	}
+}
+
+// OnTxRemoved is invoked when a tx leaves mempool, it notifies RPC subscribers
+func (serverObj *Server) OnTxRemoved(tx metadata.Transaction) {
+	if serverObj.rpcServer != nil {
+		serverObj.rpcServer.NotifyTxRemoved(tx)
+	}
+}
+
+/*
+CompactShardBlock - make compact block of a shard block which is proposed or relayed by this node,
+transactions of the block are served to peers which do not have them in mempool
+*/
+func (serverObj *Server) CompactShardBlock(block *blockchain.ShardBlock) *blockchain.CompactShardBlock {
+	return serverObj.netSync.CompactShardBlock(block)
+}
+
 /*
 PushMessageToAll broadcast msg
 */
 func (serverObj *Server) PushMessageToAll(msg wire.Message) error {
 	Logger.log.Debug("Push msg to all peers")
+	// transactions of this node are sent in stem phase of dandelion before they are announced
+	if msgTx, ok := msg.(*wire.MessageTx); ok && msgTx.Transaction != nil && !serverObj.cfg.DisableDandelion {
+		if serverObj.stemLocalTx(msgTx.Transaction) {
+			return nil
+		}
+	}
+	// transactions and blocks are announced by hash, peers request them with getdata
+	if invVect, ok := inventoryOfMessage(msg); ok {
+		serverObj.relayInventory(invVect, msg)
+		return nil
+	}
 	var dc chan<- struct{}
 	msg.SetSenderID(serverObj.connManager.Config.ListenerPeer.PeerID)
 	serverObj.connManager.Config.ListenerPeer.QueueMessageWithEncoding(msg, dc, peer.MESSAGE_TO_ALL, nil)
@@ -1038,10 +1286,12 @@ func (serverObj *Server) PushVersionMessage(peerConn *peer.PeerConn) error {
 	msg.(*wire.MessageVersion).RawRemoteAddress = peerConn.ListenerPeer.RawAddress
 	msg.(*wire.MessageVersion).RemotePeerId = peerConn.ListenerPeer.PeerID
 	msg.(*wire.MessageVersion).ProtocolVersion = serverObj.protocolVersion
+	msg.(*wire.MessageVersion).WireVersion = wire.CurrentWireVersion
+	msg.(*wire.MessageVersion).Capabilities = serverObj.localCapabilities()
 	msg.(*wire.MessageVersion).PublicKey = peerConn.ListenerPeer.Config.UserKeySet.GetPublicKeyB58()
 	// Validate Public Key from UserPrvKey
 	// if peerConn.ListenerPeer.Config.UserKeySet != "" {
-	// 	// keySet, err := cfg.GetUserKeySet()
+	// 	// keySet, err := serverObj.cfg.GetUserKeySet()
 	// 	// if err != nil {
 	// 	// 	Logger.log.Critical("Invalid producer's private key")
 	// 	// 	return err
@@ -1060,6 +1310,7 @@ func (serverObj *Server) PushVersionMessage(peerConn *peer.PeerConn) error {
 	if err != nil {
 		return err
 	}
+	peerConn.SetVersionSent(time.Now())
 	peerConn.QueueMessageWithEncoding(msg, nil, peer.MESSAGE_TO_PEER, nil)
 	return nil
 }
@@ -1239,7 +1490,7 @@ func (serverObj *Server) BoardcastNodeState() error {
 	msg.(*wire.MessagePeerState).ShardToBeaconPool = serverObj.shardToBeaconPool.GetValidPendingBlockHash()
 
 	userRole, shardID := serverObj.blockChain.BestState.Beacon.GetPubkeyRole(serverObj.userKeySet.GetPublicKeyB58(), serverObj.blockChain.BestState.Beacon.BestBlock.Header.Round)
-	if (cfg.NodeMode == "auto" || cfg.NodeMode == "shard") && userRole == "shard" {
+	if (serverObj.nodeMode == "auto" || serverObj.nodeMode == "shard") && userRole == "shard" {
 		userRole = serverObj.blockChain.BestState.Shard[shardID].GetPubkeyRole(serverObj.userKeySet.GetPublicKeyB58(), serverObj.blockChain.BestState.Shard[shardID].BestBlock.Header.Round)
 		if userRole == "shard-proposer" || userRole == "shard-validator" {
 			// TODO: waiting for crossShardPool to be rewrite
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/privacy"
+)
+
+// simFundAmount is paid to stakers and receivers, salary of a shard block is enough for a stake and its fee
+const simFundAmount = blockchain.TestnetBasicSalary
+
+// newSimCommitteeNetwork creates a network of the beacon committee and a committee node of each shard,
+// shard producers earn salary, so that their keys fund other keys
+func newSimCommitteeNetwork(t *testing.T) *simNetwork {
+	network := newSimNetwork(t)
+	network.AddNode(simNodeConfig{
+		Name:        "beacon-0",
+		SpendingKey: blockchain.PreSelectBeaconNodeTestnet[0],
+		NodeMode:    "beacon",
+	})
+	for shardID := 0; shardID < blockchain.TestNetActiveShards; shardID++ {
+		network.AddNode(simNodeConfig{
+			Name:        fmt.Sprintf("shard-%d", shardID),
+			SpendingKey: blockchain.PreSelectShardNodeTestnet[shardID],
+			NodeMode:    "shard",
+		})
+	}
+	return network
+}
+
+// simUnusedShardKey returns a preselected shard key which is not in genesis committees and matches filter
+func simUnusedShardKey(t *testing.T, filter func(keySet *cashec.KeySet) bool) (string, *cashec.KeySet) {
+	for _, privateKey := range blockchain.PreSelectShardNodeTestnet[blockchain.TestNetActiveShards*blockchain.TestNetShardCommitteeSize:] {
+		keySet := simKeySet(t, privateKey)
+		if filter(keySet) {
+			return privateKey, keySet
+		}
+	}
+	t.Fatalf("No unused shard key matches")
+	return "", nil
+}
+
+// fund pays fund amount from salary of the producer of shard 0 to key set
+func (network *simNetwork) fund(keySet *cashec.KeySet, timeout time.Duration) {
+	funder := simKeySet(network.t, blockchain.PreSelectShardNodeTestnet[0])
+	network.WaitForBalance(funder, simFundAmount, timeout)
+	network.SendTx(funder, []*privacy.PaymentInfo{{PaymentAddress: keySet.PaymentAddress, Amount: simFundAmount}}, nil)
+	network.WaitForBalance(keySet, simFundAmount, timeout)
+}
+
+func TestSimNetworkConnectsNodes(t *testing.T) {
+	network := newSimNetwork(t)
+	defer network.Stop()
+	first := network.AddNode(simNodeConfig{Name: "relay-1", NodeMode: "relay", RelayShards: "all"})
+	second := network.AddNode(simNodeConfig{Name: "relay-2", NodeMode: "relay", RelayShards: "all"})
+	network.Start()
+
+	network.WaitFor(30*time.Second, "connection of relay nodes", func() bool {
+		return first.PeerCount() > 0 && second.PeerCount() > 0
+	})
+	network.AssertSameBeaconBestState()
+	network.AssertSameShardBestState(0)
+}
+
+func TestSimNetworkBeaconCommitteeProducesBlocks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("beacon committee produces blocks in real time")
+	}
+	network := newSimNetwork(t)
+	defer network.Stop()
+	for i, spendingKey := range blockchain.PreSelectBeaconNodeTestnet {
+		network.AddNode(simNodeConfig{
+			Name:        fmt.Sprintf("beacon-%d", i),
+			SpendingKey: spendingKey,
+			NodeMode:    "beacon",
+		})
+	}
+	network.Start()
+
+	network.WaitForBeaconHeight(3, 5*time.Minute)
+	network.AssertSameBeaconBestState()
+	network.AssertBeaconCommittee(blockchain.PreSelectBeaconNodeTestnetSerializedPubkey)
+}
+
+func TestSimNetworkCrossShardPayment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("committees produce blocks in real time")
+	}
+	network := newSimCommitteeNetwork(t)
+	defer network.Stop()
+	network.Start()
+
+	sender := simKeySet(t, blockchain.PreSelectShardNodeTestnet[0])
+	_, receiver := simUnusedShardKey(t, func(keySet *cashec.KeySet) bool {
+		return simShardOfKeySet(keySet) != simShardOfKeySet(sender)
+	})
+	network.WaitForBalance(sender, simFundAmount, 10*time.Minute)
+	senderNode := network.nodeOfShard(simShardOfKeySet(sender))
+	spentCoins, _ := senderNode.unspentCoins(sender)
+	network.SendTx(sender, []*privacy.PaymentInfo{{PaymentAddress: receiver.PaymentAddress, Amount: simFundAmount}}, nil)
+
+	// coins of receiver are created in its shard by cross shard block of shard of sender
+	network.WaitForBalance(receiver, simFundAmount, 10*time.Minute)
+	for _, node := range network.nodes {
+		if balance, ok := node.Balance(receiver); ok && balance != simFundAmount {
+			t.Errorf("Balance of receiver in %s is %d, expected %d", node.name, balance, simFundAmount)
+		}
+	}
+	// sender keeps earning salary, so only coins which were spent by tx are checked
+	unspentCoins, _ := senderNode.unspentCoins(sender)
+	for _, spent := range spentCoins {
+		for _, unspent := range unspentCoins {
+			if spent.CoinDetails.SNDerivator.Cmp(unspent.CoinDetails.SNDerivator) == 0 {
+				t.Errorf("Coin %d of sender is not spent", spent.CoinDetails.Value)
+			}
+		}
+	}
+	network.AssertSameBeaconBestState()
+	network.AssertSameShardBestState(simShardOfKeySet(sender))
+	network.AssertSameShardBestState(simShardOfKeySet(receiver))
+}
+
+func TestSimNetworkShardCommitteeSwap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("committees produce blocks in real time")
+	}
+	network := newSimCommitteeNetwork(t)
+	defer network.Stop()
+	stakerKey, staker := simUnusedShardKey(t, func(keySet *cashec.KeySet) bool { return true })
+	// staker node takes the role of the shard which it is assigned to
+	network.AddNode(simNodeConfig{Name: "staker", SpendingKey: stakerKey, NodeMode: "auto"})
+	network.Start()
+
+	network.fund(staker, 10*time.Minute)
+	network.Stake(staker, metadata.ShardStakingMeta)
+
+	// staker is assigned to a shard with random of the next epoch and is swapped in at the end of epoch
+	stakerPublicKey := simPublicKey(staker)
+	beacon := network.nodes[0]
+	network.WaitFor(20*time.Minute, "swap of staker into a shard committee", func() bool {
+		_, ok := beacon.ShardOfCommittee(stakerPublicKey)
+		return ok
+	})
+	shardID, _ := beacon.ShardOfCommittee(stakerPublicKey)
+	if committee := beacon.server.blockChain.BestState.Beacon.ShardCommittee[shardID]; len(committee) != blockchain.TestNetShardCommitteeSize {
+		t.Errorf("Committee of shard %d is %v, expected %d members", shardID, committee, blockchain.TestNetShardCommitteeSize)
+	}
+
+	// staker is the only member of committee, so shard only grows when staker produces blocks
+	shardNode := network.nodeOfShard(shardID)
+	height, _ := shardNode.ShardHeight(shardID)
+	network.WaitFor(5*time.Minute, fmt.Sprintf("blocks of shard %d from staker", shardID), func() bool {
+		newHeight, _ := shardNode.ShardHeight(shardID)
+		return newHeight >= height+2
+	})
+	network.AssertSameShardBestState(shardID)
+}
+
+func TestSimNetworkBeaconCommitteeSwap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("committees produce blocks in real time")
+	}
+	network := newSimCommitteeNetwork(t)
+	defer network.Stop()
+	stakerKey := blockchain.PreSelectBeaconNodeTestnet[1]
+	network.AddNode(simNodeConfig{Name: "beacon-1", SpendingKey: stakerKey, NodeMode: "beacon"})
+	network.Start()
+
+	staker := simKeySet(t, stakerKey)
+	network.fund(staker, 10*time.Minute)
+	network.Stake(staker, metadata.BeaconStakingMeta)
+
+	// beacon committee has one seat, so the genesis member is swapped out
+	stakerPublicKey := simPublicKey(staker)
+	network.WaitFor(20*time.Minute, "swap of staker into beacon committee", func() bool {
+		for _, node := range network.nodes {
+			if !common.CompareStringArray(node.server.blockChain.BestState.Beacon.BeaconCommittee, []string{stakerPublicKey}) {
+				return false
+			}
+		}
+		return true
+	})
+	network.WaitForBeaconHeight(network.nodes[0].BeaconHeight()+2, 5*time.Minute)
+	network.AssertSameBeaconBestState()
+	network.AssertBeaconCommittee([]string{stakerPublicKey})
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	host "github.com/libp2p/go-libp2p-host"
+	libpeer "github.com/libp2p/go-libp2p-peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/common/base58"
+	"github.com/ninjadotorg/constant/database"
+	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/peer"
+	"github.com/ninjadotorg/constant/privacy"
+	"github.com/ninjadotorg/constant/transaction"
+	"github.com/ninjadotorg/constant/wallet"
+	"github.com/ninjadotorg/constant/wire"
+)
+
+// simNodeConfig is config of a node of a simulated network
+type simNodeConfig struct {
+	Name        string
+	SpendingKey string // private key of node, node has no role when it is empty
+	NodeMode    string // auto, beacon, shard or relay
+	RelayShards string
+}
+
+// simNode is a full node of a simulated network
+type simNode struct {
+	name   string
+	server *Server
+	host   host.Host
+	db     database.DatabaseInterface
+}
+
+/*
+simNetwork - start full nodes in one process which are connected by in-memory libp2p streams,
+test controls partitions, message drops and latency of network. All nodes run on the manual clock of network,
+so block timestamps, consensus timeouts and latency only move when clock is advanced, WaitFor advances it by step at each poll.
+
+Each node has its own config, so key, mode, data directory and relay shards may differ between nodes.
+*/
+type simNetwork struct {
+	t         *testing.T
+	mocknet   mocknet.Mocknet
+	clock     *common.ManualClock
+	step      time.Duration
+	dir       string
+	interrupt chan struct{}
+	nodes     []*simNode
+
+	groups   map[libpeer.ID]int // partition group of node, nodes of different groups do not receive messages of each other
+	dropRate float64
+	latency  time.Duration
+	random   *rand.Rand
+	mtx      sync.Mutex
+}
+
+func newSimNetwork(t *testing.T) *simNetwork {
+	dir, err := ioutil.TempDir("", "constant-simnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &simNetwork{
+		t:         t,
+		mocknet:   mocknet.New(context.Background()),
+		clock:     common.NewManualClock(time.Now()),
+		step:      time.Second,
+		dir:       dir,
+		interrupt: make(chan struct{}),
+		groups:    make(map[libpeer.ID]int),
+		random:    rand.New(rand.NewSource(1)),
+	}
+}
+
+// AddNode creates a node with its own database, chain and pools, node is started by Start
+func (network *simNetwork) AddNode(nodeConfig simNodeConfig) *simNode {
+	h, err := network.mocknet.GenPeer()
+	if err != nil {
+		network.t.Fatal(err)
+	}
+
+	nodeCfg := defaultConfig()
+	nodeCfg.DataDir = filepath.Join(network.dir, nodeConfig.Name)
+	nodeCfg.Listener = "127.0.0.1:9333"
+	nodeCfg.NodeMode = nodeConfig.NodeMode
+	nodeCfg.SpendingKey = nodeConfig.SpendingKey
+	nodeCfg.RelayShards = nodeConfig.RelayShards
+	nodeCfg.DisableRPC = true
+	nodeCfg.DiscoverPeers = false
+	// stem phase of dandelion delays txs by real timers, txs are relayed right away in simulation
+	nodeCfg.DisableDandelion = true
+	nodeCfg.FastStartup = false
+
+	db, err := database.Open("leveldb", filepath.Join(nodeCfg.DataDir, nodeCfg.DatabaseDir))
+	if err != nil {
+		network.t.Fatal(err)
+	}
+
+	node := &simNode{
+		name: nodeConfig.Name,
+		host: h,
+		db:   db,
+	}
+	node.server = &Server{
+		listenerHost:  h,
+		messageFilter: network.messageFilter(h.ID()),
+		clock:         network.clock,
+		cfg:           &nodeCfg,
+	}
+	err = node.server.NewServer(nodeCfg.Listener, db, testNetParams.Params, version(), network.interrupt)
+	if err != nil {
+		network.t.Fatalf("Create node %s: %v", nodeConfig.Name, err)
+	}
+	network.nodes = append(network.nodes, node)
+	return node
+}
+
+// Start starts all nodes and connects every pair of them
+func (network *simNetwork) Start() {
+	for _, node := range network.nodes {
+		node.server.Start()
+	}
+	err := network.mocknet.LinkAll()
+	if err != nil {
+		network.t.Fatal(err)
+	}
+	for i, node := range network.nodes {
+		for _, other := range network.nodes[i+1:] {
+			rawAddress := other.server.connManager.Config.ListenerPeer.RawAddress
+			go node.server.connManager.Connect(rawAddress, common.EmptyString, nil)
+		}
+	}
+}
+
+// Stop stops all nodes and removes their data
+func (network *simNetwork) Stop() {
+	for _, node := range network.nodes {
+		node.server.Stop()
+	}
+	close(network.interrupt)
+	for _, node := range network.nodes {
+		node.host.Close()
+		node.db.Close()
+	}
+	os.RemoveAll(network.dir)
+}
+
+// messageFilter drops messages of node which cross a partition or are lost, and delays others by latency
+func (network *simNetwork) messageFilter(local libpeer.ID) func(peerConn *peer.PeerConn, command string) bool {
+	return func(peerConn *peer.PeerConn, command string) bool {
+		network.mtx.Lock()
+		partitioned := network.groups[local] != network.groups[peerConn.RemotePeerID]
+		lost := network.dropRate > 0 && network.random.Float64() < network.dropRate
+		latency := network.latency
+		network.mtx.Unlock()
+		if partitioned || lost {
+			return false
+		}
+		if latency > 0 {
+			<-network.clock.After(latency)
+		}
+		return true
+	}
+}
+
+// Partition splits network into groups, nodes which are not in any group are in the group of the first one
+func (network *simNetwork) Partition(groups ...[]*simNode) {
+	network.mtx.Lock()
+	defer network.mtx.Unlock()
+	network.groups = make(map[libpeer.ID]int)
+	for i, group := range groups {
+		for _, node := range group {
+			network.groups[node.host.ID()] = i
+		}
+	}
+}
+
+// Heal removes all partitions
+func (network *simNetwork) Heal() {
+	network.Partition()
+}
+
+// SetDropRate sets probability that a message is lost
+func (network *simNetwork) SetDropRate(rate float64) {
+	network.mtx.Lock()
+	defer network.mtx.Unlock()
+	network.dropRate = rate
+}
+
+// SetLatency delays every message until clock of network is advanced by latency
+func (network *simNetwork) SetLatency(latency time.Duration) {
+	network.mtx.Lock()
+	defer network.mtx.Unlock()
+	network.latency = latency
+}
+
+// SetStep sets how far clock of network is advanced at each poll of WaitFor, clock is not advanced when it is 0
+func (network *simNetwork) SetStep(step time.Duration) {
+	network.mtx.Lock()
+	defer network.mtx.Unlock()
+	network.step = step
+}
+
+// WaitFor polls cond and advances clock of network until cond is true, test fails when it is still false after timeout of real time
+func (network *simNetwork) WaitFor(timeout time.Duration, description string, cond func() bool) {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			network.t.Fatalf("Timeout waiting for %s", description)
+		}
+		time.Sleep(100 * time.Millisecond)
+		network.mtx.Lock()
+		step := network.step
+		network.mtx.Unlock()
+		if step > 0 {
+			network.clock.Advance(step)
+		}
+	}
+}
+
+// BeaconHeight returns height of beacon best state of node
+func (node *simNode) BeaconHeight() uint64 {
+	return node.server.blockChain.BestState.Beacon.BeaconHeight
+}
+
+// ShardHeight returns height of best state of a shard in chain of node, ok is false when node does not keep shard
+func (node *simNode) ShardHeight(shardID byte) (uint64, bool) {
+	bestState, ok := node.server.blockChain.BestState.Shard[shardID]
+	if !ok {
+		return 0, false
+	}
+	return bestState.ShardHeight, true
+}
+
+// PeerCount returns number of connections of node
+func (node *simNode) PeerCount() int {
+	return len(node.server.connManager.GetPeerConnOfAll())
+}
+
+// WaitForBeaconHeight waits until all nodes reach a beacon height
+func (network *simNetwork) WaitForBeaconHeight(height uint64, timeout time.Duration) {
+	network.WaitFor(timeout, fmt.Sprintf("beacon height %d", height), func() bool {
+		for _, node := range network.nodes {
+			if node.BeaconHeight() < height {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// AssertSameBeaconBestState fails when nodes do not agree on best beacon block
+func (network *simNetwork) AssertSameBeaconBestState() {
+	network.t.Helper()
+	first := network.nodes[0]
+	expected := first.server.blockChain.BestState.Beacon.BestBlockHash
+	for _, node := range network.nodes[1:] {
+		bestBlockHash := node.server.blockChain.BestState.Beacon.BestBlockHash
+		if !bestBlockHash.IsEqual(&expected) {
+			network.t.Errorf("Best beacon block of %s is %s, %s has %s", node.name, bestBlockHash.String(), first.name, expected.String())
+		}
+	}
+}
+
+// AssertSameShardBestState fails when nodes which keep a shard do not agree on its best block
+func (network *simNetwork) AssertSameShardBestState(shardID byte) {
+	network.t.Helper()
+	var expected *common.Hash
+	name := ""
+	for _, node := range network.nodes {
+		bestState, ok := node.server.blockChain.BestState.Shard[shardID]
+		if !ok {
+			continue
+		}
+		if expected == nil {
+			expected = &bestState.BestBlockHash
+			name = node.name
+			continue
+		}
+		if !bestState.BestBlockHash.IsEqual(expected) {
+			network.t.Errorf("Best block of shard %d of %s is %s, %s has %s", shardID, node.name, bestState.BestBlockHash.String(), name, expected.String())
+		}
+	}
+}
+
+// AssertBeaconCommittee fails when beacon committee of a node differs from expected public keys
+func (network *simNetwork) AssertBeaconCommittee(expected []string) {
+	network.t.Helper()
+	sortedExpected := append([]string{}, expected...)
+	sort.Strings(sortedExpected)
+	for _, node := range network.nodes {
+		committee := append([]string{}, node.server.blockChain.BestState.Beacon.BeaconCommittee...)
+		sort.Strings(committee)
+		if !common.CompareStringArray(committee, sortedExpected) {
+			network.t.Errorf("Beacon committee of %s is %v, expected %v", node.name, committee, sortedExpected)
+		}
+	}
+}
+
+// simKeySet returns key set of a private key
+func simKeySet(t *testing.T, privateKey string) *cashec.KeySet {
+	keyWallet, err := wallet.Base58CheckDeserialize(privateKey)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	keyWallet.KeySet.ImportFromPrivateKey(&keyWallet.KeySet.PrivateKey)
+	return &keyWallet.KeySet
+}
+
+// simPublicKey returns public key of key set in format of committees
+func simPublicKey(keySet *cashec.KeySet) string {
+	return base58.Base58Check{}.Encode(keySet.PaymentAddress.Pk, byte(0x00))
+}
+
+// simShardOfKeySet returns shard which keeps coins of key set
+func simShardOfKeySet(keySet *cashec.KeySet) byte {
+	return common.GetShardIDFromLastByte(keySet.PaymentAddress.Pk[len(keySet.PaymentAddress.Pk)-1])
+}
+
+// nodeOfShard returns the first node which keeps a shard, it is nil when no node keeps it
+func (network *simNetwork) nodeOfShard(shardID byte) *simNode {
+	for _, node := range network.nodes {
+		if _, ok := node.ShardHeight(shardID); ok && node.server.memPool != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// Balance returns unspent constant of key set in chain of node, ok is false when node does not keep shard of key set
+func (node *simNode) Balance(keySet *cashec.KeySet) (uint64, bool) {
+	outCoins, ok := node.unspentCoins(keySet)
+	if !ok {
+		return 0, false
+	}
+	balance := uint64(0)
+	for _, outCoin := range outCoins {
+		balance += outCoin.CoinDetails.Value
+	}
+	return balance, true
+}
+
+func (node *simNode) unspentCoins(keySet *cashec.KeySet) ([]*privacy.OutputCoin, bool) {
+	shardID := simShardOfKeySet(keySet)
+	if _, ok := node.ShardHeight(shardID); !ok {
+		return nil, false
+	}
+	constantTokenID := &common.Hash{}
+	constantTokenID.SetBytes(common.ConstantID[:])
+	outCoins, err := node.server.blockChain.GetListOutputCoinsByKeyset(keySet, shardID, constantTokenID)
+	if err != nil {
+		return nil, false
+	}
+	return outCoins, true
+}
+
+// ShardOfCommittee returns shard whose committee has a public key in beacon best state of node
+func (node *simNode) ShardOfCommittee(publicKey string) (byte, bool) {
+	for shardID, committee := range node.server.blockChain.BestState.Beacon.ShardCommittee {
+		if common.IndexOfStr(publicKey, committee) >= 0 {
+			return shardID, true
+		}
+	}
+	return 0, false
+}
+
+// WaitForBalance waits until a node which keeps shard of key set has at least amount of it
+func (network *simNetwork) WaitForBalance(keySet *cashec.KeySet, amount uint64, timeout time.Duration) {
+	network.WaitFor(timeout, fmt.Sprintf("balance %d of %s", amount, simPublicKey(keySet)), func() bool {
+		node := network.nodeOfShard(simShardOfKeySet(keySet))
+		if node == nil {
+			return false
+		}
+		balance, _ := node.Balance(keySet)
+		return balance >= amount
+	})
+}
+
+/*
+SendTx - create a tx of key set which pays receivers from all unspent coins of key set, rest of coins is paid back to key set,
+tx is accepted into pool of a node which keeps shard of key set and is sent to network
+*/
+func (network *simNetwork) SendTx(keySet *cashec.KeySet, receivers []*privacy.PaymentInfo, meta metadata.Metadata) *transaction.Tx {
+	network.t.Helper()
+	shardID := simShardOfKeySet(keySet)
+	node := network.nodeOfShard(shardID)
+	if node == nil {
+		network.t.Fatalf("No node keeps shard %d of sender", shardID)
+	}
+	outCoins, _ := node.unspentCoins(keySet)
+	total := uint64(0)
+	for _, outCoin := range outCoins {
+		total += outCoin.CoinDetails.Value
+	}
+	amount := uint64(0)
+	for _, receiver := range receivers {
+		amount += receiver.Amount
+	}
+	paymentInfos := append([]*privacy.PaymentInfo{}, receivers...)
+	fee := node.server.blockChain.GetFeePerKbTx() * transaction.EstimateTxSize(outCoins, paymentInfos, false, meta, nil, nil)
+	if total < amount+fee {
+		network.t.Fatalf("Balance %d of sender is under %d", total, amount+fee)
+	}
+	if rest := total - amount - fee; rest > 0 {
+		paymentInfos = append(paymentInfos, &privacy.PaymentInfo{PaymentAddress: keySet.PaymentAddress, Amount: rest})
+	}
+
+	tx := &transaction.Tx{}
+	txErr := tx.Init(&keySet.PrivateKey, paymentInfos, transaction.ConvertOutputCoinToInputCoin(outCoins), fee, false, node.db, nil, meta)
+	if txErr != nil {
+		network.t.Fatalf("Create tx: %+v", txErr)
+	}
+	if _, _, err := node.server.memPool.MaybeAcceptTransaction(tx); err != nil {
+		network.t.Fatalf("Accept tx into pool of %s: %+v", node.name, err)
+	}
+	txMsg, err := wire.MakeEmptyMessage(wire.CmdTx)
+	if err != nil {
+		network.t.Fatal(err)
+	}
+	txMsg.(*wire.MessageTx).Transaction = tx
+	if err := node.server.PushMessageToAll(txMsg); err != nil {
+		network.t.Fatalf("Send tx: %+v", err)
+	}
+	return tx
+}
+
+// Stake sends a staking tx of key set, which burns stake amount of its type
+func (network *simNetwork) Stake(keySet *cashec.KeySet, stakingType int) {
+	network.t.Helper()
+	stakingMeta, err := metadata.NewStakingMetadata(stakingType)
+	if err != nil {
+		network.t.Fatal(err)
+	}
+	amount := uint64(metadata.STAKE_SHARD_AMOUNT)
+	if stakingType == metadata.BeaconStakingMeta {
+		amount = metadata.STAKE_BEACON_AMOUNT
+	}
+	burningAddress, err := wallet.Base58CheckDeserialize(common.BurningAddress)
+	if err != nil {
+		network.t.Fatal(err)
+	}
+	network.SendTx(keySet, []*privacy.PaymentInfo{{PaymentAddress: burningAddress.KeySet.PaymentAddress, Amount: amount}}, stakingMeta)
+}
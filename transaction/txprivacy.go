@@ -16,6 +16,7 @@ import (
 	"github.com/ninjadotorg/constant/common"
 	"github.com/ninjadotorg/constant/database"
 	"github.com/ninjadotorg/constant/metadata"
+	"github.com/ninjadotorg/constant/metrics"
 	"github.com/ninjadotorg/constant/privacy"
 	"github.com/ninjadotorg/constant/privacy/zeroknowledge"
 	"github.com/ninjadotorg/constant/wallet"
@@ -472,7 +473,9 @@ func (tx *Tx) ValidateTransaction(hasPrivacy bool, db database.DatabaseInterface
 		}
 
 		// Verify the payment proof
+		verifyStart := time.Now()
 		valid = tx.Proof.Verify(hasPrivacy, tx.SigPubKey, tx.Fee, db, shardID, tokenID)
+		metrics.ObserveSince(metrics.ProofVerifyDuration.WithLabelValues(strconv.FormatBool(hasPrivacy)), verifyStart)
 		Logger.log.Infof("proof valid: %v\n", valid)
 		if !valid {
 			Logger.log.Infof("[PRIVACY LOG] - FAILED VERIFICATION PAYMENT PROOF")
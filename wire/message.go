@@ -43,6 +43,10 @@ const (
 	CmdGetAddr            = "getaddr"
 	CmdAddr               = "addr"
 	CmdPing               = "ping"
+	CmdCompactBlockShard  = "cmpctblkshd"
+	CmdGetBlockTxn        = "getblocktxn"
+	CmdBlockTxn           = "blocktxn"
+	CmdStemTx             = "stemtx"
 
 	// POS Cmd
 	CmdBFTPropose   = "bftpropose"
@@ -146,6 +150,11 @@ func MakeEmptyMessage(messageType string) (Message, error) {
 			Transaction: &transaction.Tx{},
 		}
 		break
+	case CmdStemTx:
+		msg = &MessageStemTx{
+			Transaction: &transaction.Tx{},
+		}
+		break
 	case CmdVersion:
 		msg = &MessageVersion{}
 		break
@@ -198,6 +207,21 @@ func MakeEmptyMessage(messageType string) (Message, error) {
 	case CmdPing:
 		msg = &MessagePing{}
 		break
+	case CmdInv:
+		msg = &MessageInv{}
+		break
+	case CmdGetData:
+		msg = &MessageGetData{}
+		break
+	case CmdCompactBlockShard:
+		msg = &MessageCompactBlockShard{}
+		break
+	case CmdGetBlockTxn:
+		msg = &MessageGetBlockTxn{}
+		break
+	case CmdBlockTxn:
+		msg = &MessageBlockTxn{}
+		break
 	case CmdMsgCheck:
 		msg = &MessageMsgCheck{
 			Timestamp: time.Now().UnixNano(),
@@ -234,6 +258,8 @@ func GetCmdType(msgType reflect.Type) (string, error) {
 		return CmdGetBlockShard, nil
 	case reflect.TypeOf(&MessageTx{}):
 		return CmdTx, nil
+	case reflect.TypeOf(&MessageStemTx{}):
+		return CmdStemTx, nil
 		/*case reflect.TypeOf(&MessageRegistration{}):
 		  return CmdRegisteration, nil*/
 	case reflect.TypeOf(&MessageVersion{}):
@@ -246,6 +272,16 @@ func GetCmdType(msgType reflect.Type) (string, error) {
 		return CmdAddr, nil
 	case reflect.TypeOf(&MessagePing{}):
 		return CmdPing, nil
+	case reflect.TypeOf(&MessageInv{}):
+		return CmdInv, nil
+	case reflect.TypeOf(&MessageGetData{}):
+		return CmdGetData, nil
+	case reflect.TypeOf(&MessageCompactBlockShard{}):
+		return CmdCompactBlockShard, nil
+	case reflect.TypeOf(&MessageGetBlockTxn{}):
+		return CmdGetBlockTxn, nil
+	case reflect.TypeOf(&MessageBlockTxn{}):
+		return CmdBlockTxn, nil
 	case reflect.TypeOf(&MessageBFTPropose{}):
 		return CmdBFTPropose, nil
 	case reflect.TypeOf(&MessageBFTPrepare{}):
@@ -2,6 +2,10 @@ package wire
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/ninjadotorg/constant/cashec"
 
@@ -12,12 +16,79 @@ import (
 )
 
 const (
-	MaxGetAddressPayload = 1000 // 1 Kb
+	MaxGetAddressPayload = 200000 // 200 Kb
+	// MaxAddrPerMsg is the max number of peers in an addr message
+	MaxAddrPerMsg = 250
+	// MaxRecordClockSkew is how far in the future timestamp of a signed record can be
+	MaxRecordClockSkew = 10 * time.Minute
+	// MaxRecordAge is how old a signed record can be, so that a stale role or shard is not trusted
+	MaxRecordAge = 24 * time.Hour
 )
 
+/*
+RawPeer is an address of a peer, it is a signed record when SignDataB58 is set:
+owner of public key signs its address, role, shard and time so that peers can relay it
+without being able to change it
+*/
 type RawPeer struct {
-	RawAddress string
-	PublicKey  string
+	RawAddress  string
+	PublicKey   string
+	Role        string `json:",omitempty"`
+	ShardID     *byte  `json:",omitempty"`
+	Timestamp   int64  `json:",omitempty"`
+	SignDataB58 string `json:",omitempty"`
+}
+
+// recordData returns bytes of a record which are signed by owner of public key
+func (rawPeer *RawPeer) recordData() []byte {
+	shard := "-"
+	if rawPeer.ShardID != nil {
+		shard = strconv.Itoa(int(*rawPeer.ShardID))
+	}
+	return []byte(strings.Join([]string{rawPeer.RawAddress, rawPeer.PublicKey, rawPeer.Role, shard, strconv.FormatInt(rawPeer.Timestamp, 10)}, "|"))
+}
+
+// Sign signs record with key set of its public key at timestamp
+func (rawPeer *RawPeer) Sign(keySet *cashec.KeySet, timestamp time.Time) error {
+	rawPeer.PublicKey = keySet.GetPublicKeyB58()
+	rawPeer.Timestamp = timestamp.Unix()
+	signDataB58, err := keySet.SignDataB58(rawPeer.recordData())
+	if err != nil {
+		return err
+	}
+	rawPeer.SignDataB58 = signDataB58
+	return nil
+}
+
+// IsSigned returns whether record is signed, role and shard of an unsigned record are not trusted
+func (rawPeer *RawPeer) IsSigned() bool {
+	return rawPeer.SignDataB58 != common.EmptyString
+}
+
+/*
+CheckRecordTime - check that a record is neither signed in the future nor older than MaxRecordAge,
+a peer may relay such a record in good faith when its clock differs
+*/
+func (rawPeer *RawPeer) CheckRecordTime(now time.Time) error {
+	signedAt := time.Unix(rawPeer.Timestamp, 0)
+	if signedAt.After(now.Add(MaxRecordClockSkew)) {
+		return fmt.Errorf("record of %s is signed in the future", rawPeer.RawAddress)
+	}
+	if signedAt.Before(now.Add(-MaxRecordAge)) {
+		return fmt.Errorf("record of %s is expired", rawPeer.RawAddress)
+	}
+	return nil
+}
+
+// VerifyRecord checks signature of a signed record and that it is signed in [now-MaxRecordAge, now+MaxRecordClockSkew]
+func (rawPeer *RawPeer) VerifyRecord(now time.Time) error {
+	if rawPeer.PublicKey == common.EmptyString {
+		return errors.New("record has no public key")
+	}
+	if err := rawPeer.CheckRecordTime(now); err != nil {
+		return err
+	}
+	return cashec.ValidateDataB58(rawPeer.PublicKey, rawPeer.SignDataB58, rawPeer.recordData())
 }
 
 type MessageAddr struct {
@@ -60,5 +131,8 @@ func (msg *MessageAddr) SignMsg(_ *cashec.KeySet) error {
 }
 
 func (msg *MessageAddr) VerifyMsgSanity() error {
+	if len(msg.RawPeers) > MaxAddrPerMsg {
+		return fmt.Errorf("addr message has %d peers, max is %d", len(msg.RawPeers), MaxAddrPerMsg)
+	}
 	return nil
 }
@@ -0,0 +1,64 @@
+package wire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ninjadotorg/constant/cashec"
+)
+
+func newSignedRecord(t *testing.T, signedAt time.Time) RawPeer {
+	keySet := (&cashec.KeySet{}).GenerateKey([]byte("record"))
+	shardID := byte(1)
+	record := RawPeer{RawAddress: "/ip4/1.2.3.4/tcp/9333", Role: "shard", ShardID: &shardID}
+	if err := record.Sign(keySet, signedAt); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	return record
+}
+
+func TestVerifyRecord(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	record := newSignedRecord(t, now)
+	if !record.IsSigned() {
+		t.Fatalf("expected signed record")
+	}
+	if err := record.VerifyRecord(now); err != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+	// clock of verifier may be a little behind or record may be a little old
+	if err := record.VerifyRecord(now.Add(-MaxRecordClockSkew / 2)); err != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+	if err := record.VerifyRecord(now.Add(MaxRecordAge / 2)); err != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+
+	forged := record
+	forged.Role = "beacon"
+	if err := forged.VerifyRecord(now); err == nil {
+		t.Errorf("expected error of forged role")
+	}
+	forged = record
+	forged.PublicKey = ""
+	if err := forged.VerifyRecord(now); err == nil {
+		t.Errorf("expected error of record without public key")
+	}
+}
+
+func TestCheckRecordTime(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	record := newSignedRecord(t, now)
+	if err := record.CheckRecordTime(now.Add(-2 * MaxRecordClockSkew)); err == nil {
+		t.Errorf("expected error of record signed in the future")
+	}
+	if err := record.VerifyRecord(now.Add(-2 * MaxRecordClockSkew)); err == nil {
+		t.Errorf("expected error of record signed in the future")
+	}
+	if err := record.CheckRecordTime(now.Add(MaxRecordAge + time.Second)); err == nil {
+		t.Errorf("expected error of expired record")
+	}
+	if err := record.VerifyRecord(now.Add(MaxRecordAge + time.Second)); err == nil {
+		t.Errorf("expected error of expired record")
+	}
+}
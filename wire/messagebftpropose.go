@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
 	"github.com/ninjadotorg/constant/cashec"
 	"github.com/ninjadotorg/constant/common"
 )
@@ -20,10 +21,21 @@ type MessageBFTPropose struct {
 	ContentSig string
 	Pubkey     string
 	Timestamp  int64
+
+	// Block is json of blockchain.CompactShardBlock when CompactBlock is set
+	CompactBlock bool `json:",omitempty"`
+	// SenderID is the peer which missing transactions of compact block are requested from
+	// when the peer which delivered the proposal is unknown
+	SenderID string `json:",omitempty"`
+	// ShardBlock is the block which is rebuilt from compact block, it is not sent
+	ShardBlock *blockchain.ShardBlock `json:"-"`
 }
 
 func (msg *MessageBFTPropose) Hash() string {
-	rawBytes, err := msg.JsonSerialize()
+	// sender is changed when message is forwarded, it is not a part of hash
+	msgCopy := *msg
+	msgCopy.SenderID = ""
+	rawBytes, err := msgCopy.JsonSerialize()
 	if err != nil {
 		return ""
 	}
@@ -49,6 +61,9 @@ func (msg *MessageBFTPropose) JsonDeserialize(jsonStr string) error {
 }
 
 func (msg *MessageBFTPropose) SetSenderID(senderID peer.ID) error {
+	if msg.CompactBlock {
+		msg.SenderID = senderID.Pretty()
+	}
 	return nil
 }
 
@@ -59,6 +74,9 @@ func (msg *MessageBFTPropose) SignMsg(keySet *cashec.KeySet) error {
 	dataBytes = append(dataBytes, msg.Block...)
 	dataBytes = append(dataBytes, []byte(msg.Pubkey)...)
 	dataBytes = append(dataBytes, []byte(fmt.Sprint(msg.Timestamp))...)
+	if msg.CompactBlock {
+		dataBytes = append(dataBytes, []byte(CmdCompactBlockShard)...)
+	}
 	var err error
 	msg.ContentSig, err = keySet.SignDataB58(dataBytes)
 	return err
@@ -71,6 +89,9 @@ func (msg *MessageBFTPropose) VerifyMsgSanity() error {
 	dataBytes = append(dataBytes, msg.Block...)
 	dataBytes = append(dataBytes, []byte(msg.Pubkey)...)
 	dataBytes = append(dataBytes, []byte(fmt.Sprint(msg.Timestamp))...)
+	if msg.CompactBlock {
+		dataBytes = append(dataBytes, []byte(CmdCompactBlockShard)...)
+	}
 	err := cashec.ValidateDataB58(msg.Pubkey, msg.ContentSig, dataBytes)
 	return err
 }
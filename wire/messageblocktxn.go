@@ -0,0 +1,59 @@
+package wire
+
+import (
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+)
+
+/*
+MessageBlockTxn - transactions of a compact block which are requested by MessageGetBlockTxn
+*/
+type MessageBlockTxn struct {
+	BlockHash    common.Hash
+	Indexes      []int
+	Transactions blockchain.TxList
+	SenderID     string
+}
+
+func (msg *MessageBlockTxn) Hash() string {
+	rawBytes, err := msg.JsonSerialize()
+	if err != nil {
+		return ""
+	}
+	return common.HashH(rawBytes).String()
+}
+
+func (msg *MessageBlockTxn) MessageType() string {
+	return CmdBlockTxn
+}
+
+func (msg *MessageBlockTxn) MaxPayloadLength(pver int) int {
+	return MaxBlockPayload
+}
+
+func (msg *MessageBlockTxn) JsonSerialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(msg)
+	return jsonBytes, err
+}
+
+func (msg *MessageBlockTxn) JsonDeserialize(jsonStr string) error {
+	err := json.Unmarshal([]byte(jsonStr), msg)
+	return err
+}
+
+func (msg *MessageBlockTxn) SetSenderID(senderID peer.ID) error {
+	msg.SenderID = senderID.Pretty()
+	return nil
+}
+
+func (msg *MessageBlockTxn) SignMsg(_ *cashec.KeySet) error {
+	return nil
+}
+
+func (msg *MessageBlockTxn) VerifyMsgSanity() error {
+	return nil
+}
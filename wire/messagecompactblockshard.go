@@ -0,0 +1,58 @@
+package wire
+
+import (
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/blockchain"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+)
+
+/*
+MessageCompactBlockShard - relay a shard block with short ids of its transactions,
+receiver requests transactions which are not in its mempool with MessageGetBlockTxn
+*/
+type MessageCompactBlockShard struct {
+	Block    blockchain.CompactShardBlock
+	SenderID string
+}
+
+func (msg *MessageCompactBlockShard) Hash() string {
+	rawBytes, err := msg.JsonSerialize()
+	if err != nil {
+		return ""
+	}
+	return common.HashH(rawBytes).String()
+}
+
+func (msg *MessageCompactBlockShard) MessageType() string {
+	return CmdCompactBlockShard
+}
+
+func (msg *MessageCompactBlockShard) MaxPayloadLength(pver int) int {
+	return MaxBlockPayload
+}
+
+func (msg *MessageCompactBlockShard) JsonSerialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(msg)
+	return jsonBytes, err
+}
+
+func (msg *MessageCompactBlockShard) JsonDeserialize(jsonStr string) error {
+	err := json.Unmarshal([]byte(jsonStr), msg)
+	return err
+}
+
+func (msg *MessageCompactBlockShard) SetSenderID(senderID peer.ID) error {
+	msg.SenderID = senderID.Pretty()
+	return nil
+}
+
+func (msg *MessageCompactBlockShard) SignMsg(_ *cashec.KeySet) error {
+	return nil
+}
+
+func (msg *MessageCompactBlockShard) VerifyMsgSanity() error {
+	return nil
+}
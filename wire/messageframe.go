@@ -0,0 +1,138 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ninjadotorg/constant/common"
+)
+
+// Wire versions which are negotiated in version message,
+// a peer which does not send WireVersion only speaks WireVersionJSON
+const (
+	// hex encoded json message terminated by a delimiter
+	WireVersionJSON = 0
+	// length prefixed binary frame, see MessageFrameHeader
+	WireVersionBinary = 1
+
+	CurrentWireVersion = WireVersionBinary
+)
+
+const (
+	// magic(4) + command(12) + forward type(1) + forward value(1) + flags(1) + length(4) + checksum(4)
+	MessageFrameHeaderSize = 27
+	MessageChecksumSize    = 4
+
+	// payload of frame is gzip data
+	FrameFlagGzip = byte(1 << 0)
+)
+
+// MessageMagic starts every binary frame, its first byte is not a hex character
+// so that a reader can tell a binary frame from a hex encoded json message
+var MessageMagic = [4]byte{0xc0, 0x57, 0xa1, 0x7e}
+
+// MessageFrameHeader is header of a binary frame
+type MessageFrameHeader struct {
+	Command      string
+	ForwardType  byte
+	ForwardValue byte
+	Flags        byte
+	Length       uint32
+	Checksum     [MessageChecksumSize]byte
+}
+
+// MessageChecksum returns first bytes of hash of payload
+func MessageChecksum(payload []byte) [MessageChecksumSize]byte {
+	var checksum [MessageChecksumSize]byte
+	hash := common.HashH(payload)
+	copy(checksum[:], hash[:MessageChecksumSize])
+	return checksum
+}
+
+// IsMessageFrame returns whether first byte of a message is the first byte of magic
+func IsMessageFrame(firstByte byte) bool {
+	return firstByte == MessageMagic[0]
+}
+
+/*
+WriteMessageFrame - write a binary frame into w
+*/
+func WriteMessageFrame(w io.Writer, command string, forwardType byte, forwardValue byte, flags byte, payload []byte) error {
+	if len(command) > MessageCmdTypeSize {
+		return fmt.Errorf("command %s is longer than %d bytes", command, MessageCmdTypeSize)
+	}
+	header := make([]byte, MessageFrameHeaderSize)
+	copy(header[0:4], MessageMagic[:])
+	copy(header[4:4+MessageCmdTypeSize], []byte(command))
+	offset := 4 + MessageCmdTypeSize
+	header[offset] = forwardType
+	header[offset+1] = forwardValue
+	header[offset+2] = flags
+	binary.BigEndian.PutUint32(header[offset+3:offset+7], uint32(len(payload)))
+	checksum := MessageChecksum(payload)
+	copy(header[offset+7:], checksum[:])
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+/*
+ReadMessageFrame - read a binary frame from r and verify its checksum,
+payload which is longer than maxPayloadLength is rejected before reading it
+*/
+func ReadMessageFrame(r io.Reader, maxPayloadLength int) (*MessageFrameHeader, []byte, error) {
+	headerBytes := make([]byte, MessageFrameHeaderSize)
+	if _, err := io.ReadFull(r, headerBytes); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(headerBytes[0:4], MessageMagic[:]) {
+		return nil, nil, errors.New("invalid magic of message frame")
+	}
+	offset := 4 + MessageCmdTypeSize
+	header := &MessageFrameHeader{
+		Command:      string(bytes.Trim(headerBytes[4:offset], "\x00")),
+		ForwardType:  headerBytes[offset],
+		ForwardValue: headerBytes[offset+1],
+		Flags:        headerBytes[offset+2],
+		Length:       binary.BigEndian.Uint32(headerBytes[offset+3 : offset+7]),
+	}
+	copy(header.Checksum[:], headerBytes[offset+7:])
+	if int(header.Length) > maxPayloadLength {
+		return nil, nil, fmt.Errorf("payload of message %s is %d bytes, limit is %d bytes", header.Command, header.Length, maxPayloadLength)
+	}
+
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+	if MessageChecksum(payload) != header.Checksum {
+		return nil, nil, fmt.Errorf("checksum of message %s is invalid", header.Command)
+	}
+	return header, payload, nil
+}
+
+/*
+MessageHeaderBytes - make the 24 bytes header which is appended to json body of a message:
+command(12) + forward type(1) + forward value(1) + padding
+*/
+func MessageHeaderBytes(command string, forwardType byte, forwardValue byte) []byte {
+	headerBytes := make([]byte, MessageHeaderSize)
+	copy(headerBytes[:], []byte(command))
+	headerBytes[MessageCmdTypeSize] = forwardType
+	headerBytes[MessageCmdTypeSize+1] = forwardValue
+	return headerBytes
+}
+
+/*
+ParseMessageHeaderBytes - return command and forward info in the 24 bytes header of a message
+*/
+func ParseMessageHeaderBytes(headerBytes []byte) (string, byte, byte) {
+	command := string(bytes.Trim(headerBytes[:MessageCmdTypeSize], "\x00"))
+	return command, headerBytes[MessageCmdTypeSize], headerBytes[MessageCmdTypeSize+1]
+}
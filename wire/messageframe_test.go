@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMessageFrameRoundTrip(t *testing.T) {
+	payload := []byte("payload of block message")
+	buf := &bytes.Buffer{}
+	err := WriteMessageFrame(buf, CmdBlockShard, 's', 3, FrameFlagGzip, payload)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if buf.Len() != MessageFrameHeaderSize+len(payload) || !IsMessageFrame(buf.Bytes()[0]) {
+		t.Fatalf("wrong frame %x", buf.Bytes())
+	}
+
+	header, readPayload, err := ReadMessageFrame(buf, len(payload))
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if header.Command != CmdBlockShard || header.ForwardType != 's' || header.ForwardValue != 3 || header.Flags != FrameFlagGzip {
+		t.Errorf("wrong header %+v", header)
+	}
+	if int(header.Length) != len(payload) || !bytes.Equal(readPayload, payload) {
+		t.Errorf("wrong payload %s", readPayload)
+	}
+
+	// empty payload is a valid frame
+	buf.Reset()
+	if err := WriteMessageFrame(buf, CmdPing, 0, 0, 0, nil); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	header, readPayload, err = ReadMessageFrame(buf, 0)
+	if err != nil || header.Command != CmdPing || len(readPayload) != 0 {
+		t.Errorf("wrong empty frame %+v %+v", header, err)
+	}
+
+	// hex encoded json message does not start with magic
+	if IsMessageFrame([]byte("7b")[0]) {
+		t.Errorf("hex message is taken as a frame")
+	}
+}
+
+func TestMessageFrameInvalid(t *testing.T) {
+	payload := []byte("payload")
+	newFrame := func() []byte {
+		buf := &bytes.Buffer{}
+		if err := WriteMessageFrame(buf, CmdTx, 0, 0, 0, payload); err != nil {
+			t.Fatalf("unexpected error %+v", err)
+		}
+		return buf.Bytes()
+	}
+
+	frame := newFrame()
+	frame[1] ^= 0xff
+	if _, _, err := ReadMessageFrame(bytes.NewReader(frame), len(payload)); err == nil {
+		t.Errorf("expect error of bad magic")
+	}
+
+	frame = newFrame()
+	frame[MessageFrameHeaderSize-1] ^= 0xff
+	if _, _, err := ReadMessageFrame(bytes.NewReader(frame), len(payload)); err == nil {
+		t.Errorf("expect error of bad checksum")
+	}
+
+	frame = newFrame()
+	frame[len(frame)-1] ^= 0xff
+	if _, _, err := ReadMessageFrame(bytes.NewReader(frame), len(payload)); err == nil {
+		t.Errorf("expect error of corrupted payload")
+	}
+
+	// oversize payload is rejected before it is read, so a truncated frame reports the limit
+	frame = newFrame()
+	_, _, err := ReadMessageFrame(bytes.NewReader(frame[:MessageFrameHeaderSize]), len(payload)-1)
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("expect error of oversize payload, got %+v", err)
+	}
+
+	frame = newFrame()
+	if _, _, err := ReadMessageFrame(bytes.NewReader(frame[:len(frame)-1]), len(payload)); err == nil {
+		t.Errorf("expect error of truncated payload")
+	}
+
+	if err := WriteMessageFrame(&bytes.Buffer{}, "commandlongerthan12", 0, 0, 0, payload); err == nil {
+		t.Errorf("expect error of long command")
+	}
+}
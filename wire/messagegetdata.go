@@ -0,0 +1,75 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+)
+
+const (
+	// MaxGetDataPerMsg is the maximum number of inventory vectors in a getdata message,
+	// it is lower than MaxInvPerMsg because every item is served with a full message
+	MaxGetDataPerMsg  = 100
+	MaxGetDataPayload = MaxGetDataPerMsg*128 + 100
+)
+
+/*
+MessageGetData - request transactions and blocks which are announced in MessageInv,
+the peer replies each known item with its full message
+*/
+type MessageGetData struct {
+	InvList []InvVect
+}
+
+// AddInvVect appends an inventory vector into message
+func (msg *MessageGetData) AddInvVect(invVect InvVect) error {
+	if len(msg.InvList) >= MaxGetDataPerMsg {
+		return fmt.Errorf("too many inventory vectors in message, max %d", MaxGetDataPerMsg)
+	}
+	msg.InvList = append(msg.InvList, invVect)
+	return nil
+}
+
+func (msg *MessageGetData) Hash() string {
+	rawBytes, err := msg.JsonSerialize()
+	if err != nil {
+		return ""
+	}
+	return common.HashH(rawBytes).String()
+}
+
+func (msg *MessageGetData) MessageType() string {
+	return CmdGetData
+}
+
+func (msg *MessageGetData) MaxPayloadLength(pver int) int {
+	return MaxGetDataPayload
+}
+
+func (msg *MessageGetData) JsonSerialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(msg)
+	return jsonBytes, err
+}
+
+func (msg *MessageGetData) JsonDeserialize(jsonStr string) error {
+	err := json.Unmarshal([]byte(jsonStr), msg)
+	return err
+}
+
+func (msg *MessageGetData) SetSenderID(senderID peer.ID) error {
+	return nil
+}
+
+func (msg *MessageGetData) SignMsg(_ *cashec.KeySet) error {
+	return nil
+}
+
+func (msg *MessageGetData) VerifyMsgSanity() error {
+	if len(msg.InvList) > MaxGetDataPerMsg {
+		return fmt.Errorf("too many inventory vectors in message, max %d", MaxGetDataPerMsg)
+	}
+	return nil
+}
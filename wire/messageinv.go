@@ -0,0 +1,92 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+)
+
+const (
+	// MaxInvPerMsg is the maximum number of inventory vectors in a single inv or getdata message
+	MaxInvPerMsg = 1000
+	// type(8) + hash(32) in json is less than 128 bytes
+	MaxInvPayload = MaxInvPerMsg*128 + 100
+)
+
+// Types of inventory which are announced by inv message
+const (
+	InvTypeTx          = 1
+	InvTypeBlockShard  = 2
+	InvTypeBlockBeacon = 3
+)
+
+// InvVect identifies a transaction or a block which a peer has
+type InvVect struct {
+	Type int
+	Hash common.Hash
+}
+
+func (invVect InvVect) String() string {
+	return fmt.Sprintf("%d:%s", invVect.Type, invVect.Hash.String())
+}
+
+/*
+MessageInv - announce hashes of transactions and blocks to a peer,
+the peer requests unknown items with MessageGetData
+*/
+type MessageInv struct {
+	InvList []InvVect
+}
+
+// AddInvVect appends an inventory vector into message
+func (msg *MessageInv) AddInvVect(invVect InvVect) error {
+	if len(msg.InvList) >= MaxInvPerMsg {
+		return fmt.Errorf("too many inventory vectors in message, max %d", MaxInvPerMsg)
+	}
+	msg.InvList = append(msg.InvList, invVect)
+	return nil
+}
+
+func (msg *MessageInv) Hash() string {
+	rawBytes, err := msg.JsonSerialize()
+	if err != nil {
+		return ""
+	}
+	return common.HashH(rawBytes).String()
+}
+
+func (msg *MessageInv) MessageType() string {
+	return CmdInv
+}
+
+func (msg *MessageInv) MaxPayloadLength(pver int) int {
+	return MaxInvPayload
+}
+
+func (msg *MessageInv) JsonSerialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(msg)
+	return jsonBytes, err
+}
+
+func (msg *MessageInv) JsonDeserialize(jsonStr string) error {
+	err := json.Unmarshal([]byte(jsonStr), msg)
+	return err
+}
+
+func (msg *MessageInv) SetSenderID(senderID peer.ID) error {
+	return nil
+}
+
+func (msg *MessageInv) SignMsg(_ *cashec.KeySet) error {
+	return nil
+}
+
+func (msg *MessageInv) VerifyMsgSanity() error {
+	if len(msg.InvList) > MaxInvPerMsg {
+		return fmt.Errorf("too many inventory vectors in message, max %d", MaxInvPerMsg)
+	}
+	return nil
+}
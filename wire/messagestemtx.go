@@ -0,0 +1,56 @@
+package wire
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/ninjadotorg/constant/cashec"
+	"github.com/ninjadotorg/constant/common"
+	"github.com/ninjadotorg/constant/metadata"
+)
+
+// MessageStemTx carries a transaction in stem phase of dandelion,
+// it is sent to one relay peer which announces CapabilityDandelion and it is never announced by inv
+type MessageStemTx struct {
+	Transaction metadata.Transaction
+}
+
+func (msg *MessageStemTx) Hash() string {
+	rawBytes, err := msg.JsonSerialize()
+	if err != nil {
+		return ""
+	}
+	return common.HashH(rawBytes).String()
+}
+
+func (msg *MessageStemTx) MessageType() string {
+	return CmdStemTx
+}
+
+func (msg *MessageStemTx) MaxPayloadLength(pver int) int {
+	return MaxTxPayload
+}
+
+func (msg *MessageStemTx) JsonSerialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(msg)
+	return jsonBytes, err
+}
+
+func (msg *MessageStemTx) JsonDeserialize(jsonStr string) error {
+	jsonDecodeString, _ := hex.DecodeString(jsonStr)
+	err := json.Unmarshal([]byte(jsonDecodeString), msg)
+	return err
+}
+
+func (msg *MessageStemTx) SetSenderID(senderID peer.ID) error {
+	return nil
+}
+
+func (msg *MessageStemTx) SignMsg(_ *cashec.KeySet) error {
+	return nil
+}
+
+func (msg *MessageStemTx) VerifyMsgSanity() error {
+	return nil
+}
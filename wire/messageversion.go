@@ -13,6 +13,20 @@ const (
 	MaxVersionPayload = 1000 // 1 1Kb
 )
 
+// Capabilities of node which are announced in version message
+const (
+	// node reads gzip payload in binary frames when flag FrameFlagGzip is set
+	CapabilityCompression = uint64(1 << 0)
+	// node announces transactions and blocks with inv and serves them with getdata
+	CapabilityInventory = uint64(1 << 1)
+	// node rebuilds compact shard blocks and serves getblocktxn
+	CapabilityCompactBlock = uint64(1 << 2)
+	// node relays stem transactions and keeps them in stem pool until they are fluffed
+	CapabilityDandelion = uint64(1 << 3)
+
+	CurrentCapabilities = CapabilityCompression | CapabilityInventory | CapabilityCompactBlock | CapabilityDandelion
+)
+
 type MessageVersion struct {
 	ProtocolVersion  string
 	Timestamp        int64
@@ -24,6 +38,13 @@ type MessageVersion struct {
 	LocalPeerId      peer.ID
 	PublicKey        string
 	SignDataB58      string
+
+	// WireVersion is the highest wire version which sender can read,
+	// it is zero (WireVersionJSON) for old peers
+	WireVersion int
+
+	// Capabilities is a bit set of Capability flags
+	Capabilities uint64
 }
 
 func (msg *MessageVersion) Hash() string {